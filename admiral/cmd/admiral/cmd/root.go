@@ -91,6 +91,8 @@ func GetRootCmd(args []string) *cobra.Command {
 		"Use a Kubernetes configuration file instead of in-cluster configuration")
 	rootCmd.PersistentFlags().BoolVar(&params.ArgoRolloutsEnabled, "argo_rollouts", false,
 		"Use argo rollout configurations")
+	rootCmd.PersistentFlags().BoolVar(&params.FlaggerEnabled, "flagger", false,
+		"Use flagger canary configurations, the deployments targeted by a canary are routed through its primary and canary services")
 	rootCmd.PersistentFlags().StringVar(&params.ClusterRegistriesNamespace, "secret_namespace", "admiral",
 		"Namespace to monitor for secrets defaults to admiral-secrets")
 	rootCmd.PersistentFlags().StringVar(&params.DependenciesNamespace, "dependency_namespace", "admiral",
@@ -117,20 +119,70 @@ func GetRootCmd(args []string) *cobra.Command {
 		"The label value, on a namespace, which tells Istio to perform sidecar injection")
 	rootCmd.PersistentFlags().StringVar(&params.HostnameSuffix, "hostname_suffix", "global",
 		"The hostname suffix to customize the cname generated by admiral. Default suffix value will be \"global\"")
+	rootCmd.PersistentFlags().StringVar(&params.PreviewHostnamePrefix, "preview_hostname_prefix", common.BlueGreenRolloutPreviewPrefix,
+		"The prefix of the cname generated by admiral for the preview service of a blue green rollout, e.g. preview.<cname>. The `admiral.io/preview-hostname-prefix` annotation on the pod spec of a rollout overrides it")
+	rootCmd.PersistentFlags().StringVar(&params.CanaryHostnamePrefix, "canary_hostname_prefix", common.CanaryRolloutPrefix,
+		"The prefix of the cname generated by admiral for the canary service of a canary rollout, e.g. canary.<cname>. An empty prefix disables the canary cnames")
 	rootCmd.PersistentFlags().StringVar(&params.LabelSet.WorkloadIdentityKey, "workload_identity_key", "identity",
 		"The workload identity  key, on deployment which holds identity value used to generate cname by admiral. Default label key will be \"identity\" Admiral will look for a label with this key. If present, that will be used. If not, it will try an annotation (for use cases where an identity is longer than 63 chars)")
 	rootCmd.PersistentFlags().StringVar(&params.LabelSet.GlobalTrafficDeploymentLabel, "globaltraffic_deployment_label", "identity",
 		"The label key which will be used to tie globaltrafficpolicy objects to deployments. Configured separately to the workload identity key because this one won't fall back to annotations.")
 	rootCmd.PersistentFlags().StringVar(&params.WorkloadSidecarUpdate, "workload_sidecar_update", "disabled",
-		"The parameter will be used to decide whether to update workload sidecar resource or not. By default these updates will be disabled.")
+		"The parameter will be used to decide whether to update workload sidecar resource or not. By default these updates will be disabled. "+
+			"`enabled` adds the dependencies to the existing sidecar resources, `managed` creates the sidecar resources and keeps their egress hosts in sync with the dependency records")
 	rootCmd.PersistentFlags().StringVar(&params.WorkloadSidecarName, "workload_sidecar_name", "default",
 		"Name of the sidecar resource in the workload namespace. By default sidecar resource will be named as \"default\".")
+	rootCmd.PersistentFlags().StringVar(&params.WorkloadSidecarScope, "workload_sidecar_scope", common.WorkloadSidecarScopeNamespace,
+		"Whether the sidecar resources written in the `managed` mode apply to a `namespace`, with the dependencies of all its workloads, or to a `workload`, selected by its identity label")
+	rootCmd.PersistentFlags().StringSliceVar(&params.WorkloadSidecarEgressHosts, "workload_sidecar_egress_hosts", []string{"./*", common.NamespaceIstioSystem + "/*"},
+		"Egress hosts of the sidecar resources written in the `managed` mode on top of the hosts of the dependencies")
 	rootCmd.PersistentFlags().StringVar(&params.LabelSet.EnvKey, "env_key", "admiral.io/env",
 		"The annotation or label, on a pod spec in a deployment, which will be used to group deployments across regions/clusters under a single environment. Defaults to `admiral.io/env`. "+
 			"The order would be to use annotation specified as `env_key`, followed by label specified as `env_key` and then fallback to the label `env`")
 	rootCmd.PersistentFlags().StringVar(&params.LabelSet.GatewayApp, "gateway_app", "istio-ingressgateway",
 		"The the value of the `app` label to use to match and find the service that represents the ingress for cross cluster traffic (AUTO_PASSTHROUGH mode)")
 	rootCmd.PersistentFlags().BoolVar(&params.MetricsEnabled, "metrics", true, "Enable prometheus metrics collections")
+	rootCmd.PersistentFlags().StringToStringVar(&params.ClusterLocality, "cluster_locality", map[string]string{},
+		"Overrides the locality of a cluster that is otherwise derived from the topology labels on its nodes, in the format `cluster1=region/zone/subzone,cluster2=region`")
+	rootCmd.PersistentFlags().DurationVar(&params.SeAddressReclaimInterval, "se_address_reclaim_interval", 0,
+		"Interval for releasing the addresses of service entries that no longer exist in any cluster, disabled by default")
+	rootCmd.PersistentFlags().DurationVar(&params.SeAddressReclaimGracePeriod, "se_address_reclaim_grace_period", 24*time.Hour,
+		"How long the address of a service entry missing from all the clusters is kept before being released, defaults to 24h")
+	rootCmd.PersistentFlags().StringVar(&params.SeAddressAllocator, "se_address_allocator", common.SeAddressAllocatorConfigMap,
+		"Where the service entry addresses are recorded, `configmap` keeps them all in a single configmap while `crd` keeps one AddressAllocation object per service entry. "+
+			"Switching to `crd` copies the addresses from the configmap")
+	rootCmd.PersistentFlags().DurationVar(&params.OrphanCollectionInterval, "orphan_collection_interval", 0,
+		"Interval for deleting the service entries, destination rules and virtual services owned by Admiral that it wouldn't generate anymore, disabled by default")
+	rootCmd.PersistentFlags().BoolVar(&params.OrphanCollectionDryRun, "orphan_collection_dry_run", false,
+		"Only log and count the orphans found by the orphan collector instead of deleting them")
+	rootCmd.PersistentFlags().DurationVar(&params.ReconcileInterval, "reconcile_interval", 0,
+		"Interval for regenerating the service entries, destination rules and virtual services of every identity to correct the ones that drifted in the clusters, disabled by default")
+	rootCmd.PersistentFlags().Float32Var(&params.ReconcileQPS, "reconcile_qps_per_cluster", 5,
+		"Identities reconciled per second in each cluster by the reconciler")
+	rootCmd.PersistentFlags().BoolVar(&params.DryRun, "dry_run", false,
+		"Record the changes to the istio config of the clusters instead of writing them, the change plan of every cluster is served by the /changeplan endpoint")
+	rootCmd.PersistentFlags().IntVar(&params.IdentityQueueWorkers, "identity_queue_workers", 5,
+		"Workers syncing the config of the identity and env keys queued by the events, the events of a key waiting in the queue are coalesced into a single sync. 0 syncs the config in the event handlers")
+	rootCmd.PersistentFlags().Float32Var(&params.IdentityQueueQPS, "identity_queue_qps", 20,
+		"Identity and env keys synced per second across the identity queue workers, 0 disables the rate limit")
+	rootCmd.PersistentFlags().IntVar(&params.ClusterWriteParallelism, "cluster_write_parallelism", 10,
+		"Clusters the service entries and destination rules of a sync are written to at the same time")
+	rootCmd.PersistentFlags().DurationVar(&params.ClusterWriteTimeout, "cluster_write_timeout", 30*time.Second,
+		"How long a sync waits for the writes to a cluster. A cluster that times out or is unavailable is backed off, and its writes are skipped until the backoff expires. 0 waits until the writes are done")
+	rootCmd.PersistentFlags().DurationVar(&params.ReadinessCheckInterval, "readiness_check_interval", time.Second,
+		"Interval for checking whether the informers of every cluster have synced. The events are skipped until they have, then the config of every identity is regenerated. 0 disables the regeneration")
+	rootCmd.PersistentFlags().DurationVar(&params.ClusterSyncTimeout, "cluster_sync_timeout", 10*time.Minute,
+		"How long the readiness waits for the controllers of a cluster to process the existing objects. A cluster that hasn't synced by then is left out, so it can't hold the events of the other clusters back. 0 waits until it has synced")
+	rootCmd.PersistentFlags().IntVar(&params.SeEndpointRemovalThreshold, "se_endpoint_removal_threshold", 50,
+		"Percentage of the endpoints of a service entry an update can remove. Updates removing more endpoints or all of them are blocked until confirmed")
+	rootCmd.PersistentFlags().DurationVar(&params.SeUpdateConfirmationDelay, "se_update_confirmation_delay", time.Minute,
+		"How long after being blocked a service entry update has to be observed again to be applied, 0 disables the blocking of the updates removing endpoints")
+	rootCmd.PersistentFlags().StringVar(&params.GatewaySelector, "gateway_selector", "",
+		"Label selector of the east west gateway services the cross cluster traffic is sent to, e.g. `istio=eastwestgateway`. Every matching service is a gateway of its cluster. Defaults to the `app` label set to --gateway_app")
+	rootCmd.PersistentFlags().StringSliceVar(&params.GatewayNamespaces, "gateway_namespaces", []string{common.NamespaceIstioSystem},
+		"Namespaces of the east west gateway services")
+	rootCmd.PersistentFlags().StringVar(&params.GatewayPortName, "gateway_port_name", common.Tls,
+		"Name of the port of the east west gateway services for the cross cluster traffic. The 15443 port is used when a gateway has no port with the name")
 
 	return rootCmd
 }
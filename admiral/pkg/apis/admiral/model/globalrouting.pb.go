@@ -55,10 +55,19 @@ type GlobalTrafficPolicy struct {
 	// on which this global routing policy should be applied. The scope of
 	// label search is restricted to namespace mark for mesh enablement
 	// this will scan all cluster and namespace
-	Selector             map[string]string `protobuf:"bytes,2,rep,name=selector,proto3" json:"selector,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
-	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
-	XXX_unrecognized     []byte            `json:"-"`
-	XXX_sizecache        int32             `json:"-"`
+	Selector map[string]string `protobuf:"bytes,2,rep,name=selector,proto3" json:"selector,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// OPTIONAL: priority of this policy when more than one policy matches
+	// the same identity and env across clusters and namespaces. Exactly one
+	// policy is applied, picked using the following order, the rest are
+	// reported as conflicts:
+	// 1. the highest priority (defaults to 0)
+	// 2. the most recently created
+	// 3. the lowest namespace/name in lexicographical order
+	// 4. the lowest cluster id in lexicographical order
+	Priority             int32    `protobuf:"varint,3,opt,name=priority,proto3" json:"priority,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
 func (m *GlobalTrafficPolicy) Reset()         { *m = GlobalTrafficPolicy{} }
@@ -100,6 +109,13 @@ func (m *GlobalTrafficPolicy) GetSelector() map[string]string {
 	return nil
 }
 
+func (m *GlobalTrafficPolicy) GetPriority() int32 {
+	if m != nil {
+		return m.Priority
+	}
+	return 0
+}
+
 // TrafficPolicy describes routing for a hostname.
 type TrafficPolicy struct {
 	// REQUIRED: dns that can be used by client.  This name will have the
@@ -113,10 +129,14 @@ type TrafficPolicy struct {
 	//Ex: dnsPrefix = west => generated service name = west.stage.servicename.global
 	DnsPrefix string `protobuf:"bytes,4,opt,name=dnsPrefix,proto3" json:"dnsPrefix,omitempty"`
 	//OPTIONAL: to configure the outlierDetection in DestinationRule
-	OutlierDetection     *TrafficPolicy_OutlierDetection `protobuf:"bytes,5,opt,name=outlier_detection,json=outlierDetection,proto3" json:"outlier_detection,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}                        `json:"-"`
-	XXX_unrecognized     []byte                          `json:"-"`
-	XXX_sizecache        int32                           `json:"-"`
+	OutlierDetection *TrafficPolicy_OutlierDetection `protobuf:"bytes,5,opt,name=outlier_detection,json=outlierDetection,proto3" json:"outlier_detection,omitempty"`
+	//OPTIONAL: explicit failover chain for lbType TOPOLOGY, applied as locality failover in DestinationRule
+	//Ex: us-west2 -> us-east2 -> eu-west1 is expressed with the pairs {from: us-west2, to: us-east2} and {from: us-east2, to: eu-west1}
+	//Regions must be known to admiral, a region can be the origin of only one pair
+	Failover             []*TrafficPolicy_Failover `protobuf:"bytes,6,rep,name=failover,proto3" json:"failover,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}                  `json:"-"`
+	XXX_unrecognized     []byte                    `json:"-"`
+	XXX_sizecache        int32                     `json:"-"`
 }
 
 func (m *TrafficPolicy) Reset()         { *m = TrafficPolicy{} }
@@ -180,6 +200,13 @@ func (m *TrafficPolicy) GetOutlierDetection() *TrafficPolicy_OutlierDetection {
 	return nil
 }
 
+func (m *TrafficPolicy) GetFailover() []*TrafficPolicy_Failover {
+	if m != nil {
+		return m.Failover
+	}
+	return nil
+}
+
 type TrafficPolicy_OutlierDetection struct {
 	//REQUIRED: Minimum duration of time in seconds, the endpoint will be ejected
 	BaseEjectionTime int64 `protobuf:"varint,1,opt,name=base_ejection_time,json=baseEjectionTime,proto3" json:"base_ejection_time,omitempty"`
@@ -238,8 +265,57 @@ func (m *TrafficPolicy_OutlierDetection) GetInterval() int64 {
 	return 0
 }
 
+type TrafficPolicy_Failover struct {
+	//REQUIRED: originating region
+	From string `protobuf:"bytes,1,opt,name=from,proto3" json:"from,omitempty"`
+	//REQUIRED: region the traffic will fail over to when endpoints in the 'from' region become unhealthy
+	To                   string   `protobuf:"bytes,2,opt,name=to,proto3" json:"to,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *TrafficPolicy_Failover) Reset()         { *m = TrafficPolicy_Failover{} }
+func (m *TrafficPolicy_Failover) String() string { return proto.CompactTextString(m) }
+func (*TrafficPolicy_Failover) ProtoMessage()    {}
+func (*TrafficPolicy_Failover) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a5c0dc509add6f4f, []int{1, 1}
+}
+
+func (m *TrafficPolicy_Failover) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TrafficPolicy_Failover.Unmarshal(m, b)
+}
+func (m *TrafficPolicy_Failover) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TrafficPolicy_Failover.Marshal(b, m, deterministic)
+}
+func (m *TrafficPolicy_Failover) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TrafficPolicy_Failover.Merge(m, src)
+}
+func (m *TrafficPolicy_Failover) XXX_Size() int {
+	return xxx_messageInfo_TrafficPolicy_Failover.Size(m)
+}
+func (m *TrafficPolicy_Failover) XXX_DiscardUnknown() {
+	xxx_messageInfo_TrafficPolicy_Failover.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TrafficPolicy_Failover proto.InternalMessageInfo
+
+func (m *TrafficPolicy_Failover) GetFrom() string {
+	if m != nil {
+		return m.From
+	}
+	return ""
+}
+
+func (m *TrafficPolicy_Failover) GetTo() string {
+	if m != nil {
+		return m.To
+	}
+	return ""
+}
+
 type TrafficGroup struct {
-	//region for the traffic
+	//region for the traffic, a zone in the region can be targeted using the region/zone format
 	Region string `protobuf:"bytes,1,opt,name=region,proto3" json:"region,omitempty"`
 	//weight for traffic this region should get.
 	Weight               int32    `protobuf:"varint,2,opt,name=weight,proto3" json:"weight,omitempty"`
@@ -293,40 +369,45 @@ func init() {
 	proto.RegisterMapType((map[string]string)(nil), "admiral.global.v1alpha.GlobalTrafficPolicy.SelectorEntry")
 	proto.RegisterType((*TrafficPolicy)(nil), "admiral.global.v1alpha.TrafficPolicy")
 	proto.RegisterType((*TrafficPolicy_OutlierDetection)(nil), "admiral.global.v1alpha.TrafficPolicy.OutlierDetection")
+	proto.RegisterType((*TrafficPolicy_Failover)(nil), "admiral.global.v1alpha.TrafficPolicy.Failover")
 	proto.RegisterType((*TrafficGroup)(nil), "admiral.global.v1alpha.TrafficGroup")
 }
 
 func init() { proto.RegisterFile("globalrouting.proto", fileDescriptor_a5c0dc509add6f4f) }
 
 var fileDescriptor_a5c0dc509add6f4f = []byte{
-	// 463 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x53, 0x5d, 0x6b, 0xdb, 0x30,
-	0x14, 0x9d, 0xe3, 0xc5, 0x4b, 0x6f, 0x9b, 0xe1, 0xa9, 0xa5, 0x88, 0xb0, 0x87, 0x12, 0x3a, 0xc8,
-	0x43, 0x31, 0x2c, 0x83, 0xb1, 0x6f, 0x58, 0x69, 0x16, 0x06, 0x81, 0x04, 0x2d, 0x1b, 0x6c, 0x2f,
-	0x46, 0xb1, 0x6f, 0x5c, 0x6d, 0x8a, 0x65, 0x64, 0x39, 0x5d, 0xfe, 0xca, 0x7e, 0xe1, 0xde, 0xf6,
-	0x17, 0x86, 0x25, 0xaf, 0x6b, 0x4a, 0xc7, 0xfa, 0xa6, 0x73, 0x8e, 0xce, 0xd1, 0xbd, 0x07, 0x1b,
-	0xf6, 0x33, 0xa9, 0x16, 0x5c, 0x6a, 0x55, 0x19, 0x91, 0x67, 0x51, 0xa1, 0x95, 0x51, 0xe4, 0x90,
-	0xa7, 0x2b, 0xa1, 0xb9, 0x8c, 0x9c, 0x18, 0xad, 0x1f, 0x73, 0x59, 0x9c, 0xf3, 0xfe, 0x4f, 0x0f,
-	0xf6, 0xc7, 0x96, 0x9a, 0x6b, 0xbe, 0x5c, 0x8a, 0x64, 0xa6, 0xa4, 0x48, 0x36, 0xe4, 0x35, 0x04,
-	0x85, 0x3d, 0x51, 0xef, 0xc8, 0x1f, 0xec, 0x0e, 0x1f, 0x45, 0x37, 0x07, 0x44, 0x5b, 0x36, 0xd6,
-	0x98, 0xc8, 0x47, 0xe8, 0x94, 0x28, 0x31, 0x31, 0x4a, 0xd3, 0x96, 0x0d, 0x78, 0xfe, 0xaf, 0x80,
-	0x1b, 0x5e, 0x8f, 0x3e, 0x34, 0xde, 0x51, 0x6e, 0xf4, 0x86, 0x5d, 0x46, 0xf5, 0x5e, 0x42, 0x77,
-	0x4b, 0x22, 0x21, 0xf8, 0xdf, 0xb0, 0x9e, 0xd1, 0x1b, 0xec, 0xb0, 0xfa, 0x48, 0x0e, 0xa0, 0xbd,
-	0xe6, 0xb2, 0x42, 0xda, 0xb2, 0x9c, 0x03, 0x2f, 0x5a, 0xcf, 0xbc, 0xfe, 0x2f, 0x1f, 0xba, 0xdb,
-	0x4b, 0x1e, 0x80, 0x9f, 0xe6, 0xa5, 0x73, 0x9f, 0xb6, 0xa8, 0xc7, 0x6a, 0x48, 0xce, 0x20, 0x90,
-	0x8b, 0xf9, 0xa6, 0x70, 0x11, 0xf7, 0x87, 0x27, 0xb7, 0x5a, 0x3d, 0x9a, 0x58, 0x0f, 0x6b, 0xbc,
-	0xe4, 0x15, 0x04, 0x86, 0xeb, 0x0c, 0x0d, 0xf5, 0xed, 0xfe, 0xc7, 0xff, 0x49, 0x19, 0x6b, 0x55,
-	0x15, 0xac, 0xf1, 0x90, 0x87, 0xb0, 0x93, 0xe6, 0xe5, 0x4c, 0xe3, 0x52, 0x7c, 0xa7, 0x77, 0xed,
-	0x26, 0x7f, 0x09, 0x92, 0xc0, 0x03, 0x55, 0x19, 0x29, 0x50, 0xc7, 0x29, 0x1a, 0x4c, 0x8c, 0x50,
-	0x39, 0x6d, 0x1f, 0x79, 0x83, 0xdd, 0xe1, 0xd3, 0xdb, 0x0d, 0x3b, 0x75, 0xf6, 0xb3, 0x3f, 0x6e,
-	0x16, 0xaa, 0x6b, 0x4c, 0xef, 0x87, 0x07, 0xe1, 0xf5, 0x6b, 0xe4, 0x04, 0xc8, 0x82, 0x97, 0x18,
-	0xe3, 0x57, 0x47, 0xc4, 0x46, 0xac, 0xd0, 0x16, 0xe8, 0xb3, 0xb0, 0x56, 0x46, 0x8d, 0x30, 0x17,
-	0xab, 0xba, 0x83, 0x5e, 0xa2, 0xf2, 0x12, 0x93, 0xca, 0x88, 0x35, 0xc6, 0x19, 0x37, 0x78, 0xc1,
-	0x37, 0x31, 0x6a, 0xad, 0x74, 0x69, 0xdb, 0xed, 0x32, 0x7a, 0xe5, 0xc6, 0xd8, 0x5d, 0x18, 0x59,
-	0x9d, 0xf4, 0xa0, 0x23, 0x72, 0x83, 0x7a, 0xcd, 0x25, 0xf5, 0xed, 0x0b, 0x97, 0xb8, 0x7f, 0x0c,
-	0x81, 0xeb, 0x9b, 0xec, 0x41, 0x67, 0x3e, 0x9d, 0x4d, 0x27, 0xd3, 0xf1, 0xe7, 0xf0, 0x4e, 0x8d,
-	0xde, 0xbd, 0x7d, 0x3f, 0x99, 0x7e, 0x1a, 0xb1, 0xd0, 0xeb, 0xbf, 0x81, 0xbd, 0xab, 0xed, 0x92,
-	0x43, 0x08, 0x34, 0x66, 0x75, 0x59, 0xee, 0x83, 0x69, 0x50, 0xcd, 0x5f, 0xa0, 0xc8, 0xce, 0x8d,
-	0x9d, 0xa9, 0xcd, 0x1a, 0x74, 0x7a, 0xef, 0x4b, 0x7b, 0xa5, 0x52, 0x94, 0x8b, 0xc0, 0xfe, 0x44,
-	0x4f, 0x7e, 0x07, 0x00, 0x00, 0xff, 0xff, 0xde, 0xb6, 0x59, 0xbf, 0x5b, 0x03, 0x00, 0x00,
+	// 522 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x93, 0x5f, 0x6b, 0xdb, 0x3c,
+	0x14, 0xc6, 0x5f, 0xdb, 0x89, 0xdf, 0xf4, 0xb4, 0x29, 0x9e, 0x5a, 0x8a, 0x09, 0xbb, 0x08, 0xa1,
+	0x83, 0x5c, 0x14, 0xc3, 0x32, 0x18, 0xfb, 0x0f, 0x2b, 0x4d, 0xc3, 0x46, 0x20, 0x41, 0xcb, 0x06,
+	0xdb, 0x4d, 0x50, 0x9c, 0x13, 0x57, 0x9b, 0x62, 0x19, 0x59, 0x49, 0xe7, 0xfb, 0x7d, 0x8a, 0x7d,
+	0xc5, 0x7d, 0x89, 0x61, 0x59, 0xcd, 0x9a, 0xd2, 0xb1, 0xdc, 0xe9, 0x39, 0xc7, 0xbf, 0x47, 0x47,
+	0xe7, 0x1c, 0xc3, 0x51, 0x22, 0xe4, 0x8c, 0x09, 0x25, 0x57, 0x9a, 0xa7, 0x49, 0x94, 0x29, 0xa9,
+	0x25, 0x39, 0x61, 0xf3, 0x25, 0x57, 0x4c, 0x44, 0x55, 0x32, 0x5a, 0x3f, 0x66, 0x22, 0xbb, 0x62,
+	0x9d, 0x1f, 0x2e, 0x1c, 0x0d, 0x4c, 0x68, 0xa2, 0xd8, 0x62, 0xc1, 0xe3, 0xb1, 0x14, 0x3c, 0x2e,
+	0xc8, 0x6b, 0xf0, 0x33, 0x73, 0x0a, 0x9d, 0xb6, 0xd7, 0xdd, 0xef, 0x3d, 0x8a, 0xee, 0x37, 0x88,
+	0xb6, 0x30, 0x6a, 0x21, 0xf2, 0x11, 0x1a, 0x39, 0x0a, 0x8c, 0xb5, 0x54, 0xa1, 0x6b, 0x0c, 0x9e,
+	0xff, 0xcd, 0xe0, 0x9e, 0xdb, 0xa3, 0x0f, 0x96, 0xed, 0xa7, 0x5a, 0x15, 0x74, 0x63, 0x45, 0x5a,
+	0xd0, 0xc8, 0x14, 0x97, 0x8a, 0xeb, 0x22, 0xf4, 0xda, 0x4e, 0xb7, 0x4e, 0x37, 0xba, 0xf5, 0x12,
+	0x9a, 0x5b, 0x18, 0x09, 0xc0, 0xfb, 0x86, 0x65, 0xfd, 0x4e, 0x77, 0x8f, 0x96, 0x47, 0x72, 0x0c,
+	0xf5, 0x35, 0x13, 0x2b, 0x0c, 0x5d, 0x13, 0xab, 0xc4, 0x0b, 0xf7, 0x99, 0xd3, 0xf9, 0x55, 0x83,
+	0xe6, 0x76, 0x03, 0x8e, 0xc1, 0x9b, 0xa7, 0x79, 0x45, 0x9f, 0xbb, 0xa1, 0x43, 0x4b, 0x49, 0x2e,
+	0xc0, 0x17, 0xb3, 0x49, 0x91, 0x55, 0x16, 0x87, 0xbd, 0xb3, 0x9d, 0xda, 0x12, 0x0d, 0x0d, 0x43,
+	0x2d, 0x4b, 0x5e, 0x81, 0xaf, 0x99, 0x4a, 0x50, 0x87, 0x9e, 0xe9, 0xcd, 0xe9, 0x3f, 0x5c, 0x06,
+	0x4a, 0xae, 0x32, 0x6a, 0x19, 0xf2, 0x10, 0xf6, 0xe6, 0x69, 0x3e, 0x56, 0xb8, 0xe0, 0xdf, 0xc3,
+	0x9a, 0x79, 0xc9, 0x9f, 0x00, 0x89, 0xe1, 0x81, 0x5c, 0x69, 0xc1, 0x51, 0x4d, 0xe7, 0xa8, 0x31,
+	0xd6, 0x5c, 0xa6, 0x61, 0xbd, 0xed, 0x74, 0xf7, 0x7b, 0x4f, 0x77, 0x2b, 0x76, 0x54, 0xe1, 0x17,
+	0x37, 0x34, 0x0d, 0xe4, 0x9d, 0x08, 0x79, 0x0f, 0x8d, 0x05, 0xe3, 0x42, 0xae, 0x51, 0x85, 0xbe,
+	0x79, 0x42, 0xb4, 0x9b, 0xf7, 0xa5, 0xa5, 0xe8, 0x86, 0x6f, 0xfd, 0x74, 0x20, 0xb8, 0x7b, 0x25,
+	0x39, 0x03, 0x32, 0x63, 0x39, 0x4e, 0xf1, 0x6b, 0x15, 0x98, 0x6a, 0xbe, 0x44, 0x33, 0x0c, 0x8f,
+	0x06, 0x65, 0xa6, 0x6f, 0x13, 0x13, 0xbe, 0x2c, 0xfb, 0xd9, 0x8a, 0x65, 0x9a, 0x63, 0xbc, 0xd2,
+	0x7c, 0x8d, 0xd3, 0x84, 0x69, 0xbc, 0x66, 0xc5, 0x14, 0x95, 0x92, 0x2a, 0x37, 0x93, 0x6a, 0xd2,
+	0xf0, 0xd6, 0x17, 0x83, 0xea, 0x83, 0xbe, 0xc9, 0x97, 0x4b, 0xc5, 0x53, 0x8d, 0x6a, 0xcd, 0x84,
+	0x59, 0x2a, 0x8f, 0x6e, 0x74, 0x2b, 0x82, 0xc6, 0x4d, 0xc9, 0x84, 0x40, 0x6d, 0xa1, 0xe4, 0xd2,
+	0x2e, 0x94, 0x39, 0x93, 0x43, 0x70, 0xb5, 0xb4, 0xeb, 0xe4, 0x6a, 0xd9, 0x39, 0x05, 0xbf, 0x9a,
+	0x35, 0x39, 0x80, 0xc6, 0x64, 0x34, 0x1e, 0x0d, 0x47, 0x83, 0xcf, 0xc1, 0x7f, 0xa5, 0xba, 0x7c,
+	0xfb, 0x6e, 0x38, 0xfa, 0xd4, 0xa7, 0x81, 0xd3, 0x79, 0x03, 0x07, 0xb7, 0x27, 0x4b, 0x4e, 0xc0,
+	0x57, 0x98, 0x94, 0x83, 0xaa, 0xbc, 0xad, 0x2a, 0xe3, 0xd7, 0xc8, 0x93, 0x2b, 0x6d, 0x6e, 0xa8,
+	0x53, 0xab, 0xce, 0xff, 0xff, 0x52, 0x5f, 0xca, 0x39, 0x8a, 0x99, 0x6f, 0x7e, 0xee, 0x27, 0xbf,
+	0x07, 0x00, 0x86, 0xb8, 0xe5, 0xdf, 0xf3, 0x03, 0x00, 0x00,
 }
@@ -123,6 +123,22 @@ func (in *TrafficPolicy) DeepCopyInto(out *TrafficPolicy) {
 			}
 		}
 	}
+	if in.OutlierDetection != nil {
+		in, out := &in.OutlierDetection, &out.OutlierDetection
+		*out = new(TrafficPolicy_OutlierDetection)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Failover != nil {
+		in, out := &in.Failover, &out.Failover
+		*out = make([]*TrafficPolicy_Failover, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(TrafficPolicy_Failover)
+				(*in).DeepCopyInto(*out)
+			}
+		}
+	}
 	out.XXX_NoUnkeyedLiteral = in.XXX_NoUnkeyedLiteral
 	if in.XXX_unrecognized != nil {
 		in, out := &in.XXX_unrecognized, &out.XXX_unrecognized
@@ -141,3 +157,47 @@ func (in *TrafficPolicy) DeepCopy() *TrafficPolicy {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TrafficPolicy_Failover) DeepCopyInto(out *TrafficPolicy_Failover) {
+	*out = *in
+	out.XXX_NoUnkeyedLiteral = in.XXX_NoUnkeyedLiteral
+	if in.XXX_unrecognized != nil {
+		in, out := &in.XXX_unrecognized, &out.XXX_unrecognized
+		*out = make([]byte, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TrafficPolicy_Failover.
+func (in *TrafficPolicy_Failover) DeepCopy() *TrafficPolicy_Failover {
+	if in == nil {
+		return nil
+	}
+	out := new(TrafficPolicy_Failover)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TrafficPolicy_OutlierDetection) DeepCopyInto(out *TrafficPolicy_OutlierDetection) {
+	*out = *in
+	out.XXX_NoUnkeyedLiteral = in.XXX_NoUnkeyedLiteral
+	if in.XXX_unrecognized != nil {
+		in, out := &in.XXX_unrecognized, &out.XXX_unrecognized
+		*out = make([]byte, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TrafficPolicy_OutlierDetection.
+func (in *TrafficPolicy_OutlierDetection) DeepCopy() *TrafficPolicy_OutlierDetection {
+	if in == nil {
+		return nil
+	}
+	out := new(TrafficPolicy_OutlierDetection)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -223,3 +223,93 @@ func TestGetServiceEntriesByIdentity(t *testing.T) {
 		})
 	}
 }
+
+func TestGetGlobalTrafficPolicyConflicts(t *testing.T) {
+	url := "https://admiral.com/globaltrafficpolicies/conflicts"
+	opts := RouteOpts{
+		RemoteRegistry: &clusters.RemoteRegistry{
+			AdmiralCache: &clusters.AdmiralCache{},
+		},
+	}
+	r := httptest.NewRequest("GET", url, strings.NewReader(""))
+	w := httptest.NewRecorder()
+
+	opts.GetGlobalTrafficPolicyConflicts(w, r)
+	resp := w.Result()
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "[]", string(body))
+}
+
+func TestGetChangePlan(t *testing.T) {
+	url := "https://admiral.com/changeplan"
+	testCases := []struct {
+		name           string
+		recorder       *clusters.ChangeRecorder
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Given dry run is disabled, should return not found",
+			expectedStatus: 404,
+			expectedBody:   "Dry run is not enabled\n",
+		},
+		{
+			name:           "Given dry run is enabled, should return the change plan",
+			recorder:       clusters.NewChangeRecorder(),
+			expectedStatus: 200,
+			expectedBody:   "{}",
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			opts := RouteOpts{
+				RemoteRegistry: &clusters.RemoteRegistry{ChangeRecorder: c.recorder},
+			}
+			r := httptest.NewRequest("GET", url, strings.NewReader(""))
+			w := httptest.NewRecorder()
+
+			opts.GetChangePlan(w, r)
+			resp := w.Result()
+			body, _ := ioutil.ReadAll(resp.Body)
+
+			assert.Equal(t, c.expectedStatus, resp.StatusCode)
+			assert.Equal(t, c.expectedBody, string(body))
+		})
+	}
+}
+
+func TestGetReadiness(t *testing.T) {
+	url := "https://admiral.com/health/ready"
+	opts := RouteOpts{
+		RemoteRegistry: &clusters.RemoteRegistry{RemoteControllers: map[string]*clusters.RemoteController{"cluster1": {ClusterID: "cluster1"}}},
+	}
+	r := httptest.NewRequest("GET", url, strings.NewReader(""))
+	w := httptest.NewRecorder()
+
+	opts.GetReadiness(w, r)
+	resp := w.Result()
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, `{"ready":true,"clusters":{"cluster1":{"ready":true}}}`, string(body))
+}
+
+func TestGetBlockedServiceEntryUpdates(t *testing.T) {
+	url := "https://admiral.com/serviceentries/blocked"
+	opts := RouteOpts{
+		RemoteRegistry: &clusters.RemoteRegistry{RemoteControllers: map[string]*clusters.RemoteController{"cluster1": {ClusterID: "cluster1"}}},
+	}
+	r := httptest.NewRequest("GET", url, strings.NewReader(""))
+	w := httptest.NewRecorder()
+
+	opts.GetBlockedServiceEntryUpdates(w, r)
+	resp := w.Result()
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "[]", string(body))
+}
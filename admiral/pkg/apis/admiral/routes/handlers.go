@@ -157,3 +157,126 @@ func (opts *RouteOpts) GetServiceEntriesByIdentity(w http.ResponseWriter, r *htt
 		http.Error(w, "Identity not provided as part of the request", http.StatusBadRequest)
 	}
 }
+
+func (opts *RouteOpts) GetGlobalTrafficPolicyConflicts(w http.ResponseWriter, r *http.Request) {
+
+	response := clusters.GetGlobalTrafficPolicyConflicts(opts.RemoteRegistry)
+
+	out, err := json.Marshal(response)
+	if err != nil {
+		log.Printf("Failed to marshall response for GetGlobalTrafficPolicyConflicts call")
+		http.Error(w, "Failed to marshall response", http.StatusInternalServerError)
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(200)
+		_, err := w.Write(out)
+		if err != nil {
+			log.Println("failed to write resp body", err)
+		}
+	}
+}
+
+func (opts *RouteOpts) GetServiceEntryAddressPoolUsage(w http.ResponseWriter, r *http.Request) {
+
+	response := clusters.GetServiceEntryAddressPoolUsage(opts.RemoteRegistry)
+
+	out, err := json.Marshal(response)
+	if err != nil {
+		log.Printf("Failed to marshall response for GetServiceEntryAddressPoolUsage call")
+		http.Error(w, "Failed to marshall response", http.StatusInternalServerError)
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(200)
+		_, err := w.Write(out)
+		if err != nil {
+			log.Println("failed to write resp body", err)
+		}
+	}
+}
+
+func (opts *RouteOpts) GetBlockedServiceEntryUpdates(w http.ResponseWriter, r *http.Request) {
+
+	response := clusters.GetBlockedServiceEntryUpdates(opts.RemoteRegistry)
+
+	out, err := json.Marshal(response)
+	if err != nil {
+		log.Printf("Failed to marshall response for GetBlockedServiceEntryUpdates call")
+		http.Error(w, "Failed to marshall response", http.StatusInternalServerError)
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(200)
+		_, err := w.Write(out)
+		if err != nil {
+			log.Println("failed to write resp body", err)
+		}
+	}
+}
+
+func (opts *RouteOpts) GetOrphans(w http.ResponseWriter, r *http.Request) {
+
+	//always a dry run, the orphans are only deleted by the collector
+	response, err := clusters.CollectOrphans(opts.RemoteRegistry, true)
+	if err != nil {
+		log.Printf("Failed to collect orphans: %v", err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	out, err := json.Marshal(response)
+	if err != nil {
+		log.Printf("Failed to marshall response for GetOrphans call")
+		http.Error(w, "Failed to marshall response", http.StatusInternalServerError)
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(200)
+		_, err := w.Write(out)
+		if err != nil {
+			log.Println("failed to write resp body", err)
+		}
+	}
+}
+
+func (opts *RouteOpts) GetChangePlan(w http.ResponseWriter, r *http.Request) {
+
+	if opts.RemoteRegistry.ChangeRecorder == nil {
+		http.Error(w, "Dry run is not enabled", http.StatusNotFound)
+		return
+	}
+	response := opts.RemoteRegistry.ChangeRecorder.GetChangePlan()
+
+	out, err := json.Marshal(response)
+	if err != nil {
+		log.Printf("Failed to marshall response for GetChangePlan call")
+		http.Error(w, "Failed to marshall response", http.StatusInternalServerError)
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(200)
+		_, err := w.Write(out)
+		if err != nil {
+			log.Println("failed to write resp body", err)
+		}
+	}
+}
+
+func (opts *RouteOpts) GetReadiness(w http.ResponseWriter, r *http.Request) {
+
+	response := opts.RemoteRegistry.GetReadiness()
+
+	out, err := json.Marshal(response)
+	if err != nil {
+		log.Printf("Failed to marshall response for GetReadiness call")
+		http.Error(w, "Failed to marshall response", http.StatusInternalServerError)
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		//the events are skipped until all the informers have synced
+		if response.Ready {
+			w.WriteHeader(200)
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_, err := w.Write(out)
+		if err != nil {
+			log.Println("failed to write resp body", err)
+		}
+	}
+}
@@ -25,10 +25,10 @@ func NewAdmiralAPIServer(opts *RouteOpts) server.Routes {
 	return server.Routes{
 
 		server.Route{
-			Name:        "Success health check",
+			Name:        "Readiness of the controllers of every cluster",
 			Method:      "GET",
 			Pattern:     "/health/ready",
-			HandlerFunc: opts.ReturnSuccessGET,
+			HandlerFunc: opts.GetReadiness,
 		},
 		server.Route{
 			Name:        "Get list of clusters admiral is watching",
@@ -48,6 +48,36 @@ func NewAdmiralAPIServer(opts *RouteOpts) server.Routes {
 			Pattern:     "/identity/{identity}/serviceentries",
 			HandlerFunc: opts.GetServiceEntriesByIdentity,
 		},
+		server.Route{
+			Name:        "Get list of conflicting global traffic policies",
+			Method:      "GET",
+			Pattern:     "/globaltrafficpolicies/conflicts",
+			HandlerFunc: opts.GetGlobalTrafficPolicyConflicts,
+		},
+		server.Route{
+			Name:        "Get the usage of the service entry address pool",
+			Method:      "GET",
+			Pattern:     "/serviceentries/addresses",
+			HandlerFunc: opts.GetServiceEntryAddressPoolUsage,
+		},
+		server.Route{
+			Name:        "Get the service entry updates blocked until confirmed because they remove too many endpoints",
+			Method:      "GET",
+			Pattern:     "/serviceentries/blocked",
+			HandlerFunc: opts.GetBlockedServiceEntryUpdates,
+		},
+		server.Route{
+			Name:        "Get the objects owned by Admiral that it wouldn't generate anymore",
+			Method:      "GET",
+			Pattern:     "/orphans",
+			HandlerFunc: opts.GetOrphans,
+		},
+		server.Route{
+			Name:        "Get the changes recorded for every cluster in dry run mode",
+			Method:      "GET",
+			Pattern:     "/changeplan",
+			HandlerFunc: opts.GetChangePlan,
+		},
 	}
 }
 
@@ -5,6 +5,16 @@ import (
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+const (
+	//Dependency states
+	DependencyStateSynced = "Synced"
+	DependencyStateError  = "Error"
+
+	//GlobalTrafficPolicy states
+	GtpStateActive   = "Active"
+	GtpStateShadowed = "Shadowed"
+)
+
 // +genclient
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 //generic cdr object to wrap the dependency api
@@ -17,8 +27,10 @@ type Dependency struct {
 
 // FooStatus is the status for a Foo resource
 type DependencyStatus struct {
-	ClusterSynced int32  `json:"clustersSynced"`
-	State         string `json:"state"`
+	ClusterSynced int32             `json:"clustersSynced"`
+	State         string            `json:"state"`
+	LastSyncTime  meta_v1.Time      `json:"lastSyncTime,omitempty"`
+	ClusterErrors map[string]string `json:"clusterErrors,omitempty"`
 }
 
 // FooList is a list of Foo resources
@@ -43,8 +55,12 @@ type GlobalTrafficPolicy struct {
 // FooStatus is the status for a Foo resource
 
 type GlobalTrafficPolicyStatus struct {
-	ClusterSynced int32  `json:"clustersSynced"`
-	State         string `json:"state"`
+	ClusterSynced int32             `json:"clustersSynced"`
+	State         string            `json:"state"`
+	LastSyncTime  meta_v1.Time      `json:"lastSyncTime,omitempty"`
+	ClusterErrors map[string]string `json:"clusterErrors,omitempty"`
+	//cluster/namespace/name of the GTP that is in effect when State is Shadowed
+	ShadowedBy string `json:"shadowedBy,omitempty"`
 }
 
 // FooList is a list of Foo resources
@@ -55,3 +71,30 @@ type GlobalTrafficPolicyList struct {
 
 	Items []GlobalTrafficPolicy `json:"items"`
 }
+
+//generic cdr object to record the address allocated to a ServiceEntry, one object per ServiceEntry named after it
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type AddressAllocation struct {
+	meta_v1.TypeMeta   `json:",inline"`
+	meta_v1.ObjectMeta `json:"metadata"`
+	Spec               AddressAllocationSpec   `json:"spec"`
+	Status             AddressAllocationStatus `json:"status"`
+}
+
+type AddressAllocationSpec struct {
+	Address string `json:"address"`
+}
+
+type AddressAllocationStatus struct {
+	//set when the ServiceEntry no longer exists in any cluster, the address is released once it has been unused for the grace period
+	UnusedSince *meta_v1.Time `json:"unusedSince,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type AddressAllocationList struct {
+	meta_v1.TypeMeta `json:",inline"`
+	meta_v1.ListMeta `json:"metadata"`
+
+	Items []AddressAllocation `json:"items"`
+}
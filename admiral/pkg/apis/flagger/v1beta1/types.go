@@ -0,0 +1,61 @@
+//Package v1beta1 holds the fields of the Flagger canary resources Admiral routes with. The Flagger api isn't a dependency of Admiral,
+//the canaries are read through the dynamic client and converted to these types
+package v1beta1
+
+import (
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	GroupName = "flagger.app"
+
+	//suffixes of the services Flagger generates for the target of a canary, next to the apex service selecting the primary pods
+	PrimarySuffix = "-primary"
+	CanarySuffix  = "-canary"
+
+	DeploymentKind = "Deployment"
+)
+
+var SchemeGroupVersion = schema.GroupVersion{Group: GroupName, Version: "v1beta1"}
+
+//CanaryResource is the resource of the Flagger canaries, used to watch them with the dynamic client
+var CanaryResource = SchemeGroupVersion.WithResource("canaries")
+
+type Canary struct {
+	meta_v1.TypeMeta   `json:",inline"`
+	meta_v1.ObjectMeta `json:"metadata,omitempty"`
+	Spec               CanarySpec   `json:"spec"`
+	Status             CanaryStatus `json:"status,omitempty"`
+}
+
+type CanarySpec struct {
+	//the workload Flagger makes a primary copy of, its own pods become the canary pods
+	TargetRef TargetRef     `json:"targetRef"`
+	Service   CanaryService `json:"service"`
+}
+
+type TargetRef struct {
+	APIVersion string `json:"apiVersion,omitempty"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+}
+
+type CanaryService struct {
+	//the name of the apex service and the prefix of the generated services, the name of the target when empty
+	Name string `json:"name,omitempty"`
+	Port int32  `json:"port"`
+}
+
+type CanaryStatus struct {
+	Phase        string `json:"phase,omitempty"`
+	CanaryWeight int32  `json:"canaryWeight,omitempty"`
+}
+
+//GetServiceName returns the name of the apex service of the canary, the generated services are named after it
+func (c *Canary) GetServiceName() string {
+	if len(c.Spec.Service.Name) > 0 {
+		return c.Spec.Service.Name
+	}
+	return c.Spec.TargetRef.Name
+}
@@ -0,0 +1,191 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1
+
+import (
+	"time"
+
+	v1 "github.com/istio-ecosystem/admiral/admiral/pkg/apis/admiral/v1"
+	scheme "github.com/istio-ecosystem/admiral/admiral/pkg/client/clientset/versioned/scheme"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	rest "k8s.io/client-go/rest"
+)
+
+// AddressAllocationsGetter has a method to return a AddressAllocationInterface.
+// A group's client should implement this interface.
+type AddressAllocationsGetter interface {
+	AddressAllocations(namespace string) AddressAllocationInterface
+}
+
+// AddressAllocationInterface has methods to work with AddressAllocation resources.
+type AddressAllocationInterface interface {
+	Create(*v1.AddressAllocation) (*v1.AddressAllocation, error)
+	Update(*v1.AddressAllocation) (*v1.AddressAllocation, error)
+	UpdateStatus(*v1.AddressAllocation) (*v1.AddressAllocation, error)
+	Delete(name string, options *metav1.DeleteOptions) error
+	DeleteCollection(options *metav1.DeleteOptions, listOptions metav1.ListOptions) error
+	Get(name string, options metav1.GetOptions) (*v1.AddressAllocation, error)
+	List(opts metav1.ListOptions) (*v1.AddressAllocationList, error)
+	Watch(opts metav1.ListOptions) (watch.Interface, error)
+	Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result *v1.AddressAllocation, err error)
+	AddressAllocationExpansion
+}
+
+// addressAllocations implements AddressAllocationInterface
+type addressAllocations struct {
+	client rest.Interface
+	ns     string
+}
+
+// newAddressAllocations returns a AddressAllocations
+func newAddressAllocations(c *AdmiralV1Client, namespace string) *addressAllocations {
+	return &addressAllocations{
+		client: c.RESTClient(),
+		ns:     namespace,
+	}
+}
+
+// Get takes name of the addressAllocation, and returns the corresponding addressAllocation object, and an error if there is any.
+func (c *addressAllocations) Get(name string, options metav1.GetOptions) (result *v1.AddressAllocation, err error) {
+	result = &v1.AddressAllocation{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("addressallocations").
+		Name(name).
+		VersionedParams(&options, scheme.ParameterCodec).
+		Do().
+		Into(result)
+	return
+}
+
+// List takes label and field selectors, and returns the list of AddressAllocations that match those selectors.
+func (c *addressAllocations) List(opts metav1.ListOptions) (result *v1.AddressAllocationList, err error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	result = &v1.AddressAllocationList{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("addressallocations").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Do().
+		Into(result)
+	return
+}
+
+// Watch returns a watch.Interface that watches the requested addressAllocations.
+func (c *addressAllocations) Watch(opts metav1.ListOptions) (watch.Interface, error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	opts.Watch = true
+	return c.client.Get().
+		Namespace(c.ns).
+		Resource("addressallocations").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Watch()
+}
+
+// Create takes the representation of a addressAllocation and creates it.  Returns the server's representation of the addressAllocation, and an error, if there is any.
+func (c *addressAllocations) Create(addressAllocation *v1.AddressAllocation) (result *v1.AddressAllocation, err error) {
+	result = &v1.AddressAllocation{}
+	err = c.client.Post().
+		Namespace(c.ns).
+		Resource("addressallocations").
+		Body(addressAllocation).
+		Do().
+		Into(result)
+	return
+}
+
+// Update takes the representation of a addressAllocation and updates it. Returns the server's representation of the addressAllocation, and an error, if there is any.
+func (c *addressAllocations) Update(addressAllocation *v1.AddressAllocation) (result *v1.AddressAllocation, err error) {
+	result = &v1.AddressAllocation{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("addressallocations").
+		Name(addressAllocation.Name).
+		Body(addressAllocation).
+		Do().
+		Into(result)
+	return
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+
+func (c *addressAllocations) UpdateStatus(addressAllocation *v1.AddressAllocation) (result *v1.AddressAllocation, err error) {
+	result = &v1.AddressAllocation{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("addressallocations").
+		Name(addressAllocation.Name).
+		SubResource("status").
+		Body(addressAllocation).
+		Do().
+		Into(result)
+	return
+}
+
+// Delete takes name of the addressAllocation and deletes it. Returns an error if one occurs.
+func (c *addressAllocations) Delete(name string, options *metav1.DeleteOptions) error {
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("addressallocations").
+		Name(name).
+		Body(options).
+		Do().
+		Error()
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *addressAllocations) DeleteCollection(options *metav1.DeleteOptions, listOptions metav1.ListOptions) error {
+	var timeout time.Duration
+	if listOptions.TimeoutSeconds != nil {
+		timeout = time.Duration(*listOptions.TimeoutSeconds) * time.Second
+	}
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("addressallocations").
+		VersionedParams(&listOptions, scheme.ParameterCodec).
+		Timeout(timeout).
+		Body(options).
+		Do().
+		Error()
+}
+
+// Patch applies the patch and returns the patched addressAllocation.
+func (c *addressAllocations) Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result *v1.AddressAllocation, err error) {
+	result = &v1.AddressAllocation{}
+	err = c.client.Patch(pt).
+		Namespace(c.ns).
+		Resource("addressallocations").
+		SubResource(subresources...).
+		Name(name).
+		Body(data).
+		Do().
+		Into(result)
+	return
+}
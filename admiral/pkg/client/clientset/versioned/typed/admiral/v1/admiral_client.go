@@ -26,6 +26,7 @@ import (
 
 type AdmiralV1Interface interface {
 	RESTClient() rest.Interface
+	AddressAllocationsGetter
 	DependenciesGetter
 	GlobalTrafficPoliciesGetter
 }
@@ -35,6 +36,10 @@ type AdmiralV1Client struct {
 	restClient rest.Interface
 }
 
+func (c *AdmiralV1Client) AddressAllocations(namespace string) AddressAllocationInterface {
+	return newAddressAllocations(c, namespace)
+}
+
 func (c *AdmiralV1Client) Dependencies(namespace string) DependencyInterface {
 	return newDependencies(c, namespace)
 }
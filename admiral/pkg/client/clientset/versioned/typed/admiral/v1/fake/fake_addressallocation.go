@@ -0,0 +1,140 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	admiralv1 "github.com/istio-ecosystem/admiral/admiral/pkg/apis/admiral/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	schema "k8s.io/apimachinery/pkg/runtime/schema"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	testing "k8s.io/client-go/testing"
+)
+
+// FakeAddressAllocations implements AddressAllocationInterface
+type FakeAddressAllocations struct {
+	Fake *FakeAdmiralV1
+	ns   string
+}
+
+var addressallocationsResource = schema.GroupVersionResource{Group: "admiral.io", Version: "v1", Resource: "addressallocations"}
+
+var addressallocationsKind = schema.GroupVersionKind{Group: "admiral.io", Version: "v1", Kind: "AddressAllocation"}
+
+// Get takes name of the addressAllocation, and returns the corresponding addressAllocation object, and an error if there is any.
+func (c *FakeAddressAllocations) Get(name string, options v1.GetOptions) (result *admiralv1.AddressAllocation, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewGetAction(addressallocationsResource, c.ns, name), &admiralv1.AddressAllocation{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*admiralv1.AddressAllocation), err
+}
+
+// List takes label and field selectors, and returns the list of AddressAllocations that match those selectors.
+func (c *FakeAddressAllocations) List(opts v1.ListOptions) (result *admiralv1.AddressAllocationList, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewListAction(addressallocationsResource, addressallocationsKind, c.ns, opts), &admiralv1.AddressAllocationList{})
+
+	if obj == nil {
+		return nil, err
+	}
+
+	label, _, _ := testing.ExtractFromListOptions(opts)
+	if label == nil {
+		label = labels.Everything()
+	}
+	list := &admiralv1.AddressAllocationList{ListMeta: obj.(*admiralv1.AddressAllocationList).ListMeta}
+	for _, item := range obj.(*admiralv1.AddressAllocationList).Items {
+		if label.Matches(labels.Set(item.Labels)) {
+			list.Items = append(list.Items, item)
+		}
+	}
+	return list, err
+}
+
+// Watch returns a watch.Interface that watches the requested addressAllocations.
+func (c *FakeAddressAllocations) Watch(opts v1.ListOptions) (watch.Interface, error) {
+	return c.Fake.
+		InvokesWatch(testing.NewWatchAction(addressallocationsResource, c.ns, opts))
+
+}
+
+// Create takes the representation of a addressAllocation and creates it.  Returns the server's representation of the addressAllocation, and an error, if there is any.
+func (c *FakeAddressAllocations) Create(addressAllocation *admiralv1.AddressAllocation) (result *admiralv1.AddressAllocation, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewCreateAction(addressallocationsResource, c.ns, addressAllocation), &admiralv1.AddressAllocation{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*admiralv1.AddressAllocation), err
+}
+
+// Update takes the representation of a addressAllocation and updates it. Returns the server's representation of the addressAllocation, and an error, if there is any.
+func (c *FakeAddressAllocations) Update(addressAllocation *admiralv1.AddressAllocation) (result *admiralv1.AddressAllocation, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateAction(addressallocationsResource, c.ns, addressAllocation), &admiralv1.AddressAllocation{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*admiralv1.AddressAllocation), err
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *FakeAddressAllocations) UpdateStatus(addressAllocation *admiralv1.AddressAllocation) (*admiralv1.AddressAllocation, error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateSubresourceAction(addressallocationsResource, "status", c.ns, addressAllocation), &admiralv1.AddressAllocation{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*admiralv1.AddressAllocation), err
+}
+
+// Delete takes name of the addressAllocation and deletes it. Returns an error if one occurs.
+func (c *FakeAddressAllocations) Delete(name string, options *v1.DeleteOptions) error {
+	_, err := c.Fake.
+		Invokes(testing.NewDeleteAction(addressallocationsResource, c.ns, name), &admiralv1.AddressAllocation{})
+
+	return err
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *FakeAddressAllocations) DeleteCollection(options *v1.DeleteOptions, listOptions v1.ListOptions) error {
+	action := testing.NewDeleteCollectionAction(addressallocationsResource, c.ns, listOptions)
+
+	_, err := c.Fake.Invokes(action, &admiralv1.AddressAllocationList{})
+	return err
+}
+
+// Patch applies the patch and returns the patched addressAllocation.
+func (c *FakeAddressAllocations) Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result *admiralv1.AddressAllocation, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewPatchSubresourceAction(addressallocationsResource, c.ns, name, pt, data, subresources...), &admiralv1.AddressAllocation{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*admiralv1.AddressAllocation), err
+}
@@ -28,6 +28,10 @@ type FakeAdmiralV1 struct {
 	*testing.Fake
 }
 
+func (c *FakeAdmiralV1) AddressAllocations(namespace string) v1.AddressAllocationInterface {
+	return &FakeAddressAllocations{c, namespace}
+}
+
 func (c *FakeAdmiralV1) Dependencies(namespace string) v1.DependencyInterface {
 	return &FakeDependencies{c, namespace}
 }
@@ -18,6 +18,8 @@ limitations under the License.
 
 package v1
 
+type AddressAllocationExpansion interface{}
+
 type DependencyExpansion interface{}
 
 type GlobalTrafficPolicyExpansion interface{}
@@ -0,0 +1,89 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by informer-gen. DO NOT EDIT.
+
+package v1
+
+import (
+	time "time"
+
+	admiralv1 "github.com/istio-ecosystem/admiral/admiral/pkg/apis/admiral/v1"
+	versioned "github.com/istio-ecosystem/admiral/admiral/pkg/client/clientset/versioned"
+	internalinterfaces "github.com/istio-ecosystem/admiral/admiral/pkg/client/informers/externalversions/internalinterfaces"
+	v1 "github.com/istio-ecosystem/admiral/admiral/pkg/client/listers/admiral/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	watch "k8s.io/apimachinery/pkg/watch"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// AddressAllocationInformer provides access to a shared informer and lister for
+// AddressAllocations.
+type AddressAllocationInformer interface {
+	Informer() cache.SharedIndexInformer
+	Lister() v1.AddressAllocationLister
+}
+
+type addressAllocationInformer struct {
+	factory          internalinterfaces.SharedInformerFactory
+	tweakListOptions internalinterfaces.TweakListOptionsFunc
+	namespace        string
+}
+
+// NewAddressAllocationInformer constructs a new informer for AddressAllocation type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewAddressAllocationInformer(client versioned.Interface, namespace string, resyncPeriod time.Duration, indexers cache.Indexers) cache.SharedIndexInformer {
+	return NewFilteredAddressAllocationInformer(client, namespace, resyncPeriod, indexers, nil)
+}
+
+// NewFilteredAddressAllocationInformer constructs a new informer for AddressAllocation type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewFilteredAddressAllocationInformer(client versioned.Interface, namespace string, resyncPeriod time.Duration, indexers cache.Indexers, tweakListOptions internalinterfaces.TweakListOptionsFunc) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.AdmiralV1().AddressAllocations(namespace).List(options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.AdmiralV1().AddressAllocations(namespace).Watch(options)
+			},
+		},
+		&admiralv1.AddressAllocation{},
+		resyncPeriod,
+		indexers,
+	)
+}
+
+func (f *addressAllocationInformer) defaultInformer(client versioned.Interface, resyncPeriod time.Duration) cache.SharedIndexInformer {
+	return NewFilteredAddressAllocationInformer(client, f.namespace, resyncPeriod, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}, f.tweakListOptions)
+}
+
+func (f *addressAllocationInformer) Informer() cache.SharedIndexInformer {
+	return f.factory.InformerFor(&admiralv1.AddressAllocation{}, f.defaultInformer)
+}
+
+func (f *addressAllocationInformer) Lister() v1.AddressAllocationLister {
+	return v1.NewAddressAllocationLister(f.Informer().GetIndexer())
+}
@@ -24,6 +24,8 @@ import (
 
 // Interface provides access to all the informers in this group version.
 type Interface interface {
+	// AddressAllocations returns a AddressAllocationInformer.
+	AddressAllocations() AddressAllocationInformer
 	// Dependencies returns a DependencyInformer.
 	Dependencies() DependencyInformer
 	// GlobalTrafficPolicies returns a GlobalTrafficPolicyInformer.
@@ -41,6 +43,11 @@ func New(f internalinterfaces.SharedInformerFactory, namespace string, tweakList
 	return &version{factory: f, namespace: namespace, tweakListOptions: tweakListOptions}
 }
 
+// AddressAllocations returns a AddressAllocationInformer.
+func (v *version) AddressAllocations() AddressAllocationInformer {
+	return &addressAllocationInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
 // Dependencies returns a DependencyInformer.
 func (v *version) Dependencies() DependencyInformer {
 	return &dependencyInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
@@ -53,6 +53,8 @@ func (f *genericInformer) Lister() cache.GenericLister {
 func (f *sharedInformerFactory) ForResource(resource schema.GroupVersionResource) (GenericInformer, error) {
 	switch resource {
 	// Group=admiral.io, Version=v1
+	case v1.SchemeGroupVersion.WithResource("addressallocations"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Admiral().V1().AddressAllocations().Informer()}, nil
 	case v1.SchemeGroupVersion.WithResource("dependencies"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Admiral().V1().Dependencies().Informer()}, nil
 	case v1.SchemeGroupVersion.WithResource("globaltrafficpolicies"):
@@ -0,0 +1,94 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1
+
+import (
+	v1 "github.com/istio-ecosystem/admiral/admiral/pkg/apis/admiral/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// AddressAllocationLister helps list AddressAllocations.
+type AddressAllocationLister interface {
+	// List lists all AddressAllocations in the indexer.
+	List(selector labels.Selector) (ret []*v1.AddressAllocation, err error)
+	// AddressAllocations returns an object that can list and get AddressAllocations.
+	AddressAllocations(namespace string) AddressAllocationNamespaceLister
+	AddressAllocationListerExpansion
+}
+
+// addressAllocationLister implements the AddressAllocationLister interface.
+type addressAllocationLister struct {
+	indexer cache.Indexer
+}
+
+// NewAddressAllocationLister returns a new AddressAllocationLister.
+func NewAddressAllocationLister(indexer cache.Indexer) AddressAllocationLister {
+	return &addressAllocationLister{indexer: indexer}
+}
+
+// List lists all AddressAllocations in the indexer.
+func (s *addressAllocationLister) List(selector labels.Selector) (ret []*v1.AddressAllocation, err error) {
+	err = cache.ListAll(s.indexer, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1.AddressAllocation))
+	})
+	return ret, err
+}
+
+// AddressAllocations returns an object that can list and get AddressAllocations.
+func (s *addressAllocationLister) AddressAllocations(namespace string) AddressAllocationNamespaceLister {
+	return addressAllocationNamespaceLister{indexer: s.indexer, namespace: namespace}
+}
+
+// AddressAllocationNamespaceLister helps list and get AddressAllocations.
+type AddressAllocationNamespaceLister interface {
+	// List lists all AddressAllocations in the indexer for a given namespace.
+	List(selector labels.Selector) (ret []*v1.AddressAllocation, err error)
+	// Get retrieves the AddressAllocation from the indexer for a given namespace and name.
+	Get(name string) (*v1.AddressAllocation, error)
+	AddressAllocationNamespaceListerExpansion
+}
+
+// addressAllocationNamespaceLister implements the AddressAllocationNamespaceLister
+// interface.
+type addressAllocationNamespaceLister struct {
+	indexer   cache.Indexer
+	namespace string
+}
+
+// List lists all AddressAllocations in the indexer for a given namespace.
+func (s addressAllocationNamespaceLister) List(selector labels.Selector) (ret []*v1.AddressAllocation, err error) {
+	err = cache.ListAllByNamespace(s.indexer, s.namespace, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1.AddressAllocation))
+	})
+	return ret, err
+}
+
+// Get retrieves the AddressAllocation from the indexer for a given namespace and name.
+func (s addressAllocationNamespaceLister) Get(name string) (*v1.AddressAllocation, error) {
+	obj, exists, err := s.indexer.GetByKey(s.namespace + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.NewNotFound(v1.Resource("addressallocation"), name)
+	}
+	return obj.(*v1.AddressAllocation), nil
+}
@@ -18,6 +18,14 @@ limitations under the License.
 
 package v1
 
+// AddressAllocationListerExpansion allows custom methods to be added to
+// AddressAllocationLister.
+type AddressAllocationListerExpansion interface{}
+
+// AddressAllocationNamespaceListerExpansion allows custom methods to be added to
+// AddressAllocationNamespaceLister.
+type AddressAllocationNamespaceListerExpansion interface{}
+
 // DependencyListerExpansion allows custom methods to be added to
 // DependencyLister.
 type DependencyListerExpansion interface{}
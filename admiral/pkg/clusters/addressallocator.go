@@ -0,0 +1,220 @@
+package clusters
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/admiral"
+	log "github.com/sirupsen/logrus"
+	v12 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+//ServiceEntryAddressAllocator hands out the unique local addresses of the service entries
+type ServiceEntryAddressAllocator interface {
+	//GetAddress returns the address of the service entry, allocating a new one if needed. The bool is true iff the backing store was updated
+	GetAddress(seName string) (string, bool, error)
+	//GetAddressStore returns the allocated addresses as currently known by Admiral
+	GetAddressStore() *ServiceEntryAddressStore
+	//UpdateAddressStore applies the update to the latest allocated addresses, the update returns false when there is nothing to save
+	UpdateAddressStore(update func(addressStore *ServiceEntryAddressStore) bool) error
+}
+
+//falls back to the configmap allocator when none is configured
+func getAddressAllocator(admiralCache *AdmiralCache) ServiceEntryAddressAllocator {
+	if admiralCache.AddressAllocator != nil {
+		return admiralCache.AddressAllocator
+	}
+	return &configMapAddressAllocator{admiralCache: admiralCache}
+}
+
+//configMapAddressAllocator keeps all the addresses in a single configmap, every allocation rewrites the whole configmap
+type configMapAddressAllocator struct {
+	admiralCache *AdmiralCache
+}
+
+func (a *configMapAddressAllocator) GetAddress(seName string) (string, bool, error) {
+	address, needsCacheUpdate, err := GetLocalAddressForSe(seName, a.admiralCache.ServiceEntryAddressStore, a.admiralCache.ConfigMapController)
+	if err == nil && needsCacheUpdate {
+		loadServiceEntryCacheData(a.admiralCache.ConfigMapController, a.admiralCache)
+	}
+	return address, needsCacheUpdate, err
+}
+
+func (a *configMapAddressAllocator) GetAddressStore() *ServiceEntryAddressStore {
+	return a.admiralCache.ServiceEntryAddressStore
+}
+
+func (a *configMapAddressAllocator) UpdateAddressStore(update func(addressStore *ServiceEntryAddressStore) bool) error {
+	configMapController := a.admiralCache.ConfigMapController
+	cm, err := configMapController.GetConfigMap()
+	if err != nil {
+		return err
+	}
+	addressStore := GetServiceEntryStateFromConfigmap(cm)
+	if addressStore == nil {
+		return errors.New("could not unmarshall configmap yaml")
+	}
+	if !update(addressStore) {
+		return nil
+	}
+	err = putServiceEntryStateFromConfigmap(configMapController, cm, addressStore)
+	if err != nil {
+		return err
+	}
+	loadServiceEntryCacheData(configMapController, a.admiralCache)
+	return nil
+}
+
+//maximum number of free addresses tried when the picked ones are leased by other admiral instances
+const maxAddressAllocationAttempts = 10
+
+//addressAllocationAllocator keeps one AddressAllocation object per service entry, so allocating an address only creates a small object
+//and never contends with the other allocations. The controller must have synced before the allocator is used
+type addressAllocationAllocator struct {
+	controller *admiral.AddressAllocationController
+	//serializes the allocations of this instance, the address leases keep the addresses unique across admiral instances
+	mutex sync.Mutex
+}
+
+func NewAddressAllocationAllocator(controller *admiral.AddressAllocationController) ServiceEntryAddressAllocator {
+	return &addressAllocationAllocator{controller: controller}
+}
+
+func (a *addressAllocationAllocator) GetAddress(seName string) (string, bool, error) {
+	if allocation := a.controller.Cache.Get(seName); allocation != nil {
+		return allocation.Spec.Address, false, nil
+	}
+
+	defer a.mutex.Unlock()
+	a.mutex.Lock()
+
+	if allocation := a.controller.Cache.Get(seName); allocation != nil {
+		return allocation.Spec.Address, false, nil
+	}
+	//the cache may not have seen the allocations of the other instances yet, the addresses they leased are skipped
+	usedAddresses := a.controller.Cache.GetAddresses()
+	for attempt := 0; attempt < maxAddressAllocationAttempts; attempt++ {
+		address, err := getFreeServiceEntryAddress(usedAddresses)
+		if err != nil {
+			return "", false, err
+		}
+		allocation, err := a.controller.Create(seName, address)
+		if err == admiral.ErrAddressLeased {
+			usedAddresses = append(usedAddresses, address)
+			continue
+		}
+		if err != nil {
+			return "", false, err
+		}
+		return allocation.Spec.Address, true, nil
+	}
+	return "", false, fmt.Errorf("no free address could be leased for service entry=%s after %d attempts", seName, maxAddressAllocationAttempts)
+}
+
+func (a *addressAllocationAllocator) GetAddressStore() *ServiceEntryAddressStore {
+	addressStore := &ServiceEntryAddressStore{
+		EntryAddresses: make(map[string]string),
+		Addresses:      make([]string, 0),
+		UnusedSince:    make(map[string]string),
+	}
+	for _, allocation := range a.controller.Cache.List() {
+		addressStore.EntryAddresses[allocation.Name] = allocation.Spec.Address
+		addressStore.Addresses = append(addressStore.Addresses, allocation.Spec.Address)
+		if allocation.Status.UnusedSince != nil {
+			addressStore.UnusedSince[allocation.Name] = allocation.Status.UnusedSince.UTC().Format(time.RFC3339)
+		}
+	}
+	return addressStore
+}
+
+//Only the allocations that differ between the store before and after the update are written
+func (a *addressAllocationAllocator) UpdateAddressStore(update func(addressStore *ServiceEntryAddressStore) bool) error {
+	defer a.mutex.Unlock()
+	a.mutex.Lock()
+
+	before := a.GetAddressStore()
+	addressStore := a.GetAddressStore()
+	if !update(addressStore) {
+		return nil
+	}
+
+	var errs []string
+	for seName := range before.EntryAddresses {
+		if _, ok := addressStore.EntryAddresses[seName]; ok {
+			continue
+		}
+		if err := a.controller.Delete(seName); err != nil {
+			errs = append(errs, fmt.Sprintf("failed to delete address allocation=%s: %v", seName, err))
+		}
+	}
+	for seName := range addressStore.EntryAddresses {
+		if before.UnusedSince[seName] == addressStore.UnusedSince[seName] {
+			continue
+		}
+		allocation := a.controller.Cache.Get(seName)
+		if allocation == nil {
+			continue
+		}
+		allocation = allocation.DeepCopy()
+		allocation.Status.UnusedSince = nil
+		if unusedSince, ok := addressStore.UnusedSince[seName]; ok {
+			since, err := time.Parse(time.RFC3339, unusedSince)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("invalid unused time for address allocation=%s: %v", seName, err))
+				continue
+			}
+			mark := v12.NewTime(since)
+			allocation.Status.UnusedSince = &mark
+		}
+		if _, err := a.controller.UpdateStatus(allocation); err != nil {
+			errs = append(errs, fmt.Sprintf("failed to update address allocation=%s: %v", seName, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
+
+//Copies the addresses recorded in the configmap into address allocations, so that the service entries keep their addresses
+//The configmap is left untouched to allow going back to the configmap allocator
+func MigrateAddressStoreToAddressAllocations(configMapController admiral.ConfigMapControllerInterface, controller *admiral.AddressAllocationController) error {
+	cm, err := configMapController.GetConfigMap()
+	if err != nil {
+		return err
+	}
+	addressStore := GetServiceEntryStateFromConfigmap(cm)
+	if addressStore == nil {
+		return errors.New("could not unmarshall configmap yaml")
+	}
+
+	allocatedAddresses := make(map[string]string)
+	for _, allocation := range controller.Cache.List() {
+		allocatedAddresses[allocation.Spec.Address] = allocation.Name
+	}
+
+	migrated := 0
+	for seName, address := range addressStore.EntryAddresses {
+		if controller.Cache.Get(seName) != nil {
+			continue
+		}
+		if owner, ok := allocatedAddresses[address]; ok {
+			log.Warnf(LogFormat, "Migrate", "AddressAllocation", seName, "", "address "+address+" already allocated to "+owner+", a new address will be allocated")
+			continue
+		}
+		allocation, err := controller.Create(seName, address)
+		if err == admiral.ErrAddressLeased {
+			log.Warnf(LogFormat, "Migrate", "AddressAllocation", seName, "", "address "+address+" is leased to another service entry, a new address will be allocated")
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		allocatedAddresses[allocation.Spec.Address] = seName
+		migrated++
+	}
+	log.Infof(LogFormat, "Migrate", "AddressAllocation", "", "", fmt.Sprintf("migrated %d addresses from the configmap", migrated))
+	return nil
+}
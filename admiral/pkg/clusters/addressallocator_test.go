@@ -0,0 +1,162 @@
+package clusters
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	admiralFake "github.com/istio-ecosystem/admiral/admiral/pkg/client/clientset/versioned/fake"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/admiral"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/common"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/istio"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/test"
+	istionetworkingv1alpha3 "istio.io/api/networking/v1alpha3"
+	"istio.io/client-go/pkg/apis/networking/v1alpha3"
+	istiofake "istio.io/client-go/pkg/clientset/versioned/fake"
+	coordinationV1 "k8s.io/api/coordination/v1"
+	v12 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sFake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestAddressAllocationAllocator_GetAddress(t *testing.T) {
+	stop := make(chan struct{})
+	defer close(stop)
+	controller := admiral.NewAddressAllocationController(stop, admiralFake.NewSimpleClientset(), k8sFake.NewSimpleClientset(), "ns", 0)
+	controller.Create("a-se", "240.0.10.1")
+	allocator := NewAddressAllocationAllocator(controller)
+
+	testCases := []struct {
+		name            string
+		seName          string
+		expectedAddress string
+		expectedUpdated bool
+	}{
+		{
+			name:            "Given a service entry with an allocated address, should return it",
+			seName:          "a-se",
+			expectedAddress: "240.0.10.1",
+		},
+		{
+			name:            "Given a new service entry, should allocate the lowest free address",
+			seName:          "b-se",
+			expectedAddress: "240.0.10.2",
+			expectedUpdated: true,
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			address, updated, err := allocator.GetAddress(c.seName)
+			if err != nil {
+				t.Fatalf("Unexpected error %v", err)
+			}
+			if address != c.expectedAddress {
+				t.Errorf("Expected address %v, got %v", c.expectedAddress, address)
+			}
+			if updated != c.expectedUpdated {
+				t.Errorf("Expected updated=%v, got %v", c.expectedUpdated, updated)
+			}
+			allocation, err := controller.CrdClient.AdmiralV1().AddressAllocations("ns").Get(c.seName, v12.GetOptions{})
+			if err != nil || allocation.Spec.Address != c.expectedAddress {
+				t.Errorf("Expected an address allocation with address %v, got %v (err=%v)", c.expectedAddress, allocation, err)
+			}
+		})
+	}
+}
+
+func TestAddressAllocationAllocator_GetAddressLeasedByAnotherInstance(t *testing.T) {
+	stop := make(chan struct{})
+	defer close(stop)
+	controller := admiral.NewAddressAllocationController(stop, admiralFake.NewSimpleClientset(), k8sFake.NewSimpleClientset(), "ns", 0)
+	controller.Create("a-se", "240.0.10.1")
+	//another admiral instance leased the next address, its allocation isn't cached yet
+	holder := "other-se"
+	controller.K8sClient.CoordinationV1().Leases("ns").Create(&coordinationV1.Lease{
+		ObjectMeta: v12.ObjectMeta{Name: "se-address-240-0-10-2", Namespace: "ns"},
+		Spec:       coordinationV1.LeaseSpec{HolderIdentity: &holder},
+	})
+	allocator := NewAddressAllocationAllocator(controller)
+
+	address, updated, err := allocator.GetAddress("b-se")
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if address != "240.0.10.3" || !updated {
+		t.Errorf("Expected the next free address to be allocated, got %v (updated=%v)", address, updated)
+	}
+}
+
+func TestReclaimServiceEntryAddressesWithAddressAllocations(t *testing.T) {
+	common.InitializeConfig(common.AdmiralParams{SyncNamespace: "ns"})
+
+	stop := make(chan struct{})
+	defer close(stop)
+	controller := admiral.NewAddressAllocationController(stop, admiralFake.NewSimpleClientset(), k8sFake.NewSimpleClientset(), "ns", 0)
+	controller.Create("a-se", "240.0.10.1")
+	controller.Create("c-se", "240.0.10.3")
+	allocation, _ := controller.Create("b-se", "240.0.10.2")
+	unusedSince := v12.NewTime(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC))
+	allocation.Status.UnusedSince = &unusedSince
+	controller.UpdateStatus(allocation)
+
+	fakeIstioClient := istiofake.NewSimpleClientset()
+	fakeIstioClient.NetworkingV1alpha3().ServiceEntries(common.GetSyncNamespace()).Create(&v1alpha3.ServiceEntry{
+		ObjectMeta: v12.ObjectMeta{Name: "a-se", Namespace: common.GetSyncNamespace()},
+		Spec:       istionetworkingv1alpha3.ServiceEntry{Hosts: []string{"a"}},
+	})
+
+	rr := &RemoteRegistry{RemoteControllers: map[string]*RemoteController{}}
+	rr.StartTime = time.Now().Add(-time.Hour * 24)
+	rr.AdmiralCache = &AdmiralCache{AddressAllocator: NewAddressAllocationAllocator(controller)}
+	rr.RemoteControllers["cluster1"] = &RemoteController{
+		ServiceEntryController: &istio.ServiceEntryController{IstioClient: fakeIstioClient},
+	}
+
+	err := ReclaimServiceEntryAddresses(rr, time.Hour, time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	if _, err := controller.CrdClient.AdmiralV1().AddressAllocations("ns").Get("b-se", v12.GetOptions{}); err == nil {
+		t.Errorf("Address allocation of b-se should have been released")
+	}
+	marked, err := controller.CrdClient.AdmiralV1().AddressAllocations("ns").Get("c-se", v12.GetOptions{})
+	if err != nil || marked.Status.UnusedSince == nil {
+		t.Errorf("Address allocation of c-se should have been marked as unused, got %v (err=%v)", marked, err)
+	}
+
+	usage := GetServiceEntryAddressPoolUsage(rr)
+	expectedUsage := ServiceEntryAddressPoolUsage{Capacity: ServiceEntryAddressPoolSize, Used: 2, Unused: 1, Free: ServiceEntryAddressPoolSize - 2}
+	if usage != expectedUsage {
+		t.Errorf("Unexpected pool usage, got %v expected %v", usage, expectedUsage)
+	}
+}
+
+func TestMigrateAddressStoreToAddressAllocations(t *testing.T) {
+	addressStore := ServiceEntryAddressStore{
+		EntryAddresses: map[string]string{"a-se": "240.0.10.1", "b-se": "240.0.10.2"},
+		Addresses:      []string{"240.0.10.1", "240.0.10.2"},
+	}
+	cacheController := &test.FakeConfigMapController{
+		ConfigmapToReturn: buildFakeConfigMapFromAddressStore(&addressStore, "123"),
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	controller := admiral.NewAddressAllocationController(stop, admiralFake.NewSimpleClientset(), k8sFake.NewSimpleClientset(), "ns", 0)
+	controller.Create("c-se", "240.0.10.2")
+
+	err := MigrateAddressStoreToAddressAllocations(cacheController, controller)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	expectedAddresses := map[string]string{"a-se": "240.0.10.1", "c-se": "240.0.10.2"}
+	addresses := make(map[string]string)
+	for _, allocation := range controller.Cache.List() {
+		addresses[allocation.Name] = allocation.Spec.Address
+	}
+	if !reflect.DeepEqual(addresses, expectedAddresses) {
+		t.Errorf("Unexpected address allocations, got %v expected %v", addresses, expectedAddresses)
+	}
+}
@@ -0,0 +1,215 @@
+package clusters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/common"
+	log "github.com/sirupsen/logrus"
+	v12 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	//service entry addresses are allocated from 240.0.10.1 up to 240.0.255.255
+	seAddressFirstSubnet        = 10
+	seAddressLastSubnet         = 255
+	seAddressHostsPerSubnet     = 255
+	ServiceEntryAddressPoolSize = (seAddressLastSubnet - seAddressFirstSubnet + 1) * seAddressHostsPerSubnet
+)
+
+//ServiceEntryAddressPoolUsage reports how much of the service entry address pool is in use
+type ServiceEntryAddressPoolUsage struct {
+	Capacity int `json:"capacity"`
+	Used     int `json:"used"`
+	Unused   int `json:"unused"` //allocated to service entries that no longer exist, waiting for the grace period to be released
+	Free     int `json:"free"`
+}
+
+//Returns the lowest address of the pool that is not in use
+func getFreeServiceEntryAddress(addresses []string) (string, error) {
+	used := make(map[string]bool, len(addresses))
+	for _, address := range addresses {
+		used[address] = true
+	}
+	for secondIndex := seAddressFirstSubnet; secondIndex <= seAddressLastSubnet; secondIndex++ {
+		for firstIndex := 1; firstIndex <= seAddressHostsPerSubnet; firstIndex++ {
+			address := common.LocalAddressPrefix + common.Sep + strconv.Itoa(secondIndex) + common.Sep + strconv.Itoa(firstIndex)
+			if !used[address] {
+				return address, nil
+			}
+		}
+	}
+	return "", errors.New("service entry address pool exhausted")
+}
+
+//Periodically releases the addresses of service entries that no longer exist in any cluster, until the context is done
+func StartServiceEntryAddressReclaimer(ctx context.Context, remoteRegistry *RemoteRegistry, interval time.Duration, gracePeriod time.Duration) {
+	log.Infof("starting service entry address reclaimer, interval=%v grace period=%v", interval, gracePeriod)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := ReclaimServiceEntryAddresses(remoteRegistry, gracePeriod, time.Now()); err != nil {
+				log.Warnf(LogErrFormat, "Reclaim", "ServiceEntryAddress", "", "", err)
+			}
+		}
+	}
+}
+
+//Marks the addresses of service entries missing from all the clusters as unused, and releases the ones that have been unused for longer than the grace period
+func ReclaimServiceEntryAddresses(remoteRegistry *RemoteRegistry, gracePeriod time.Duration, now time.Time) error {
+	if IsCacheWarmupTime(remoteRegistry) {
+		log.Infof(LogFormat, "Reclaim", "ServiceEntryAddress", "", "", "Processing skipped during cache warm up state")
+		return nil
+	}
+
+	existingServiceEntries, err := getServiceEntryNamesInAllClusters(remoteRegistry)
+	if err != nil {
+		return err
+	}
+
+	var released []string
+	var usage ServiceEntryAddressPoolUsage
+	err = getAddressAllocator(remoteRegistry.AdmiralCache).UpdateAddressStore(func(addressStore *ServiceEntryAddressStore) bool {
+		var changed bool
+		released, changed = markAndReleaseUnusedAddresses(addressStore, existingServiceEntries, gracePeriod, now)
+		usage = getServiceEntryAddressPoolUsage(addressStore)
+		return changed
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, seName := range released {
+		log.Infof(LogFormat, "Reclaim", "ServiceEntryAddress", seName, "", "address released after being unused for "+gracePeriod.String())
+		common.SeAddressesReclaimed.Inc()
+	}
+	reportServiceEntryAddressPoolUsage(usage)
+	return nil
+}
+
+//Returns the names of the service entries in the sync namespace of every cluster, fails if any of the clusters can't be listed
+//as an address can't be considered unused without knowing about all the clusters
+func getServiceEntryNamesInAllClusters(remoteRegistry *RemoteRegistry) (map[string]bool, error) {
+	remoteRegistry.Lock()
+	remoteControllers := make(map[string]*RemoteController, len(remoteRegistry.RemoteControllers))
+	for clusterId, rc := range remoteRegistry.RemoteControllers {
+		remoteControllers[clusterId] = rc
+	}
+	remoteRegistry.Unlock()
+
+	if len(remoteControllers) == 0 {
+		return nil, errors.New("no clusters are monitored, skipping")
+	}
+
+	serviceEntryNames := make(map[string]bool)
+	for clusterId, rc := range remoteControllers {
+		if rc.ServiceEntryController == nil {
+			return nil, fmt.Errorf("service entry controller not initialized for cluster=%s", clusterId)
+		}
+		serviceEntries, err := rc.ServiceEntryController.IstioClient.NetworkingV1alpha3().ServiceEntries(common.GetSyncNamespace()).List(v12.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list service entries in cluster=%s: %v", clusterId, err)
+		}
+		for _, serviceEntry := range serviceEntries.Items {
+			serviceEntryNames[serviceEntry.Name] = true
+		}
+	}
+	return serviceEntryNames, nil
+}
+
+//Updates the unused marks of the address store and removes the addresses unused for longer than the grace period
+//Returns the released service entry names and whether the store was modified
+func markAndReleaseUnusedAddresses(addressStore *ServiceEntryAddressStore, existingServiceEntries map[string]bool,
+	gracePeriod time.Duration, now time.Time) ([]string, bool) {
+	if addressStore.UnusedSince == nil {
+		addressStore.UnusedSince = make(map[string]string)
+	}
+	changed := false
+	released := make([]string, 0)
+	releasedAddresses := make(map[string]bool)
+
+	for seName, address := range addressStore.EntryAddresses {
+		unusedSince, marked := addressStore.UnusedSince[seName]
+		if existingServiceEntries[seName] {
+			if marked {
+				delete(addressStore.UnusedSince, seName)
+				changed = true
+			}
+			continue
+		}
+		if !marked {
+			addressStore.UnusedSince[seName] = now.UTC().Format(time.RFC3339)
+			changed = true
+			continue
+		}
+		since, err := time.Parse(time.RFC3339, unusedSince)
+		if err != nil {
+			log.Warnf(LogErrFormat, "Reclaim", "ServiceEntryAddress", seName, "", err)
+			addressStore.UnusedSince[seName] = now.UTC().Format(time.RFC3339)
+			changed = true
+			continue
+		}
+		if now.Sub(since) >= gracePeriod {
+			delete(addressStore.EntryAddresses, seName)
+			delete(addressStore.UnusedSince, seName)
+			releasedAddresses[address] = true
+			released = append(released, seName)
+			changed = true
+		}
+	}
+
+	//drop the marks of entries that are gone already
+	for seName := range addressStore.UnusedSince {
+		if _, ok := addressStore.EntryAddresses[seName]; !ok {
+			delete(addressStore.UnusedSince, seName)
+			changed = true
+		}
+	}
+
+	if len(releasedAddresses) > 0 {
+		addresses := make([]string, 0, len(addressStore.Addresses))
+		for _, address := range addressStore.Addresses {
+			if !releasedAddresses[address] {
+				addresses = append(addresses, address)
+			}
+		}
+		addressStore.Addresses = addresses
+	}
+	return released, changed
+}
+
+func getServiceEntryAddressPoolUsage(addressStore *ServiceEntryAddressStore) ServiceEntryAddressPoolUsage {
+	usage := ServiceEntryAddressPoolUsage{Capacity: ServiceEntryAddressPoolSize}
+	if addressStore == nil {
+		usage.Free = usage.Capacity
+		return usage
+	}
+	usage.Used = len(addressStore.Addresses)
+	usage.Unused = len(addressStore.UnusedSince)
+	usage.Free = usage.Capacity - usage.Used
+	if usage.Free < 0 {
+		usage.Free = 0
+	}
+	return usage
+}
+
+func reportServiceEntryAddressPoolUsage(usage ServiceEntryAddressPoolUsage) {
+	common.SeAddressPool.With(common.SeAddressUsedLabelValue).Set(float64(usage.Used))
+	common.SeAddressPool.With(common.SeAddressUnusedLabelValue).Set(float64(usage.Unused))
+	common.SeAddressPool.With(common.SeAddressFreeLabelValue).Set(float64(usage.Free))
+}
+
+//GetServiceEntryAddressPoolUsage returns the usage of the service entry address pool as currently known by Admiral
+func GetServiceEntryAddressPoolUsage(remoteRegistry *RemoteRegistry) ServiceEntryAddressPoolUsage {
+	if remoteRegistry.AdmiralCache == nil {
+		return getServiceEntryAddressPoolUsage(nil)
+	}
+	return getServiceEntryAddressPoolUsage(getAddressAllocator(remoteRegistry.AdmiralCache).GetAddressStore())
+}
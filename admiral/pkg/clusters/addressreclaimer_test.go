@@ -0,0 +1,225 @@
+package clusters
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/common"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/istio"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/test"
+	istionetworkingv1alpha3 "istio.io/api/networking/v1alpha3"
+	"istio.io/client-go/pkg/apis/networking/v1alpha3"
+	istiofake "istio.io/client-go/pkg/clientset/versioned/fake"
+	v12 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetFreeServiceEntryAddress(t *testing.T) {
+	fullPool := make([]string, 0, ServiceEntryAddressPoolSize)
+	for secondIndex := seAddressFirstSubnet; secondIndex <= seAddressLastSubnet; secondIndex++ {
+		for firstIndex := 1; firstIndex <= seAddressHostsPerSubnet; firstIndex++ {
+			fullPool = append(fullPool, common.LocalAddressPrefix+"."+strconv.Itoa(secondIndex)+"."+strconv.Itoa(firstIndex))
+		}
+	}
+
+	testCases := []struct {
+		name            string
+		addresses       []string
+		expectedAddress string
+		expectedErr     bool
+	}{
+		{
+			name:            "Given no addresses in use, should return the first address of the pool",
+			addresses:       []string{},
+			expectedAddress: common.LocalAddressPrefix + ".10.1",
+		},
+		{
+			name:            "Given a released address, should reuse it",
+			addresses:       []string{common.LocalAddressPrefix + ".10.1", common.LocalAddressPrefix + ".10.3"},
+			expectedAddress: common.LocalAddressPrefix + ".10.2",
+		},
+		{
+			name:            "Given a full subnet, should return the first address of the next subnet",
+			addresses:       fullPool[:seAddressHostsPerSubnet],
+			expectedAddress: common.LocalAddressPrefix + ".11.1",
+		},
+		{
+			name:        "Given an exhausted pool, should return an error",
+			addresses:   fullPool,
+			expectedErr: true,
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			address, err := getFreeServiceEntryAddress(c.addresses)
+			if c.expectedErr != (err != nil) {
+				t.Errorf("Unexpected error %v", err)
+			}
+			if address != c.expectedAddress {
+				t.Errorf("Expected address %v, got %v", c.expectedAddress, address)
+			}
+		})
+	}
+}
+
+func TestMarkAndReleaseUnusedAddresses(t *testing.T) {
+	now := time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)
+	gracePeriod := 24 * time.Hour
+
+	testCases := []struct {
+		name             string
+		addressStore     ServiceEntryAddressStore
+		existing         map[string]bool
+		expectedStore    ServiceEntryAddressStore
+		expectedReleased []string
+		expectedChanged  bool
+	}{
+		{
+			name: "Given all service entries exist, should leave the store untouched",
+			addressStore: ServiceEntryAddressStore{
+				EntryAddresses: map[string]string{"a-se": "240.0.10.1"},
+				Addresses:      []string{"240.0.10.1"},
+			},
+			existing: map[string]bool{"a-se": true},
+			expectedStore: ServiceEntryAddressStore{
+				EntryAddresses: map[string]string{"a-se": "240.0.10.1"},
+				Addresses:      []string{"240.0.10.1"},
+				UnusedSince:    map[string]string{},
+			},
+			expectedReleased: []string{},
+		},
+		{
+			name: "Given a missing service entry, should mark its address as unused",
+			addressStore: ServiceEntryAddressStore{
+				EntryAddresses: map[string]string{"a-se": "240.0.10.1", "b-se": "240.0.10.2"},
+				Addresses:      []string{"240.0.10.1", "240.0.10.2"},
+			},
+			existing: map[string]bool{"a-se": true},
+			expectedStore: ServiceEntryAddressStore{
+				EntryAddresses: map[string]string{"a-se": "240.0.10.1", "b-se": "240.0.10.2"},
+				Addresses:      []string{"240.0.10.1", "240.0.10.2"},
+				UnusedSince:    map[string]string{"b-se": "2021-01-02T00:00:00Z"},
+			},
+			expectedReleased: []string{},
+			expectedChanged:  true,
+		},
+		{
+			name: "Given an address unused for less than the grace period, should keep it",
+			addressStore: ServiceEntryAddressStore{
+				EntryAddresses: map[string]string{"b-se": "240.0.10.2"},
+				Addresses:      []string{"240.0.10.2"},
+				UnusedSince:    map[string]string{"b-se": "2021-01-01T12:00:00Z"},
+			},
+			existing: map[string]bool{},
+			expectedStore: ServiceEntryAddressStore{
+				EntryAddresses: map[string]string{"b-se": "240.0.10.2"},
+				Addresses:      []string{"240.0.10.2"},
+				UnusedSince:    map[string]string{"b-se": "2021-01-01T12:00:00Z"},
+			},
+			expectedReleased: []string{},
+		},
+		{
+			name: "Given an address unused for longer than the grace period, should release it",
+			addressStore: ServiceEntryAddressStore{
+				EntryAddresses: map[string]string{"a-se": "240.0.10.1", "b-se": "240.0.10.2"},
+				Addresses:      []string{"240.0.10.1", "240.0.10.2"},
+				UnusedSince:    map[string]string{"b-se": "2021-01-01T00:00:00Z"},
+			},
+			existing: map[string]bool{"a-se": true},
+			expectedStore: ServiceEntryAddressStore{
+				EntryAddresses: map[string]string{"a-se": "240.0.10.1"},
+				Addresses:      []string{"240.0.10.1"},
+				UnusedSince:    map[string]string{},
+			},
+			expectedReleased: []string{"b-se"},
+			expectedChanged:  true,
+		},
+		{
+			name: "Given a service entry that came back, should remove its unused mark",
+			addressStore: ServiceEntryAddressStore{
+				EntryAddresses: map[string]string{"b-se": "240.0.10.2"},
+				Addresses:      []string{"240.0.10.2"},
+				UnusedSince:    map[string]string{"b-se": "2021-01-01T00:00:00Z"},
+			},
+			existing: map[string]bool{"b-se": true},
+			expectedStore: ServiceEntryAddressStore{
+				EntryAddresses: map[string]string{"b-se": "240.0.10.2"},
+				Addresses:      []string{"240.0.10.2"},
+				UnusedSince:    map[string]string{},
+			},
+			expectedReleased: []string{},
+			expectedChanged:  true,
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			released, changed := markAndReleaseUnusedAddresses(&c.addressStore, c.existing, gracePeriod, now)
+			if !reflect.DeepEqual(c.addressStore, c.expectedStore) {
+				t.Errorf("Unexpected address store, got %v expected %v", c.addressStore, c.expectedStore)
+			}
+			if !reflect.DeepEqual(released, c.expectedReleased) {
+				t.Errorf("Unexpected released service entries, got %v expected %v", released, c.expectedReleased)
+			}
+			if changed != c.expectedChanged {
+				t.Errorf("Expected changed=%v, got %v", c.expectedChanged, changed)
+			}
+		})
+	}
+}
+
+func TestReclaimServiceEntryAddresses(t *testing.T) {
+	common.InitializeConfig(common.AdmiralParams{SyncNamespace: "ns"})
+
+	addressStore := ServiceEntryAddressStore{
+		EntryAddresses: map[string]string{"a-se": "240.0.10.1", "b-se": "240.0.10.2"},
+		Addresses:      []string{"240.0.10.1", "240.0.10.2"},
+		UnusedSince:    map[string]string{"b-se": "2021-01-01T00:00:00Z"},
+	}
+	cacheController := &test.FakeConfigMapController{
+		ConfigmapToReturn: buildFakeConfigMapFromAddressStore(&addressStore, "123"),
+	}
+
+	fakeIstioClient := istiofake.NewSimpleClientset()
+	fakeIstioClient.NetworkingV1alpha3().ServiceEntries(common.GetSyncNamespace()).Create(&v1alpha3.ServiceEntry{
+		ObjectMeta: v12.ObjectMeta{Name: "a-se", Namespace: common.GetSyncNamespace()},
+		Spec:       istionetworkingv1alpha3.ServiceEntry{Hosts: []string{"a"}},
+	})
+
+	rr := &RemoteRegistry{RemoteControllers: map[string]*RemoteController{}}
+	rr.StartTime = time.Now().Add(-time.Hour * 24)
+	rr.AdmiralCache = &AdmiralCache{
+		ServiceEntryAddressStore: &ServiceEntryAddressStore{},
+		ConfigMapController:      cacheController,
+	}
+
+	err := ReclaimServiceEntryAddresses(rr, time.Hour, time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC))
+	if err == nil {
+		t.Errorf("Expected an error when no clusters are monitored")
+	}
+
+	rr.RemoteControllers["cluster1"] = &RemoteController{
+		ServiceEntryController: &istio.ServiceEntryController{IstioClient: fakeIstioClient},
+	}
+
+	err = ReclaimServiceEntryAddresses(rr, time.Hour, time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	expectedStore := ServiceEntryAddressStore{
+		EntryAddresses: map[string]string{"a-se": "240.0.10.1"},
+		Addresses:      []string{"240.0.10.1"},
+	}
+	if !reflect.DeepEqual(*rr.AdmiralCache.ServiceEntryAddressStore, expectedStore) {
+		t.Errorf("Unexpected address store, got %v expected %v", *rr.AdmiralCache.ServiceEntryAddressStore, expectedStore)
+	}
+
+	usage := GetServiceEntryAddressPoolUsage(rr)
+	expectedUsage := ServiceEntryAddressPoolUsage{Capacity: ServiceEntryAddressPoolSize, Used: 1, Unused: 0, Free: ServiceEntryAddressPoolSize - 1}
+	if usage != expectedUsage {
+		t.Errorf("Unexpected pool usage, got %v expected %v", usage, expectedUsage)
+	}
+}
@@ -0,0 +1,225 @@
+package clusters
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/common"
+	log "github.com/sirupsen/logrus"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const (
+	clusterWriteBackoffBase = time.Second
+	clusterWriteBackoffMax  = 2 * time.Minute
+)
+
+//ClusterWriter writes the config of a sync to the clusters concurrently, so that a slow or unreachable cluster only delays its own updates.
+//A cluster whose writes time out or fail because it is unavailable is backed off, its writes are skipped until the backoff expires and
+//the writes that timed out are done, so that they can't overwrite newer ones. The identities whose writes were skipped or failed are
+//requeued once the cluster is written to again
+type ClusterWriter struct {
+	//clusters written to at the same time by a sync
+	parallelism int
+	//how long a sync waits for the writes to a cluster, 0 waits until they are done
+	timeout time.Duration
+	//syncs the identity in env again, the requeued identities are dropped when not set
+	Requeue func(env string, identity string)
+	mutex   sync.Mutex
+	//key=cluster id
+	backoffs map[string]*clusterBackoff
+}
+
+type clusterBackoff struct {
+	failures int
+	until    time.Time
+	//writes still running after the sync stopped waiting for them
+	inFlight int
+	//the identities to sync again once the cluster is written to again
+	retries map[identityKey]bool
+}
+
+func NewClusterWriter(parallelism int, timeout time.Duration) *ClusterWriter {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	return &ClusterWriter{parallelism: parallelism, timeout: timeout, backoffs: make(map[string]*clusterBackoff)}
+}
+
+//Write calls write for every cluster and returns the errors by cluster, the identities are the ones of the written config.
+//A nil writer writes to the clusters one after the other
+func (w *ClusterWriter) Write(identities []identityKey, clusters []string, write func(clusterId string) error) map[string]error {
+	clusterErrors := make(map[string]error)
+	if w == nil {
+		for _, clusterId := range clusters {
+			if err := write(clusterId); err != nil {
+				clusterErrors[clusterId] = err
+			}
+		}
+		return clusterErrors
+	}
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, w.parallelism)
+	for _, clusterId := range clusters {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(clusterId string) {
+			defer wg.Done()
+			err := w.writeToCluster(clusterId, identities, slots, write)
+			if err != nil {
+				mutex.Lock()
+				clusterErrors[clusterId] = err
+				mutex.Unlock()
+			}
+		}(clusterId)
+	}
+	wg.Wait()
+	return clusterErrors
+}
+
+//Holds a slot until the writes are done, even when the sync stopped waiting for them
+func (w *ClusterWriter) writeToCluster(clusterId string, identities []identityKey, slots chan struct{}, write func(clusterId string) error) error {
+	if w.skipBackedOff(clusterId, identities) {
+		<-slots
+		common.ClusterWriteErrors.With(clusterId).Inc()
+		return fmt.Errorf("writes to cluster=%s skipped while it is backed off after previous failures", clusterId)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() { <-slots }()
+		start := time.Now()
+		err := write(clusterId)
+		common.ClusterWriteDuration.With(clusterId).Observe(time.Since(start).Seconds())
+		done <- err
+	}()
+
+	var err error
+	if w.timeout > 0 {
+		select {
+		case err = <-done:
+		case <-time.After(w.timeout):
+			err = k8sErrors.NewTimeoutError(fmt.Sprintf("writes to cluster=%s didn't complete within %v", clusterId, w.timeout), 0)
+			w.waitForTimedOutWrite(clusterId, done)
+		}
+	} else {
+		err = <-done
+	}
+
+	if err != nil {
+		common.ClusterWriteErrors.With(clusterId).Inc()
+	}
+	w.updateBackoff(clusterId, identities, err)
+	return err
+}
+
+//Returns true if the writes to the cluster must be skipped, the identities are then synced again once the cluster is written to again
+func (w *ClusterWriter) skipBackedOff(clusterId string, identities []identityKey) bool {
+	defer w.mutex.Unlock()
+	w.mutex.Lock()
+	backoff, ok := w.backoffs[clusterId]
+	if !ok || (backoff.inFlight == 0 && !time.Now().Before(backoff.until)) {
+		return false
+	}
+	backoff.addRetries(identities)
+	return true
+}
+
+//The cluster stays backed off until the write that timed out is done
+func (w *ClusterWriter) waitForTimedOutWrite(clusterId string, done chan error) {
+	w.mutex.Lock()
+	w.getOrCreateBackoff(clusterId).inFlight++
+	w.mutex.Unlock()
+	go func() {
+		<-done
+		w.mutex.Lock()
+		w.backoffs[clusterId].inFlight--
+		w.mutex.Unlock()
+		w.retry(clusterId)
+	}()
+}
+
+//Only the errors showing that the cluster is unavailable back it off, a rejected object doesn't
+func (w *ClusterWriter) updateBackoff(clusterId string, identities []identityKey, err error) {
+	w.mutex.Lock()
+	if err == nil || !isClusterUnavailableError(err) {
+		backoff, ok := w.backoffs[clusterId]
+		if !ok {
+			w.mutex.Unlock()
+			return
+		}
+		backoff.failures = 0
+		backoff.until = time.Time{}
+		w.mutex.Unlock()
+		w.retry(clusterId)
+		return
+	}
+	backoff := w.getOrCreateBackoff(clusterId)
+	backoff.addRetries(identities)
+	backoff.failures++
+	delay := clusterWriteBackoffMax
+	if backoff.failures < 8 {
+		delay = clusterWriteBackoffBase << uint(backoff.failures-1)
+	}
+	if delay > clusterWriteBackoffMax {
+		delay = clusterWriteBackoffMax
+	}
+	backoff.until = time.Now().Add(delay)
+	w.mutex.Unlock()
+	log.Warnf(LogErrFormat, "Write", "", "", clusterId, fmt.Sprintf("cluster backed off for %v: %v", delay, err))
+	time.AfterFunc(delay, func() { w.retry(clusterId) })
+}
+
+//Requeues the identities whose writes to the cluster were skipped or failed, once the cluster isn't backed off anymore
+func (w *ClusterWriter) retry(clusterId string) {
+	w.mutex.Lock()
+	backoff, ok := w.backoffs[clusterId]
+	if !ok || backoff.inFlight > 0 || time.Now().Before(backoff.until) {
+		w.mutex.Unlock()
+		return
+	}
+	retries := backoff.retries
+	backoff.retries = nil
+	//the failures are kept until a write succeeds, so that the next backoff is longer
+	if backoff.failures == 0 {
+		delete(w.backoffs, clusterId)
+	}
+	w.mutex.Unlock()
+
+	if w.Requeue == nil {
+		return
+	}
+	for key := range retries {
+		log.Infof(LogFormat, "Requeue", "identity", key.identity, clusterId, "Writes skipped while the cluster was backed off, env="+key.env)
+		w.Requeue(key.env, key.identity)
+	}
+}
+
+func (w *ClusterWriter) getOrCreateBackoff(clusterId string) *clusterBackoff {
+	backoff, ok := w.backoffs[clusterId]
+	if !ok {
+		backoff = &clusterBackoff{}
+		w.backoffs[clusterId] = backoff
+	}
+	return backoff
+}
+
+func (b *clusterBackoff) addRetries(identities []identityKey) {
+	if b.retries == nil {
+		b.retries = make(map[identityKey]bool)
+	}
+	for _, key := range identities {
+		b.retries[key] = true
+	}
+}
+
+//Returns true for the errors that don't come from the API server rejecting a request, e.g. connection errors, and the timeouts
+func isClusterUnavailableError(err error) bool {
+	if _, ok := err.(k8sErrors.APIStatus); !ok {
+		return true
+	}
+	return k8sErrors.IsTimeout(err) || k8sErrors.IsServerTimeout(err) || k8sErrors.IsTooManyRequests(err) || k8sErrors.IsServiceUnavailable(err)
+}
@@ -0,0 +1,169 @@
+package clusters
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/common"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestClusterWriter_Write(t *testing.T) {
+	common.InitializeConfig(common.AdmiralParams{SyncNamespace: "ns"})
+	writer := NewClusterWriter(2, 50*time.Millisecond)
+
+	var mutex sync.Mutex
+	var running, maxRunning int
+	calls := make(map[string]int)
+	write := func(clusterId string) error {
+		mutex.Lock()
+		calls[clusterId]++
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mutex.Unlock()
+		defer func() {
+			mutex.Lock()
+			running--
+			mutex.Unlock()
+		}()
+		switch clusterId {
+		case "slow":
+			time.Sleep(200 * time.Millisecond)
+		case "rejecting":
+			return k8sErrors.NewBadRequest("invalid service entry")
+		default:
+			time.Sleep(10 * time.Millisecond)
+		}
+		return nil
+	}
+
+	clusterErrors := writer.Write(nil, []string{"cluster1", "cluster2", "cluster3", "slow", "rejecting"}, write)
+	if len(clusterErrors) != 2 || clusterErrors["slow"] == nil || clusterErrors["rejecting"] == nil {
+		t.Errorf("Expected errors for the slow and rejecting clusters only, got %v", clusterErrors)
+	}
+	if maxRunning > 2 {
+		t.Errorf("Expected at most 2 clusters to be written at the same time, got %v", maxRunning)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	clusterErrors = writer.Write(nil, []string{"cluster1", "slow", "rejecting"}, write)
+	if calls["slow"] != 1 {
+		t.Errorf("Expected the writes to the slow cluster to be skipped while it is backed off, got %v calls", calls["slow"])
+	}
+	if clusterErrors["slow"] == nil {
+		t.Errorf("Expected the skipped writes to be reported as an error")
+	}
+	if calls["rejecting"] != 2 {
+		t.Errorf("Expected a cluster rejecting an object not to be backed off, got %v calls", calls["rejecting"])
+	}
+	if clusterErrors["cluster1"] != nil || calls["cluster1"] != 2 {
+		t.Errorf("Expected the other clusters to be written, got %v calls and error %v", calls["cluster1"], clusterErrors["cluster1"])
+	}
+}
+
+func TestClusterWriter_Requeue(t *testing.T) {
+	writer := NewClusterWriter(1, 20*time.Millisecond)
+	var mutex sync.Mutex
+	requeued := make(map[identityKey]int)
+	writer.Requeue = func(env string, identity string) {
+		mutex.Lock()
+		requeued[identityKey{identity: identity, env: env}]++
+		mutex.Unlock()
+	}
+	release := make(chan struct{})
+	var writes int
+	write := func(clusterId string) error {
+		mutex.Lock()
+		writes++
+		mutex.Unlock()
+		<-release
+		return nil
+	}
+	a, b := identityKey{identity: "a", env: "stage"}, identityKey{identity: "b", env: "stage"}
+
+	if err := writer.Write([]identityKey{a}, []string{"slow"}, write)["slow"]; err == nil {
+		t.Fatalf("Expected the write to time out")
+	}
+	//the cluster stays backed off past the backoff while the write that timed out is running, so it can't overwrite a newer one
+	time.Sleep(clusterWriteBackoffBase + 100*time.Millisecond)
+	if err := writer.Write([]identityKey{b}, []string{"slow"}, write)["slow"]; err == nil {
+		t.Errorf("Expected the writes to be skipped while the write that timed out is running")
+	}
+	mutex.Lock()
+	if writes != 1 || len(requeued) != 0 {
+		t.Errorf("Expected a single write and nothing requeued yet, got %v writes and %v requeued", writes, requeued)
+	}
+	mutex.Unlock()
+
+	close(release)
+	time.Sleep(50 * time.Millisecond)
+	mutex.Lock()
+	defer mutex.Unlock()
+	if requeued[a] != 1 || requeued[b] != 1 {
+		t.Errorf("Expected the identities of the failed and skipped writes to be requeued once, got %v", requeued)
+	}
+}
+
+func TestClusterWriter_WriteWithoutWriter(t *testing.T) {
+	var writer *ClusterWriter
+	var order []string
+	clusterErrors := writer.Write(nil, []string{"cluster1", "cluster2"}, func(clusterId string) error {
+		order = append(order, clusterId)
+		if clusterId == "cluster2" {
+			return errors.New("failed")
+		}
+		return nil
+	})
+	if len(order) != 2 || order[0] != "cluster1" || order[1] != "cluster2" {
+		t.Errorf("Expected the clusters to be written one after the other, got %v", order)
+	}
+	if len(clusterErrors) != 1 || clusterErrors["cluster2"] == nil {
+		t.Errorf("Expected an error for cluster2 only, got %v", clusterErrors)
+	}
+}
+
+func TestIsClusterUnavailableError(t *testing.T) {
+	resource := schema.GroupResource{Group: "networking.istio.io", Resource: "serviceentries"}
+	testCases := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{
+			name:     "Given a connection error, should be unavailable",
+			err:      errors.New("dial tcp 10.0.0.1:443: connect: connection refused"),
+			expected: true,
+		},
+		{
+			name:     "Given a timeout, should be unavailable",
+			err:      k8sErrors.NewTimeoutError("timeout", 0),
+			expected: true,
+		},
+		{
+			name:     "Given a throttled request, should be unavailable",
+			err:      k8sErrors.NewTooManyRequests("throttled", 1),
+			expected: true,
+		},
+		{
+			name: "Given a missing object, should not be unavailable",
+			err:  k8sErrors.NewNotFound(resource, "a-se"),
+		},
+		{
+			name: "Given a conflict, should not be unavailable",
+			err:  k8sErrors.NewConflict(resource, "a-se", errors.New("conflict")),
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			if unavailable := isClusterUnavailableError(c.err); unavailable != c.expected {
+				t.Errorf("Expected %v, got %v", c.expected, unavailable)
+			}
+		})
+	}
+}
@@ -0,0 +1,376 @@
+package clusters
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/admiral"
+	log "github.com/sirupsen/logrus"
+	"istio.io/client-go/pkg/apis/networking/v1alpha3"
+	"istio.io/client-go/pkg/clientset/versioned"
+	networkingv1alpha3 "istio.io/client-go/pkg/clientset/versioned/typed/networking/v1alpha3"
+	k8sV1 "k8s.io/api/core/v1"
+	v12 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+const (
+	ChangeOpAdd    = "Add"
+	ChangeOpUpdate = "Update"
+	ChangeOpDelete = "Delete"
+)
+
+//Change is a write to a cluster that was recorded instead of sent to the API server in dry run mode
+type Change struct {
+	Op        string      `json:"op"`
+	Kind      string      `json:"kind"`
+	Namespace string      `json:"namespace"`
+	Name      string      `json:"name"`
+	Object    interface{} `json:"object,omitempty"`
+	Time      time.Time   `json:"time"`
+}
+
+//ChangeRecorder collects the changes of every cluster in dry run mode. Only the latest change of an object is kept, so the changes of a
+//cluster are the plan that would bring it to the config computed by Admiral
+type ChangeRecorder struct {
+	mutex sync.Mutex
+	//key=cluster id then kind/namespace/name
+	changes map[string]map[string]Change
+}
+
+func NewChangeRecorder() *ChangeRecorder {
+	return &ChangeRecorder{changes: make(map[string]map[string]Change)}
+}
+
+func (r *ChangeRecorder) Record(clusterId string, change Change) {
+	change.Time = time.Now()
+	log.Infof(LogFormat, change.Op, change.Kind, change.Name, clusterId, "Dry run, change recorded in namespace="+change.Namespace)
+
+	defer r.mutex.Unlock()
+	r.mutex.Lock()
+	if r.changes[clusterId] == nil {
+		r.changes[clusterId] = make(map[string]Change)
+	}
+	r.changes[clusterId][change.Kind+"/"+change.Namespace+"/"+change.Name] = change
+}
+
+//Drops the changes of a cluster that is no longer monitored
+func (r *ChangeRecorder) DeleteCluster(clusterId string) {
+	defer r.mutex.Unlock()
+	r.mutex.Lock()
+	delete(r.changes, clusterId)
+}
+
+//GetChangePlan returns the recorded changes of every cluster sorted by kind, namespace and name
+func (r *ChangeRecorder) GetChangePlan() map[string][]Change {
+	defer r.mutex.Unlock()
+	r.mutex.Lock()
+	plan := make(map[string][]Change, len(r.changes))
+	for clusterId, changes := range r.changes {
+		keys := make([]string, 0, len(changes))
+		for key := range changes {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		plan[clusterId] = make([]Change, 0, len(keys))
+		for _, key := range keys {
+			plan[clusterId] = append(plan[clusterId], changes[key])
+		}
+	}
+	return plan
+}
+
+//Sends the writes of the istio controllers of the cluster to the recorder, the reads still go to the cluster
+func enableDryRun(rc *RemoteController, recorder *ChangeRecorder) {
+	if rc.ServiceEntryController != nil {
+		rc.ServiceEntryController.IstioClient = NewDryRunIstioClient(rc.ServiceEntryController.IstioClient, rc.ClusterID, recorder)
+	}
+	if rc.DestinationRuleController != nil {
+		rc.DestinationRuleController.IstioClient = NewDryRunIstioClient(rc.DestinationRuleController.IstioClient, rc.ClusterID, recorder)
+	}
+	if rc.VirtualServiceController != nil {
+		rc.VirtualServiceController.IstioClient = NewDryRunIstioClient(rc.VirtualServiceController.IstioClient, rc.ClusterID, recorder)
+	}
+	if rc.SidecarController != nil {
+		rc.SidecarController.IstioClient = NewDryRunIstioClient(rc.SidecarController.IstioClient, rc.ClusterID, recorder)
+	}
+	//the services of the destination rule subsets
+	if rc.ServiceController != nil && rc.ServiceController.K8sClient != nil {
+		rc.ServiceController.K8sClient = NewDryRunK8sClient(rc.ServiceController.K8sClient, rc.ClusterID, recorder)
+	}
+}
+
+//NewDryRunIstioClient wraps the client so that the creates, updates and deletes of service entries, destination rules, virtual services
+//and sidecars are recorded instead of written
+func NewDryRunIstioClient(client versioned.Interface, clusterId string, recorder *ChangeRecorder) versioned.Interface {
+	return &dryRunIstioClient{Interface: client, clusterId: clusterId, recorder: recorder}
+}
+
+type dryRunIstioClient struct {
+	versioned.Interface
+	clusterId string
+	recorder  *ChangeRecorder
+}
+
+func (c *dryRunIstioClient) NetworkingV1alpha3() networkingv1alpha3.NetworkingV1alpha3Interface {
+	return &dryRunNetworkingClient{NetworkingV1alpha3Interface: c.Interface.NetworkingV1alpha3(), clusterId: c.clusterId, recorder: c.recorder}
+}
+
+type dryRunNetworkingClient struct {
+	networkingv1alpha3.NetworkingV1alpha3Interface
+	clusterId string
+	recorder  *ChangeRecorder
+}
+
+func (c *dryRunNetworkingClient) writer(kind string, namespace string) dryRunWriter {
+	return dryRunWriter{kind: kind, namespace: namespace, clusterId: c.clusterId, recorder: c.recorder}
+}
+
+func (c *dryRunNetworkingClient) ServiceEntries(namespace string) networkingv1alpha3.ServiceEntryInterface {
+	return &dryRunServiceEntries{ServiceEntryInterface: c.NetworkingV1alpha3Interface.ServiceEntries(namespace), dryRunWriter: c.writer("ServiceEntry", namespace)}
+}
+
+func (c *dryRunNetworkingClient) DestinationRules(namespace string) networkingv1alpha3.DestinationRuleInterface {
+	return &dryRunDestinationRules{DestinationRuleInterface: c.NetworkingV1alpha3Interface.DestinationRules(namespace), dryRunWriter: c.writer("DestinationRule", namespace)}
+}
+
+func (c *dryRunNetworkingClient) VirtualServices(namespace string) networkingv1alpha3.VirtualServiceInterface {
+	return &dryRunVirtualServices{VirtualServiceInterface: c.NetworkingV1alpha3Interface.VirtualServices(namespace), dryRunWriter: c.writer("VirtualService", namespace)}
+}
+
+func (c *dryRunNetworkingClient) Sidecars(namespace string) networkingv1alpha3.SidecarInterface {
+	return &dryRunSidecars{SidecarInterface: c.NetworkingV1alpha3Interface.Sidecars(namespace), dryRunWriter: c.writer("Sidecar", namespace)}
+}
+
+type dryRunWriter struct {
+	kind      string
+	namespace string
+	clusterId string
+	recorder  *ChangeRecorder
+}
+
+func (w dryRunWriter) record(op string, name string, obj interface{}) {
+	w.recorder.Record(w.clusterId, Change{Op: op, Kind: w.kind, Namespace: w.namespace, Name: name, Object: obj})
+}
+
+type dryRunServiceEntries struct {
+	networkingv1alpha3.ServiceEntryInterface
+	dryRunWriter
+}
+
+func (s *dryRunServiceEntries) Create(obj *v1alpha3.ServiceEntry) (*v1alpha3.ServiceEntry, error) {
+	s.record(ChangeOpAdd, obj.Name, obj.DeepCopy())
+	return obj, nil
+}
+
+func (s *dryRunServiceEntries) Update(obj *v1alpha3.ServiceEntry) (*v1alpha3.ServiceEntry, error) {
+	s.record(ChangeOpUpdate, obj.Name, obj.DeepCopy())
+	return obj, nil
+}
+
+//a missing object fails like it would in the cluster
+func (s *dryRunServiceEntries) Delete(name string, options *v12.DeleteOptions) error {
+	if _, err := s.Get(name, v12.GetOptions{}); err != nil {
+		return err
+	}
+	s.record(ChangeOpDelete, name, nil)
+	return nil
+}
+
+type dryRunDestinationRules struct {
+	networkingv1alpha3.DestinationRuleInterface
+	dryRunWriter
+}
+
+func (d *dryRunDestinationRules) Create(obj *v1alpha3.DestinationRule) (*v1alpha3.DestinationRule, error) {
+	d.record(ChangeOpAdd, obj.Name, obj.DeepCopy())
+	return obj, nil
+}
+
+func (d *dryRunDestinationRules) Update(obj *v1alpha3.DestinationRule) (*v1alpha3.DestinationRule, error) {
+	d.record(ChangeOpUpdate, obj.Name, obj.DeepCopy())
+	return obj, nil
+}
+
+func (d *dryRunDestinationRules) Delete(name string, options *v12.DeleteOptions) error {
+	if _, err := d.Get(name, v12.GetOptions{}); err != nil {
+		return err
+	}
+	d.record(ChangeOpDelete, name, nil)
+	return nil
+}
+
+type dryRunVirtualServices struct {
+	networkingv1alpha3.VirtualServiceInterface
+	dryRunWriter
+}
+
+func (v *dryRunVirtualServices) Create(obj *v1alpha3.VirtualService) (*v1alpha3.VirtualService, error) {
+	v.record(ChangeOpAdd, obj.Name, obj.DeepCopy())
+	return obj, nil
+}
+
+func (v *dryRunVirtualServices) Update(obj *v1alpha3.VirtualService) (*v1alpha3.VirtualService, error) {
+	v.record(ChangeOpUpdate, obj.Name, obj.DeepCopy())
+	return obj, nil
+}
+
+func (v *dryRunVirtualServices) Delete(name string, options *v12.DeleteOptions) error {
+	if _, err := v.Get(name, v12.GetOptions{}); err != nil {
+		return err
+	}
+	v.record(ChangeOpDelete, name, nil)
+	return nil
+}
+
+type dryRunSidecars struct {
+	networkingv1alpha3.SidecarInterface
+	dryRunWriter
+}
+
+func (s *dryRunSidecars) Create(obj *v1alpha3.Sidecar) (*v1alpha3.Sidecar, error) {
+	s.record(ChangeOpAdd, obj.Name, obj.DeepCopy())
+	return obj, nil
+}
+
+func (s *dryRunSidecars) Update(obj *v1alpha3.Sidecar) (*v1alpha3.Sidecar, error) {
+	s.record(ChangeOpUpdate, obj.Name, obj.DeepCopy())
+	return obj, nil
+}
+
+func (s *dryRunSidecars) Delete(name string, options *v12.DeleteOptions) error {
+	if _, err := s.Get(name, v12.GetOptions{}); err != nil {
+		return err
+	}
+	s.record(ChangeOpDelete, name, nil)
+	return nil
+}
+
+//NewDryRunK8sClient wraps the client so that the creates, updates and deletes of services are recorded instead of written
+func NewDryRunK8sClient(client kubernetes.Interface, clusterId string, recorder *ChangeRecorder) kubernetes.Interface {
+	return &dryRunK8sClient{Interface: client, clusterId: clusterId, recorder: recorder}
+}
+
+type dryRunK8sClient struct {
+	kubernetes.Interface
+	clusterId string
+	recorder  *ChangeRecorder
+}
+
+func (c *dryRunK8sClient) CoreV1() corev1.CoreV1Interface {
+	return &dryRunCoreClient{CoreV1Interface: c.Interface.CoreV1(), clusterId: c.clusterId, recorder: c.recorder}
+}
+
+type dryRunCoreClient struct {
+	corev1.CoreV1Interface
+	clusterId string
+	recorder  *ChangeRecorder
+}
+
+func (c *dryRunCoreClient) Services(namespace string) corev1.ServiceInterface {
+	return &dryRunServices{
+		ServiceInterface: c.CoreV1Interface.Services(namespace),
+		dryRunWriter:     dryRunWriter{kind: "Service", namespace: namespace, clusterId: c.clusterId, recorder: c.recorder},
+	}
+}
+
+type dryRunServices struct {
+	corev1.ServiceInterface
+	dryRunWriter
+}
+
+func (s *dryRunServices) Create(obj *k8sV1.Service) (*k8sV1.Service, error) {
+	s.record(ChangeOpAdd, obj.Name, obj.DeepCopy())
+	return obj, nil
+}
+
+func (s *dryRunServices) Update(obj *k8sV1.Service) (*k8sV1.Service, error) {
+	s.record(ChangeOpUpdate, obj.Name, obj.DeepCopy())
+	return obj, nil
+}
+
+func (s *dryRunServices) Delete(name string, options *v12.DeleteOptions) error {
+	if _, err := s.Get(name, v12.GetOptions{}); err != nil {
+		return err
+	}
+	s.record(ChangeOpDelete, name, nil)
+	return nil
+}
+
+//dryRunAddressAllocator hands out the addresses already allocated by the wrapped allocator and keeps the new ones in memory, so that
+//a dry run never takes addresses from the shared pool
+type dryRunAddressAllocator struct {
+	allocator ServiceEntryAddressAllocator
+	mutex     sync.Mutex
+	//addresses allocated by the dry run, key=service entry name
+	entryAddresses map[string]string
+}
+
+func newDryRunAddressAllocator(allocator ServiceEntryAddressAllocator) ServiceEntryAddressAllocator {
+	return &dryRunAddressAllocator{allocator: allocator, entryAddresses: make(map[string]string)}
+}
+
+func (a *dryRunAddressAllocator) GetAddress(seName string) (string, bool, error) {
+	defer a.mutex.Unlock()
+	a.mutex.Lock()
+	addressStore := a.getAddressStore()
+	if address, ok := addressStore.EntryAddresses[seName]; ok {
+		return address, false, nil
+	}
+	address, err := getFreeServiceEntryAddress(addressStore.Addresses)
+	if err != nil {
+		return "", false, err
+	}
+	a.entryAddresses[seName] = address
+	log.Infof(LogFormat, ChangeOpAdd, "ServiceEntryAddress", seName, "", "Dry run, address "+address+" allocated in memory")
+	return address, false, nil
+}
+
+func (a *dryRunAddressAllocator) GetAddressStore() *ServiceEntryAddressStore {
+	defer a.mutex.Unlock()
+	a.mutex.Lock()
+	return a.getAddressStore()
+}
+
+//the addresses of the wrapped allocator with the ones allocated by the dry run
+func (a *dryRunAddressAllocator) getAddressStore() *ServiceEntryAddressStore {
+	source := a.allocator.GetAddressStore()
+	addressStore := &ServiceEntryAddressStore{
+		EntryAddresses: make(map[string]string, len(source.EntryAddresses)+len(a.entryAddresses)),
+		Addresses:      append([]string{}, source.Addresses...),
+		UnusedSince:    make(map[string]string, len(source.UnusedSince)),
+	}
+	for seName, address := range source.EntryAddresses {
+		addressStore.EntryAddresses[seName] = address
+	}
+	for seName, unusedSince := range source.UnusedSince {
+		addressStore.UnusedSince[seName] = unusedSince
+	}
+	for seName, address := range a.entryAddresses {
+		if _, ok := addressStore.EntryAddresses[seName]; !ok {
+			addressStore.EntryAddresses[seName] = address
+			addressStore.Addresses = append(addressStore.Addresses, address)
+		}
+	}
+	return addressStore
+}
+
+//The update is applied to a copy of the addresses and discarded
+func (a *dryRunAddressAllocator) UpdateAddressStore(update func(addressStore *ServiceEntryAddressStore) bool) error {
+	if update(a.GetAddressStore()) {
+		log.Infof(LogFormat, ChangeOpUpdate, "ServiceEntryAddress", "", "", "Dry run, address store update discarded")
+	}
+	return nil
+}
+
+//dryRunConfigMapController never writes the configmap, for the code paths that update the address configmap directly
+type dryRunConfigMapController struct {
+	admiral.ConfigMapControllerInterface
+}
+
+func (c *dryRunConfigMapController) PutConfigMap(newMap *k8sV1.ConfigMap) error {
+	log.Infof(LogFormat, ChangeOpUpdate, "ConfigMap", newMap.Name, "", "Dry run, configmap update discarded")
+	return nil
+}
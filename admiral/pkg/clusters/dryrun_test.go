@@ -0,0 +1,161 @@
+package clusters
+
+import (
+	"reflect"
+	"testing"
+
+	istionetworkingv1alpha3 "istio.io/api/networking/v1alpha3"
+	"istio.io/client-go/pkg/apis/networking/v1alpha3"
+	istiofake "istio.io/client-go/pkg/clientset/versioned/fake"
+	k8sV1 "k8s.io/api/core/v1"
+	v12 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sFake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestDryRunIstioClient(t *testing.T) {
+	fakeIstioClient := istiofake.NewSimpleClientset()
+	fakeIstioClient.NetworkingV1alpha3().ServiceEntries("ns").Create(&v1alpha3.ServiceEntry{
+		ObjectMeta: v12.ObjectMeta{Name: "a-se", Namespace: "ns"},
+		Spec:       istionetworkingv1alpha3.ServiceEntry{Hosts: []string{"a"}},
+	})
+	recorder := NewChangeRecorder()
+	client := NewDryRunIstioClient(fakeIstioClient, "cluster1", recorder)
+
+	serviceEntries := client.NetworkingV1alpha3().ServiceEntries("ns")
+	if _, err := serviceEntries.Create(&v1alpha3.ServiceEntry{
+		ObjectMeta: v12.ObjectMeta{Name: "b-se", Namespace: "ns"},
+		Spec:       istionetworkingv1alpha3.ServiceEntry{Hosts: []string{"b"}},
+	}); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	exist, err := serviceEntries.Get("a-se", v12.GetOptions{})
+	if err != nil {
+		t.Fatalf("Reads should go to the cluster, err: %v", err)
+	}
+	exist.Spec.Hosts = []string{"a", "c"}
+	if _, err := serviceEntries.Update(exist); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	if err := serviceEntries.Delete("a-se", &v12.DeleteOptions{}); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	if err := serviceEntries.Delete("c-se", &v12.DeleteOptions{}); err == nil {
+		t.Errorf("Deleting a missing service entry should fail")
+	}
+	if err := client.NetworkingV1alpha3().DestinationRules("ns").Delete("a-dr", &v12.DeleteOptions{}); err == nil {
+		t.Errorf("Deleting a missing destination rule should fail")
+	}
+	client.NetworkingV1alpha3().VirtualServices("ns").Create(&v1alpha3.VirtualService{ObjectMeta: v12.ObjectMeta{Name: "a-vs", Namespace: "ns"}})
+
+	if _, err := fakeIstioClient.NetworkingV1alpha3().ServiceEntries("ns").Get("b-se", v12.GetOptions{}); err == nil {
+		t.Errorf("Service entry should not have been created in dry run mode")
+	}
+	if _, err := fakeIstioClient.NetworkingV1alpha3().ServiceEntries("ns").Get("a-se", v12.GetOptions{}); err != nil {
+		t.Errorf("Service entry should not have been deleted in dry run mode")
+	}
+
+	plan := recorder.GetChangePlan()
+	expectedChanges := []string{"Delete ServiceEntry a-se", "Add ServiceEntry b-se", "Add VirtualService a-vs"}
+	changes := make([]string, 0)
+	for _, change := range plan["cluster1"] {
+		changes = append(changes, change.Op+" "+change.Kind+" "+change.Name)
+	}
+	if !reflect.DeepEqual(changes, expectedChanges) {
+		t.Errorf("Unexpected change plan, got %v expected %v", changes, expectedChanges)
+	}
+
+	recorder.DeleteCluster("cluster1")
+	if len(recorder.GetChangePlan()) != 0 {
+		t.Errorf("Change plan of a deleted cluster should have been dropped")
+	}
+}
+
+func TestDryRunK8sClient(t *testing.T) {
+	fakeK8sClient := k8sFake.NewSimpleClientset(&k8sV1.Service{ObjectMeta: v12.ObjectMeta{Name: "a-v1", Namespace: "ns"}})
+	recorder := NewChangeRecorder()
+	client := NewDryRunK8sClient(fakeK8sClient, "cluster1", recorder)
+
+	services := client.CoreV1().Services("ns")
+	if _, err := services.Create(&k8sV1.Service{ObjectMeta: v12.ObjectMeta{Name: "b-v1", Namespace: "ns"}}); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	if err := services.Delete("a-v1", &v12.DeleteOptions{}); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	if err := services.Delete("c-v1", &v12.DeleteOptions{}); err == nil {
+		t.Errorf("Deleting a missing service should fail")
+	}
+
+	if _, err := fakeK8sClient.CoreV1().Services("ns").Get("b-v1", v12.GetOptions{}); err == nil {
+		t.Errorf("Service should not have been created in dry run mode")
+	}
+	if _, err := fakeK8sClient.CoreV1().Services("ns").Get("a-v1", v12.GetOptions{}); err != nil {
+		t.Errorf("Service should not have been deleted in dry run mode")
+	}
+	expectedChanges := []string{"Delete Service a-v1", "Add Service b-v1"}
+	changes := make([]string, 0)
+	for _, change := range recorder.GetChangePlan()["cluster1"] {
+		changes = append(changes, change.Op+" "+change.Kind+" "+change.Name)
+	}
+	if !reflect.DeepEqual(changes, expectedChanges) {
+		t.Errorf("Unexpected change plan, got %v expected %v", changes, expectedChanges)
+	}
+}
+
+func TestDryRunAddressAllocator(t *testing.T) {
+	admiralCache := &AdmiralCache{
+		ServiceEntryAddressStore: &ServiceEntryAddressStore{
+			EntryAddresses: map[string]string{"a-se": "240.0.10.1"},
+			Addresses:      []string{"240.0.10.1"},
+		},
+	}
+	allocator := newDryRunAddressAllocator(getAddressAllocator(admiralCache))
+
+	testCases := []struct {
+		name            string
+		seName          string
+		expectedAddress string
+	}{
+		{
+			name:            "Given a service entry with an allocated address, should return it",
+			seName:          "a-se",
+			expectedAddress: "240.0.10.1",
+		},
+		{
+			name:            "Given a new service entry, should allocate the lowest free address in memory",
+			seName:          "b-se",
+			expectedAddress: "240.0.10.2",
+		},
+		{
+			name:            "Given a service entry allocated by the dry run, should return the same address",
+			seName:          "b-se",
+			expectedAddress: "240.0.10.2",
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			address, updated, err := allocator.GetAddress(c.seName)
+			if err != nil {
+				t.Fatalf("Unexpected error %v", err)
+			}
+			if address != c.expectedAddress {
+				t.Errorf("Expected address %v, got %v", c.expectedAddress, address)
+			}
+			if updated {
+				t.Errorf("The address store should never be updated in dry run mode")
+			}
+		})
+	}
+
+	if _, ok := admiralCache.ServiceEntryAddressStore.EntryAddresses["b-se"]; ok {
+		t.Errorf("Address allocated by the dry run should not be saved")
+	}
+	allocator.UpdateAddressStore(func(addressStore *ServiceEntryAddressStore) bool {
+		delete(addressStore.EntryAddresses, "a-se")
+		return true
+	})
+	if _, ok := admiralCache.ServiceEntryAddressStore.EntryAddresses["a-se"]; !ok {
+		t.Errorf("Address store updates should be discarded in dry run mode")
+	}
+}
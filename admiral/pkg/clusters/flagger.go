@@ -0,0 +1,159 @@
+package clusters
+
+import (
+	flagger "github.com/istio-ecosystem/admiral/admiral/pkg/apis/flagger/v1beta1"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/admiral"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/common"
+	log "github.com/sirupsen/logrus"
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/client-go/pkg/apis/networking/v1alpha3"
+	k8sAppsV1 "k8s.io/api/apps/v1"
+	k8sV1 "k8s.io/api/core/v1"
+	v12 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type FlaggerHandler struct {
+	RemoteRegistry *RemoteRegistry
+	ClusterID      string
+}
+
+func (fh *FlaggerHandler) Added(obj *flagger.Canary) {
+	HandleEventForFlaggerCanary(admiral.Add, obj, fh.RemoteRegistry, fh.ClusterID)
+}
+
+func (fh *FlaggerHandler) Updated(obj *flagger.Canary) {
+	HandleEventForFlaggerCanary(admiral.Update, obj, fh.RemoteRegistry, fh.ClusterID)
+}
+
+func (fh *FlaggerHandler) Deleted(obj *flagger.Canary) {
+	HandleEventForFlaggerCanary(admiral.Delete, obj, fh.RemoteRegistry, fh.ClusterID)
+}
+
+//Syncs the deployment targeted by the canary, Flagger updates the status of the canary every time it shifts the traffic.
+//The deployment is synced on deletes as well, it's then routed to through its own service again
+func HandleEventForFlaggerCanary(event admiral.EventType, obj *flagger.Canary, remoteRegistry *RemoteRegistry, clusterName string) {
+	log.Infof(LogFormat, event, "flaggercanary", obj.Name, clusterName, "Received")
+	if obj.Spec.TargetRef.Kind != flagger.DeploymentKind {
+		log.Infof(LogFormat, "Event", "flaggercanary", obj.Name, clusterName, "Skipped as its target isn't a deployment, namespace="+obj.Namespace)
+		return
+	}
+	rc := remoteRegistry.RemoteControllers[clusterName]
+	if rc == nil || rc.DeploymentController == nil {
+		return
+	}
+	//the target is looked up along with the primary copy Flagger made of it, they share the identity of the target
+	deployment := rc.DeploymentController.Cache.GetDeploymentInNamespace(obj.Namespace, obj.Spec.TargetRef.Name)
+	if deployment == nil {
+		deployment = rc.DeploymentController.Cache.GetDeploymentInNamespace(obj.Namespace, obj.Spec.TargetRef.Name+flagger.PrimarySuffix)
+	}
+	if deployment == nil {
+		log.Infof(LogFormat, "Event", "flaggercanary", obj.Name, clusterName, "Skipped as its target isn't cached, namespace="+obj.Namespace)
+		return
+	}
+	HandleEventForDeployment(admiral.Update, deployment, remoteRegistry, clusterName)
+}
+
+//Returns the canary targeting the deployment, or the one the deployment is the primary copy of, nil if flagger is disabled
+func getFlaggerCanaryForDeployment(rc *RemoteController, deployment *k8sAppsV1.Deployment) *flagger.Canary {
+	if !common.GetFlaggerEnabled() || rc.FlaggerController == nil || deployment == nil {
+		return nil
+	}
+	canary := rc.FlaggerController.GetCanaryForWorkload(deployment.Namespace, deployment.Name)
+	if canary == nil || canary.Spec.TargetRef.Kind != flagger.DeploymentKind {
+		return nil
+	}
+	return canary
+}
+
+//Returns the primary and canary services Flagger generated for the canary, weighted as in its virtual service. The canary service is
+//left out until the analysis shifts traffic to it, returns nil until the primary service is generated
+func getServiceForFlaggerCanary(rc *RemoteController, canary *flagger.Canary) map[string]*WeightedService {
+	if canary == nil {
+		return nil
+	}
+	serviceName := canary.GetServiceName()
+	primaryName, canaryName := serviceName+flagger.PrimarySuffix, serviceName+flagger.CanarySuffix
+
+	var primaryService, canaryService *k8sV1.Service
+	for _, service := range rc.ServiceController.Cache.Get(canary.Namespace) {
+		switch service.Name {
+		case primaryName:
+			primaryService = service
+		case canaryName:
+			canaryService = service
+		}
+	}
+	if primaryService == nil {
+		return nil
+	}
+
+	weightedServices := map[string]*WeightedService{primaryName: {Weight: 1, Service: primaryService}}
+	weights := getFlaggerCanaryWeights(rc, canary, primaryName, canaryName)
+	if canaryService != nil && weights[canaryName] > 0 {
+		weightedServices[primaryName].Weight = weights[primaryName]
+		weightedServices[canaryName] = &WeightedService{Weight: weights[canaryName], Service: canaryService}
+	}
+	return weightedServices
+}
+
+//Returns the weights of the primary and canary services in the default route of the virtual service of the canary, named after its apex
+//service. Falls back to the canary weight in the status of the canary when the virtual service can't be read
+func getFlaggerCanaryWeights(rc *RemoteController, canary *flagger.Canary, primaryName string, canaryName string) map[string]int32 {
+	weights := map[string]int32{
+		primaryName: 100 - canary.Status.CanaryWeight,
+		canaryName:  canary.Status.CanaryWeight,
+	}
+	if rc.VirtualServiceController == nil || rc.VirtualServiceController.IstioClient == nil {
+		return weights
+	}
+	virtualService, err := rc.VirtualServiceController.IstioClient.NetworkingV1alpha3().VirtualServices(canary.Namespace).Get(canary.GetServiceName(), v12.GetOptions{})
+	if err != nil {
+		log.Warnf("Error fetching VirtualService of flagger canary with name=%s in namespace=%s and cluster=%s err=%v", canary.Name, canary.Namespace, rc.ClusterID, err)
+		return weights
+	}
+	httpRoute := getFlaggerDefaultRoute(virtualService)
+	if httpRoute == nil {
+		return weights
+	}
+	routeWeights := make(map[string]int32)
+	for _, route := range httpRoute.Route {
+		if route.Destination == nil {
+			continue
+		}
+		name, namespace := getLocalHostService(route.Destination.Host, canary.Namespace)
+		if namespace == canary.Namespace && (name == primaryName || name == canaryName) {
+			routeWeights[name] = route.Weight
+		}
+	}
+	if _, ok := routeWeights[primaryName]; !ok {
+		return weights
+	}
+	return routeWeights
+}
+
+//Returns the route without match conditions, the A/B testing routes of Flagger match the requests sent to the canary only
+func getFlaggerDefaultRoute(virtualService *v1alpha3.VirtualService) *networking.HTTPRoute {
+	for _, httpRoute := range virtualService.Spec.Http {
+		if len(httpRoute.Match) == 0 {
+			return httpRoute
+		}
+	}
+	return nil
+}
+
+//Syncs the deployments targeted by the canaries routed with the virtual service, like for the canary rollouts the weights of their
+//endpoints follow the ones of the virtual service
+func handleVirtualServiceEventForFlagger(obj *v1alpha3.VirtualService, vh *VirtualServiceHandler) {
+	if !common.GetFlaggerEnabled() {
+		return
+	}
+	rc := vh.RemoteRegistry.RemoteControllers[vh.ClusterID]
+	if rc == nil || rc.FlaggerController == nil {
+		return
+	}
+	for _, canary := range rc.FlaggerController.Cache.List(obj.Namespace) {
+		if canary.GetServiceName() == obj.Name {
+			HandleEventForFlaggerCanary(admiral.Update, canary, vh.RemoteRegistry, vh.ClusterID)
+		}
+	}
+}
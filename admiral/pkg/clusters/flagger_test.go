@@ -0,0 +1,224 @@
+package clusters
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/admiral"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/common"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/istio"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/test"
+	istionetworkingv1alpha3 "istio.io/api/networking/v1alpha3"
+	"istio.io/client-go/pkg/apis/networking/v1alpha3"
+	istiofake "istio.io/client-go/pkg/clientset/versioned/fake"
+	k8sAppsV1 "k8s.io/api/apps/v1"
+	k8sV1 "k8s.io/api/core/v1"
+	v12 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	k8sFake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+)
+
+func newFlaggerTestController(t *testing.T) (*RemoteController, *istiofake.Clientset) {
+	config := rest.Config{Host: "localhost"}
+	serviceController, err := admiral.NewServiceController("cluster1", make(chan struct{}), &test.MockServiceHandler{}, &config, time.Minute)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	flaggerController, err := admiral.NewFlaggerController("cluster1", make(chan struct{}), &test.MockFlaggerHandler{}, &config, time.Minute)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	//the services flagger generates for the greeting deployment
+	for name, app := range map[string]string{"greeting": "greeting-primary", "greeting-primary": "greeting-primary", "greeting-canary": "greeting"} {
+		serviceController.Cache.Put(&k8sV1.Service{
+			ObjectMeta: v12.ObjectMeta{Name: name, Namespace: "ns"},
+			Spec:       k8sV1.ServiceSpec{Selector: map[string]string{"app": app}, Ports: []k8sV1.ServicePort{{Name: "http", Port: 80}}},
+		})
+	}
+	flaggerController.Added(&unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "flagger.app/v1beta1",
+		"kind":       "Canary",
+		"metadata":   map[string]interface{}{"name": "greeting", "namespace": "ns"},
+		"spec": map[string]interface{}{
+			"targetRef": map[string]interface{}{"apiVersion": "apps/v1", "kind": "Deployment", "name": "greeting"},
+			"service":   map[string]interface{}{"port": int64(80)},
+		},
+		"status": map[string]interface{}{"canaryWeight": int64(30)},
+	}})
+	istioClient := istiofake.NewSimpleClientset()
+	return &RemoteController{
+		ClusterID:                "cluster1",
+		ServiceController:        serviceController,
+		FlaggerController:        flaggerController,
+		VirtualServiceController: &istio.VirtualServiceController{IstioClient: istioClient},
+	}, istioClient
+}
+
+func newFlaggerVirtualService(routes ...*istionetworkingv1alpha3.HTTPRoute) *v1alpha3.VirtualService {
+	return &v1alpha3.VirtualService{
+		ObjectMeta: v12.ObjectMeta{Name: "greeting", Namespace: "ns"},
+		Spec:       istionetworkingv1alpha3.VirtualService{Hosts: []string{"greeting"}, Http: routes},
+	}
+}
+
+func newFlaggerRoute(primaryWeight int32, canaryWeight int32) *istionetworkingv1alpha3.HTTPRoute {
+	return &istionetworkingv1alpha3.HTTPRoute{Route: []*istionetworkingv1alpha3.HTTPRouteDestination{
+		{Destination: &istionetworkingv1alpha3.Destination{Host: "greeting-primary"}, Weight: primaryWeight},
+		{Destination: &istionetworkingv1alpha3.Destination{Host: "greeting-canary.ns.svc.cluster.local"}, Weight: canaryWeight},
+	}}
+}
+
+func TestGetServiceForFlaggerCanary(t *testing.T) {
+	abRoute := newFlaggerRoute(0, 100)
+	abRoute.Match = []*istionetworkingv1alpha3.HTTPMatchRequest{{Headers: map[string]*istionetworkingv1alpha3.StringMatch{
+		"x-canary": {MatchType: &istionetworkingv1alpha3.StringMatch_Exact{Exact: "insider"}},
+	}}}
+
+	testCases := []struct {
+		name           string
+		virtualService *v1alpha3.VirtualService
+		expected       map[string]int32
+	}{
+		{
+			name:           "Given the traffic shifted to the canary, should weight the services as in the virtual service",
+			virtualService: newFlaggerVirtualService(newFlaggerRoute(80, 20)),
+			expected:       map[string]int32{"greeting-primary": 80, "greeting-canary": 20},
+		},
+		{
+			name:           "Given no traffic shifted to the canary, should return the primary service only",
+			virtualService: newFlaggerVirtualService(newFlaggerRoute(100, 0)),
+			expected:       map[string]int32{"greeting-primary": 1},
+		},
+		{
+			name:           "Given an A/B test, should weight the services as in the default route",
+			virtualService: newFlaggerVirtualService(abRoute, newFlaggerRoute(100, 0)),
+			expected:       map[string]int32{"greeting-primary": 1},
+		},
+		{
+			name:     "Given no virtual service, should weight the services as in the status of the canary",
+			expected: map[string]int32{"greeting-primary": 70, "greeting-canary": 30},
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			rc, istioClient := newFlaggerTestController(t)
+			if c.virtualService != nil {
+				istioClient.NetworkingV1alpha3().VirtualServices("ns").Create(c.virtualService)
+			}
+			deployment := &k8sAppsV1.Deployment{ObjectMeta: v12.ObjectMeta{Name: "greeting-primary", Namespace: "ns"}}
+			canary := getFlaggerCanaryForDeployment(rc, deployment)
+			if canary == nil {
+				t.Fatalf("Expected the canary of the primary deployment")
+			}
+			weightedServices := getServiceForFlaggerCanary(rc, canary)
+			if len(weightedServices) != len(c.expected) {
+				t.Fatalf("Expected %v, got %v", c.expected, weightedServices)
+			}
+			for name, weight := range c.expected {
+				if weightedServices[name] == nil || weightedServices[name].Weight != weight || weightedServices[name].Service.Name != name {
+					t.Errorf("Expected %v with weight %v, got %v", name, weight, weightedServices[name])
+				}
+			}
+		})
+	}
+}
+
+//Returns the registry of the flagger test controller with the deployment cached
+func newFlaggerTestRegistry(t *testing.T, rc *RemoteController, istioClient *istiofake.Clientset, deployment *k8sAppsV1.Deployment) *RemoteRegistry {
+	config := rest.Config{Host: "localhost"}
+	deploymentController, err := admiral.NewDeploymentController("cluster1", make(chan struct{}), &test.MockDeploymentHandler{}, &config, time.Minute)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	gtpController, err := admiral.NewGlobalTrafficController("cluster1", make(chan struct{}), &test.MockGlobalTrafficHandler{}, &config, time.Minute)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	rc.ServiceController.K8sClient = k8sFake.NewSimpleClientset()
+	rc.DeploymentController = deploymentController
+	rc.GlobalTraffic = gtpController
+	rc.ServiceEntryController = &istio.ServiceEntryController{IstioClient: istioClient}
+	rc.DestinationRuleController = &istio.DestinationRuleController{IstioClient: istioClient}
+	rc.NodeController = &admiral.NodeController{Locality: &admiral.Locality{Region: "us-west-2"}}
+	deploymentController.Cache.UpdateDeploymentToClusterCache("greeting", deployment)
+
+	return &RemoteRegistry{
+		RemoteControllers: map[string]*RemoteController{"cluster1": rc},
+		AdmiralCache: &AdmiralCache{
+			IdentityClusterCache:       common.NewMapOfMaps(),
+			CnameClusterCache:          common.NewMapOfMaps(),
+			CnameDependentClusterCache: common.NewMapOfMaps(),
+			IdentityDependencyCache:    common.NewMapOfMaps(),
+			CnameIdentityCache:         &sync.Map{},
+			CnameSubsetCache:           common.NewMapOfMaps(),
+			SeClusterCache:             common.NewMapOfMaps(),
+			GlobalTrafficCache:         &globalTrafficCache{},
+			DependencyNamespaceCache:   common.NewSidecarEgressMap(),
+			ServiceEntryAddressStore:   &ServiceEntryAddressStore{EntryAddresses: map[string]string{"stage.greeting.mesh-se": "240.0.10.1"}},
+			ConfigMapController:        &test.FakeConfigMapController{},
+		},
+	}
+}
+
+func newFlaggerTestDeployment(name string) *k8sAppsV1.Deployment {
+	return &k8sAppsV1.Deployment{
+		ObjectMeta: v12.ObjectMeta{Name: name, Namespace: "ns"},
+		Spec: k8sAppsV1.DeploymentSpec{
+			Selector: &v12.LabelSelector{MatchLabels: map[string]string{"app": name}},
+			Template: k8sV1.PodTemplateSpec{ObjectMeta: v12.ObjectMeta{
+				Labels: map[string]string{"app": name, "identity": "greeting", "env": "stage"},
+			}},
+		},
+	}
+}
+
+func TestCreateServiceEntryForFlaggerCanary(t *testing.T) {
+	rc, istioClient := newFlaggerTestController(t)
+	istioClient.NetworkingV1alpha3().VirtualServices("ns").Create(newFlaggerVirtualService(newFlaggerRoute(80, 20)))
+	rr := newFlaggerTestRegistry(t, rc, istioClient, newFlaggerTestDeployment("greeting-primary"))
+
+	serviceEntries := modifyServiceEntryForNewServiceOrPod(admiral.Add, "stage", "greeting", rr)
+	if serviceEntries["stage.greeting.mesh"] == nil {
+		t.Fatalf("Expected a service entry for the deployment, got %v", serviceEntries)
+	}
+	se, err := istioClient.NetworkingV1alpha3().ServiceEntries(common.GetSyncNamespace()).Get("stage.greeting.mesh-se", v12.GetOptions{})
+	if err != nil {
+		t.Fatalf("Expected the service entry to be written to the source cluster, got %v", err)
+	}
+	weights := make(map[string]uint32)
+	for _, endpoint := range se.Spec.Endpoints {
+		weights[endpoint.Address] = endpoint.Weight
+	}
+	if len(weights) != 2 || weights["greeting-primary.ns.svc.cluster.local"] != 80 || weights["greeting-canary.ns.svc.cluster.local"] != 20 {
+		t.Errorf("Expected the endpoints of the primary and canary services weighted as in the virtual service, got %v", se.Spec.Endpoints)
+	}
+
+	if getFlaggerCanaryForDeployment(rc, &k8sAppsV1.Deployment{ObjectMeta: v12.ObjectMeta{Name: "other", Namespace: "ns"}}) != nil {
+		t.Errorf("Expected no canary for the deployments not targeted by one")
+	}
+}
+
+func TestCreateServiceEntryForFlaggerCanaryBeforeInitialization(t *testing.T) {
+	rc, istioClient := newFlaggerTestController(t)
+	//flagger didn't generate the primary service yet, the target is still served by its own service
+	for _, name := range []string{"greeting", "greeting-primary", "greeting-canary"} {
+		rc.ServiceController.Cache.Delete(&k8sV1.Service{ObjectMeta: v12.ObjectMeta{Name: name, Namespace: "ns"}})
+	}
+	rc.ServiceController.Cache.Put(&k8sV1.Service{
+		ObjectMeta: v12.ObjectMeta{Name: "greeting", Namespace: "ns"},
+		Spec:       k8sV1.ServiceSpec{Selector: map[string]string{"app": "greeting"}, Ports: []k8sV1.ServicePort{{Name: "http", Port: 80}}},
+	})
+	rr := newFlaggerTestRegistry(t, rc, istioClient, newFlaggerTestDeployment("greeting"))
+
+	modifyServiceEntryForNewServiceOrPod(admiral.Add, "stage", "greeting", rr)
+	se, err := istioClient.NetworkingV1alpha3().ServiceEntries(common.GetSyncNamespace()).Get("stage.greeting.mesh-se", v12.GetOptions{})
+	if err != nil {
+		t.Fatalf("Expected the service entry of the target to be written before the primary service exists, got %v", err)
+	}
+	if len(se.Spec.Endpoints) != 1 || se.Spec.Endpoints[0].Address != "greeting.ns.svc.cluster.local" {
+		t.Errorf("Expected the endpoint of the service of the target, got %v", se.Spec.Endpoints)
+	}
+}
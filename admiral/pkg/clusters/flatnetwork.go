@@ -0,0 +1,245 @@
+package clusters
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/common"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/util"
+	log "github.com/sirupsen/logrus"
+	networking "istio.io/api/networking/v1alpha3"
+	k8sV1 "k8s.io/api/core/v1"
+)
+
+//flatNetworkEndpoints are the endpoints of a source cluster in a service entry. In the service entries written to the other clusters
+//on the same flat network, the gateway endpoints are replaced by the direct ones
+type flatNetworkEndpoints struct {
+	gateway []*networking.ServiceEntry_Endpoint
+	direct  []*networking.ServiceEntry_Endpoint
+}
+
+//Returns the flat network of a cluster declared on its secret, and the addresses the other clusters on the flat network point at
+func getFlatNetwork(clusterID string, annotations map[string]string) (string, string) {
+	flatNetwork := annotations[common.FlatNetworkAnnotation]
+	if flatNetwork == "" {
+		return "", ""
+	}
+	switch endpoints := annotations[common.FlatNetworkModeAnnotation]; endpoints {
+	case "", common.FlatNetworkEndpointsService:
+		return flatNetwork, common.FlatNetworkEndpointsService
+	case common.FlatNetworkEndpointsPod:
+		return flatNetwork, common.FlatNetworkEndpointsPod
+	default:
+		log.Warnf(LogFormat, "Parse", common.FlatNetworkModeAnnotation, endpoints, clusterID, "unknown value, pointing at the service load balancers")
+		return flatNetwork, common.FlatNetworkEndpointsService
+	}
+}
+
+//Returns true if the service entries of the target cluster point straight at the workloads of the source cluster
+func isFlatNetwork(source *RemoteController, target *RemoteController) bool {
+	return source != nil && target != nil && source.ClusterID != target.ClusterID && source.FlatNetwork != "" && source.FlatNetwork == target.FlatNetwork
+}
+
+//Returns the gateway and direct endpoints of the source cluster in the service entries of its service, key=service entry host. The
+//statefulset pod hosts (mapped to the local fqdn of the pod) point at their own pod only. Nil if the cluster isn't on a flat network
+func getFlatNetworkEndpoints(rc *RemoteController, service *k8sV1.Service, meshPorts map[string]uint32, cname string, podHosts map[string]string) map[string]flatNetworkEndpoints {
+	if rc.FlatNetwork == "" || service == nil {
+		return nil
+	}
+	sePorts := getServiceEntryPorts(meshPorts)
+	gatewayEndpoints := getGatewayServiceEntryEndpoints(rc, sePorts)
+	endpoints := make(map[string]flatNetworkEndpoints)
+
+	if rc.FlatNetworkEndpoints == common.FlatNetworkEndpointsPod {
+		endpoints[cname] = flatNetworkEndpoints{gateway: gatewayEndpoints, direct: getPodEndpoints(rc, service, meshPorts, sePorts, "")}
+		for podHost, localFqdn := range podHosts {
+			podName := strings.SplitN(localFqdn, common.Sep, 2)[0]
+			endpoints[podHost] = flatNetworkEndpoints{gateway: gatewayEndpoints, direct: getPodEndpoints(rc, service, meshPorts, sePorts, podName)}
+		}
+	} else {
+		endpoints[cname] = flatNetworkEndpoints{gateway: gatewayEndpoints, direct: getLoadBalancerEndpoints(rc, service, meshPorts, sePorts)}
+	}
+	return endpoints
+}
+
+//Returns an endpoint per load balancer address of the service, the service entry ports map to the service ports
+func getLoadBalancerEndpoints(rc *RemoteController, service *k8sV1.Service, meshPorts map[string]uint32, sePorts []*networking.Port) []*networking.ServiceEntry_Endpoint {
+	ports := make(map[string]uint32, len(sePorts))
+	for _, sePort := range sePorts {
+		if meshPorts[sePort.Name] == 0 {
+			return nil
+		}
+		ports[sePort.Name] = meshPorts[sePort.Name]
+	}
+	locality := rc.NodeController.GetLocality().String()
+	var seEndpoints []*networking.ServiceEntry_Endpoint
+	for _, ingress := range service.Status.LoadBalancer.Ingress {
+		address := ingress.Hostname
+		if address == "" {
+			address = ingress.IP
+		}
+		if address == "" {
+			continue
+		}
+		seEndpoints = append(seEndpoints, &networking.ServiceEntry_Endpoint{Address: address, Locality: locality, Ports: copyPorts(ports)})
+	}
+	sortEndpoints(seEndpoints)
+	return seEndpoints
+}
+
+//Returns an endpoint per ready pod behind the service, only the pod with the given name if set. The service entry ports map to the
+//target ports of the pods, and the endpoints get the locality of the node of their pod. They are labeled as pod endpoints, as they
+//come and go with the pods unlike the gateways and load balancers
+func getPodEndpoints(rc *RemoteController, service *k8sV1.Service, meshPorts map[string]uint32, sePorts []*networking.Port, podName string) []*networking.ServiceEntry_Endpoint {
+	if rc.EndpointsController == nil {
+		return nil
+	}
+	endpoints := rc.EndpointsController.Cache.Get(service.Namespace, service.Name)
+	if endpoints == nil {
+		return nil
+	}
+	//the endpoints ports have the name of the service ports
+	servicePortNames := make(map[uint32]string, len(service.Spec.Ports))
+	for _, servicePort := range service.Spec.Ports {
+		servicePortNames[uint32(servicePort.Port)] = servicePort.Name
+	}
+	clusterLocality := rc.NodeController.GetLocality().String()
+
+	var seEndpoints []*networking.ServiceEntry_Endpoint
+	for _, subset := range endpoints.Subsets {
+		ports := getSubsetPorts(subset, servicePortNames, meshPorts, sePorts)
+		if ports == nil {
+			continue
+		}
+		for _, address := range subset.Addresses {
+			if podName != "" && (address.TargetRef == nil || !strings.EqualFold(address.TargetRef.Name, podName)) {
+				continue
+			}
+			locality := clusterLocality
+			if address.NodeName != nil && rc.NodeController != nil {
+				if nodeLocality := rc.NodeController.GetNodeLocality(*address.NodeName); nodeLocality != "" {
+					locality = nodeLocality
+				}
+			}
+			seEndpoints = append(seEndpoints, &networking.ServiceEntry_Endpoint{Address: address.IP, Locality: locality, Ports: copyPorts(ports),
+				Labels: map[string]string{common.FlatNetworkPodEndpointLabel: "true"}})
+		}
+	}
+	sortEndpoints(seEndpoints)
+	return seEndpoints
+}
+
+//Returns the target port of every service entry port in the subset, nil if one of them isn't exposed by the pods of the subset
+func getSubsetPorts(subset k8sV1.EndpointSubset, servicePortNames map[uint32]string, meshPorts map[string]uint32, sePorts []*networking.Port) map[string]uint32 {
+	ports := make(map[string]uint32, len(sePorts))
+	for _, sePort := range sePorts {
+		servicePortName, ok := servicePortNames[meshPorts[sePort.Name]]
+		if !ok {
+			return nil
+		}
+		for _, port := range subset.Ports {
+			if port.Name == servicePortName {
+				ports[sePort.Name] = uint32(port.Port)
+			}
+		}
+		if ports[sePort.Name] == 0 {
+			return nil
+		}
+	}
+	return ports
+}
+
+//Returns the service entry to write to the target cluster, with the gateway endpoints of the source clusters on the same flat network
+//replaced by the addresses of their workloads. The service entry is returned as is when there are none
+func useFlatNetworkEndpoints(se *networking.ServiceEntry, key string, target *RemoteController, rcs map[string]*RemoteController, flatEndpoints map[string]map[string]flatNetworkEndpoints) *networking.ServiceEntry {
+	sourceClusters := make([]string, 0, len(flatEndpoints))
+	for sourceCluster := range flatEndpoints {
+		sourceClusters = append(sourceClusters, sourceCluster)
+	}
+	sort.Strings(sourceClusters)
+
+	newSe := se
+	for _, sourceCluster := range sourceClusters {
+		endpoints, ok := flatEndpoints[sourceCluster][key]
+		if !ok || len(endpoints.direct) == 0 || !isFlatNetwork(rcs[sourceCluster], target) {
+			continue
+		}
+		remainEndpoints := make([]*networking.ServiceEntry_Endpoint, 0, len(newSe.Endpoints))
+		removed := make([]bool, len(endpoints.gateway))
+		for _, ep := range newSe.Endpoints {
+			var matched bool
+			for i, gatewayEndpoint := range endpoints.gateway {
+				if !removed[i] && reflect.DeepEqual(gatewayEndpoint, ep) {
+					removed[i], matched = true, true
+					break
+				}
+			}
+			if !matched {
+				remainEndpoints = append(remainEndpoints, ep)
+			}
+		}
+		//the source cluster has no endpoints in the service entry, e.g. when its workload is being deleted
+		if len(remainEndpoints) == len(newSe.Endpoints) {
+			continue
+		}
+		if newSe == se {
+			newSe = copyServiceEntry(se)
+		}
+		newSe.Endpoints = append(remainEndpoints, copyEndpoints(endpoints.direct)...)
+	}
+	return newSe
+}
+
+//Returns the service entries to write to the target cluster, nil if none of them point at the workloads of a source cluster on the same
+//flat network
+func getFlatNetworkServiceEntries(serviceEntries map[string]*networking.ServiceEntry, target *RemoteController, rcs map[string]*RemoteController, flatEndpoints map[string]map[string]flatNetworkEndpoints) map[string]*networking.ServiceEntry {
+	if target == nil || target.FlatNetwork == "" {
+		return nil
+	}
+	var flatServiceEntries map[string]*networking.ServiceEntry
+	for key, se := range serviceEntries {
+		if newSe := useFlatNetworkEndpoints(se, key, target, rcs, flatEndpoints); newSe != se {
+			if flatServiceEntries == nil {
+				flatServiceEntries = make(map[string]*networking.ServiceEntry, len(serviceEntries))
+			}
+			flatServiceEntries[key] = newSe
+		}
+	}
+	if flatServiceEntries == nil {
+		return nil
+	}
+	for key, se := range serviceEntries {
+		if _, ok := flatServiceEntries[key]; !ok {
+			flatServiceEntries[key] = se
+		}
+	}
+	return flatServiceEntries
+}
+
+func copyPorts(ports map[string]uint32) map[string]uint32 {
+	newPorts := make(map[string]uint32, len(ports))
+	for name, port := range ports {
+		newPorts[name] = port
+	}
+	return newPorts
+}
+
+func copyEndpoints(endpoints []*networking.ServiceEntry_Endpoint) []*networking.ServiceEntry_Endpoint {
+	newEndpoints := make([]*networking.ServiceEntry_Endpoint, 0, len(endpoints))
+	for _, ep := range endpoints {
+		newEndpoint := &networking.ServiceEntry_Endpoint{Address: ep.Address, Locality: ep.Locality, Network: ep.Network, Ports: copyPorts(ep.Ports)}
+		if ep.Labels != nil {
+			newEndpoint.Labels = make(map[string]string, len(ep.Labels))
+			util.MapCopy(newEndpoint.Labels, ep.Labels)
+		}
+		newEndpoints = append(newEndpoints, newEndpoint)
+	}
+	return newEndpoints
+}
+
+func sortEndpoints(endpoints []*networking.ServiceEntry_Endpoint) {
+	sort.Slice(endpoints, func(i, j int) bool {
+		return endpoints[i].Address < endpoints[j].Address
+	})
+}
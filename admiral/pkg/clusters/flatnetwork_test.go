@@ -0,0 +1,152 @@
+package clusters
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/admiral"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/common"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/test"
+	istionetworkingv1alpha3 "istio.io/api/networking/v1alpha3"
+	k8sV1 "k8s.io/api/core/v1"
+	v12 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+)
+
+func TestGetFlatNetwork(t *testing.T) {
+	testCases := []struct {
+		name        string
+		annotations map[string]string
+		network     string
+		endpoints   string
+	}{
+		{
+			name: "Given no flat network, should return none",
+		},
+		{
+			name:        "Given a flat network, should point at the service load balancers by default",
+			annotations: map[string]string{common.FlatNetworkAnnotation: "flat1"},
+			network:     "flat1",
+			endpoints:   common.FlatNetworkEndpointsService,
+		},
+		{
+			name:        "Given a flat network pointing at the pods, should point at the pods",
+			annotations: map[string]string{common.FlatNetworkAnnotation: "flat1", common.FlatNetworkModeAnnotation: common.FlatNetworkEndpointsPod},
+			network:     "flat1",
+			endpoints:   common.FlatNetworkEndpointsPod,
+		},
+		{
+			name:        "Given an unknown value, should point at the service load balancers",
+			annotations: map[string]string{common.FlatNetworkAnnotation: "flat1", common.FlatNetworkModeAnnotation: "node"},
+			network:     "flat1",
+			endpoints:   common.FlatNetworkEndpointsService,
+		},
+		{
+			name:        "Given the endpoints without a flat network, should return none",
+			annotations: map[string]string{common.FlatNetworkModeAnnotation: common.FlatNetworkEndpointsPod},
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			network, endpoints := getFlatNetwork("cluster1", c.annotations)
+			if network != c.network || endpoints != c.endpoints {
+				t.Errorf("Expected %q %q, got %q %q", c.network, c.endpoints, network, endpoints)
+			}
+		})
+	}
+}
+
+func TestFlatNetworkServiceEntries(t *testing.T) {
+	config := rest.Config{Host: "localhost"}
+	stop := make(chan struct{})
+	serviceController, err := admiral.NewServiceController("cluster1", stop, &test.MockServiceHandler{}, &config, time.Minute)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	endpointsController, err := admiral.NewEndpointsController("cluster1", stop, &test.MockEndpointsHandler{}, &config, 0)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	nodeController := &admiral.NodeController{}
+	nodeController.Added(&k8sV1.Node{ObjectMeta: v12.ObjectMeta{Name: "node1", Labels: map[string]string{common.NodeRegionLabel: "us-west-2", common.NodeZoneLabel: "us-west-2a"}}})
+	nodeController.Added(&k8sV1.Node{ObjectMeta: v12.ObjectMeta{Name: "node2", Labels: map[string]string{common.NodeRegionLabel: "us-west-2", common.NodeZoneLabel: "us-west-2b"}}})
+
+	service := &k8sV1.Service{
+		ObjectMeta: v12.ObjectMeta{Name: "foo", Namespace: "ns"},
+		Spec:       k8sV1.ServiceSpec{Ports: []k8sV1.ServicePort{{Name: "http", Port: 80}, {Name: "grpc", Port: 8090}}},
+		Status:     k8sV1.ServiceStatus{LoadBalancer: k8sV1.LoadBalancerStatus{Ingress: []k8sV1.LoadBalancerIngress{{IP: "10.1.0.1"}}}},
+	}
+	node1, node2 := "node1", "node2"
+	endpointsController.Cache.Put(&k8sV1.Endpoints{
+		ObjectMeta: v12.ObjectMeta{Name: "foo", Namespace: "ns"},
+		Subsets: []k8sV1.EndpointSubset{
+			{
+				Addresses: []k8sV1.EndpointAddress{{IP: "10.0.0.2", NodeName: &node2}, {IP: "10.0.0.1", NodeName: &node1}},
+				Ports:     []k8sV1.EndpointPort{{Name: "http", Port: 8080}, {Name: "grpc", Port: 9090}},
+			},
+			{
+				//the pods of the subset don't expose the grpc port yet
+				Addresses: []k8sV1.EndpointAddress{{IP: "10.0.0.3", NodeName: &node1}},
+				Ports:     []k8sV1.EndpointPort{{Name: "http", Port: 8080}},
+			},
+		},
+	})
+
+	rc1 := &RemoteController{ClusterID: "cluster1", FlatNetwork: "flat1", FlatNetworkEndpoints: common.FlatNetworkEndpointsPod,
+		ServiceController: serviceController, EndpointsController: endpointsController, NodeController: nodeController}
+	rc2 := &RemoteController{ClusterID: "cluster2", FlatNetwork: "flat1", FlatNetworkEndpoints: common.FlatNetworkEndpointsService}
+	rc3 := &RemoteController{ClusterID: "cluster3"}
+	rcs := map[string]*RemoteController{"cluster1": rc1, "cluster2": rc2, "cluster3": rc3}
+
+	meshPorts := map[string]uint32{"http": 80, "grpc-8090": 8090}
+	gatewayEndpoints := getGatewayServiceEntryEndpoints(rc1, getServiceEntryPorts(meshPorts))
+	remoteEndpoint := &istionetworkingv1alpha3.ServiceEntry_Endpoint{Address: "cluster3.elb", Ports: map[string]uint32{"http": 15443}, Locality: "us-east-2"}
+	se := &istionetworkingv1alpha3.ServiceEntry{Hosts: []string{"e2e.foo.global"}, Endpoints: append([]*istionetworkingv1alpha3.ServiceEntry_Endpoint{remoteEndpoint}, gatewayEndpoints...)}
+	serviceEntries := map[string]*istionetworkingv1alpha3.ServiceEntry{"e2e.foo.global": se}
+
+	flatEndpoints := map[string]map[string]flatNetworkEndpoints{
+		"cluster1": getFlatNetworkEndpoints(rc1, service, meshPorts, "e2e.foo.global", nil),
+		"cluster3": getFlatNetworkEndpoints(rc3, service, meshPorts, "e2e.foo.global", nil),
+	}
+
+	flatServiceEntries := getFlatNetworkServiceEntries(serviceEntries, rc2, rcs, flatEndpoints)
+	//the pod endpoints are labeled, their removal isn't guarded as they follow the scaling of the workload
+	podLabels := map[string]string{common.FlatNetworkPodEndpointLabel: "true"}
+	expected := []*istionetworkingv1alpha3.ServiceEntry_Endpoint{
+		remoteEndpoint,
+		{Address: "10.0.0.1", Ports: map[string]uint32{"http": 8080, "grpc-8090": 9090}, Locality: "us-west-2/us-west-2a", Labels: podLabels},
+		{Address: "10.0.0.2", Ports: map[string]uint32{"http": 8080, "grpc-8090": 9090}, Locality: "us-west-2/us-west-2b", Labels: podLabels},
+	}
+	if flatServiceEntries == nil || !reflect.DeepEqual(flatServiceEntries["e2e.foo.global"].Endpoints, expected) {
+		t.Errorf("Expected the gateway endpoints of the cluster on the same flat network to be replaced by its pods, got %v", flatServiceEntries)
+	}
+	if len(se.Endpoints) != 1+len(gatewayEndpoints) {
+		t.Errorf("Expected the service entry not to be modified, got %v", se.Endpoints)
+	}
+
+	if getFlatNetworkServiceEntries(serviceEntries, rc3, rcs, flatEndpoints) != nil {
+		t.Errorf("Expected no service entries for a cluster not on the flat network")
+	}
+	if useFlatNetworkEndpoints(se, "e2e.foo.global", rc1, rcs, flatEndpoints) != se {
+		t.Errorf("Expected the source cluster not to point at its own pods")
+	}
+
+	rc1.FlatNetworkEndpoints = common.FlatNetworkEndpointsService
+	flatEndpoints["cluster1"] = getFlatNetworkEndpoints(rc1, service, map[string]uint32{"http": 80}, "e2e.foo.global", nil)
+	se.Endpoints = append([]*istionetworkingv1alpha3.ServiceEntry_Endpoint{remoteEndpoint}, getGatewayServiceEntryEndpoints(rc1, getServiceEntryPorts(map[string]uint32{"http": 80}))...)
+	expected = []*istionetworkingv1alpha3.ServiceEntry_Endpoint{
+		remoteEndpoint,
+		{Address: "10.1.0.1", Ports: map[string]uint32{"http": 80}, Locality: "us-west-2/us-west-2a"},
+	}
+	if newSe := useFlatNetworkEndpoints(se, "e2e.foo.global", rc2, rcs, flatEndpoints); !reflect.DeepEqual(newSe.Endpoints, expected) {
+		t.Errorf("Expected the gateway endpoints to be replaced by the service load balancer, got %v", newSe.Endpoints)
+	}
+
+	service.Status.LoadBalancer.Ingress = nil
+	flatEndpoints["cluster1"] = getFlatNetworkEndpoints(rc1, service, map[string]uint32{"http": 80}, "e2e.foo.global", nil)
+	if useFlatNetworkEndpoints(se, "e2e.foo.global", rc2, rcs, flatEndpoints) != se {
+		t.Errorf("Expected the gateway endpoints to be kept while the service has no load balancer")
+	}
+}
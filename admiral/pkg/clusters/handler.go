@@ -6,9 +6,10 @@ import (
 	"net"
 	"reflect"
 	"strings"
-	"time"
 
+	"github.com/argoproj/argo-rollouts/pkg/apis/rollouts"
 	argo "github.com/argoproj/argo-rollouts/pkg/apis/rollouts/v1alpha1"
+	"github.com/gogo/protobuf/proto"
 	"github.com/gogo/protobuf/types"
 	"github.com/istio-ecosystem/admiral/admiral/pkg/apis/admiral/model"
 	v1 "github.com/istio-ecosystem/admiral/admiral/pkg/apis/admiral/v1"
@@ -27,6 +28,8 @@ const (
 	DefaultBaseEjectionTime         int64  = 300
 	DefaultConsecutiveGatewayErrors uint32 = 50
 	DefaultInterval                 int64  = 60
+	DefaultTcpKeepaliveTime         int64  = 300
+	DefaultTcpKeepaliveInterval     int64  = 75
 )
 
 type ServiceEntryHandler struct {
@@ -54,11 +57,118 @@ type WeightedService struct {
 	Service *k8sV1.Service
 }
 
-func updateIdentityDependencyCache(sourceIdentity string, identityDependencyCache *common.MapOfMaps, dr *v1.Dependency) {
+//updates the dependency cache with the destinations of the dependency record and returns the destinations the source no longer depends on
+func updateIdentityDependencyCache(sourceIdentity string, identityDependencyCache *common.MapOfMaps, dr *v1.Dependency) map[string]string {
+	removedDestinations := getDestinationsForSource(sourceIdentity, identityDependencyCache)
 	for _, dIdentity := range dr.Spec.Destinations {
 		identityDependencyCache.Put(dIdentity, sourceIdentity, sourceIdentity)
+		delete(removedDestinations, dIdentity)
+	}
+	for dIdentity := range removedDestinations {
+		identityDependencyCache.DeleteMap(dIdentity, sourceIdentity)
+	}
+	log.Infof(LogFormat, "Update", "dependency-cache", dr.Name, "", fmt.Sprintf("Updated=true namespace=%s removed=%v", dr.Namespace, removedDestinations))
+	return removedDestinations
+}
+
+//removes the source from the dependency cache and returns all the destinations it depended on
+func deleteIdentityDependencyCache(sourceIdentity string, identityDependencyCache *common.MapOfMaps, dr *v1.Dependency) map[string]string {
+	removedDestinations := getDestinationsForSource(sourceIdentity, identityDependencyCache)
+	for dIdentity := range removedDestinations {
+		identityDependencyCache.DeleteMap(dIdentity, sourceIdentity)
+	}
+	log.Infof(LogFormat, "Delete", "dependency-cache", dr.Name, "", fmt.Sprintf("Deleted=true namespace=%s removed=%v", dr.Namespace, removedDestinations))
+	return removedDestinations
+}
+
+func getDestinationsForSource(sourceIdentity string, identityDependencyCache *common.MapOfMaps) map[string]string {
+	destinations := make(map[string]string)
+	identityDependencyCache.Range(func(dIdentity string, sources *common.Map) {
+		if len(sources.Get(sourceIdentity)) > 0 {
+			destinations[dIdentity] = dIdentity
+		}
+	})
+	return destinations
+}
+
+//Removes the service entries and destination rules of the destination identities from the dependent clusters that no source depends on anymore
+func cleanupDependentClusters(destinations map[string]string, remoteRegistry *RemoteRegistry) {
+	if len(destinations) == 0 {
+		return
+	}
+	//which clusters still need the destinations is only known from the complete caches
+	if IsCacheWarmupTime(remoteRegistry) {
+		log.Infof(LogFormat, "Delete", "dependent-clusters", "", "", "Cleanup deferred until the cache warm up is over")
+		runAfterCacheWarmup(remoteRegistry, func() { cleanupDependentClusters(destinations, remoteRegistry) })
+		return
+	}
+	cache := remoteRegistry.AdmiralCache
+	for dIdentity := range destinations {
+		sourceClusters := cache.IdentityClusterCache.Get(dIdentity).Copy()
+		requiredClusters := make(map[string]string)
+		for depIdentity := range cache.IdentityDependencyCache.Get(dIdentity).Copy() {
+			for _, clusterID := range cache.IdentityClusterCache.Get(depIdentity).Copy() {
+				requiredClusters[clusterID] = clusterID
+			}
+		}
+
+		cnames := make([]string, 0)
+		cache.CnameIdentityCache.Range(func(cname, identity interface{}) bool {
+			if fmt.Sprint(identity) == dIdentity {
+				cnames = append(cnames, fmt.Sprint(cname))
+			}
+			return true
+		})
+
+		for _, cname := range cnames {
+			for _, clusterID := range cache.CnameDependentClusterCache.Get(cname).Copy() {
+				_, required := requiredClusters[clusterID]
+				_, source := sourceClusters[clusterID]
+				if required || source {
+					continue
+				}
+				rc := remoteRegistry.RemoteControllers[clusterID]
+				if rc != nil {
+					deleteServiceEntriesForCname(cname, dIdentity, rc, cache)
+				}
+				cache.CnameDependentClusterCache.DeleteMap(cname, clusterID)
+				log.Infof(LogFormat, "Delete", "dependent-cluster", cname, clusterID, "No dependents left in cluster for identity="+dIdentity)
+			}
+		}
+	}
+}
+
+//deletes the service entries (including the ones generated for gtp dns prefixes) and destination rules for a cname in a cluster
+func deleteServiceEntriesForCname(cname string, identity string, rc *RemoteController, cache *AdmiralCache) {
+	syncNamespace := common.GetSyncNamespace()
+	listOptions := v12.ListOptions{LabelSelector: common.GetWorkloadIdentifier() + "=" + identity}
+	serviceEntries, err := rc.ServiceEntryController.IstioClient.NetworkingV1alpha3().ServiceEntries(syncNamespace).List(listOptions)
+	if err != nil {
+		log.Errorf(LogErrFormat, "List", "ServiceEntry", cname, rc.ClusterID, err)
+		return
+	}
+	for i := range serviceEntries.Items {
+		serviceEntry := &serviceEntries.Items[i]
+		if len(serviceEntry.Spec.Hosts) == 0 {
+			continue
+		}
+		host := serviceEntry.Spec.Hosts[0]
+		if host != cname && !strings.HasSuffix(host, common.Sep+cname) {
+			continue
+		}
+		drName := getIstioResourceName(host, "-dr")
+		if host == cname {
+			drName = getIstioResourceName(host, "-default-dr")
+		}
+		destinationRule, err := rc.DestinationRuleController.IstioClient.NetworkingV1alpha3().DestinationRules(syncNamespace).Get(drName, v12.GetOptions{})
+		if err != nil {
+			log.Infof(LogFormat, "Get (error)", "old DestinationRule", drName, rc.ClusterID, err)
+			destinationRule = nil
+		}
+		deleteServiceEntry(serviceEntry, syncNamespace, rc)
+		deleteDestinationRule(destinationRule, syncNamespace, rc)
+		cache.SeClusterCache.DeleteMap(host, rc.ClusterID)
 	}
-	log.Infof(LogFormat, "Update", "dependency-cache", dr.Name, "", "Updated=true namespace="+dr.Namespace)
 }
 
 func getIstioResourceName(host string, suffix string) string {
@@ -68,7 +178,9 @@ func getIstioResourceName(host string, suffix string) string {
 func getDestinationRule(se *v1alpha32.ServiceEntry, locality string, gtpTrafficPolicy *model.TrafficPolicy) *v1alpha32.DestinationRule {
 	var dr = &v1alpha32.DestinationRule{}
 	dr.Host = se.Hosts[0]
+	//istio mutual tls is required for every protocol, including opaque tcp and tls, as the remote gateway routes on the sni it sets
 	dr.TrafficPolicy = &v1alpha32.TrafficPolicy{Tls: &v1alpha32.TLSSettings{Mode: v1alpha32.TLSSettings_ISTIO_MUTUAL}}
+	dr.TrafficPolicy.PortLevelSettings = getOpaqueTcpPortSettings(se)
 	processGtp := true
 	if len(locality) == 0 {
 		log.Warnf(LogErrFormat, "Process", "GlobalTrafficPolicy", dr.Host, "", "Skipping gtp processing, locality of the cluster nodes cannot be determined. Is this minikube?")
@@ -79,23 +191,36 @@ func getDestinationRule(se *v1alpha32.ServiceEntry, locality string, gtpTrafficP
 			LbPolicy: &v1alpha32.LoadBalancerSettings_Simple{Simple: v1alpha32.LoadBalancerSettings_ROUND_ROBIN},
 		}
 
-		if len(gtpTrafficPolicy.Target) > 0 {
+		if len(gtpTrafficPolicy.Target) > 0 || len(gtpTrafficPolicy.Failover) > 0 {
 			var localityLbSettings = &v1alpha32.LocalityLoadBalancerSetting{}
 
-			if gtpTrafficPolicy.LbType == model.TrafficPolicy_FAILOVER {
+			if gtpTrafficPolicy.LbType == model.TrafficPolicy_FAILOVER && len(gtpTrafficPolicy.Target) > 0 {
 				distribute := make([]*v1alpha32.LocalityLoadBalancerSetting_Distribute, 0)
 				targetTrafficMap := make(map[string]uint32)
+				zoneTargets := false
 				for _, tg := range gtpTrafficPolicy.Target {
 					//skip 0 values from GTP as that's implicit for locality settings
 					if tg.Weight != int32(0) {
-						targetTrafficMap[tg.Region] = uint32(tg.Weight)
+						target := tg.Region
+						//a target in the region/zone format gets the traffic of the zone only
+						if strings.Contains(target, common.Slash) {
+							target = strings.TrimSuffix(target, common.Slash+"*") + common.Slash + "*"
+							zoneTargets = true
+						}
+						targetTrafficMap[target] = uint32(tg.Weight)
 					}
 				}
 				distribute = append(distribute, &v1alpha32.LocalityLoadBalancerSetting_Distribute{
-					From: locality + "/*",
+					From: getDistributeFrom(locality, zoneTargets),
 					To:   targetTrafficMap,
 				})
 				localityLbSettings.Distribute = distribute
+			} else if len(gtpTrafficPolicy.Failover) > 0 {
+				failover := make([]*v1alpha32.LocalityLoadBalancerSetting_Failover, 0, len(gtpTrafficPolicy.Failover))
+				for _, fo := range gtpTrafficPolicy.Failover {
+					failover = append(failover, &v1alpha32.LocalityLoadBalancerSetting_Failover{From: fo.From, To: fo.To})
+				}
+				localityLbSettings.Failover = failover
 			}
 			// else default behavior
 			loadBalancerSettings.LocalityLbSetting = localityLbSettings
@@ -103,9 +228,80 @@ func getDestinationRule(se *v1alpha32.ServiceEntry, locality string, gtpTrafficP
 		}
 	}
 	dr.TrafficPolicy.OutlierDetection = getOutlierDetection(se, locality, gtpTrafficPolicy)
+	//the settings of a port replace those of the whole destination on that port, so the load balancing of the gtp and the outlier
+	//detection are repeated for every port with its own settings
+	for _, portSettings := range dr.TrafficPolicy.PortLevelSettings {
+		portSettings.LoadBalancer = dr.TrafficPolicy.LoadBalancer
+		portSettings.OutlierDetection = dr.TrafficPolicy.OutlierDetection
+	}
 	return dr
 }
 
+//Returns the settings of the opaque tcp ports of the service entry. Their connections carry no requests istio could route on, so the
+//istio mutual tls the remote gateway routes on is set on the port itself, where a tls setting of the port can't override it.
+//The connections are long lived, keepalives stop the load balancers in front of the gateways from dropping them when idle
+func getOpaqueTcpPortSettings(se *v1alpha32.ServiceEntry) []*v1alpha32.TrafficPolicy_PortTrafficPolicy {
+	var portSettings []*v1alpha32.TrafficPolicy_PortTrafficPolicy
+	for _, port := range se.Ports {
+		if !isOpaqueTcpProtocol(strings.ToLower(port.Protocol)) {
+			continue
+		}
+		portSettings = append(portSettings, &v1alpha32.TrafficPolicy_PortTrafficPolicy{
+			Port: &v1alpha32.PortSelector{Number: port.Number},
+			Tls:  &v1alpha32.TLSSettings{Mode: v1alpha32.TLSSettings_ISTIO_MUTUAL},
+			ConnectionPool: &v1alpha32.ConnectionPoolSettings{Tcp: &v1alpha32.ConnectionPoolSettings_TCPSettings{
+				TcpKeepalive: &v1alpha32.ConnectionPoolSettings_TCPSettings_TcpKeepalive{
+					Time:     &types.Duration{Seconds: DefaultTcpKeepaliveTime},
+					Interval: &types.Duration{Seconds: DefaultTcpKeepaliveInterval},
+				},
+			}},
+		})
+	}
+	return portSettings
+}
+
+//Returns the localities the distribution applies to, the region of the cluster unless zones are targeted, then the zone of the cluster
+func getDistributeFrom(locality string, zoneTargets bool) string {
+	clusterLocality := admiral.ParseLocality(locality)
+	if clusterLocality == nil {
+		return locality + common.Slash + "*"
+	}
+	if zoneTargets && clusterLocality.Zone != "" {
+		return clusterLocality.Region + common.Slash + clusterLocality.Zone + common.Slash + "*"
+	}
+	return clusterLocality.Region + common.Slash + "*"
+}
+
+//Validates the failover chain of a traffic policy against the regions known to admiral
+func validateFailover(gtpTrafficPolicy *model.TrafficPolicy, knownRegions map[string]string) error {
+	if len(gtpTrafficPolicy.Failover) == 0 {
+		return nil
+	}
+	//istio does not allow locality failover together with weighted distribution
+	if gtpTrafficPolicy.LbType == model.TrafficPolicy_FAILOVER {
+		return fmt.Errorf("failover cannot be used with lbType %v, use target weights instead", model.TrafficPolicy_FAILOVER)
+	}
+	froms := make(map[string]string)
+	for _, fo := range gtpTrafficPolicy.Failover {
+		if fo.From == "" || fo.To == "" {
+			return fmt.Errorf("failover from=%s to=%s must have both regions set", fo.From, fo.To)
+		}
+		if fo.From == fo.To {
+			return fmt.Errorf("failover from=%s to=%s must be between different regions", fo.From, fo.To)
+		}
+		if _, ok := froms[fo.From]; ok {
+			return fmt.Errorf("failover from=%s is defined more than once", fo.From)
+		}
+		froms[fo.From] = fo.To
+		for _, region := range []string{fo.From, fo.To} {
+			if _, ok := knownRegions[region]; !ok {
+				return fmt.Errorf("failover region=%s is not one of the known regions %v", region, knownRegions)
+			}
+		}
+	}
+	return nil
+}
+
 func getOutlierDetection(se *v1alpha32.ServiceEntry, locality string, gtpTrafficPolicy *model.TrafficPolicy) *v1alpha32.OutlierDetection {
 
 	outlierDetection := &v1alpha32.OutlierDetection{
@@ -193,6 +389,8 @@ func (dh *DestinationRuleHandler) Deleted(obj *v1alpha3.DestinationRule) {
 }
 
 func (vh *VirtualServiceHandler) Added(obj *v1alpha3.VirtualService) {
+	handleVirtualServiceEventForRollouts(obj, vh)
+	handleVirtualServiceEventForFlagger(obj, vh)
 	if IgnoreIstioResource(obj.Spec.ExportTo, obj.Annotations, obj.Namespace) {
 		log.Infof(LogFormat, "Add", "VirtualService", obj.Name, vh.ClusterID, "Skipping resource from namespace="+obj.Namespace)
 		return
@@ -204,6 +402,8 @@ func (vh *VirtualServiceHandler) Added(obj *v1alpha3.VirtualService) {
 }
 
 func (vh *VirtualServiceHandler) Updated(obj *v1alpha3.VirtualService) {
+	handleVirtualServiceEventForRollouts(obj, vh)
+	handleVirtualServiceEventForFlagger(obj, vh)
 	if IgnoreIstioResource(obj.Spec.ExportTo, obj.Annotations, obj.Namespace) {
 		log.Infof(LogFormat, "Update", "VirtualService", obj.Name, vh.ClusterID, "Skipping resource from namespace="+obj.Namespace)
 		return
@@ -215,6 +415,8 @@ func (vh *VirtualServiceHandler) Updated(obj *v1alpha3.VirtualService) {
 }
 
 func (vh *VirtualServiceHandler) Deleted(obj *v1alpha3.VirtualService) {
+	handleVirtualServiceEventForRollouts(obj, vh)
+	handleVirtualServiceEventForFlagger(obj, vh)
 	if IgnoreIstioResource(obj.Spec.ExportTo, obj.Annotations, obj.Namespace) {
 		log.Infof(LogFormat, "Delete", "VirtualService", obj.Name, vh.ClusterID, "Skipping resource from namespace="+obj.Namespace)
 		return
@@ -225,6 +427,33 @@ func (vh *VirtualServiceHandler) Deleted(obj *v1alpha3.VirtualService) {
 	}
 }
 
+//Syncs the rollouts using the virtual service for their canary strategy, the weights of their endpoints follow the ones of the virtual
+//service. The virtual services of the rollouts are usually not exported, so this is done before they are ignored
+func handleVirtualServiceEventForRollouts(obj *v1alpha3.VirtualService, vh *VirtualServiceHandler) {
+	if !common.GetAdmiralParams().ArgoRolloutsEnabled {
+		return
+	}
+	rc := vh.RemoteRegistry.RemoteControllers[vh.ClusterID]
+	if rc == nil || rc.RolloutController == nil {
+		return
+	}
+	for identity := range rc.RolloutController.Cache.GetEnvsByIdentity() {
+		entry := rc.RolloutController.Cache.Get(identity)
+		if entry == nil {
+			continue
+		}
+		for _, rollout := range entry.Rollouts {
+			if rollout.Namespace != obj.Namespace {
+				continue
+			}
+			canary := rollout.Spec.Strategy.Canary
+			if canary != nil && canary.TrafficRouting != nil && canary.TrafficRouting.Istio != nil && canary.TrafficRouting.Istio.VirtualService.Name == obj.Name {
+				HandleEventForRollout(admiral.Update, rollout, vh.RemoteRegistry, vh.ClusterID)
+			}
+		}
+	}
+}
+
 func (dh *SidecarHandler) Added(obj *v1alpha3.Sidecar) {}
 
 func (dh *SidecarHandler) Updated(obj *v1alpha3.Sidecar) {}
@@ -283,8 +512,27 @@ func handleDestinationRuleEvent(obj *v1alpha3.DestinationRule, dh *DestinationRu
 
 		util.MapCopy(allDependentClusters, dependentClusters)
 
+		//the other source clusters route the subset hosts to their own workloads
+		util.MapCopy(allDependentClusters, r.AdmiralCache.CnameClusterCache.Get(host).Copy())
+
 		allDependentClusters[clusterId] = clusterId
 
+		//the subsets removed from the destination rule are deleted from every cluster
+		subsets := make(map[string]string, len(destinationRule.Subsets))
+		if event != common.Delete {
+			for _, subset := range destinationRule.Subsets {
+				subsets[strings.ToLower(subset.Name)] = subset.Name
+			}
+		}
+		removedSubsets := make(map[string]string)
+		if r.AdmiralCache.CnameSubsetCache != nil {
+			for prefix, subset := range r.AdmiralCache.CnameSubsetCache.Get(host).Copy() {
+				if _, ok := subsets[prefix]; !ok {
+					removedSubsets[prefix] = subset
+				}
+			}
+		}
+
 		for _, dependentCluster := range allDependentClusters {
 
 			rc := r.RemoteControllers[dependentCluster]
@@ -314,7 +562,7 @@ func handleDestinationRuleEvent(obj *v1alpha3.DestinationRule, dh *DestinationRu
 					if dependentCluster == clusterId {
 						localIdentityId = identityId
 					}
-					drServiceEntries = createSeWithDrLabels(rc, dependentCluster == clusterId, identityId, seName, &serviceEntry, &destinationRule, r.AdmiralCache.ServiceEntryAddressStore, r.AdmiralCache.ConfigMapController)
+					drServiceEntries = createSeWithDrLabels(rc, identityId, seName, &serviceEntry, &destinationRule, r.AdmiralCache)
 				}
 
 			}
@@ -334,13 +582,7 @@ func handleDestinationRuleEvent(obj *v1alpha3.DestinationRule, dh *DestinationRu
 					log.Errorf(LogErrFormat, "Delete", "ServiceEntry", seName, clusterId, err)
 				}
 				for _, subset := range destinationRule.Subsets {
-					sseName := seName + common.Dash + subset.Name
-					err = rc.ServiceEntryController.IstioClient.NetworkingV1alpha3().ServiceEntries(syncNamespace).Delete(sseName, &v12.DeleteOptions{})
-					if err != nil {
-						log.Infof(LogFormat, "Delete", "ServiceEntry", sseName, clusterId, "success")
-					} else {
-						log.Errorf(LogErrFormat, "Delete", "ServiceEntry", sseName, clusterId, err)
-					}
+					deleteSubset(rc, host, subset.Name)
 				}
 				err = rc.DestinationRuleController.IstioClient.NetworkingV1alpha3().DestinationRules(syncNamespace).Delete(localDrName, &v12.DeleteOptions{})
 				if err != nil {
@@ -365,6 +607,7 @@ func handleDestinationRuleEvent(obj *v1alpha3.DestinationRule, dh *DestinationRu
 						log.Warnf(LogErrFormat, "Create", "ServiceEntry", seName, clusterId, err)
 					}
 					if newServiceEntry != nil {
+						newServiceEntry.Labels = map[string]string{common.GetWorkloadIdentifier(): identityId}
 						addUpdateServiceEntry(newServiceEntry, existsServiceEntry, syncNamespace, rc)
 						r.AdmiralCache.SeClusterCache.Put(newServiceEntry.Spec.Hosts[0], rc.ClusterID, rc.ClusterID)
 					}
@@ -374,6 +617,21 @@ func handleDestinationRuleEvent(obj *v1alpha3.DestinationRule, dh *DestinationRu
 					}
 				}
 
+				for _, subset := range destinationRule.Subsets {
+					subsetHost := getSubsetHost(host, subset.Name)
+					if _, ok := drServiceEntries[getIstioResourceName(subsetHost, "-se")]; !ok {
+						continue
+					}
+					subsetDrName := getIstioResourceName(subsetHost, "-dr")
+					existsDestinationRule, _ := rc.DestinationRuleController.IstioClient.NetworkingV1alpha3().DestinationRules(syncNamespace).Get(subsetDrName, v12.GetOptions{})
+					subsetDestinationRule := createDestinationRuleSkeletion(*getSubsetDestinationRule(subsetHost, &destinationRule, subset), subsetDrName, syncNamespace)
+					subsetDestinationRule.Labels = map[string]string{common.GetWorkloadIdentifier(): identityId}
+					addUpdateDestinationRule(subsetDestinationRule, existsDestinationRule, syncNamespace, rc)
+				}
+				for _, subset := range removedSubsets {
+					deleteSubset(rc, host, subset)
+				}
+
 				if dependentCluster == clusterId {
 					//we need a destination rule with local fqdn for destination rules created with cnames to work in local cluster
 					createDestinationRuleForLocal(rc, localDrName, localIdentityId, clusterId, &destinationRule)
@@ -381,6 +639,15 @@ func handleDestinationRuleEvent(obj *v1alpha3.DestinationRule, dh *DestinationRu
 
 			}
 		}
+
+		if r.AdmiralCache.CnameSubsetCache != nil {
+			for prefix := range removedSubsets {
+				r.AdmiralCache.CnameSubsetCache.DeleteMap(host, prefix)
+			}
+			for prefix, subset := range subsets {
+				r.AdmiralCache.CnameSubsetCache.Put(host, prefix, subset)
+			}
+		}
 		return
 	} else {
 		log.Infof(LogFormat, "Event", "DestinationRule", obj.Name, clusterId, "No dependent clusters found")
@@ -451,33 +718,48 @@ func handleVirtualServiceEvent(obj *v1alpha3.VirtualService, vh *VirtualServiceH
 
 	syncNamespace := common.GetSyncNamespace()
 
-	if len(virtualService.Hosts) > 1 {
-		log.Errorf(LogFormat, "Event", resourceType, obj.Name, clusterId, "Skipping as multiple hosts not supported for virtual service namespace="+obj.Namespace)
+	//the delegate virtual services have no hosts, the root virtual services delegating to them can't be followed as the delegate field
+	//isn't supported by the Istio API Admiral is built with
+	if len(virtualService.Hosts) == 0 {
+		log.Infof(LogFormat, "Event", resourceType, obj.Name, clusterId, "Skipped as virtual services without hosts aren't supported namespace="+obj.Namespace)
 		return nil
 	}
 
-	//check if this virtual service is used by Argo rollouts for canary strategy, if so, update the corresponding SE with appropriate weights
-	if common.GetAdmiralParams().ArgoRolloutsEnabled {
-		rollouts, err := vh.RemoteRegistry.RemoteControllers[clusterId].RolloutController.RolloutClient.Rollouts(obj.Namespace).List(v12.ListOptions{})
+	//the local hosts of the virtual service and of its destinations mapped to the cnames of their workloads
+	cnames := make(map[string]string)
+	destinations := getVirtualServiceDestinations(&virtualService)
+	localHosts := make([]string, 0, len(virtualService.Hosts)+len(destinations))
+	localHosts = append(localHosts, virtualService.Hosts...)
+	for _, destination := range destinations {
+		if destination != nil {
+			localHosts = append(localHosts, destination.Host)
+		}
+	}
+	for _, host := range localHosts {
+		if _, ok := cnames[host]; ok {
+			continue
+		}
+		if cname := getCnameForLocalHost(r.RemoteControllers[clusterId], r.AdmiralCache, host, obj.Namespace); len(cname) > 0 {
+			cnames[host] = cname
+		}
+	}
 
-		if err != nil {
-			log.Errorf(LogErrFormat, "Get", "Rollout", "Error finding rollouts in namespace="+obj.Namespace, clusterId, err)
-		} else {
-			if len(rollouts.Items) > 0 {
-				for _, rollout := range rollouts.Items {
-					if rollout.Spec.Strategy.Canary != nil && rollout.Spec.Strategy.Canary.TrafficRouting != nil && rollout.Spec.Strategy.Canary.TrafficRouting.Istio != nil && rollout.Spec.Strategy.Canary.TrafficRouting.Istio.VirtualService.Name == obj.Name {
-						HandleEventForRollout(admiral.Update, &rollout, vh.RemoteRegistry, clusterId)
-					}
-				}
+	//the hosts of the virtual service needed by each dependent cluster
+	dependentClusters := make(map[string][]string)
+	for _, host := range virtualService.Hosts {
+		if cname, ok := cnames[host]; ok {
+			host = cname
+		}
+		for _, dependentCluster := range r.AdmiralCache.CnameDependentClusterCache.Get(host).Copy() {
+			if !util.Contains(dependentClusters[dependentCluster], host) {
+				dependentClusters[dependentCluster] = append(dependentClusters[dependentCluster], host)
 			}
 		}
 	}
 
-	dependentClusters := r.AdmiralCache.CnameDependentClusterCache.Get(virtualService.Hosts[0]).Copy()
-
 	if len(dependentClusters) > 0 {
 
-		for _, dependentCluster := range dependentClusters {
+		for dependentCluster, dependentHosts := range dependentClusters {
 
 			rc := r.RemoteControllers[dependentCluster]
 
@@ -496,26 +778,12 @@ func handleVirtualServiceEvent(obj *v1alpha3.VirtualService, vh *VirtualServiceH
 
 					exist, _ := rc.VirtualServiceController.IstioClient.NetworkingV1alpha3().VirtualServices(syncNamespace).Get(obj.Name, v12.GetOptions{})
 
-					//change destination host for all http routes <service_name>.<ns>. to same as host on the virtual service
-					for _, httpRoute := range virtualService.Http {
-						for _, destination := range httpRoute.Route {
-							//get at index 0, we do not support wildcards or multiple hosts currently
-							if strings.HasSuffix(destination.Destination.Host, common.DotLocalDomainSuffix) {
-								destination.Destination.Host = virtualService.Hosts[0]
-							}
-						}
-					}
+					//each cluster only gets the hosts it depends on, with the destinations <service_name>.<ns>. changed to their cnames
+					dependentVs := obj.DeepCopy()
+					dependentVs.Spec.Hosts = dependentHosts
+					rewriteVirtualServiceDestinations(&dependentVs.Spec, cnames, r.AdmiralCache)
 
-					for _, tlsRoute := range virtualService.Tls {
-						for _, destination := range tlsRoute.Route {
-							//get at index 0, we do not support wildcards or multiple hosts currently
-							if strings.HasSuffix(destination.Destination.Host, common.DotLocalDomainSuffix) {
-								destination.Destination.Host = virtualService.Hosts[0]
-							}
-						}
-					}
-
-					addUpdateVirtualService(obj, exist, syncNamespace, rc)
+					addUpdateVirtualService(dependentVs, exist, syncNamespace, rc)
 				}
 			}
 		}
@@ -551,12 +819,15 @@ func addUpdateVirtualService(obj *v1alpha3.VirtualService, exist *v1alpha3.Virtu
 	if obj.Annotations == nil {
 		obj.Annotations = map[string]string{}
 	}
-	obj.Annotations["app.kubernetes.io/created-by"] = "admiral"
+	obj.Annotations[common.CreatedByAnnotation] = common.Admiral
 	if exist == nil || len(exist.Spec.Hosts) == 0 {
 		obj.Namespace = namespace
 		obj.ResourceVersion = ""
 		_, err = rc.VirtualServiceController.IstioClient.NetworkingV1alpha3().VirtualServices(namespace).Create(obj)
 		op = "Add"
+	} else if isIstioObjectUnchanged(&obj.Spec, &exist.Spec, obj.ObjectMeta, exist.ObjectMeta) {
+		log.Debugf(LogFormat, "Update", "VirtualService", obj.Name, rc.ClusterID, "Skipped as it is unchanged")
+		return
 	} else {
 		exist.Labels = obj.Labels
 		exist.Annotations = obj.Annotations
@@ -572,20 +843,24 @@ func addUpdateVirtualService(obj *v1alpha3.VirtualService, exist *v1alpha3.Virtu
 	}
 }
 
-func addUpdateServiceEntry(obj *v1alpha3.ServiceEntry, exist *v1alpha3.ServiceEntry, namespace string, rc *RemoteController) {
+func addUpdateServiceEntry(obj *v1alpha3.ServiceEntry, exist *v1alpha3.ServiceEntry, namespace string, rc *RemoteController) error {
 	var err error
 	var op, diff string
 	var skipUpdate bool
 	if obj.Annotations == nil {
 		obj.Annotations = map[string]string{}
 	}
-	obj.Annotations["app.kubernetes.io/created-by"] = "admiral"
+	obj.Annotations[common.CreatedByAnnotation] = common.Admiral
 	if exist == nil || exist.Spec.Hosts == nil {
 		obj.Namespace = namespace
 		obj.ResourceVersion = ""
 		_, err = rc.ServiceEntryController.IstioClient.NetworkingV1alpha3().ServiceEntries(namespace).Create(obj)
 		op = "Add"
 		log.Infof(LogFormat+" SE=%s", op, "ServiceEntry", obj.Name, rc.ClusterID, "New SE", obj.Spec.String())
+	} else if isIstioObjectUnchanged(&obj.Spec, &exist.Spec, obj.ObjectMeta, exist.ObjectMeta) {
+		log.Debugf(LogFormat, "Update", "ServiceEntry", obj.Name, rc.ClusterID, "Skipped as it is unchanged")
+		rc.ServiceEntryGuard.Forget(exist.Namespace, exist.Name)
+		return nil
 	} else {
 		exist.Labels = obj.Labels
 		exist.Annotations = obj.Annotations
@@ -596,7 +871,10 @@ func addUpdateServiceEntry(obj *v1alpha3.ServiceEntry, exist *v1alpha3.ServiceEn
 		}
 		if skipUpdate {
 			log.Infof(LogFormat, op, "ServiceEntry", obj.Name, rc.ClusterID, "Update skipped as it was destructive during Admiral's bootup phase")
-			return
+			return nil
+		} else if allowed, reason := rc.ServiceEntryGuard.Allow(obj, exist); !allowed {
+			log.Warnf(LogFormat, op, "ServiceEntry", obj.Name, rc.ClusterID, "Update blocked until confirmed as it "+reason)
+			return nil
 		} else {
 			exist.Spec = obj.Spec
 			_, err = rc.ServiceEntryController.IstioClient.NetworkingV1alpha3().ServiceEntries(namespace).Update(exist)
@@ -609,13 +887,20 @@ func addUpdateServiceEntry(obj *v1alpha3.ServiceEntry, exist *v1alpha3.ServiceEn
 	} else {
 		log.Infof(LogFormat, op, "ServiceEntry", obj.Name, rc.ClusterID, "Success")
 	}
+	return err
+}
+
+//Returns true if the object in the cluster already has the spec, labels and annotations Admiral would write
+func isIstioObjectUnchanged(spec proto.Message, existSpec proto.Message, meta v12.ObjectMeta, existMeta v12.ObjectMeta) bool {
+	return proto.Equal(spec, existSpec) && reflect.DeepEqual(meta.Labels, existMeta.Labels) && reflect.DeepEqual(meta.Annotations, existMeta.Annotations)
 }
 
 func skipDestructiveUpdate(rc *RemoteController, new *v1alpha3.ServiceEntry, old *v1alpha3.ServiceEntry) (skipDestructive bool, diff string) {
 	skipDestructive = false
 	destructive, diff := getServiceEntryDiff(new, old)
-	//do not update SEs during bootup phase if they are destructive
-	if time.Since(rc.StartTime) < (2*common.GetAdmiralParams().CacheRefreshDuration) && destructive {
+	//do not update SEs while the caches are incomplete if they are destructive, the endpoints of a service entry come from the caches of
+	//the other clusters as well
+	if destructive && (!rc.HasSynced() || (rc.registrySynced != nil && !rc.registrySynced())) {
 		skipDestructive = true
 	}
 
@@ -670,22 +955,46 @@ func deleteServiceEntry(exist *v1alpha3.ServiceEntry, namespace string, rc *Remo
 			log.Errorf(LogErrFormat, "Delete", "ServiceEntry", exist.Name, rc.ClusterID, err)
 		} else {
 			log.Infof(LogFormat, "Delete", "ServiceEntry", exist.Name, rc.ClusterID, "Success")
+			rc.ServiceEntryGuard.Forget(exist.Namespace, exist.Name)
 		}
 	}
 }
 
-func addUpdateDestinationRule(obj *v1alpha3.DestinationRule, exist *v1alpha3.DestinationRule, namespace string, rc *RemoteController) {
+//Deletes the service entry unless removing its endpoints is a destructive update that is skipped or blocked, like the updates removing
+//them all. Returns true if the service entry doesn't exist anymore
+func deleteGuardedServiceEntry(exist *v1alpha3.ServiceEntry, namespace string, rc *RemoteController) bool {
+	if exist == nil {
+		return true
+	}
+	withoutEndpoints := exist.DeepCopy()
+	withoutEndpoints.Spec.Endpoints = nil
+	if skipDelete, _ := skipDestructiveUpdate(rc, withoutEndpoints, exist); skipDelete {
+		log.Infof(LogFormat, "Delete", "ServiceEntry", exist.Name, rc.ClusterID, "Delete skipped as it was destructive during Admiral's bootup phase")
+		return false
+	}
+	if allowed, reason := rc.ServiceEntryGuard.Allow(withoutEndpoints, exist); !allowed {
+		log.Warnf(LogFormat, "Delete", "ServiceEntry", exist.Name, rc.ClusterID, "Delete blocked until confirmed as it "+reason)
+		return false
+	}
+	deleteServiceEntry(exist, namespace, rc)
+	return true
+}
+
+func addUpdateDestinationRule(obj *v1alpha3.DestinationRule, exist *v1alpha3.DestinationRule, namespace string, rc *RemoteController) error {
 	var err error
 	var op string
 	if obj.Annotations == nil {
 		obj.Annotations = map[string]string{}
 	}
-	obj.Annotations["app.kubernetes.io/created-by"] = "admiral"
+	obj.Annotations[common.CreatedByAnnotation] = common.Admiral
 	if exist == nil || exist.Name == "" || exist.Spec.Host == "" {
 		obj.Namespace = namespace
 		obj.ResourceVersion = ""
 		_, err = rc.DestinationRuleController.IstioClient.NetworkingV1alpha3().DestinationRules(namespace).Create(obj)
 		op = "Add"
+	} else if isIstioObjectUnchanged(&obj.Spec, &exist.Spec, obj.ObjectMeta, exist.ObjectMeta) {
+		log.Debugf(LogFormat, "Update", "DestinationRule", obj.Name, rc.ClusterID, "Skipped as it is unchanged")
+		return nil
 	} else {
 		exist.Labels = obj.Labels
 		exist.Annotations = obj.Annotations
@@ -699,6 +1008,7 @@ func addUpdateDestinationRule(obj *v1alpha3.DestinationRule, exist *v1alpha3.Des
 	} else {
 		log.Infof(LogFormat, op, "DestinationRule", obj.Name, rc.ClusterID, "Success")
 	}
+	return err
 }
 
 func deleteDestinationRule(exist *v1alpha3.DestinationRule, namespace string, rc *RemoteController) {
@@ -749,6 +1059,35 @@ func getServiceForDeployment(rc *RemoteController, deployment *k8sAppsV1.Deploym
 	return matchedService
 }
 
+func getServiceForStatefulSet(rc *RemoteController, statefulSet *k8sAppsV1.StatefulSet) *k8sV1.Service {
+
+	if statefulSet == nil {
+		return nil
+	}
+
+	cachedServices := rc.ServiceController.Cache.Get(statefulSet.Namespace)
+
+	if cachedServices == nil {
+		return nil
+	}
+	var matchedService *k8sV1.Service
+	for _, service := range cachedServices {
+		var match = common.IsServiceMatch(service.Spec.Selector, statefulSet.Spec.Selector)
+		//make sure the service matches the statefulset Selector and also has a mesh port in the port spec
+		if match {
+			ports := GetMeshPortsForStatefulSet(rc.ClusterID, service, statefulSet)
+			if len(ports) > 0 {
+				matchedService = service
+				//prefer the governing service of the statefulset, it is the one giving the pods their stable names
+				if service.Name == statefulSet.Spec.ServiceName {
+					break
+				}
+			}
+		}
+	}
+	return matchedService
+}
+
 func getDependentClusters(dependents map[string]string, identityClusterCache *common.MapOfMaps, sourceServices map[string]*k8sV1.Service) map[string]string {
 	var dependentClusters = make(map[string]string)
 
@@ -888,6 +1227,11 @@ func getServiceForRollout(rc *RemoteController, rollout *argo.Rollout) map[strin
 			continue
 		}
 
+		if isArgoAnalysisService(service) {
+			log.Infof("Skipping service=%s of an analysis for rollout=%s in namespace=%s and cluster=%s", service.Name, rollout.Name, rollout.Namespace, rc.ClusterID)
+			continue
+		}
+
 		match := common.IsServiceMatch(service.Spec.Selector, rollout.Spec.Selector)
 		//make sure the service matches the rollout Selector and also has a mesh port in the port spec
 		if match {
@@ -910,9 +1254,19 @@ func getServiceForRollout(rc *RemoteController, rollout *argo.Rollout) map[strin
 	return matchedServices
 }
 
+//Returns true if the service was created by an Argo experiment or analysis run, their pods don't take the traffic of the rollout
+func isArgoAnalysisService(service *k8sV1.Service) bool {
+	for _, owner := range service.OwnerReferences {
+		if strings.HasPrefix(owner.APIVersion, rollouts.Group+common.Slash) && (owner.Kind == rollouts.ExperimentKind || owner.Kind == rollouts.AnalysisRunKind) {
+			return true
+		}
+	}
+	return false
+}
+
 func GetServiceWithSuffixMatch(suffix string, services []*k8sV1.Service) string {
 	for _, service := range services {
-		if strings.HasSuffix(service.Name, suffix) {
+		if strings.HasSuffix(service.Name, suffix) && !isArgoAnalysisService(service) {
 			return service.Name
 		}
 	}
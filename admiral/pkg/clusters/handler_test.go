@@ -3,6 +3,7 @@ package clusters
 import (
 	"reflect"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -10,6 +11,7 @@ import (
 	"github.com/gogo/protobuf/types"
 	"github.com/google/go-cmp/cmp"
 	"github.com/istio-ecosystem/admiral/admiral/pkg/apis/admiral/model"
+	v1 "github.com/istio-ecosystem/admiral/admiral/pkg/apis/admiral/v1"
 	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/admiral"
 	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/common"
 	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/istio"
@@ -98,6 +100,123 @@ func TestGetDependentClusters(t *testing.T) {
 
 }
 
+func TestUpdateIdentityDependencyCache(t *testing.T) {
+
+	identityDependencyCache := common.NewMapOfMaps()
+	identityDependencyCache.Put("dest1", "source1", "source1")
+	identityDependencyCache.Put("dest2", "source1", "source1")
+	identityDependencyCache.Put("dest2", "source2", "source2")
+
+	dependency := &v1.Dependency{
+		ObjectMeta: v12.ObjectMeta{Name: "source1-dependency", Namespace: "admiral"},
+		Spec: model.Dependency{
+			Source:       "source1",
+			Destinations: []string{"dest1", "dest3"},
+		},
+	}
+
+	removed := updateIdentityDependencyCache("source1", identityDependencyCache, dependency)
+
+	assert.Equal(t, map[string]string{"dest2": "dest2"}, removed)
+	assert.Equal(t, map[string]string{"source1": "source1"}, identityDependencyCache.Get("dest1").Copy())
+	assert.Equal(t, map[string]string{"source2": "source2"}, identityDependencyCache.Get("dest2").Copy())
+	assert.Equal(t, map[string]string{"source1": "source1"}, identityDependencyCache.Get("dest3").Copy())
+
+	removed = deleteIdentityDependencyCache("source1", identityDependencyCache, dependency)
+
+	assert.Equal(t, map[string]string{"dest1": "dest1", "dest3": "dest3"}, removed)
+	assert.Nil(t, identityDependencyCache.Get("dest1"))
+	assert.Nil(t, identityDependencyCache.Get("dest3"))
+	assert.Equal(t, map[string]string{"source2": "source2"}, identityDependencyCache.Get("dest2").Copy())
+}
+
+func TestCleanupDependentClusters(t *testing.T) {
+
+	cname := "dev.dest.global"
+	gtpCname := "west.dev.dest.global"
+
+	newServiceEntry := func(name, host, identity string) *v1alpha32.ServiceEntry {
+		return &v1alpha32.ServiceEntry{
+			ObjectMeta: v12.ObjectMeta{Name: name, Namespace: "ns", Labels: map[string]string{"identity": identity}},
+			Spec:       v1alpha3.ServiceEntry{Hosts: []string{host}},
+		}
+	}
+	newDestinationRule := func(name, host string) *v1alpha32.DestinationRule {
+		return &v1alpha32.DestinationRule{
+			ObjectMeta: v12.ObjectMeta{Name: name, Namespace: "ns"},
+			Spec:       v1alpha3.DestinationRule{Host: host},
+		}
+	}
+	newRemoteController := func(clusterID string) *RemoteController {
+		fakeIstioClient := istiofake.NewSimpleClientset()
+		fakeIstioClient.NetworkingV1alpha3().ServiceEntries("ns").Create(newServiceEntry("dev.dest.global-se", cname, "dest"))
+		fakeIstioClient.NetworkingV1alpha3().ServiceEntries("ns").Create(newServiceEntry("west.dev.dest.global-se", gtpCname, "dest"))
+		fakeIstioClient.NetworkingV1alpha3().ServiceEntries("ns").Create(newServiceEntry("dev.other.global-se", "dev.other.global", "other"))
+		fakeIstioClient.NetworkingV1alpha3().DestinationRules("ns").Create(newDestinationRule("dev.dest.global-default-dr", cname))
+		fakeIstioClient.NetworkingV1alpha3().DestinationRules("ns").Create(newDestinationRule("west.dev.dest.global-dr", gtpCname))
+		return &RemoteController{
+			ClusterID: clusterID,
+			ServiceEntryController: &istio.ServiceEntryController{
+				IstioClient: fakeIstioClient,
+			},
+			DestinationRuleController: &istio.DestinationRuleController{
+				IstioClient: fakeIstioClient,
+			},
+		}
+	}
+
+	admiralCache := &AdmiralCache{
+		IdentityClusterCache:       common.NewMapOfMaps(),
+		IdentityDependencyCache:    common.NewMapOfMaps(),
+		CnameDependentClusterCache: common.NewMapOfMaps(),
+		CnameIdentityCache:         &sync.Map{},
+		SeClusterCache:             common.NewMapOfMaps(),
+	}
+	admiralCache.CnameIdentityCache.Store(cname, "dest")
+	admiralCache.IdentityClusterCache.Put("dest", "cluster-dest", "cluster-dest")
+	admiralCache.IdentityClusterCache.Put("source1", "cluster-1", "cluster-1")
+	admiralCache.IdentityClusterCache.Put("source2", "cluster-2", "cluster-2")
+	//source1 no longer depends on dest while source2 still does
+	admiralCache.IdentityDependencyCache.Put("dest", "source2", "source2")
+	admiralCache.CnameDependentClusterCache.Put(cname, "cluster-1", "cluster-1")
+	admiralCache.CnameDependentClusterCache.Put(cname, "cluster-2", "cluster-2")
+	admiralCache.SeClusterCache.Put(cname, "cluster-1", "cluster-1")
+	admiralCache.SeClusterCache.Put(cname, "cluster-2", "cluster-2")
+
+	rc1, rc2 := newRemoteController("cluster-1"), newRemoteController("cluster-2")
+	remoteRegistry := &RemoteRegistry{
+		RemoteControllers: map[string]*RemoteController{"cluster-1": rc1, "cluster-2": rc2},
+		AdmiralCache:      admiralCache,
+		StartTime:         time.Now().Add(-time.Hour),
+		informersSynced:   map[string]func() bool{"dependency": func() bool { return false }},
+		readinessWatched:  true,
+	}
+
+	//the cleanup waits for the end of the cache warm up
+	cleanupDependentClusters(map[string]string{"dest": "dest"}, remoteRegistry)
+	runDeferredCleanups(remoteRegistry)
+	if ses, _ := rc1.ServiceEntryController.IstioClient.NetworkingV1alpha3().ServiceEntries("ns").List(v12.ListOptions{}); len(ses.Items) != 3 {
+		t.Errorf("expected the cleanup to be deferred during the cache warm up, got %v", ses.Items)
+	}
+	remoteRegistry.informersSynced = nil
+	runDeferredCleanups(remoteRegistry)
+
+	ses, _ := rc1.ServiceEntryController.IstioClient.NetworkingV1alpha3().ServiceEntries("ns").List(v12.ListOptions{})
+	if len(ses.Items) != 1 || ses.Items[0].Name != "dev.other.global-se" {
+		t.Errorf("expected only the service entry of the other identity to remain in cluster-1, got %v", ses.Items)
+	}
+	drs, _ := rc1.DestinationRuleController.IstioClient.NetworkingV1alpha3().DestinationRules("ns").List(v12.ListOptions{})
+	if len(drs.Items) != 0 {
+		t.Errorf("expected destination rules to be deleted from cluster-1, got %v", drs.Items)
+	}
+	ses, _ = rc2.ServiceEntryController.IstioClient.NetworkingV1alpha3().ServiceEntries("ns").List(v12.ListOptions{})
+	if len(ses.Items) != 3 {
+		t.Errorf("expected service entries to remain in cluster-2, got %v", ses.Items)
+	}
+	assert.Equal(t, map[string]string{"cluster-2": "cluster-2"}, admiralCache.CnameDependentClusterCache.Get(cname).Copy())
+	assert.Equal(t, map[string]string{"cluster-2": "cluster-2"}, admiralCache.SeClusterCache.Get(cname).Copy())
+}
+
 func TestIgnoreIstioResource(t *testing.T) {
 
 	//Struct of test case info. Name is required.
@@ -190,6 +309,27 @@ func TestGetDestinationRule(t *testing.T) {
 		TrafficPolicy: mTLS,
 	}
 
+	opaqueTcpPortSettings := &v1alpha3.TrafficPolicy_PortTrafficPolicy{
+		Port: &v1alpha3.PortSelector{Number: 5432},
+		Tls:  &v1alpha3.TLSSettings{Mode: v1alpha3.TLSSettings_ISTIO_MUTUAL},
+		ConnectionPool: &v1alpha3.ConnectionPoolSettings{Tcp: &v1alpha3.ConnectionPoolSettings_TCPSettings{
+			TcpKeepalive: &v1alpha3.ConnectionPoolSettings_TCPSettings_TcpKeepalive{
+				Time:     &types.Duration{Seconds: DefaultTcpKeepaliveTime},
+				Interval: &types.Duration{Seconds: DefaultTcpKeepaliveInterval},
+			},
+		}},
+		OutlierDetection: outlierDetection,
+	}
+
+	opaqueTcpDr := v1alpha3.DestinationRule{
+		Host: "qa.myservice.global",
+		TrafficPolicy: &v1alpha3.TrafficPolicy{
+			Tls:               &v1alpha3.TLSSettings{Mode: v1alpha3.TLSSettings_ISTIO_MUTUAL},
+			OutlierDetection:  outlierDetection,
+			PortLevelSettings: []*v1alpha3.TrafficPolicy_PortTrafficPolicy{opaqueTcpPortSettings},
+		},
+	}
+
 	basicGtpDr := v1alpha3.DestinationRule{
 		Host: "qa.myservice.global",
 		TrafficPolicy: &v1alpha3.TrafficPolicy{
@@ -221,6 +361,75 @@ func TestGetDestinationRule(t *testing.T) {
 		},
 	}
 
+	zoneFailoverGtpDr := v1alpha3.DestinationRule{
+		Host: "qa.myservice.global",
+		TrafficPolicy: &v1alpha3.TrafficPolicy{
+			Tls: &v1alpha3.TLSSettings{Mode: v1alpha3.TLSSettings_ISTIO_MUTUAL},
+			LoadBalancer: &v1alpha3.LoadBalancerSettings{
+				LbPolicy: &v1alpha3.LoadBalancerSettings_Simple{Simple: v1alpha3.LoadBalancerSettings_ROUND_ROBIN},
+				LocalityLbSetting: &v1alpha3.LocalityLoadBalancerSetting{
+					Distribute: []*v1alpha3.LocalityLoadBalancerSetting_Distribute{
+						{
+							From: "us-west-2/us-west-2a/*",
+							To:   map[string]uint32{"us-west-2/us-west-2a/*": 80, "us-west-2/us-west-2b/*": 20},
+						},
+					},
+				},
+			},
+			OutlierDetection: outlierDetection,
+		},
+	}
+
+	zoneFailoverGTPPolicy := &model.TrafficPolicy{
+		LbType: model.TrafficPolicy_FAILOVER,
+		Target: []*model.TrafficGroup{
+			{Region: "us-west-2/us-west-2a", Weight: 80},
+			{Region: "us-west-2/us-west-2b", Weight: 20},
+		},
+	}
+
+	localityFailoverGtpDr := v1alpha3.DestinationRule{
+		Host: "qa.myservice.global",
+		TrafficPolicy: &v1alpha3.TrafficPolicy{
+			Tls: &v1alpha3.TLSSettings{Mode: v1alpha3.TLSSettings_ISTIO_MUTUAL},
+			LoadBalancer: &v1alpha3.LoadBalancerSettings{
+				LbPolicy: &v1alpha3.LoadBalancerSettings_Simple{Simple: v1alpha3.LoadBalancerSettings_ROUND_ROBIN},
+				LocalityLbSetting: &v1alpha3.LocalityLoadBalancerSetting{
+					Failover: []*v1alpha3.LocalityLoadBalancerSetting_Failover{
+						{From: "us-west-2", To: "us-east-2"},
+						{From: "us-east-2", To: "eu-west-1"},
+					},
+				},
+			},
+			OutlierDetection: outlierDetection,
+		},
+	}
+
+	//the port of the opaque tcp protocol keeps the failover of the gtp
+	multiplePortsGtpDr := v1alpha3.DestinationRule{
+		Host: "qa.myservice.global",
+		TrafficPolicy: &v1alpha3.TrafficPolicy{
+			Tls:              localityFailoverGtpDr.TrafficPolicy.Tls,
+			LoadBalancer:     localityFailoverGtpDr.TrafficPolicy.LoadBalancer,
+			OutlierDetection: outlierDetection,
+			PortLevelSettings: []*v1alpha3.TrafficPolicy_PortTrafficPolicy{{
+				Port:             opaqueTcpPortSettings.Port,
+				Tls:              opaqueTcpPortSettings.Tls,
+				ConnectionPool:   opaqueTcpPortSettings.ConnectionPool,
+				LoadBalancer:     localityFailoverGtpDr.TrafficPolicy.LoadBalancer,
+				OutlierDetection: outlierDetection,
+			}},
+		},
+	}
+
+	localityFailoverGTPPolicy := &model.TrafficPolicy{
+		LbType: model.TrafficPolicy_TOPOLOGY,
+		Failover: []*model.TrafficPolicy_Failover{
+			{From: "us-west-2", To: "us-east-2"},
+			{From: "us-east-2", To: "eu-west-1"},
+		},
+	}
+
 	topologyGTPPolicy := &model.TrafficPolicy{
 		LbType: model.TrafficPolicy_TOPOLOGY,
 		Target: []*model.TrafficGroup{
@@ -260,6 +469,14 @@ func TestGetDestinationRule(t *testing.T) {
 			gtpPolicy:       nil,
 			destinationRule: &noGtpDr,
 		},
+		{
+			name: "Should use istio mutual tls and keepalives on the opaque tcp ports",
+			se: &v1alpha3.ServiceEntry{Hosts: []string{"qa.myservice.global"}, Endpoints: se.Endpoints,
+				Ports: []*v1alpha3.Port{{Number: 80, Name: "http", Protocol: "http"}, {Number: 5432, Name: "tcp-5432", Protocol: "tcp"}}},
+			locality:        "uswest2",
+			gtpPolicy:       nil,
+			destinationRule: &opaqueTcpDr,
+		},
 		{
 			name:            "Should return default DR with empty locality",
 			se:              se,
@@ -281,6 +498,36 @@ func TestGetDestinationRule(t *testing.T) {
 			gtpPolicy:       failoverGTPPolicy,
 			destinationRule: &failoverGtpDr,
 		},
+		{
+			name:            "Should distribute from the region of a cluster in a zone",
+			se:              se,
+			locality:        "uswest2/uswest2a/subzone1",
+			gtpPolicy:       failoverGTPPolicy,
+			destinationRule: &failoverGtpDr,
+		},
+		{
+			name:            "Should handle a failover GTP targeting zones",
+			se:              se,
+			locality:        "us-west-2/us-west-2a/subzone1",
+			gtpPolicy:       zoneFailoverGTPPolicy,
+			destinationRule: &zoneFailoverGtpDr,
+		},
+		{
+			name:            "Should handle a topology GTP with a failover chain",
+			se:              se,
+			locality:        "uswest2",
+			gtpPolicy:       localityFailoverGTPPolicy,
+			destinationRule: &localityFailoverGtpDr,
+		},
+		{
+			name: "Should apply the GTP to every port of a service entry with multiple ports",
+			se: &v1alpha3.ServiceEntry{Hosts: []string{"qa.myservice.global"}, Endpoints: se.Endpoints,
+				Ports: []*v1alpha3.Port{{Number: 80, Name: "http", Protocol: "http"}, {Number: 8090, Name: "grpc-8090", Protocol: "grpc"},
+					{Number: 5432, Name: "tcp-5432", Protocol: "tcp"}}},
+			locality:        "uswest2",
+			gtpPolicy:       localityFailoverGTPPolicy,
+			destinationRule: &multiplePortsGtpDr,
+		},
 	}
 
 	//Run the test for every provided case
@@ -294,6 +541,62 @@ func TestGetDestinationRule(t *testing.T) {
 	}
 }
 
+func TestValidateFailover(t *testing.T) {
+	knownRegions := map[string]string{"us-west-2": "us-west-2", "us-east-2": "us-east-2"}
+
+	testCases := []struct {
+		name        string
+		gtpPolicy   *model.TrafficPolicy
+		expectedErr bool
+	}{
+		{
+			name:      "Should allow a policy without failover",
+			gtpPolicy: &model.TrafficPolicy{LbType: model.TrafficPolicy_FAILOVER},
+		},
+		{
+			name: "Should allow a failover chain between known regions",
+			gtpPolicy: &model.TrafficPolicy{Failover: []*model.TrafficPolicy_Failover{
+				{From: "us-west-2", To: "us-east-2"}, {From: "us-east-2", To: "us-west-2"}}},
+		},
+		{
+			name: "Should reject failover with lbType FAILOVER",
+			gtpPolicy: &model.TrafficPolicy{LbType: model.TrafficPolicy_FAILOVER, Failover: []*model.TrafficPolicy_Failover{
+				{From: "us-west-2", To: "us-east-2"}}},
+			expectedErr: true,
+		},
+		{
+			name:        "Should reject failover to an unknown region",
+			gtpPolicy:   &model.TrafficPolicy{Failover: []*model.TrafficPolicy_Failover{{From: "us-west-2", To: "eu-west-1"}}},
+			expectedErr: true,
+		},
+		{
+			name:        "Should reject failover to the same region",
+			gtpPolicy:   &model.TrafficPolicy{Failover: []*model.TrafficPolicy_Failover{{From: "us-west-2", To: "us-west-2"}}},
+			expectedErr: true,
+		},
+		{
+			name:        "Should reject failover with a missing region",
+			gtpPolicy:   &model.TrafficPolicy{Failover: []*model.TrafficPolicy_Failover{{From: "us-west-2"}}},
+			expectedErr: true,
+		},
+		{
+			name: "Should reject a region failing over more than once",
+			gtpPolicy: &model.TrafficPolicy{Failover: []*model.TrafficPolicy_Failover{
+				{From: "us-west-2", To: "us-east-2"}, {From: "us-west-2", To: "us-east-2"}}},
+			expectedErr: true,
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			err := validateFailover(c.gtpPolicy, knownRegions)
+			if c.expectedErr != (err != nil) {
+				t.Errorf("expected error: %v, got %v", c.expectedErr, err)
+			}
+		})
+	}
+}
+
 func TestGetOutlierDetection(t *testing.T) {
 	//Do setup here
 	outlierDetection := &v1alpha3.OutlierDetection{
@@ -1355,6 +1658,46 @@ func TestGetServiceForRolloutBlueGreen(t *testing.T) {
 	}
 }
 
+func TestGetServiceForRolloutSkipsAnalysisServices(t *testing.T) {
+	config := rest.Config{
+		Host: "localhost",
+	}
+	s, e := admiral.NewServiceController("test", make(chan struct{}), &test.MockServiceHandler{}, &config, time.Second*time.Duration(300))
+	if e != nil {
+		t.Fatalf("Inititalization failed")
+	}
+	rc := &RemoteController{ClusterID: "test", ServiceController: s}
+
+	ports := []coreV1.ServicePort{{Name: "http", Port: 8080}}
+	selector := map[string]string{"app": "test"}
+	experimentService := &coreV1.Service{
+		ObjectMeta: v12.ObjectMeta{Name: "test-experiment", Namespace: "namespace", CreationTimestamp: v12.Now(), OwnerReferences: []v12.OwnerReference{
+			{APIVersion: "argoproj.io/v1alpha1", Kind: "Experiment", Name: "test-experiment"},
+		}},
+		Spec: coreV1.ServiceSpec{Selector: selector, Ports: ports},
+	}
+	service := &coreV1.Service{
+		ObjectMeta: v12.ObjectMeta{Name: "test", Namespace: "namespace"},
+		Spec:       coreV1.ServiceSpec{Selector: selector, Ports: ports},
+	}
+	s.Cache.Put(experimentService)
+	s.Cache.Put(service)
+
+	canaryRollout := argo.Rollout{Spec: argo.RolloutSpec{
+		Selector: &v12.LabelSelector{MatchLabels: selector},
+		Strategy: argo.RolloutStrategy{Canary: &argo.CanaryStrategy{}},
+	}}
+	canaryRollout.Namespace = "namespace"
+
+	services := getServiceForRollout(rc, &canaryRollout)
+	if len(services) != 1 || services["test"] == nil {
+		t.Errorf("Expected the service of the experiment to be skipped, got %v", services)
+	}
+	if !isArgoAnalysisService(experimentService) || isArgoAnalysisService(service) {
+		t.Errorf("Expected only the service owned by the experiment to be an analysis service")
+	}
+}
+
 func TestSkipDestructiveUpdate(t *testing.T) {
 
 	twoEndpointSe := v1alpha3.ServiceEntry{
@@ -1424,11 +1767,16 @@ func TestSkipDestructiveUpdate(t *testing.T) {
 	}
 
 	rcWarmupPhase := &RemoteController{
-		StartTime: time.Now(),
+		informersSynced: map[string]func() bool{"serviceentry": func() bool { return false }},
 	}
 
 	rcNotinWarmupPhase := &RemoteController{
-		StartTime: time.Now().Add(time.Duration(-21) * time.Minute),
+		informersSynced: map[string]func() bool{"serviceentry": func() bool { return true }},
+	}
+
+	rcRegistryWarmupPhase := &RemoteController{
+		informersSynced: map[string]func() bool{"serviceentry": func() bool { return true }},
+		registrySynced:  func() bool { return false },
 	}
 
 	//Struct of test case info. Name is required.
@@ -1488,6 +1836,22 @@ func TestSkipDestructiveUpdate(t *testing.T) {
 			skipDestructive: true,
 			diff:            "Update",
 		},
+		{
+			name:            "Should return true when the cluster has synced but the other clusters haven't and is destructive",
+			rc:              rcRegistryWarmupPhase,
+			newSe:           newSeOneEndpoint,
+			oldSe:           oldSeTwoEndpoints,
+			skipDestructive: true,
+			diff:            "Delete",
+		},
+		{
+			name:            "Should return false when the cluster has synced but the other clusters haven't and is constructive",
+			rc:              rcRegistryWarmupPhase,
+			newSe:           newSeTwoEndpoints,
+			oldSe:           oldSeOneEndpoint,
+			skipDestructive: false,
+			diff:            "Add",
+		},
 	}
 
 	//Run the test for every provided case
@@ -1557,12 +1921,12 @@ func TestAddUpdateServiceEntry(t *testing.T) {
 
 	rcWarmupPhase := &RemoteController{
 		ServiceEntryController: seCtrl,
-		StartTime:              time.Now(),
+		informersSynced:        map[string]func() bool{"serviceentry": func() bool { return false }},
 	}
 
 	rcNotinWarmupPhase := &RemoteController{
 		ServiceEntryController: seCtrl,
-		StartTime:              time.Now().Add(time.Duration(-21) * time.Minute),
+		informersSynced:        map[string]func() bool{"serviceentry": func() bool { return true }},
 	}
 
 	//Struct of test case info. Name is required.
@@ -0,0 +1,136 @@
+package clusters
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/admiral"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/common"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/util/flowcontrol"
+	"k8s.io/client-go/util/workqueue"
+)
+
+//IdentityQueue coalesces the events of the workloads, services, GTPs and virtual services of an identity into a single sync per identity
+//and env. A key is queued at most once, and is synced from the state of the caches when it is processed whatever the events were, so
+//the delete of the last workload of a cluster doesn't strip the endpoints of the other clusters
+type IdentityQueue struct {
+	remoteRegistry *RemoteRegistry
+	queue          workqueue.Interface
+	//nil when the syncs are not rate limited
+	limiter flowcontrol.RateLimiter
+	mutex   sync.Mutex
+	//the keys waiting in the queue
+	pending map[identityKey]pendingSync
+}
+
+type identityKey struct {
+	identity string
+	env      string
+}
+
+type pendingSync struct {
+	//when the key was queued, the events received afterwards don't change it
+	queued time.Time
+	//closed once the key is synced
+	done chan struct{}
+}
+
+func NewIdentityQueue(remoteRegistry *RemoteRegistry, qps float32) *IdentityQueue {
+	q := &IdentityQueue{
+		remoteRegistry: remoteRegistry,
+		queue:          workqueue.NewNamed("identity"),
+		pending:        make(map[identityKey]pendingSync),
+	}
+	if qps > 0 {
+		burst := int(qps)
+		if burst < 1 {
+			burst = 1
+		}
+		q.limiter = flowcontrol.NewTokenBucketRateLimiter(qps, burst)
+	}
+	return q
+}
+
+//Enqueue queues a sync of the identity in env, unless one is already waiting. The returned channel is closed once the sync is done,
+//or right away if the queue is shut down
+func (q *IdentityQueue) Enqueue(env string, identity string) <-chan struct{} {
+	key := identityKey{identity: identity, env: env}
+	q.mutex.Lock()
+	if q.queue.ShuttingDown() {
+		q.mutex.Unlock()
+		done := make(chan struct{})
+		close(done)
+		return done
+	}
+	entry, ok := q.pending[key]
+	if !ok {
+		entry = pendingSync{queued: time.Now(), done: make(chan struct{})}
+		q.pending[key] = entry
+	}
+	q.mutex.Unlock()
+
+	q.queue.Add(key)
+	common.IdentityQueueDepth.With().Set(float64(q.queue.Len()))
+	return entry.done
+}
+
+//Run syncs the queued keys with the given number of workers until the context is done
+func (q *IdentityQueue) Run(ctx context.Context, workers int) {
+	log.Infof("starting identity queue, workers=%v", workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for q.processNextItem() {
+			}
+		}()
+	}
+	<-ctx.Done()
+	q.mutex.Lock()
+	q.queue.ShutDown()
+	//the keys still waiting won't be synced
+	for key, entry := range q.pending {
+		close(entry.done)
+		delete(q.pending, key)
+	}
+	q.mutex.Unlock()
+}
+
+func (q *IdentityQueue) processNextItem() bool {
+	item, quit := q.queue.Get()
+	if quit {
+		return false
+	}
+	defer q.queue.Done(item)
+	common.IdentityQueueDepth.With().Set(float64(q.queue.Len()))
+
+	if q.limiter != nil {
+		q.limiter.Accept()
+	}
+
+	key := item.(identityKey)
+	q.mutex.Lock()
+	entry, ok := q.pending[key]
+	delete(q.pending, key)
+	q.mutex.Unlock()
+	if !ok {
+		return true
+	}
+
+	common.IdentityQueueLatency.With().Observe(time.Since(entry.queued).Seconds())
+	start := time.Now()
+	modifyServiceEntryForNewServiceOrPod(admiral.Update, key.env, key.identity, q.remoteRegistry)
+	common.IdentitySyncDuration.With().Observe(time.Since(start).Seconds())
+	close(entry.done)
+	return true
+}
+
+//Syncs the config of the identity in env, through the identity queue when there is one. The queue syncs from the state of the caches,
+//the event only matters when syncing in the event handler
+func syncIdentity(event admiral.EventType, env string, identity string, remoteRegistry *RemoteRegistry) {
+	if remoteRegistry.IdentityQueue != nil {
+		remoteRegistry.IdentityQueue.Enqueue(env, identity)
+		return
+	}
+	modifyServiceEntryForNewServiceOrPod(event, env, identity, remoteRegistry)
+}
@@ -0,0 +1,52 @@
+package clusters
+
+import (
+	"testing"
+
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/admiral"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/common"
+)
+
+func TestIdentityQueue(t *testing.T) {
+	common.InitializeConfig(common.AdmiralParams{SyncNamespace: "ns"})
+	rr := &RemoteRegistry{RemoteControllers: map[string]*RemoteController{}}
+	//the syncs are skipped during the cache warm up
+	rr.informersSynced = map[string]func() bool{"dependency": func() bool { return false }}
+	q := NewIdentityQueue(rr, 0)
+	rr.IdentityQueue = q
+
+	syncIdentity(admiral.Add, "stage", "a", rr)
+	queued := q.pending[identityKey{identity: "a", env: "stage"}].queued
+	syncIdentity(admiral.Update, "stage", "a", rr)
+	syncIdentity(admiral.Delete, "stage", "a", rr)
+	syncIdentity(admiral.Add, "qa", "a", rr)
+
+	if q.queue.Len() != 2 {
+		t.Fatalf("Expected the events of an identity and env to be coalesced into 2 keys, got %v", q.queue.Len())
+	}
+	entry := q.pending[identityKey{identity: "a", env: "stage"}]
+	if entry.queued != queued {
+		t.Errorf("Expected the queued time to be the one of the first event, got %v expected %v", entry.queued, queued)
+	}
+
+	q.processNextItem()
+	q.processNextItem()
+	if q.queue.Len() != 0 || len(q.pending) != 0 {
+		t.Errorf("Expected all the keys to be synced, got %v queued and %v pending", q.queue.Len(), len(q.pending))
+	}
+	select {
+	case <-entry.done:
+	default:
+		t.Errorf("Expected the waiters of the key to be notified once it is synced")
+	}
+
+	syncIdentity(admiral.Add, "stage", "a", rr)
+	if q.queue.Len() != 1 {
+		t.Errorf("Expected a synced key to be queued again, got %v keys", q.queue.Len())
+	}
+	q.queue.ShutDown()
+	q.processNextItem()
+	if q.processNextItem() {
+		t.Errorf("Expected the workers to stop once the queue is shut down")
+	}
+}
@@ -0,0 +1,318 @@
+package clusters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/common"
+	log "github.com/sirupsen/logrus"
+	v12 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+//Orphan is an object written by Admiral to the sync namespace of a cluster that Admiral wouldn't generate anymore
+type Orphan struct {
+	Cluster string `json:"cluster"`
+	Kind    string `json:"kind"`
+	Name    string `json:"name"`
+	Host    string `json:"host"`
+	Deleted bool   `json:"deleted"`
+}
+
+//OrphanReport lists the orphans found in all the clusters by a collection
+type OrphanReport struct {
+	DryRun  bool     `json:"dryRun"`
+	Orphans []Orphan `json:"orphans"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+//Periodically deletes the objects Admiral wrote to the sync namespace of the clusters and wouldn't generate anymore, until the context is done
+//In dry run mode the orphans are only logged and counted
+func StartOrphanCollector(ctx context.Context, remoteRegistry *RemoteRegistry, interval time.Duration, dryRun bool) {
+	log.Infof("starting orphan collector, interval=%v dry run=%v", interval, dryRun)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := CollectOrphans(remoteRegistry, dryRun); err != nil {
+				log.Warnf(LogErrFormat, "Collect", "Orphans", "", "", err)
+			}
+		}
+	}
+}
+
+//Finds the service entries, destination rules and virtual services owned by Admiral in the sync namespace of every cluster that don't match
+//the state of the caches anymore, and deletes them unless dryRun is set
+func CollectOrphans(remoteRegistry *RemoteRegistry, dryRun bool) (*OrphanReport, error) {
+	if IsCacheWarmupTime(remoteRegistry) {
+		return nil, errors.New("orphans are not collected during cache warm up")
+	}
+
+	remoteRegistry.Lock()
+	remoteControllers := make(map[string]*RemoteController, len(remoteRegistry.RemoteControllers))
+	for clusterId, rc := range remoteRegistry.RemoteControllers {
+		remoteControllers[clusterId] = rc
+	}
+	remoteRegistry.Unlock()
+
+	report := &OrphanReport{DryRun: dryRun, Orphans: make([]Orphan, 0)}
+	for clusterId, rc := range remoteControllers {
+		//the objects of a cluster can't be compared to its workloads before they are all listed
+		if !rc.HasSynced() {
+			report.Errors = append(report.Errors, fmt.Sprintf("cluster=%s: skipped as its controllers haven't synced", clusterId))
+			continue
+		}
+		orphans, err := collectOrphansInCluster(remoteRegistry, remoteControllers, rc, dryRun)
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("cluster=%s: %v", clusterId, err))
+		}
+		counts := map[string]int{"ServiceEntry": 0, "DestinationRule": 0, "VirtualService": 0}
+		for _, orphan := range orphans {
+			counts[orphan.Kind]++
+		}
+		for kind, count := range counts {
+			common.OrphansFound.With(clusterId, kind).Set(float64(count))
+		}
+		report.Orphans = append(report.Orphans, orphans...)
+	}
+	return report, nil
+}
+
+func collectOrphansInCluster(remoteRegistry *RemoteRegistry, remoteControllers map[string]*RemoteController, rc *RemoteController, dryRun bool) ([]Orphan, error) {
+	if rc.ServiceEntryController == nil || rc.DestinationRuleController == nil || rc.VirtualServiceController == nil {
+		return nil, errors.New("istio controllers not initialized")
+	}
+	syncNamespace := common.GetSyncNamespace()
+	orphans := make([]Orphan, 0)
+
+	serviceEntries, err := rc.ServiceEntryController.IstioClient.NetworkingV1alpha3().ServiceEntries(syncNamespace).List(v12.ListOptions{})
+	if err != nil {
+		return orphans, err
+	}
+	for _, serviceEntry := range serviceEntries.Items {
+		if !isCreatedByAdmiral(serviceEntry.Annotations) || len(serviceEntry.Spec.Hosts) == 0 {
+			continue
+		}
+		host := serviceEntry.Spec.Hosts[0]
+		if isDesiredHost(remoteRegistry, remoteControllers, host, rc.ClusterID) {
+			continue
+		}
+		orphan := Orphan{Cluster: rc.ClusterID, Kind: "ServiceEntry", Name: serviceEntry.Name, Host: host}
+		if !dryRun {
+			err = rc.ServiceEntryController.IstioClient.NetworkingV1alpha3().ServiceEntries(syncNamespace).Delete(serviceEntry.Name, &v12.DeleteOptions{})
+			orphan.Deleted = err == nil
+		}
+		orphans = append(orphans, logOrphan(orphan, err))
+	}
+
+	destinationRules, err := rc.DestinationRuleController.IstioClient.NetworkingV1alpha3().DestinationRules(syncNamespace).List(v12.ListOptions{})
+	if err != nil {
+		return orphans, err
+	}
+	for _, destinationRule := range destinationRules.Items {
+		host := destinationRule.Spec.Host
+		//destination rules for local fqdns can't be traced back to a cname
+		if !isCreatedByAdmiral(destinationRule.Annotations) || strings.HasSuffix(host, common.DotLocalDomainSuffix) {
+			continue
+		}
+		if isDesiredHost(remoteRegistry, remoteControllers, host, rc.ClusterID) {
+			continue
+		}
+		orphan := Orphan{Cluster: rc.ClusterID, Kind: "DestinationRule", Name: destinationRule.Name, Host: host}
+		if !dryRun {
+			err = rc.DestinationRuleController.IstioClient.NetworkingV1alpha3().DestinationRules(syncNamespace).Delete(destinationRule.Name, &v12.DeleteOptions{})
+			orphan.Deleted = err == nil
+		}
+		orphans = append(orphans, logOrphan(orphan, err))
+	}
+
+	virtualServices, err := rc.VirtualServiceController.IstioClient.NetworkingV1alpha3().VirtualServices(syncNamespace).List(v12.ListOptions{})
+	if err != nil {
+		return orphans, err
+	}
+	for _, virtualService := range virtualServices.Items {
+		if !isCreatedByAdmiral(virtualService.Annotations) || len(virtualService.Spec.Hosts) == 0 {
+			continue
+		}
+		//a virtual service with several hosts is kept as long as one of them is desired
+		desired := false
+		for _, host := range virtualService.Spec.Hosts {
+			if isDesiredHost(remoteRegistry, remoteControllers, host, rc.ClusterID) {
+				desired = true
+				break
+			}
+		}
+		if desired {
+			continue
+		}
+		orphan := Orphan{Cluster: rc.ClusterID, Kind: "VirtualService", Name: virtualService.Name, Host: strings.Join(virtualService.Spec.Hosts, ",")}
+		if !dryRun {
+			err = rc.VirtualServiceController.IstioClient.NetworkingV1alpha3().VirtualServices(syncNamespace).Delete(virtualService.Name, &v12.DeleteOptions{})
+			orphan.Deleted = err == nil
+		}
+		orphans = append(orphans, logOrphan(orphan, err))
+	}
+	return orphans, nil
+}
+
+func logOrphan(orphan Orphan, err error) Orphan {
+	if err != nil {
+		log.Errorf(LogErrFormat, "Delete", orphan.Kind, orphan.Name, orphan.Cluster, err)
+	} else if orphan.Deleted {
+		log.Infof(LogFormat, "Delete", orphan.Kind, orphan.Name, orphan.Cluster, "Orphan deleted, host="+orphan.Host)
+	} else {
+		log.Infof(LogFormat, "Find", orphan.Kind, orphan.Name, orphan.Cluster, "Orphan found, host="+orphan.Host)
+	}
+	return orphan
+}
+
+func isCreatedByAdmiral(annotations map[string]string) bool {
+	return annotations[common.CreatedByAnnotation] == common.Admiral
+}
+
+//Returns true if Admiral generates objects for the host in the cluster given the current state of its caches
+func isDesiredHost(remoteRegistry *RemoteRegistry, remoteControllers map[string]*RemoteController, host string, clusterId string) bool {
+	cache := remoteRegistry.AdmiralCache
+	if isCnameInCluster(cache, remoteControllers, host, clusterId) {
+		return true
+	}
+
+	//the other hosts are generated on top of a cname: gtp dns prefixes, rollout previews, statefulset pods and destination rule subsets
+	index := strings.Index(host, common.Sep)
+	if index <= 0 {
+		return false
+	}
+	prefix, cname := host[:index], host[index+1:]
+	if !isCnameInCluster(cache, remoteControllers, cname, clusterId) {
+		return false
+	}
+	if len(cache.CnameClusterCache.Get(host).Copy()) > 0 {
+		return true
+	}
+
+	var identity string
+	if identityValue, ok := cache.CnameIdentityCache.Load(cname); ok {
+		identity = fmt.Sprint(identityValue)
+	}
+	env := strings.Split(cname, common.Sep)[0]
+	if globalTrafficPolicy := cache.GlobalTrafficCache.GetFromIdentity(identity, env); globalTrafficPolicy != nil {
+		for _, trafficPolicy := range globalTrafficPolicy.Spec.Policy {
+			if trafficPolicy.DnsPrefix == prefix {
+				return true
+			}
+		}
+	}
+	if isSubsetHost(cache, cname, prefix) {
+		return true
+	}
+	return isStatefulSetPodHost(remoteRegistry, identity, prefix)
+}
+
+//Returns true if the service entries of the cname are written to the cluster, either as a source or as a dependent cluster.
+//The cname caches aren't pruned when the workloads are deleted, so a workload generating the cname must still be in the workload caches
+//of the cluster itself for a source cluster, or of any source cluster for a dependent cluster
+func isCnameInCluster(cache *AdmiralCache, remoteControllers map[string]*RemoteController, cname string, clusterId string) bool {
+	sourceClusters := cache.CnameClusterCache.Get(cname).Copy()
+	_, source := sourceClusters[clusterId]
+	_, dependent := cache.CnameDependentClusterCache.Get(cname).Copy()[clusterId]
+	if !source && !dependent {
+		return false
+	}
+	identityValue, ok := cache.CnameIdentityCache.Load(cname)
+	if !ok {
+		return false
+	}
+	identity := fmt.Sprint(identityValue)
+	for sourceCluster := range sourceClusters {
+		rc := remoteControllers[sourceCluster]
+		if rc == nil {
+			continue
+		}
+		//the workloads of the cluster aren't all known yet
+		if !rc.HasSynced() {
+			return true
+		}
+		if (dependent || sourceCluster == clusterId) && hasWorkloadForCname(rc, identity, cname) {
+			return true
+		}
+	}
+	return false
+}
+
+//Returns true if a deployment, rollout or statefulset of the identity in the cluster generates the cname
+func hasWorkloadForCname(rc *RemoteController, identity string, cname string) bool {
+	identifier, suffix := common.GetWorkloadIdentifier(), common.GetHostnameSuffix()
+	if rc.DeploymentController != nil {
+		if entry := rc.DeploymentController.Cache.Get(identity); entry != nil {
+			for _, deployment := range entry.Deployments {
+				if common.GetCname(deployment, identifier, suffix) == cname {
+					return true
+				}
+			}
+		}
+	}
+	if rc.RolloutController != nil {
+		if entry := rc.RolloutController.Cache.Get(identity); entry != nil {
+			for _, rollout := range entry.Rollouts {
+				if common.GetCnameForRollout(rollout, identifier, suffix) == cname {
+					return true
+				}
+			}
+		}
+	}
+	if rc.StatefulSetController != nil {
+		if entry := rc.StatefulSetController.Cache.Get(identity); entry != nil {
+			for _, statefulSet := range entry.StatefulSets {
+				if common.GetCnameForStatefulSet(statefulSet, identifier, suffix) == cname {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+//Returns true if the prefix is the name of a pod of a statefulset of the identity that has per pod hosts enabled
+func isStatefulSetPodHost(remoteRegistry *RemoteRegistry, identity string, prefix string) bool {
+	if len(identity) == 0 {
+		return false
+	}
+	remoteRegistry.Lock()
+	defer remoteRegistry.Unlock()
+	for _, rc := range remoteRegistry.RemoteControllers {
+		if rc.StatefulSetController == nil {
+			continue
+		}
+		entry := rc.StatefulSetController.Cache.Get(identity)
+		if entry == nil {
+			continue
+		}
+		for _, statefulSet := range entry.StatefulSets {
+			if statefulSet.Spec.Template.Annotations[common.StatefulSetPodHostsAnnotation] != "true" {
+				continue
+			}
+			podPrefix := strings.ToLower(statefulSet.Name) + common.Dash
+			if !strings.HasPrefix(prefix, podPrefix) {
+				continue
+			}
+			ordinal, err := strconv.Atoi(strings.TrimPrefix(prefix, podPrefix))
+			if err != nil {
+				continue
+			}
+			var replicas int32 = 1
+			if statefulSet.Spec.Replicas != nil {
+				replicas = *statefulSet.Spec.Replicas
+			}
+			if ordinal >= 0 && int32(ordinal) < replicas {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,257 @@
+package clusters
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/istio-ecosystem/admiral/admiral/pkg/apis/admiral/model"
+	v13 "github.com/istio-ecosystem/admiral/admiral/pkg/apis/admiral/v1"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/admiral"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/common"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/istio"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/test"
+	istionetworkingv1alpha3 "istio.io/api/networking/v1alpha3"
+	"istio.io/client-go/pkg/apis/networking/v1alpha3"
+	istiofake "istio.io/client-go/pkg/clientset/versioned/fake"
+	k8sAppsV1 "k8s.io/api/apps/v1"
+	k8sV1 "k8s.io/api/core/v1"
+	v12 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+)
+
+//returns a deployment controller with a deployment of each identity in the stage env
+func newOrphanTestDeploymentController(t *testing.T, identities ...string) *admiral.DeploymentController {
+	config := rest.Config{Host: "localhost"}
+	deploymentController, err := admiral.NewDeploymentController("cluster1", make(chan struct{}), &test.MockDeploymentHandler{}, &config, time.Minute)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	for _, identity := range identities {
+		deploymentController.Cache.UpdateDeploymentToClusterCache(identity, &k8sAppsV1.Deployment{
+			ObjectMeta: v12.ObjectMeta{Name: identity, Namespace: "ns"},
+			Spec: k8sAppsV1.DeploymentSpec{Template: k8sV1.PodTemplateSpec{ObjectMeta: v12.ObjectMeta{
+				Labels: map[string]string{"identity": identity, "env": "stage"},
+			}}},
+		})
+	}
+	return deploymentController
+}
+
+func TestIsDesiredHost(t *testing.T) {
+	rr := &RemoteRegistry{RemoteControllers: map[string]*RemoteController{}}
+	rr.AdmiralCache = &AdmiralCache{
+		CnameClusterCache:          common.NewMapOfMaps(),
+		CnameDependentClusterCache: common.NewMapOfMaps(),
+		CnameIdentityCache:         &sync.Map{},
+		GlobalTrafficCache:         &globalTrafficCache{identityCache: make(map[string]*v13.GlobalTrafficPolicy), mutex: &sync.Mutex{}},
+	}
+	rr.AdmiralCache.CnameClusterCache.Put("stage.a.mesh", "cluster1", "cluster1")
+	rr.AdmiralCache.CnameClusterCache.Put("preview.stage.a.mesh", "cluster1", "cluster1")
+	rr.AdmiralCache.CnameDependentClusterCache.Put("stage.a.mesh", "cluster2", "cluster2")
+	rr.AdmiralCache.CnameIdentityCache.Store("stage.a.mesh", "a")
+	//the deployment of b was deleted, the cname caches still have its cname
+	rr.AdmiralCache.CnameClusterCache.Put("stage.b.mesh", "cluster1", "cluster1")
+	rr.AdmiralCache.CnameDependentClusterCache.Put("stage.b.mesh", "cluster2", "cluster2")
+	rr.AdmiralCache.CnameIdentityCache.Store("stage.b.mesh", "b")
+	rr.RemoteControllers["cluster1"] = &RemoteController{ClusterID: "cluster1", DeploymentController: newOrphanTestDeploymentController(t, "a")}
+	rr.AdmiralCache.GlobalTrafficCache.identityCache[common.ConstructGtpKey("stage", "a")] = &v13.GlobalTrafficPolicy{
+		Spec: model.GlobalTrafficPolicy{Policy: []*model.TrafficPolicy{{DnsPrefix: "west"}}},
+	}
+
+	testCases := []struct {
+		name      string
+		host      string
+		clusterId string
+		expected  bool
+	}{
+		{
+			name:      "Given a cname of a source cluster, should be desired",
+			host:      "stage.a.mesh",
+			clusterId: "cluster1",
+			expected:  true,
+		},
+		{
+			name:      "Given a cname of a dependent cluster, should be desired",
+			host:      "stage.a.mesh",
+			clusterId: "cluster2",
+			expected:  true,
+		},
+		{
+			name:      "Given a cname not written to the cluster, should not be desired",
+			host:      "stage.a.mesh",
+			clusterId: "cluster3",
+		},
+		{
+			name:      "Given an unknown cname, should not be desired",
+			host:      "stage.d.mesh",
+			clusterId: "cluster1",
+		},
+		{
+			name:      "Given a cname of a deleted workload, should not be desired in the source cluster",
+			host:      "stage.b.mesh",
+			clusterId: "cluster1",
+		},
+		{
+			name:      "Given a cname of a deleted workload, should not be desired in the dependent clusters",
+			host:      "stage.b.mesh",
+			clusterId: "cluster2",
+		},
+		{
+			name:      "Given a dns prefix of the gtp, should be desired",
+			host:      "west.stage.a.mesh",
+			clusterId: "cluster2",
+			expected:  true,
+		},
+		{
+			name:      "Given a dns prefix that is no longer in the gtp, should not be desired",
+			host:      "east.stage.a.mesh",
+			clusterId: "cluster1",
+		},
+		{
+			name:      "Given a rollout preview host, should be desired in the dependent clusters",
+			host:      "preview.stage.a.mesh",
+			clusterId: "cluster2",
+			expected:  true,
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			if desired := isDesiredHost(rr, rr.RemoteControllers, c.host, c.clusterId); desired != c.expected {
+				t.Errorf("Expected desired=%v for host=%v in cluster=%v, got %v", c.expected, c.host, c.clusterId, desired)
+			}
+		})
+	}
+}
+
+func TestCollectOrphans(t *testing.T) {
+	common.InitializeConfig(common.AdmiralParams{SyncNamespace: "ns"})
+	syncNamespace := common.GetSyncNamespace()
+	owned := map[string]string{common.CreatedByAnnotation: common.Admiral}
+
+	fakeIstioClient := istiofake.NewSimpleClientset()
+	serviceEntries := fakeIstioClient.NetworkingV1alpha3().ServiceEntries(syncNamespace)
+	serviceEntries.Create(&v1alpha3.ServiceEntry{
+		ObjectMeta: v12.ObjectMeta{Name: "stage.a.mesh-se", Namespace: syncNamespace, Annotations: owned},
+		Spec:       istionetworkingv1alpha3.ServiceEntry{Hosts: []string{"stage.a.mesh"}},
+	})
+	serviceEntries.Create(&v1alpha3.ServiceEntry{
+		ObjectMeta: v12.ObjectMeta{Name: "stage.b.mesh-se", Namespace: syncNamespace, Annotations: owned},
+		Spec:       istionetworkingv1alpha3.ServiceEntry{Hosts: []string{"stage.b.mesh"}},
+	})
+	serviceEntries.Create(&v1alpha3.ServiceEntry{
+		ObjectMeta: v12.ObjectMeta{Name: "manual-se", Namespace: syncNamespace},
+		Spec:       istionetworkingv1alpha3.ServiceEntry{Hosts: []string{"stage.c.mesh"}},
+	})
+	destinationRules := fakeIstioClient.NetworkingV1alpha3().DestinationRules(syncNamespace)
+	destinationRules.Create(&v1alpha3.DestinationRule{
+		ObjectMeta: v12.ObjectMeta{Name: "stage.b.mesh-default-dr", Namespace: syncNamespace, Annotations: owned},
+		Spec:       istionetworkingv1alpha3.DestinationRule{Host: "stage.b.mesh"},
+	})
+	destinationRules.Create(&v1alpha3.DestinationRule{
+		ObjectMeta: v12.ObjectMeta{Name: "b-local-dr", Namespace: syncNamespace, Annotations: owned},
+		Spec:       istionetworkingv1alpha3.DestinationRule{Host: "b.ns.svc.cluster.local"},
+	})
+	virtualServices := fakeIstioClient.NetworkingV1alpha3().VirtualServices(syncNamespace)
+	virtualServices.Create(&v1alpha3.VirtualService{
+		ObjectMeta: v12.ObjectMeta{Name: "stage.a.mesh-vs", Namespace: syncNamespace, Annotations: owned},
+		Spec:       istionetworkingv1alpha3.VirtualService{Hosts: []string{"stage.a.mesh"}},
+	})
+
+	rr := &RemoteRegistry{RemoteControllers: map[string]*RemoteController{}}
+	rr.informersSynced = map[string]func() bool{"dependency": func() bool { return false }}
+	rr.AdmiralCache = &AdmiralCache{
+		CnameClusterCache:          common.NewMapOfMaps(),
+		CnameDependentClusterCache: common.NewMapOfMaps(),
+		CnameIdentityCache:         &sync.Map{},
+		GlobalTrafficCache:         &globalTrafficCache{identityCache: make(map[string]*v13.GlobalTrafficPolicy), mutex: &sync.Mutex{}},
+	}
+	rr.AdmiralCache.CnameClusterCache.Put("stage.a.mesh", "cluster1", "cluster1")
+	rr.RemoteControllers["cluster1"] = &RemoteController{
+		ClusterID:                 "cluster1",
+		ServiceEntryController:    &istio.ServiceEntryController{IstioClient: fakeIstioClient},
+		DestinationRuleController: &istio.DestinationRuleController{IstioClient: fakeIstioClient},
+		VirtualServiceController:  &istio.VirtualServiceController{IstioClient: fakeIstioClient},
+		DeploymentController:      newOrphanTestDeploymentController(t, "a"),
+	}
+	rr.AdmiralCache.CnameIdentityCache.Store("stage.a.mesh", "a")
+
+	if _, err := CollectOrphans(rr, false); err == nil {
+		t.Errorf("Expected an error during cache warm up")
+	}
+	rr.informersSynced = nil
+
+	//a cluster left out of the readiness after its sync timeout doesn't hold the collection back, but its objects are not collected
+	unsyncedIstioClient := istiofake.NewSimpleClientset()
+	unsyncedIstioClient.NetworkingV1alpha3().ServiceEntries(syncNamespace).Create(&v1alpha3.ServiceEntry{
+		ObjectMeta: v12.ObjectMeta{Name: "stage.b.mesh-se", Namespace: syncNamespace, Annotations: owned},
+		Spec:       istionetworkingv1alpha3.ServiceEntry{Hosts: []string{"stage.b.mesh"}},
+	})
+	rr.RemoteControllers["cluster2"] = &RemoteController{
+		ClusterID:              "cluster2",
+		ServiceEntryController: &istio.ServiceEntryController{IstioClient: unsyncedIstioClient},
+		informersSynced:        map[string]func() bool{"service": func() bool { return false }},
+		syncDeadline:           time.Now().Add(-time.Second),
+	}
+
+	report, err := CollectOrphans(rr, true)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if len(report.Errors) != 1 || !strings.Contains(report.Errors[0], "cluster=cluster2") {
+		t.Errorf("Expected the unsynced cluster to be reported as skipped, got %v", report.Errors)
+	}
+	expectedOrphans := []string{"DestinationRule/stage.b.mesh-default-dr", "ServiceEntry/stage.b.mesh-se"}
+	if orphans := getOrphanNames(report); !reflect.DeepEqual(orphans, expectedOrphans) {
+		t.Errorf("Unexpected orphans, got %v expected %v", orphans, expectedOrphans)
+	}
+	for _, orphan := range report.Orphans {
+		if orphan.Deleted {
+			t.Errorf("Orphan %v should not be deleted in dry run mode", orphan.Name)
+		}
+	}
+	if _, err := serviceEntries.Get("stage.b.mesh-se", v12.GetOptions{}); err != nil {
+		t.Errorf("Service entry should not be deleted in dry run mode")
+	}
+
+	report, err = CollectOrphans(rr, false)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if orphans := getOrphanNames(report); !reflect.DeepEqual(orphans, expectedOrphans) {
+		t.Errorf("Unexpected orphans, got %v expected %v", orphans, expectedOrphans)
+	}
+	for _, orphan := range report.Orphans {
+		if !orphan.Deleted {
+			t.Errorf("Orphan %v should have been deleted", orphan.Name)
+		}
+	}
+	if _, err := serviceEntries.Get("stage.b.mesh-se", v12.GetOptions{}); err == nil {
+		t.Errorf("Orphaned service entry should have been deleted")
+	}
+	if _, err := destinationRules.Get("stage.b.mesh-default-dr", v12.GetOptions{}); err == nil {
+		t.Errorf("Orphaned destination rule should have been deleted")
+	}
+	if _, err := serviceEntries.Get("manual-se", v12.GetOptions{}); err != nil {
+		t.Errorf("Service entry not owned by Admiral should not be deleted")
+	}
+	if _, err := destinationRules.Get("b-local-dr", v12.GetOptions{}); err != nil {
+		t.Errorf("Destination rule for a local fqdn should not be deleted")
+	}
+	if _, err := unsyncedIstioClient.NetworkingV1alpha3().ServiceEntries(syncNamespace).Get("stage.b.mesh-se", v12.GetOptions{}); err != nil {
+		t.Errorf("Service entry of the unsynced cluster should not be deleted")
+	}
+}
+
+func getOrphanNames(report *OrphanReport) []string {
+	names := make([]string, 0, len(report.Orphans))
+	for _, orphan := range report.Orphans {
+		names = append(names, orphan.Kind+"/"+orphan.Name)
+	}
+	sort.Strings(names)
+	return names
+}
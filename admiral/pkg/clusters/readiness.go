@@ -0,0 +1,129 @@
+package clusters
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+//Readiness reports which of the controllers started by Admiral haven't processed the existing objects yet. The events are only processed
+//once all of them have, the clusters that don't sync within the cluster sync timeout are left out
+type Readiness struct {
+	Ready bool `json:"ready"`
+	//the controllers of the cluster Admiral runs in that haven't synced
+	Pending  []string                    `json:"pending,omitempty"`
+	Clusters map[string]ClusterReadiness `json:"clusters"`
+}
+
+type ClusterReadiness struct {
+	Ready bool `json:"ready"`
+	//the controllers of the cluster that haven't synced
+	Pending []string `json:"pending,omitempty"`
+	//the cluster hasn't synced within the cluster sync timeout, Admiral is ready without it
+	Excluded bool `json:"excluded,omitempty"`
+}
+
+//HasSynced returns true once all the controllers started for the cluster have processed the existing objects
+func (rc *RemoteController) HasSynced() bool {
+	return len(getPendingControllers(rc.informersSynced)) == 0
+}
+
+//Returns true if the cluster hasn't synced by its deadline, it then no longer holds the readiness of Admiral back
+func (rc *RemoteController) isSyncTimedOut() bool {
+	return !rc.syncDeadline.IsZero() && time.Now().After(rc.syncDeadline) && !rc.HasSynced()
+}
+
+//HasSynced returns true once the dependency and secret controllers, and the controllers of every cluster that hasn't timed out have synced
+func (r *RemoteRegistry) HasSynced() bool {
+	r.Lock()
+	defer r.Unlock()
+	if len(getPendingControllers(r.informersSynced)) > 0 {
+		return false
+	}
+	for _, rc := range r.RemoteControllers {
+		if !rc.HasSynced() && !rc.isSyncTimedOut() {
+			return false
+		}
+	}
+	return true
+}
+
+//GetReadiness returns the controllers that haven't synced, for Admiral and for every cluster
+func (r *RemoteRegistry) GetReadiness() Readiness {
+	r.Lock()
+	defer r.Unlock()
+	readiness := Readiness{Pending: getPendingControllers(r.informersSynced), Clusters: make(map[string]ClusterReadiness, len(r.RemoteControllers))}
+	readiness.Ready = len(readiness.Pending) == 0
+	for clusterId, rc := range r.RemoteControllers {
+		pending := getPendingControllers(rc.informersSynced)
+		excluded := len(pending) > 0 && rc.isSyncTimedOut()
+		readiness.Clusters[clusterId] = ClusterReadiness{Ready: len(pending) == 0, Pending: pending, Excluded: excluded}
+		if len(pending) > 0 && !excluded {
+			readiness.Ready = false
+		}
+	}
+	return readiness
+}
+
+//Registers the HasSynced func of a controller of the cluster Admiral runs in
+func (r *RemoteRegistry) addInformerSynced(name string, hasSynced func() bool) {
+	r.Lock()
+	defer r.Unlock()
+	if r.informersSynced == nil {
+		r.informersSynced = make(map[string]func() bool)
+	}
+	r.informersSynced[name] = hasSynced
+}
+
+//Returns the sorted names of the controllers whose informers haven't synced
+func getPendingControllers(informersSynced map[string]func() bool) []string {
+	var pending []string
+	for name, hasSynced := range informersSynced {
+		if !hasSynced() {
+			pending = append(pending, name)
+		}
+	}
+	sort.Strings(pending)
+	return pending
+}
+
+//The events received before all the informers have synced are skipped, so the config of every identity is regenerated each time they
+//all become synced, at startup and after a cluster is added. Checks every interval until the context is done
+func StartReadinessWatcher(ctx context.Context, remoteRegistry *RemoteRegistry, interval time.Duration, qps float32) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	ready := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !remoteRegistry.HasSynced() {
+				if ready {
+					log.Info("informers not synced, processing paused")
+				}
+				ready = false
+				continue
+			}
+			if ready {
+				continue
+			}
+			for clusterId, cluster := range remoteRegistry.GetReadiness().Clusters {
+				if cluster.Excluded {
+					log.Warnf(LogFormat, "Sync", "cluster", clusterId, clusterId, fmt.Sprintf("left out as its controllers haven't synced in time, pending=%v", cluster.Pending))
+				}
+			}
+			//the cleanups requested during the warm up run whether or not the reconciliation succeeds
+			runDeferredCleanups(remoteRegistry)
+			log.Info("informers synced, regenerating the config of every identity")
+			if _, err := NewReconciler(remoteRegistry, qps).Reconcile(); err != nil {
+				log.Warnf(LogErrFormat, "Reconcile", "", "", "", err)
+				continue
+			}
+			ready = true
+		}
+	}
+}
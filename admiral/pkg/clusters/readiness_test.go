@@ -0,0 +1,160 @@
+package clusters
+
+import (
+	"context"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetReadiness(t *testing.T) {
+	synced := func() bool { return true }
+	notSynced := func() bool { return false }
+
+	rr := &RemoteRegistry{RemoteControllers: map[string]*RemoteController{}}
+	rr.addInformerSynced("dependency", synced)
+	rr.RemoteControllers["cluster1"] = &RemoteController{
+		ClusterID:       "cluster1",
+		informersSynced: map[string]func() bool{"service": synced, "deployment": synced},
+	}
+	rr.RemoteControllers["cluster2"] = &RemoteController{
+		ClusterID:       "cluster2",
+		informersSynced: map[string]func() bool{"service": notSynced, "deployment": synced, "rollout": notSynced},
+	}
+
+	if rr.HasSynced() || !IsCacheWarmupTime(rr) {
+		t.Errorf("Expected the registry not to be synced while a cluster isn't")
+	}
+	expected := Readiness{
+		Ready: false,
+		Clusters: map[string]ClusterReadiness{
+			"cluster1": {Ready: true},
+			"cluster2": {Ready: false, Pending: []string{"rollout", "service"}},
+		},
+	}
+	if readiness := rr.GetReadiness(); !reflect.DeepEqual(readiness, expected) {
+		t.Errorf("Unexpected readiness, got %v expected %v", readiness, expected)
+	}
+
+	rr.RemoteControllers["cluster2"].informersSynced = map[string]func() bool{"service": synced}
+	rr.addInformerSynced("secret", notSynced)
+	if rr.HasSynced() {
+		t.Errorf("Expected the registry not to be synced while the secret controller isn't")
+	}
+	if readiness := rr.GetReadiness(); readiness.Ready || !reflect.DeepEqual(readiness.Pending, []string{"secret"}) {
+		t.Errorf("Expected the secret controller to be pending, got %v", readiness)
+	}
+
+	rr.addInformerSynced("secret", synced)
+	if !rr.HasSynced() || IsCacheWarmupTime(rr) {
+		t.Errorf("Expected the registry to be synced once all the controllers are")
+	}
+	if readiness := rr.GetReadiness(); !readiness.Ready || !readiness.Clusters["cluster2"].Ready {
+		t.Errorf("Expected the registry to be ready, got %v", readiness)
+	}
+}
+
+func TestGetReadinessWithTimedOutCluster(t *testing.T) {
+	synced := func() bool { return true }
+	notSynced := func() bool { return false }
+
+	rr := &RemoteRegistry{RemoteControllers: map[string]*RemoteController{}}
+	rr.RemoteControllers["cluster1"] = &RemoteController{
+		ClusterID:       "cluster1",
+		informersSynced: map[string]func() bool{"service": synced},
+	}
+	rr.RemoteControllers["cluster2"] = &RemoteController{
+		ClusterID:       "cluster2",
+		informersSynced: map[string]func() bool{"service": notSynced},
+		syncDeadline:    time.Now().Add(time.Hour),
+	}
+
+	if rr.HasSynced() {
+		t.Errorf("Expected the registry not to be synced before the deadline of the cluster")
+	}
+
+	rr.RemoteControllers["cluster2"].syncDeadline = time.Now().Add(-time.Second)
+	if !rr.HasSynced() {
+		t.Errorf("Expected the cluster to be left out once its deadline has passed")
+	}
+	expected := Readiness{
+		Ready: true,
+		Clusters: map[string]ClusterReadiness{
+			"cluster1": {Ready: true},
+			"cluster2": {Ready: false, Pending: []string{"service"}, Excluded: true},
+		},
+	}
+	if readiness := rr.GetReadiness(); !reflect.DeepEqual(readiness, expected) {
+		t.Errorf("Unexpected readiness, got %v expected %v", readiness, expected)
+	}
+
+	//a cluster that syncs after its deadline is counted again
+	rr.RemoteControllers["cluster2"].informersSynced = map[string]func() bool{"service": synced}
+	if readiness := rr.GetReadiness(); !readiness.Ready || readiness.Clusters["cluster2"].Excluded {
+		t.Errorf("Expected the cluster to be ready, got %v", readiness)
+	}
+}
+
+func TestReadinessWatcherRunsDeferredCleanups(t *testing.T) {
+	var synced int32
+	rr := &RemoteRegistry{RemoteControllers: map[string]*RemoteController{}, AdmiralCache: &AdmiralCache{}, readinessWatched: true}
+	rr.addInformerSynced("dependency", func() bool { return atomic.LoadInt32(&synced) == 1 })
+
+	done := make(chan struct{})
+	runAfterCacheWarmup(rr, func() { close(done) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go StartReadinessWatcher(ctx, rr, 10*time.Millisecond, 0)
+
+	select {
+	case <-done:
+		t.Fatalf("Expected the cleanup to wait for the end of the cache warm up")
+	case <-time.After(50 * time.Millisecond):
+	}
+	atomic.StoreInt32(&synced, 1)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Errorf("Expected the cleanup to run when Admiral becomes ready")
+	}
+}
+
+func TestRunAfterCacheWarmupWithoutReadinessWatcher(t *testing.T) {
+	defer func(interval time.Duration) { deferredCleanupPollInterval = interval }(deferredCleanupPollInterval)
+	deferredCleanupPollInterval = 10 * time.Millisecond
+
+	var synced int32
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rr := &RemoteRegistry{RemoteControllers: map[string]*RemoteController{}, ctx: ctx}
+	rr.addInformerSynced("dependency", func() bool { return atomic.LoadInt32(&synced) == 1 })
+
+	ran := make(chan int, 2)
+	runAfterCacheWarmup(rr, func() { ran <- 1 })
+	runAfterCacheWarmup(rr, func() { ran <- 2 })
+
+	select {
+	case <-ran:
+		t.Fatalf("Expected the cleanups to wait for the end of the cache warm up")
+	case <-time.After(50 * time.Millisecond):
+	}
+	atomic.StoreInt32(&synced, 1)
+	for _, expected := range []int{1, 2} {
+		select {
+		case got := <-ran:
+			if got != expected {
+				t.Errorf("Expected the cleanups to run in order, got %v expected %v", got, expected)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected the cleanups to run once the informers have synced")
+		}
+	}
+
+	//once the warm up is over the cleanups run right away
+	runAfterCacheWarmup(rr, func() { ran <- 3 })
+	if got := <-ran; got != 3 {
+		t.Errorf("Expected the cleanup to run right away, got %v", got)
+	}
+}
@@ -0,0 +1,280 @@
+package clusters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/admiral"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/common"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/util"
+	log "github.com/sirupsen/logrus"
+	v12 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+//Reconciler periodically regenerates the istio config of every identity and env, so that failed writes and manual edits in the clusters
+//don't stay around until the next event for the identity
+type Reconciler struct {
+	remoteRegistry *RemoteRegistry
+	qps            float32
+	//rate limiters of the reconciliations, key=cluster id
+	limiters map[string]flowcontrol.RateLimiter
+}
+
+//ReconcileResult counts the objects corrected by a reconciliation, key=cluster id then object type
+type ReconcileResult map[string]map[string]int
+
+func NewReconciler(remoteRegistry *RemoteRegistry, qps float32) *Reconciler {
+	return &Reconciler{
+		remoteRegistry: remoteRegistry,
+		qps:            qps,
+		limiters:       make(map[string]flowcontrol.RateLimiter),
+	}
+}
+
+//Reconciles every interval until the context is done
+func (r *Reconciler) Start(ctx context.Context, interval time.Duration) {
+	log.Infof("starting reconciler, interval=%v qps per cluster=%v", interval, r.qps)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := r.Reconcile(); err != nil {
+				log.Warnf(LogErrFormat, "Reconcile", "", "", "", err)
+			}
+		}
+	}
+}
+
+//Reconcile regenerates the service entries and destination rules of every identity and env known in the clusters, and replays the
+//virtual services and destination rules of the clusters. Only the objects that differ from the cluster are written, the objects whose
+//spec differs from the one in the cluster before the reconciliation are counted as corrections
+func (r *Reconciler) Reconcile() (ReconcileResult, error) {
+	if IsCacheWarmupTime(r.remoteRegistry) {
+		return nil, errors.New("reconciliation skipped during cache warm up")
+	}
+	defer util.LogElapsedTime("Reconcile", "", "", "")()
+
+	remoteControllers := r.getRemoteControllers()
+	before := make(map[string]map[string]proto.Message, len(remoteControllers))
+	for clusterId, rc := range remoteControllers {
+		specs, err := getOwnedObjectSpecs(rc)
+		if err != nil {
+			log.Warnf(LogErrFormat, "Reconcile", "", "", clusterId, err)
+			continue
+		}
+		before[clusterId] = specs
+	}
+
+	envsByIdentity := getEnvsByIdentity(remoteControllers)
+	identities := make([]string, 0, len(envsByIdentity))
+	for identity := range envsByIdentity {
+		identities = append(identities, identity)
+	}
+	sort.Strings(identities)
+	//the syncs go through the identity queue when there is one, so they are coalesced with the ones of the events
+	synced := make([]<-chan struct{}, 0)
+	for _, identity := range identities {
+		for env := range envsByIdentity[identity] {
+			r.wait(r.getClustersForIdentity(identity))
+			if r.remoteRegistry.IdentityQueue != nil {
+				synced = append(synced, r.remoteRegistry.IdentityQueue.Enqueue(env, identity))
+				continue
+			}
+			modifyServiceEntryForNewServiceOrPod(admiral.Update, env, identity, r.remoteRegistry)
+		}
+	}
+	for _, done := range synced {
+		<-done
+	}
+
+	for clusterId, rc := range remoteControllers {
+		r.wait(map[string]string{clusterId: clusterId})
+		r.replayIstioResources(rc)
+	}
+
+	result := make(ReconcileResult)
+	for clusterId, rc := range remoteControllers {
+		specsBefore, ok := before[clusterId]
+		if !ok {
+			continue
+		}
+		specsAfter, err := getOwnedObjectSpecs(rc)
+		if err != nil {
+			log.Warnf(LogErrFormat, "Reconcile", "", "", clusterId, err)
+			continue
+		}
+		result[clusterId] = getCorrections(specsBefore, specsAfter)
+		for objectType, count := range result[clusterId] {
+			for i := 0; i < count; i++ {
+				common.ReconcileCorrections.With(clusterId, objectType).Inc()
+			}
+			if count > 0 {
+				log.Infof(LogFormat, "Reconcile", objectType, "", clusterId, fmt.Sprintf("corrected %d objects", count))
+			}
+		}
+	}
+	return result, nil
+}
+
+func (r *Reconciler) getRemoteControllers() map[string]*RemoteController {
+	r.remoteRegistry.Lock()
+	defer r.remoteRegistry.Unlock()
+	remoteControllers := make(map[string]*RemoteController, len(r.remoteRegistry.RemoteControllers))
+	for clusterId, rc := range r.remoteRegistry.RemoteControllers {
+		remoteControllers[clusterId] = rc
+	}
+	return remoteControllers
+}
+
+//Returns the clusters the config of the identity is written to, the source clusters and the clusters of its dependents
+func (r *Reconciler) getClustersForIdentity(identity string) map[string]string {
+	cache := r.remoteRegistry.AdmiralCache
+	clusters := cache.IdentityClusterCache.Get(identity).Copy()
+	for dependent := range cache.IdentityDependencyCache.Get(identity).Copy() {
+		for clusterId := range cache.IdentityClusterCache.Get(dependent).Copy() {
+			clusters[clusterId] = clusterId
+		}
+	}
+	return clusters
+}
+
+//blocks until every cluster allows one more reconciliation
+func (r *Reconciler) wait(clusters map[string]string) {
+	if r.qps <= 0 {
+		return
+	}
+	for clusterId := range clusters {
+		limiter, ok := r.limiters[clusterId]
+		if !ok {
+			burst := int(r.qps)
+			if burst < 1 {
+				burst = 1
+			}
+			limiter = flowcontrol.NewTokenBucketRateLimiter(r.qps, burst)
+			r.limiters[clusterId] = limiter
+		}
+		limiter.Accept()
+	}
+}
+
+//Replays the virtual services and destination rules of the cluster, as they are copied to the other clusters
+func (r *Reconciler) replayIstioResources(rc *RemoteController) {
+	if rc.VirtualServiceController != nil {
+		virtualServices, err := rc.VirtualServiceController.IstioClient.NetworkingV1alpha3().VirtualServices(v12.NamespaceAll).List(v12.ListOptions{})
+		if err != nil {
+			log.Warnf(LogErrFormat, "List", "VirtualService", "", rc.ClusterID, err)
+		} else {
+			vh := &VirtualServiceHandler{RemoteRegistry: r.remoteRegistry, ClusterID: rc.ClusterID}
+			for i := range virtualServices.Items {
+				virtualService := &virtualServices.Items[i]
+				if !IgnoreIstioResource(virtualService.Spec.ExportTo, virtualService.Annotations, virtualService.Namespace) {
+					vh.Updated(virtualService)
+				}
+			}
+		}
+	}
+	if rc.DestinationRuleController != nil {
+		destinationRules, err := rc.DestinationRuleController.IstioClient.NetworkingV1alpha3().DestinationRules(v12.NamespaceAll).List(v12.ListOptions{})
+		if err != nil {
+			log.Warnf(LogErrFormat, "List", "DestinationRule", "", rc.ClusterID, err)
+		} else {
+			dh := &DestinationRuleHandler{RemoteRegistry: r.remoteRegistry, ClusterID: rc.ClusterID}
+			for i := range destinationRules.Items {
+				destinationRule := &destinationRules.Items[i]
+				if !IgnoreIstioResource(destinationRule.Spec.ExportTo, destinationRule.Annotations, destinationRule.Namespace) {
+					dh.Updated(destinationRule)
+				}
+			}
+		}
+	}
+}
+
+//Returns the envs of every identity with a deployment, rollout or statefulset in any of the clusters
+func getEnvsByIdentity(remoteControllers map[string]*RemoteController) map[string]map[string]bool {
+	envsByIdentity := make(map[string]map[string]bool)
+	add := func(identities map[string][]string) {
+		for identity, envs := range identities {
+			if envsByIdentity[identity] == nil {
+				envsByIdentity[identity] = make(map[string]bool)
+			}
+			for _, env := range envs {
+				envsByIdentity[identity][env] = true
+			}
+		}
+	}
+	for _, rc := range remoteControllers {
+		if rc.DeploymentController != nil {
+			add(rc.DeploymentController.Cache.GetEnvsByIdentity())
+		}
+		if rc.RolloutController != nil {
+			add(rc.RolloutController.Cache.GetEnvsByIdentity())
+		}
+		if rc.StatefulSetController != nil {
+			add(rc.StatefulSetController.Cache.GetEnvsByIdentity())
+		}
+	}
+	return envsByIdentity
+}
+
+//Returns the specs of the objects owned by Admiral in the sync namespace, key=object type/name. The resource version changes with any
+//write, the specs only with the config Admiral generates
+func getOwnedObjectSpecs(rc *RemoteController) (map[string]proto.Message, error) {
+	if rc.ServiceEntryController == nil || rc.DestinationRuleController == nil || rc.VirtualServiceController == nil {
+		return nil, errors.New("istio controllers not initialized")
+	}
+	syncNamespace := common.GetSyncNamespace()
+	specs := make(map[string]proto.Message)
+
+	serviceEntries, err := rc.ServiceEntryController.IstioClient.NetworkingV1alpha3().ServiceEntries(syncNamespace).List(v12.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range serviceEntries.Items {
+		if isCreatedByAdmiral(serviceEntries.Items[i].Annotations) {
+			specs[string(common.ServiceEntry)+common.Slash+serviceEntries.Items[i].Name] = &serviceEntries.Items[i].Spec
+		}
+	}
+
+	destinationRules, err := rc.DestinationRuleController.IstioClient.NetworkingV1alpha3().DestinationRules(syncNamespace).List(v12.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range destinationRules.Items {
+		if isCreatedByAdmiral(destinationRules.Items[i].Annotations) {
+			specs[string(common.DestinationRule)+common.Slash+destinationRules.Items[i].Name] = &destinationRules.Items[i].Spec
+		}
+	}
+
+	virtualServices, err := rc.VirtualServiceController.IstioClient.NetworkingV1alpha3().VirtualServices(syncNamespace).List(v12.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range virtualServices.Items {
+		if isCreatedByAdmiral(virtualServices.Items[i].Annotations) {
+			specs[string(common.VirtualService)+common.Slash+virtualServices.Items[i].Name] = &virtualServices.Items[i].Spec
+		}
+	}
+	return specs, nil
+}
+
+//Counts the objects created or whose spec changed between the two sets of specs, by object type
+func getCorrections(before map[string]proto.Message, after map[string]proto.Message) map[string]int {
+	corrections := map[string]int{string(common.ServiceEntry): 0, string(common.DestinationRule): 0, string(common.VirtualService): 0}
+	for key, spec := range after {
+		if previous, ok := before[key]; ok && proto.Equal(previous, spec) {
+			continue
+		}
+		objectType := key[:strings.Index(key, common.Slash)]
+		corrections[objectType]++
+	}
+	return corrections
+}
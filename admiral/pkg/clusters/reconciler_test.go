@@ -0,0 +1,213 @@
+package clusters
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/admiral"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/common"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/istio"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/test"
+	networking "istio.io/api/networking/v1alpha3"
+	istiofake "istio.io/client-go/pkg/clientset/versioned/fake"
+	v14 "k8s.io/api/apps/v1"
+	coreV1 "k8s.io/api/core/v1"
+	v12 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+)
+
+func TestGetCorrections(t *testing.T) {
+	se := &networking.ServiceEntry{Hosts: []string{"a.global"}, Addresses: []string{"240.0.10.1"}}
+	updatedSe := &networking.ServiceEntry{Hosts: []string{"a.global"}, Addresses: []string{"240.0.10.2"}}
+	dr := &networking.DestinationRule{Host: "a.global"}
+	vs := &networking.VirtualService{Hosts: []string{"a.global"}}
+
+	testCases := []struct {
+		name     string
+		before   map[string]proto.Message
+		after    map[string]proto.Message
+		expected map[string]int
+	}{
+		{
+			name:     "Given no changes, should count no corrections",
+			before:   map[string]proto.Message{"ServiceEntry/a-se": se, "DestinationRule/a-dr": dr},
+			after:    map[string]proto.Message{"ServiceEntry/a-se": se, "DestinationRule/a-dr": dr},
+			expected: map[string]int{"ServiceEntry": 0, "DestinationRule": 0, "VirtualService": 0},
+		},
+		{
+			name:     "Given rewritten objects with the same spec, should count no corrections",
+			before:   map[string]proto.Message{"ServiceEntry/a-se": se, "DestinationRule/a-dr": dr},
+			after:    map[string]proto.Message{"ServiceEntry/a-se": proto.Clone(se), "DestinationRule/a-dr": proto.Clone(dr)},
+			expected: map[string]int{"ServiceEntry": 0, "DestinationRule": 0, "VirtualService": 0},
+		},
+		{
+			name:     "Given updated and created objects, should count them by type",
+			before:   map[string]proto.Message{"ServiceEntry/a-se": se, "DestinationRule/a-dr": dr},
+			after:    map[string]proto.Message{"ServiceEntry/a-se": updatedSe, "ServiceEntry/b-se": se, "DestinationRule/a-dr": dr, "VirtualService/a-vs": vs},
+			expected: map[string]int{"ServiceEntry": 2, "DestinationRule": 0, "VirtualService": 1},
+		},
+		{
+			name:     "Given deleted objects, should not count them",
+			before:   map[string]proto.Message{"ServiceEntry/a-se": se},
+			after:    map[string]proto.Message{},
+			expected: map[string]int{"ServiceEntry": 0, "DestinationRule": 0, "VirtualService": 0},
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			if corrections := getCorrections(c.before, c.after); !reflect.DeepEqual(corrections, c.expected) {
+				t.Errorf("Unexpected corrections, got %v expected %v", corrections, c.expected)
+			}
+		})
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	p := common.AdmiralParams{
+		KubeconfigPath: "testdata/fake.config",
+	}
+	rr, _ := InitAdmiral(context.Background(), p)
+
+	config := rest.Config{
+		Host: "localhost",
+	}
+	d, _ := admiral.NewDeploymentController("", make(chan struct{}), &test.MockDeploymentHandler{}, &config, time.Second*time.Duration(300))
+	ss, _ := admiral.NewStatefulSetController("", make(chan struct{}), &test.MockStatefulSetHandler{}, &config, time.Second*time.Duration(300))
+	s, _ := admiral.NewServiceController("test", make(chan struct{}), &test.MockServiceHandler{}, &config, time.Second*time.Duration(300))
+	gtpc, _ := admiral.NewGlobalTrafficController("", make(chan struct{}), &test.MockGlobalTrafficHandler{}, &config, time.Second*time.Duration(300))
+
+	fakeIstioClient := istiofake.NewSimpleClientset()
+	rc := &RemoteController{
+		ClusterID:                 "test.cluster",
+		ServiceEntryController:    &istio.ServiceEntryController{IstioClient: fakeIstioClient},
+		DestinationRuleController: &istio.DestinationRuleController{IstioClient: fakeIstioClient},
+		VirtualServiceController:  &istio.VirtualServiceController{IstioClient: fakeIstioClient},
+		NodeController: &admiral.NodeController{
+			Locality: &admiral.Locality{
+				Region: "us-west-2",
+			},
+		},
+		DeploymentController:  d,
+		StatefulSetController: ss,
+		ServiceController:     s,
+		GlobalTraffic:         gtpc,
+	}
+	rr.RemoteControllers["test.cluster"] = rc
+
+	rr.AdmiralCache = &AdmiralCache{
+		IdentityClusterCache: common.NewMapOfMaps(),
+		ServiceEntryAddressStore: &ServiceEntryAddressStore{
+			EntryAddresses: map[string]string{"test.test.mesh-se": common.LocalAddressPrefix + ".10.1"},
+			Addresses:      []string{common.LocalAddressPrefix + ".10.1"},
+		},
+		CnameClusterCache:          common.NewMapOfMaps(),
+		CnameIdentityCache:         &sync.Map{},
+		CnameDependentClusterCache: common.NewMapOfMaps(),
+		IdentityDependencyCache:    common.NewMapOfMaps(),
+		GlobalTrafficCache:         &globalTrafficCache{},
+		DependencyNamespaceCache:   common.NewSidecarEgressMap(),
+		SeClusterCache:             common.NewMapOfMaps(),
+	}
+
+	statefulSet := v14.StatefulSet{
+		ObjectMeta: v12.ObjectMeta{Name: "redis", Namespace: "test-test"},
+		Spec: v14.StatefulSetSpec{
+			ServiceName: "redis",
+			Selector:    &v12.LabelSelector{MatchLabels: map[string]string{"app": "redis"}},
+			Template: coreV1.PodTemplateSpec{
+				ObjectMeta: v12.ObjectMeta{
+					Labels: map[string]string{"identity": "test"},
+				},
+			},
+		},
+	}
+	ss.Cache.UpdateStatefulSetToClusterCache("bar", &statefulSet)
+	s.Cache.Put(&coreV1.Service{
+		ObjectMeta: v12.ObjectMeta{Name: "redis", Namespace: "test-test"},
+		Spec: coreV1.ServiceSpec{
+			Selector: map[string]string{"app": "redis"},
+			Ports:    []coreV1.ServicePort{{Name: "tcp-redis", Port: 6379}},
+		},
+	})
+
+	reconciler := NewReconciler(rr, 0)
+	if _, err := reconciler.Reconcile(); err == nil {
+		t.Errorf("Expected an error during cache warm up")
+	}
+	rr.informersSynced = nil
+
+	serviceEntries := fakeIstioClient.NetworkingV1alpha3().ServiceEntries(common.GetSyncNamespace())
+	result, err := reconciler.Reconcile()
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if result["test.cluster"][string(common.ServiceEntry)] != 1 {
+		t.Errorf("Expected the missing service entry to be counted as a correction, got %v", result)
+	}
+	if _, err := serviceEntries.Get("test.test.mesh-se", v12.GetOptions{}); err != nil {
+		t.Fatalf("Service entry should have been created, err: %v", err)
+	}
+
+	result, err = reconciler.Reconcile()
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if result["test.cluster"][string(common.ServiceEntry)] != 0 {
+		t.Errorf("Expected no corrections when the cluster is up to date, got %v", result)
+	}
+
+	//a write that leaves the spec as is isn't a correction
+	serviceEntry, _ := serviceEntries.Get("test.test.mesh-se", v12.GetOptions{})
+	serviceEntry.Labels = map[string]string{"team": "test"}
+	serviceEntry.ResourceVersion = "2"
+	serviceEntries.Update(serviceEntry)
+	result, err = reconciler.Reconcile()
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if result["test.cluster"][string(common.ServiceEntry)] != 0 {
+		t.Errorf("Expected no corrections when the spec is up to date, got %v", result)
+	}
+
+	serviceEntry, _ = serviceEntries.Get("test.test.mesh-se", v12.GetOptions{})
+	serviceEntry.Spec.Hosts = []string{"edited.mesh"}
+	serviceEntries.Update(serviceEntry)
+	result, err = reconciler.Reconcile()
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if result["test.cluster"][string(common.ServiceEntry)] != 1 {
+		t.Errorf("Expected the edited service entry to be counted as a correction, got %v", result)
+	}
+
+	serviceEntries.Delete("test.test.mesh-se", &v12.DeleteOptions{})
+	result, err = reconciler.Reconcile()
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if result["test.cluster"][string(common.ServiceEntry)] != 1 {
+		t.Errorf("Expected the deleted service entry to be counted as a correction, got %v", result)
+	}
+	if _, err := serviceEntries.Get("test.test.mesh-se", v12.GetOptions{}); err != nil {
+		t.Errorf("Deleted service entry should have been restored, err: %v", err)
+	}
+
+	//the identities are synced by the identity queue when there is one
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rr.IdentityQueue = NewIdentityQueue(rr, 0)
+	go rr.IdentityQueue.Run(ctx, 1)
+	serviceEntries.Delete("test.test.mesh-se", &v12.DeleteOptions{})
+	result, err = reconciler.Reconcile()
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if result["test.cluster"][string(common.ServiceEntry)] != 1 {
+		t.Errorf("Expected the service entry restored by the identity queue to be counted as a correction, got %v", result)
+	}
+}
@@ -2,10 +2,12 @@ package clusters
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/istio-ecosystem/admiral/admiral/pkg/apis/admiral/v1"
 	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/istio"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
 	"sync"
 	"time"
 
@@ -26,8 +28,9 @@ func InitAdmiral(ctx context.Context, params common.AdmiralParams) (*RemoteRegis
 
 	common.InitializeConfig(params)
 	w := RemoteRegistry{
-		ctx: ctx,
-		StartTime: time.Now(),
+		ctx:              ctx,
+		StartTime:        time.Now(),
+		readinessWatched: params.ReadinessCheckInterval > 0,
 	}
 
 	wd := DependencyHandler{
@@ -39,6 +42,8 @@ func InitAdmiral(ctx context.Context, params common.AdmiralParams) (*RemoteRegis
 	if err != nil {
 		return nil, fmt.Errorf(" Error with dependency controller init: %v", err)
 	}
+	w.DependencyController = wd.DepController
+	w.addInformerSynced("dependency", w.DependencyController.HasSynced)
 
 	w.RemoteControllers = make(map[string]*RemoteController)
 
@@ -58,10 +63,17 @@ func InitAdmiral(ctx context.Context, params common.AdmiralParams) (*RemoteRegis
 		ServiceEntryAddressStore:        &ServiceEntryAddressStore{EntryAddresses: map[string]string{}, Addresses: []string{}},
 		GlobalTrafficCache:              gtpCache,
 		SeClusterCache:                  common.NewMapOfMaps(),
+		CnameSubsetCache:                common.NewMapOfMaps(),
+		ClusterWriter:                   NewClusterWriter(params.ClusterWriteParallelism, params.ClusterWriteTimeout),
 
 		argoRolloutsEnabled: params.ArgoRolloutsEnabled,
 	}
 
+	//the identities whose writes were skipped while a cluster was backed off
+	w.AdmiralCache.ClusterWriter.Requeue = func(env string, identity string) {
+		syncIdentity(admiral.Update, env, identity, &w)
+	}
+
 	if !params.ArgoRolloutsEnabled {
 		log.Info("argo rollouts disabled")
 	}
@@ -73,16 +85,78 @@ func InitAdmiral(ctx context.Context, params common.AdmiralParams) (*RemoteRegis
 	w.AdmiralCache.ConfigMapController = configMapController
 	loadServiceEntryCacheData(w.AdmiralCache.ConfigMapController, w.AdmiralCache)
 
+	if params.SeAddressAllocator == common.SeAddressAllocatorCrd {
+		w.AdmiralCache.AddressAllocator, err = createAddressAllocationAllocator(ctx, params, configMapController)
+		if err != nil {
+			return nil, fmt.Errorf(" Error with address allocation controller init: %v", err)
+		}
+	}
+
+	if params.DryRun {
+		log.Warn("dry run enabled, the changes to the clusters are recorded instead of written")
+		w.ChangeRecorder = NewChangeRecorder()
+		w.AdmiralCache.AddressAllocator = newDryRunAddressAllocator(getAddressAllocator(w.AdmiralCache))
+		w.AdmiralCache.ConfigMapController = &dryRunConfigMapController{ConfigMapControllerInterface: configMapController}
+	}
+
+	if params.SeAddressReclaimInterval > 0 {
+		go StartServiceEntryAddressReclaimer(ctx, &w, params.SeAddressReclaimInterval, params.SeAddressReclaimGracePeriod)
+	}
+
+	if params.OrphanCollectionInterval > 0 {
+		go StartOrphanCollector(ctx, &w, params.OrphanCollectionInterval, params.OrphanCollectionDryRun)
+	}
+
+	if params.ReconcileInterval > 0 {
+		go NewReconciler(&w, params.ReconcileQPS).Start(ctx, params.ReconcileInterval)
+	}
+
+	if params.IdentityQueueWorkers > 0 {
+		w.IdentityQueue = NewIdentityQueue(&w, params.IdentityQueueQPS)
+		go w.IdentityQueue.Run(ctx, params.IdentityQueueWorkers)
+	}
+
 	err = createSecretController(ctx, &w)
 	if err != nil {
 		return nil, fmt.Errorf(" Error with secret control init: %v", err)
 	}
 
+	if params.ReadinessCheckInterval > 0 {
+		go StartReadinessWatcher(ctx, &w, params.ReadinessCheckInterval, params.ReconcileQPS)
+	}
+
 	go w.shutdown()
 
 	return &w, nil
 }
 
+func createAddressAllocationAllocator(ctx context.Context, params common.AdmiralParams, configMapController admiral.ConfigMapControllerInterface) (ServiceEntryAddressAllocator, error) {
+	crdClient, err := admiral.AdmiralCrdClientFromPath(params.KubeconfigPath)
+	if err != nil {
+		return nil, err
+	}
+	k8sClient, err := admiral.K8sClientFromPath(params.KubeconfigPath)
+	if err != nil {
+		return nil, err
+	}
+	controller := admiral.NewAddressAllocationController(ctx.Done(), crdClient, k8sClient, common.GetSyncNamespace(), params.CacheRefreshDuration)
+	if !cache.WaitForCacheSync(ctx.Done(), controller.HasSynced) {
+		return nil, errors.New("failed to sync address allocations")
+	}
+	//the migration writes the address allocations
+	if !params.DryRun {
+		err = MigrateAddressStoreToAddressAllocations(configMapController, controller)
+		if err != nil {
+			return nil, fmt.Errorf("failed to migrate the addresses from the configmap: %v", err)
+		}
+		err = controller.EnsureAddressLeases()
+		if err != nil {
+			return nil, fmt.Errorf("failed to lease the allocated addresses: %v", err)
+		}
+	}
+	return NewAddressAllocationAllocator(controller), nil
+}
+
 func createSecretController(ctx context.Context, w *RemoteRegistry) error {
 	var err error
 	var controller *secret.Controller
@@ -104,11 +178,12 @@ func createSecretController(ctx context.Context, w *RemoteRegistry) error {
 	}
 
 	w.SecretController = controller
+	w.addInformerSynced("secret", controller.HasSynced)
 
 	return nil
 }
 
-func (r *RemoteRegistry) createCacheController(clientConfig *rest.Config, clusterID string, resyncPeriod time.Duration) error {
+func (r *RemoteRegistry) createCacheController(clientConfig *rest.Config, clusterID string, resyncPeriod time.Duration, annotations map[string]string) error {
 
 	stop := make(chan struct{})
 
@@ -118,6 +193,17 @@ func (r *RemoteRegistry) createCacheController(clientConfig *rest.Config, cluste
 		ApiServer: clientConfig.Host,
 		StartTime: time.Now(),
 	}
+	if common.GetClusterSyncTimeout() > 0 {
+		rc.syncDeadline = rc.StartTime.Add(common.GetClusterSyncTimeout())
+	}
+	rc.registrySynced = r.HasSynced
+	rc.FlatNetwork, rc.FlatNetworkEndpoints = getFlatNetwork(clusterID, annotations)
+	if common.GetSeUpdateConfirmationDelay() > 0 {
+		rc.ServiceEntryGuard = NewServiceEntryGuard(clusterID, common.GetSeEndpointRemovalThreshold(), common.GetSeUpdateConfirmationDelay())
+		rc.ServiceEntryGuard.Requeue = func(env string, identity string) {
+			syncIdentity(admiral.Update, env, identity, r)
+		}
+	}
 
 	var err error
 
@@ -178,6 +264,13 @@ func (r *RemoteRegistry) createCacheController(clientConfig *rest.Config, cluste
 		return fmt.Errorf("error with DeploymentController controller init: %v", err)
 	}
 
+	log.Infof("starting statefulset controller clusterID: %v", clusterID)
+	rc.StatefulSetController, err = admiral.NewStatefulSetController(clusterID, stop, &StatefulSetHandler{RemoteRegistry: r, ClusterID: clusterID}, clientConfig, resyncPeriod)
+
+	if err != nil {
+		return fmt.Errorf("error with StatefulSetController controller init: %v", err)
+	}
+
 	if r.AdmiralCache == nil {
 		log.Warn("admiral cache was nil!")
 	} else if r.AdmiralCache.argoRolloutsEnabled {
@@ -189,6 +282,49 @@ func (r *RemoteRegistry) createCacheController(clientConfig *rest.Config, cluste
 		}
 	}
 
+	if common.GetFlaggerEnabled() {
+		log.Infof("starting flagger canary controller clusterID: %v", clusterID)
+		rc.FlaggerController, err = admiral.NewFlaggerController(clusterID, stop, &FlaggerHandler{RemoteRegistry: r, ClusterID: clusterID}, clientConfig, resyncPeriod)
+
+		if err != nil {
+			return fmt.Errorf("error with Flagger controller init: %v", err)
+		}
+	}
+
+	if rc.FlatNetworkEndpoints == common.FlatNetworkEndpointsPod {
+		log.Infof("starting endpoints controller clusterID: %v", clusterID)
+		rc.EndpointsController, err = admiral.NewEndpointsController(clusterID, stop, &EndpointsHandler{RemoteRegistry: r, ClusterID: clusterID}, clientConfig, 0)
+
+		if err != nil {
+			return fmt.Errorf("error with EndpointsController controller init: %v", err)
+		}
+	}
+
+	rc.informersSynced = map[string]func() bool{
+		"service":             rc.ServiceController.HasSynced,
+		"globaltrafficpolicy": rc.GlobalTraffic.HasSynced,
+		"node":                rc.NodeController.HasSynced,
+		"serviceentry":        rc.ServiceEntryController.HasSynced,
+		"destinationrule":     rc.DestinationRuleController.HasSynced,
+		"virtualservice":      rc.VirtualServiceController.HasSynced,
+		"sidecar":             rc.SidecarController.HasSynced,
+		"deployment":          rc.DeploymentController.HasSynced,
+		"statefulset":         rc.StatefulSetController.HasSynced,
+	}
+	if rc.RolloutController != nil {
+		rc.informersSynced["rollout"] = rc.RolloutController.HasSynced
+	}
+	if rc.FlaggerController != nil {
+		rc.informersSynced["flaggercanary"] = rc.FlaggerController.HasSynced
+	}
+	if rc.EndpointsController != nil {
+		rc.informersSynced["endpoints"] = rc.EndpointsController.HasSynced
+	}
+
+	if r.ChangeRecorder != nil {
+		enableDryRun(&rc, r.ChangeRecorder)
+	}
+
 	r.Lock()
 	defer r.Unlock()
 	r.RemoteControllers[clusterID] = &rc
@@ -198,7 +334,7 @@ func (r *RemoteRegistry) createCacheController(clientConfig *rest.Config, cluste
 	return nil
 }
 
-func (r *RemoteRegistry) updateCacheController(clientConfig *rest.Config, clusterID string, resyncPeriod time.Duration) error {
+func (r *RemoteRegistry) updateCacheController(clientConfig *rest.Config, clusterID string, resyncPeriod time.Duration, annotations map[string]string) error {
 	//We want to refresh the cache controllers. But the current approach is parking the goroutines used in the previous set of controllers, leading to a rather large memory leak.
 	//This is a temporary fix to only do the controller refresh if the API Server of the remote cluster has changed
 	//The refresh will still park goroutines and still increase memory usage. But it will be a *much* slower leak. Filed https://github.com/istio-ecosystem/admiral/issues/122 for that.
@@ -210,9 +346,19 @@ func (r *RemoteRegistry) updateCacheController(clientConfig *rest.Config, cluste
 		if err := r.deleteCacheController(clusterID); err != nil {
 			return err
 		}
-		return r.createCacheController(clientConfig, clusterID, resyncPeriod)
+		return r.createCacheController(clientConfig, clusterID, resyncPeriod, annotations)
 
 	}
+	//the endpoints controller is only started for the clusters whose pods are pointed at, so the controllers are recreated as well
+	//when the flat network of the cluster changes
+	if flatNetwork, flatNetworkEndpoints := getFlatNetwork(clusterID, annotations); flatNetwork != controller.FlatNetwork || flatNetworkEndpoints != controller.FlatNetworkEndpoints {
+		log.Infof("Flat network changed from %v to %v, recreating cache controllers for cluster=%v", controller.FlatNetwork, flatNetwork, clusterID)
+
+		if err := r.deleteCacheController(clusterID); err != nil {
+			return err
+		}
+		return r.createCacheController(clientConfig, clusterID, resyncPeriod, annotations)
+	}
 	return nil
 }
 
@@ -227,6 +373,12 @@ func (r *RemoteRegistry) deleteCacheController(clusterID string) error {
 	r.Lock()
 	defer r.Unlock()
 	delete(r.RemoteControllers, clusterID)
+	if r.AdmiralCache != nil && r.AdmiralCache.ClusterLocalityCache != nil {
+		r.AdmiralCache.ClusterLocalityCache.Delete(clusterID)
+	}
+	if r.ChangeRecorder != nil {
+		r.ChangeRecorder.DeleteCluster(clusterID)
+	}
 
 	log.Infof(LogFormat, "Delete", "remote-controller", clusterID, clusterID, "success")
 	return nil
@@ -36,6 +36,9 @@ func init() {
 		SecretResolver:             "",
 		WorkloadSidecarUpdate:      "enabled",
 		WorkloadSidecarName:        "default",
+		WorkloadSidecarEgressHosts: []string{"./*", "istio-system/*"},
+		CanaryHostnamePrefix:       "canary",
+		FlaggerEnabled:             true,
 	}
 
 	p.LabelSet.WorkloadIdentityKey = "identity"
@@ -70,7 +73,7 @@ func TestDeleteCacheController(t *testing.T) {
 	}
 
 	cluster := "test.cluster"
-	w.createCacheController(&r, cluster, time.Second*time.Duration(300))
+	w.createCacheController(&r, cluster, time.Second*time.Duration(300), nil)
 	_, ok := w.RemoteControllers[cluster]
 
 	if !ok {
@@ -452,7 +455,7 @@ func TestUpdateCacheController(t *testing.T) {
 			}
 			rc.DeploymentController = d
 
-			err = rr.updateCacheController(c.newConfig, c.clusterId, time.Second*time.Duration(300))
+			err = rr.updateCacheController(c.newConfig, c.clusterId, time.Second*time.Duration(300), nil)
 			if err != nil {
 				t.Fatalf("Unexpected error doing update %v", err)
 			}
@@ -9,9 +9,11 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	v1 "github.com/istio-ecosystem/admiral/admiral/pkg/apis/admiral/v1"
+	flagger "github.com/istio-ecosystem/admiral/admiral/pkg/apis/flagger/v1beta1"
 
 	argo "github.com/argoproj/argo-rollouts/pkg/apis/rollouts/v1alpha1"
 	log "github.com/sirupsen/logrus"
@@ -20,6 +22,7 @@ import (
 	"istio.io/client-go/pkg/apis/networking/v1alpha3"
 	k8sAppsV1 "k8s.io/api/apps/v1"
 	k8sV1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
 	v12 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/admiral"
@@ -67,6 +70,11 @@ func modifyServiceEntryForNewServiceOrPod(event admiral.EventType, env string, s
 	sourceWeightedServices := make(map[string]map[string]*WeightedService)
 	sourceDeployments := make(map[string]*k8sAppsV1.Deployment)
 	sourceRollouts := make(map[string]*argo.Rollout)
+	sourceStatefulSets := make(map[string]*k8sAppsV1.StatefulSet)
+	//local fqdn of the statefulset pods per source cluster, keyed by the global name of the pod
+	sourcePodFqdns := make(map[string]map[string]string)
+	//endpoints of the source clusters on a flat network, the other clusters on the flat network point straight at their workloads
+	flatEndpoints := make(map[string]map[string]flatNetworkEndpoints)
 
 	var serviceEntries = make(map[string]*networking.ServiceEntry)
 
@@ -75,6 +83,7 @@ func modifyServiceEntryForNewServiceOrPod(event admiral.EventType, env string, s
 	var serviceInstance *k8sV1.Service
 	var weightedServices map[string]*WeightedService
 	var rollout *admiral.RolloutClusterEntry
+	var statefulSet *admiral.StatefulSetClusterEntry
 	var gtps = make(map[string][]*v1.GlobalTrafficPolicy)
 
 	var namespace string
@@ -90,10 +99,28 @@ func modifyServiceEntryForNewServiceOrPod(event admiral.EventType, env string, s
 			rollout = rc.RolloutController.Cache.Get(sourceIdentity)
 		}
 
+		statefulSet = nil
+		if rc.StatefulSetController != nil {
+			statefulSet = rc.StatefulSetController.Cache.Get(sourceIdentity)
+		}
+
 		if deployment != nil && deployment.Deployments[env] != nil {
 			deploymentInstance := deployment.Deployments[env]
 
-			serviceInstance = getServiceForDeployment(rc, deploymentInstance)
+			//the deployments targeted by a flagger canary are routed to through the primary and canary services, like the canary rollouts
+			weightedServices = nil
+			serviceInstance = nil
+			if canary := getFlaggerCanaryForDeployment(rc, deploymentInstance); canary != nil {
+				weightedServices = getServiceForFlaggerCanary(rc, canary)
+				if primaryService := weightedServices[canary.GetServiceName()+flagger.PrimarySuffix]; primaryService != nil {
+					serviceInstance = primaryService.Service
+				}
+			}
+			//until flagger generates the primary service of the canary, the deployment is routed to through its own service
+			if serviceInstance == nil {
+				weightedServices = nil
+				serviceInstance = getServiceForDeployment(rc, deploymentInstance)
+			}
 			if serviceInstance == nil {
 				continue
 			}
@@ -103,6 +130,10 @@ func modifyServiceEntryForNewServiceOrPod(event admiral.EventType, env string, s
 			cname = common.GetCname(deploymentInstance, common.GetWorkloadIdentifier(), common.GetHostnameSuffix())
 			sourceDeployments[rc.ClusterID] = deploymentInstance
 			createServiceEntry(event, rc, remoteRegistry.AdmiralCache, localMeshPorts, deploymentInstance, serviceEntries)
+			//the traffic of a flagger canary is split by the source cluster, it keeps going through its gateways
+			if len(weightedServices) <= 1 {
+				flatEndpoints[rc.ClusterID] = getFlatNetworkEndpoints(rc, serviceInstance, localMeshPorts, cname, nil)
+			}
 		} else if rollout != nil && rollout.Rollouts[env] != nil {
 			rolloutInstance := rollout.Rollouts[env]
 
@@ -123,6 +154,37 @@ func modifyServiceEntryForNewServiceOrPod(event admiral.EventType, env string, s
 			cnames[cname] = "1"
 			sourceRollouts[rc.ClusterID] = rolloutInstance
 			createServiceEntryForRollout(event, rc, remoteRegistry.AdmiralCache, localMeshPorts, rolloutInstance, serviceEntries)
+			//the preview and canary hosts of the rollout belong to the identity as well
+			for _, host := range []string{common.GetPreviewCnameForRollout(rolloutInstance, common.GetWorkloadIdentifier(), common.GetHostnameSuffix()), getCanaryHost(rolloutInstance)} {
+				if _, ok := serviceEntries[host]; ok {
+					cnames[host] = "1"
+					remoteRegistry.AdmiralCache.CnameIdentityCache.Store(host, sourceIdentity)
+				}
+			}
+			//the traffic of the blue green and canary rollouts is split by the source cluster, it keeps going through its gateways
+			if !isBlueGreenStrategy(rolloutInstance) && len(weightedServices) == 1 {
+				flatEndpoints[rc.ClusterID] = getFlatNetworkEndpoints(rc, serviceInstance, localMeshPorts, cname, nil)
+			}
+		} else if statefulSet != nil && statefulSet.StatefulSets[env] != nil {
+			statefulSetInstance := statefulSet.StatefulSets[env]
+
+			serviceInstance = getServiceForStatefulSet(rc, statefulSetInstance)
+			if serviceInstance == nil {
+				continue
+			}
+			namespace = statefulSetInstance.Namespace
+			localMeshPorts := GetMeshPortsForStatefulSet(rc.ClusterID, serviceInstance, statefulSetInstance)
+
+			cname = common.GetCnameForStatefulSet(statefulSetInstance, common.GetWorkloadIdentifier(), common.GetHostnameSuffix())
+			podFqdns := getStatefulSetPodHosts(statefulSetInstance, serviceInstance, cname)
+			for podHost := range podFqdns {
+				cnames[podHost] = "1"
+				remoteRegistry.AdmiralCache.CnameIdentityCache.Store(podHost, sourceIdentity)
+			}
+			sourceStatefulSets[rc.ClusterID] = statefulSetInstance
+			sourcePodFqdns[rc.ClusterID] = podFqdns
+			createServiceEntryForStatefulSet(event, rc, remoteRegistry.AdmiralCache, localMeshPorts, statefulSetInstance, serviceInstance, serviceEntries)
+			flatEndpoints[rc.ClusterID] = getFlatNetworkEndpoints(rc, serviceInstance, localMeshPorts, cname, podFqdns)
 		} else {
 			continue
 		}
@@ -147,7 +209,7 @@ func modifyServiceEntryForNewServiceOrPod(event admiral.EventType, env string, s
 	util.LogElapsedTimeSince("BuildServiceEntry", sourceIdentity, env, "", start)
 
 	//cache the latest GTP in global cache to be reused during DR creation
-	updateGlobalGtpCache(remoteRegistry.AdmiralCache, sourceIdentity, env, gtps)
+	activeGtp := updateGlobalGtpCache(remoteRegistry.AdmiralCache, sourceIdentity, env, gtps)
 
 	dependents := remoteRegistry.AdmiralCache.IdentityDependencyCache.Get(sourceIdentity).Copy()
 
@@ -156,34 +218,65 @@ func modifyServiceEntryForNewServiceOrPod(event admiral.EventType, env string, s
 
 	start = time.Now()
 
+	//clusters the generated config was written to, along with the errors encountered, used for status updates
+	syncedClusters := make(map[string]string)
+	clusterErrors := make(map[string]error)
+	//identities with a sidecar to write in the managed mode
+	sidecarIdentities := make(map[string]string)
+
 	for sourceCluster, serviceInstance := range sourceServices {
+		syncedClusters[sourceCluster] = sourceCluster
 		localFqdn := serviceInstance.Name + common.Sep + serviceInstance.Namespace + common.DotLocalDomainSuffix
 		rc := remoteRegistry.RemoteControllers[sourceCluster]
 		var meshPorts map[string]uint32
 		blueGreenStrategy := isBlueGreenStrategy(sourceRollouts[sourceCluster])
 
-		if len(sourceDeployments) > 0 {
+		if sourceDeployments[sourceCluster] != nil {
 			meshPorts = GetMeshPorts(sourceCluster, serviceInstance, sourceDeployments[sourceCluster])
+		} else if sourceStatefulSets[sourceCluster] != nil {
+			meshPorts = GetMeshPortsForStatefulSet(sourceCluster, serviceInstance, sourceStatefulSets[sourceCluster])
 		} else {
 			meshPorts = GetMeshPortsForRollout(sourceCluster, serviceInstance, sourceRollouts[sourceCluster])
 		}
 
+		gatewayAddresses := make(map[string]bool)
+		for _, gateway := range getGatewayEndpoints(rc) {
+			gatewayAddresses[gateway.Address] = true
+		}
+
 		for key, serviceEntry := range serviceEntries {
 			if len(serviceEntry.Endpoints) == 0 {
-				AddServiceEntriesWithDr(remoteRegistry.AdmiralCache, map[string]string{sourceCluster: sourceCluster}, remoteRegistry.RemoteControllers,
-					map[string]*networking.ServiceEntry{key: serviceEntry})
+				util.MapCopy(clusterErrors, AddServiceEntriesWithDr(remoteRegistry.AdmiralCache, map[string]string{sourceCluster: sourceCluster}, remoteRegistry.RemoteControllers,
+					map[string]*networking.ServiceEntry{key: serviceEntry}))
 			}
-			clusterIngress, _ := rc.ServiceController.Cache.GetLoadBalancer(common.GetAdmiralParams().LabelSet.GatewayApp, common.NamespaceIstioSystem)
+			serviceEntry = useFlatNetworkEndpoints(serviceEntry, key, rc, remoteRegistry.RemoteControllers, flatEndpoints)
+			//the local endpoints replace the endpoints of all the gateways of the cluster, not one each
+			serviceEntry = removeExtraGatewayEndpoints(serviceEntry, gatewayAddresses)
 			for _, ep := range serviceEntry.Endpoints {
+				clusterIngress := ep.Address
 				//replace istio ingress-gateway address with local fqdn, note that ingress-gateway can be empty (not provisoned, or is not up)
-				if ep.Address == clusterIngress || ep.Address == "" {
+				if gatewayAddresses[ep.Address] || ep.Address == "" {
 					// Update endpoints with locafqdn for active and preview se of bluegreen rollout
 					if blueGreenStrategy {
 						oldPorts := ep.Ports
 						updateEndpointsForBlueGreen(sourceRollouts[sourceCluster], sourceWeightedServices[sourceCluster], cnames, ep, sourceCluster, key)
 
-						AddServiceEntriesWithDr(remoteRegistry.AdmiralCache, map[string]string{sourceCluster: sourceCluster}, remoteRegistry.RemoteControllers,
-							map[string]*networking.ServiceEntry{key: serviceEntry})
+						util.MapCopy(clusterErrors, AddServiceEntriesWithDr(remoteRegistry.AdmiralCache, map[string]string{sourceCluster: sourceCluster}, remoteRegistry.RemoteControllers,
+							map[string]*networking.ServiceEntry{key: serviceEntry}))
+						//swap it back to use for next iteration
+						ep.Address = clusterIngress
+						ep.Ports = oldPorts
+					} else if canaryHost := getCanaryHost(sourceRollouts[sourceCluster]); len(canaryHost) > 0 && key == canaryHost {
+						//the canary host routes to the pods of the canary service only
+						canaryService := getServiceForRolloutCanary(rc, sourceRollouts[sourceCluster])
+						if canaryService == nil {
+							continue
+						}
+						oldPorts := ep.Ports
+						ep.Address = canaryService.Name + common.Sep + canaryService.Namespace + common.DotLocalDomainSuffix
+						ep.Ports = GetMeshPortsForRollout(sourceCluster, canaryService, sourceRollouts[sourceCluster])
+						util.MapCopy(clusterErrors, AddServiceEntriesWithDr(remoteRegistry.AdmiralCache, map[string]string{sourceCluster: sourceCluster}, remoteRegistry.RemoteControllers,
+							map[string]*networking.ServiceEntry{key: serviceEntry}))
 						//swap it back to use for next iteration
 						ep.Address = clusterIngress
 						ep.Ports = oldPorts
@@ -192,14 +285,18 @@ func modifyServiceEntryForNewServiceOrPod(event admiral.EventType, env string, s
 						//add one endpoint per each service, may be modify
 						var se = copyServiceEntry(serviceEntry)
 						updateEndpointsForWeightedServices(se, sourceWeightedServices[sourceCluster], clusterIngress, meshPorts)
-						AddServiceEntriesWithDr(remoteRegistry.AdmiralCache, map[string]string{sourceCluster: sourceCluster}, remoteRegistry.RemoteControllers,
-							map[string]*networking.ServiceEntry{key: se})
+						util.MapCopy(clusterErrors, AddServiceEntriesWithDr(remoteRegistry.AdmiralCache, map[string]string{sourceCluster: sourceCluster}, remoteRegistry.RemoteControllers,
+							map[string]*networking.ServiceEntry{key: se}))
 					} else {
 						ep.Address = localFqdn
+						//the global name of a statefulset pod resolves to that pod only
+						if podFqdn, ok := sourcePodFqdns[sourceCluster][key]; ok {
+							ep.Address = podFqdn
+						}
 						oldPorts := ep.Ports
 						ep.Ports = meshPorts
-						AddServiceEntriesWithDr(remoteRegistry.AdmiralCache, map[string]string{sourceCluster: sourceCluster}, remoteRegistry.RemoteControllers,
-							map[string]*networking.ServiceEntry{key: serviceEntry})
+						util.MapCopy(clusterErrors, AddServiceEntriesWithDr(remoteRegistry.AdmiralCache, map[string]string{sourceCluster: sourceCluster}, remoteRegistry.RemoteControllers,
+							map[string]*networking.ServiceEntry{key: serviceEntry}))
 						//swap it back to use for next iteration
 						ep.Address = clusterIngress
 						ep.Ports = oldPorts
@@ -208,16 +305,40 @@ func modifyServiceEntryForNewServiceOrPod(event admiral.EventType, env string, s
 			}
 		}
 
-		if common.GetWorkloadSidecarUpdate() == "enabled" {
+		if common.GetWorkloadSidecarUpdate() == common.WorkloadSidecarUpdateEnabled {
 			modifySidecarForLocalClusterCommunication(serviceInstance.Namespace, remoteRegistry.AdmiralCache.DependencyNamespaceCache.Get(sourceIdentity), rc)
 		}
 
-		for _, val := range dependents {
-			remoteRegistry.AdmiralCache.DependencyNamespaceCache.Put(val, serviceInstance.Namespace, localFqdn, cnames)
+		if common.GetWorkloadSidecarUpdate() == common.WorkloadSidecarUpdateManaged {
+			egressCnames := make(map[string]string, len(cnames)+1)
+			util.MapCopy(egressCnames, cnames)
+			egressCnames[cname] = "1"
+			for _, val := range dependents {
+				if remoteRegistry.AdmiralCache.DependencyNamespaceCache.PutDependency(val, sourceIdentity, env, serviceInstance.Namespace, localFqdn, egressCnames) {
+					sidecarIdentities[val] = val
+				}
+			}
+		} else {
+			for _, val := range dependents {
+				remoteRegistry.AdmiralCache.DependencyNamespaceCache.Put(val, serviceInstance.Namespace, localFqdn, cnames)
+			}
 		}
 
 	}
 
+	//the statefulset pod, preview and canary hosts that aren't generated anymore are removed, e.g. the pods of a scaled down statefulset
+	if len(sourceServices) > 0 {
+		deleteStaleHosts(remoteRegistry, sourceIdentity, env, cname, serviceEntries)
+	}
+
+	//the sidecars of the dependents get the egress of the identity, and the ones of the identity the egress of its dependencies
+	if len(sourceServices) > 0 {
+		sidecarIdentities[sourceIdentity] = sourceIdentity
+		syncWorkloadSidecars(remoteRegistry, sidecarIdentities)
+	} else {
+		pruneWorkloadSidecars(remoteRegistry, sourceIdentity, env, dependents)
+	}
+
 	util.LogElapsedTimeSince("WriteServiceEntryToSourceClusters", sourceIdentity, env, "", start)
 
 	//Write to dependent clusters
@@ -226,51 +347,101 @@ func modifyServiceEntryForNewServiceOrPod(event admiral.EventType, env string, s
 
 	dependentClusters := getDependentClusters(dependents, remoteRegistry.AdmiralCache.IdentityClusterCache, sourceServices)
 
-	//update cname dependent cluster cache
+	//update cname dependent cluster cache, with the statefulset pod, preview and canary hosts written along with the cname
 	for clusterId := range dependentClusters {
 		remoteRegistry.AdmiralCache.CnameDependentClusterCache.Put(cname, clusterId, clusterId)
+		for host := range serviceEntries {
+			remoteRegistry.AdmiralCache.CnameDependentClusterCache.Put(host, clusterId, clusterId)
+		}
 	}
 
-	AddServiceEntriesWithDr(remoteRegistry.AdmiralCache, dependentClusters, remoteRegistry.RemoteControllers, serviceEntries)
+	util.MapCopy(syncedClusters, dependentClusters)
+	//the clusters on the same flat network as a source cluster get their own service entries
+	gatewayClusters := make(map[string]string, len(dependentClusters))
+	for clusterId := range dependentClusters {
+		flatServiceEntries := getFlatNetworkServiceEntries(serviceEntries, remoteRegistry.RemoteControllers[clusterId], remoteRegistry.RemoteControllers, flatEndpoints)
+		if flatServiceEntries == nil {
+			gatewayClusters[clusterId] = clusterId
+			continue
+		}
+		util.MapCopy(clusterErrors, AddServiceEntriesWithDr(remoteRegistry.AdmiralCache, map[string]string{clusterId: clusterId}, remoteRegistry.RemoteControllers, flatServiceEntries))
+	}
+	util.MapCopy(clusterErrors, AddServiceEntriesWithDr(remoteRegistry.AdmiralCache, gatewayClusters, remoteRegistry.RemoteControllers, serviceEntries))
 
 	util.LogElapsedTimeSince("WriteServiceEntryToDependentClusters", sourceIdentity, env, "", start)
 
+	updateGlobalTrafficPolicyStatus(remoteRegistry, gtps, activeGtp, syncedClusters, clusterErrors)
+	updateDependencyStatus(remoteRegistry, dependents, syncedClusters, clusterErrors)
+
 	return serviceEntries
 }
 
-//Does two things;
-//i)  Picks the GTP that was created most recently from the passed in GTP list (GTPs from all clusters)
-//ii) Updates the global GTP cache with the selected GTP in i)
-func updateGlobalGtpCache(cache *AdmiralCache, identity, env string, gtps map[string][]*v1.GlobalTrafficPolicy) {
+//Does three things;
+//i)   Picks the GTP to apply from the passed in GTP list (GTPs from all clusters), see compareGtps for the order
+//ii)  Updates the global GTP cache with the selected GTP in i)
+//iii) Records the GTPs shadowed by the selected GTP in i) as conflicts
+//Returns the selected GTP, nil if there are none
+func updateGlobalGtpCache(cache *AdmiralCache, identity, env string, gtps map[string][]*v1.GlobalTrafficPolicy) *v1.GlobalTrafficPolicy {
 	defer util.LogElapsedTime("updateGlobalGtpCache", identity, env, "")()
 	gtpsOrdered := make([]*v1.GlobalTrafficPolicy, 0)
-	for _, gtpsInCluster := range gtps {
-		gtpsOrdered = append(gtpsOrdered, gtpsInCluster...)
+	gtpClusters := make(map[*v1.GlobalTrafficPolicy]string)
+	for clusterId, gtpsInCluster := range gtps {
+		for _, gtp := range gtpsInCluster {
+			gtpsOrdered = append(gtpsOrdered, gtp)
+			gtpClusters[gtp] = clusterId
+		}
 	}
 	if len(gtpsOrdered) == 0 {
 		log.Debugf("No GTPs found for identity=%s in env=%s. Deleting global cache entries if any", identity, env)
 		cache.GlobalTrafficCache.Delete(identity, env)
-		return
+		cache.GlobalTrafficCache.DeleteConflict(identity, env)
+		return nil
 	} else if len(gtpsOrdered) > 1 {
 		log.Debugf("More than one GTP found for identity=%s in env=%s.", identity, env)
-		//sort by creation time with most recent at the beginning
 		sort.Slice(gtpsOrdered, func(i, j int) bool {
-			iTime := gtpsOrdered[i].CreationTimestamp
-			jTime := gtpsOrdered[j].CreationTimestamp
-			log.Debugf("GTP sorting identity=%s env=%s name1=%s creationTime1=%v name2=%s creationTime2=%v", identity, env, gtpsOrdered[i].Name, iTime, gtpsOrdered[j].Name, jTime)
-			return iTime.After(jTime.Time)
+			return compareGtps(gtpsOrdered[i], gtpClusters[gtpsOrdered[i]], gtpsOrdered[j], gtpClusters[gtpsOrdered[j]])
 		})
 	}
 
-	mostRecentGtp := gtpsOrdered[0]
+	activeGtp := gtpsOrdered[0]
 
-	err := cache.GlobalTrafficCache.Put(mostRecentGtp)
+	err := cache.GlobalTrafficCache.Put(activeGtp)
 
 	if err != nil {
-		log.Errorf("Error in updating GTP with name=%s in namespace=%s as actively used for identity=%s with err=%v", mostRecentGtp.Name, mostRecentGtp.Namespace, common.GetGtpKey(mostRecentGtp), err)
+		log.Errorf("Error in updating GTP with name=%s in namespace=%s as actively used for identity=%s with err=%v", activeGtp.Name, activeGtp.Namespace, common.GetGtpKey(activeGtp), err)
 	} else {
-		log.Infof("GTP with name=%s in namespace=%s is actively used for identity=%s", mostRecentGtp.Name, mostRecentGtp.Namespace, common.GetGtpKey(mostRecentGtp))
+		log.Infof("GTP with name=%s in namespace=%s is actively used for identity=%s", activeGtp.Name, activeGtp.Namespace, common.GetGtpKey(activeGtp))
+	}
+
+	if len(gtpsOrdered) == 1 {
+		cache.GlobalTrafficCache.DeleteConflict(identity, env)
+		return activeGtp
+	}
+
+	conflict := &GtpConflict{Identity: identity, Env: env, Active: getGtpStatusName(gtpClusters[activeGtp], activeGtp)}
+	for _, gtp := range gtpsOrdered[1:] {
+		conflict.Shadowed = append(conflict.Shadowed, getGtpStatusName(gtpClusters[gtp], gtp))
+	}
+	log.Warnf("GTP conflict for identity=%s in env=%s, active=%s shadowed=%v", identity, env, conflict.Active, conflict.Shadowed)
+	cache.GlobalTrafficCache.PutConflict(conflict)
+
+	return activeGtp
+}
+
+//Returns true if gtp1 takes precedence over gtp2, GTPs are ordered by, in turn;
+//higher spec priority, more recent creation time, lower namespace/name and lower cluster id
+func compareGtps(gtp1 *v1.GlobalTrafficPolicy, cluster1 string, gtp2 *v1.GlobalTrafficPolicy, cluster2 string) bool {
+	if gtp1.Spec.Priority != gtp2.Spec.Priority {
+		return gtp1.Spec.Priority > gtp2.Spec.Priority
+	}
+	if !gtp1.CreationTimestamp.Equal(&gtp2.CreationTimestamp) {
+		return gtp1.CreationTimestamp.After(gtp2.CreationTimestamp.Time)
+	}
+	name1, name2 := gtp1.Namespace+"/"+gtp1.Name, gtp2.Namespace+"/"+gtp2.Name
+	if name1 != name2 {
+		return name1 < name2
 	}
+	return cluster1 < cluster2
 }
 
 func updateEndpointsForBlueGreen(rollout *argo.Rollout, weightedServices map[string]*WeightedService, cnames map[string]string,
@@ -278,7 +449,7 @@ func updateEndpointsForBlueGreen(rollout *argo.Rollout, weightedServices map[str
 	activeServiceName := rollout.Spec.Strategy.BlueGreen.ActiveService
 	previewServiceName := rollout.Spec.Strategy.BlueGreen.PreviewService
 
-	if previewService, ok := weightedServices[previewServiceName]; strings.HasPrefix(meshHost, common.BlueGreenRolloutPreviewPrefix+common.Sep) && ok {
+	if previewService, ok := weightedServices[previewServiceName]; strings.HasPrefix(meshHost, common.GetPreviewPrefixForRollout(rollout)+common.Sep) && ok {
 		previewServiceInstance := previewService.Service
 		localFqdn := previewServiceInstance.Name + common.Sep + previewServiceInstance.Namespace + common.DotLocalDomainSuffix
 		cnames[localFqdn] = "1"
@@ -391,12 +562,12 @@ func copySidecar(sidecar *v1alpha3.Sidecar) *v1alpha3.Sidecar {
 	return newSidecarObj
 }
 
-func createSeWithDrLabels(remoteController *RemoteController, localCluster bool, identityId string, seName string, se *networking.ServiceEntry,
-	dr *networking.DestinationRule, seAddressCache *ServiceEntryAddressStore, configmapController admiral.ConfigMapControllerInterface) map[string]*networking.ServiceEntry {
+func createSeWithDrLabels(remoteController *RemoteController, identityId string, seName string, se *networking.ServiceEntry,
+	dr *networking.DestinationRule, admiralCache *AdmiralCache) map[string]*networking.ServiceEntry {
 	var allSes = make(map[string]*networking.ServiceEntry)
 	var newSe = copyServiceEntry(se)
 
-	address, _, err := GetLocalAddressForSe(seName, seAddressCache, configmapController)
+	address, _, err := getAddressAllocator(admiralCache).GetAddress(seName)
 	if err != nil {
 		log.Warnf("Failed to get address for dr service entry. Not creating it. err:%v", err)
 		return nil
@@ -409,34 +580,42 @@ func createSeWithDrLabels(remoteController *RemoteController, localCluster bool,
 		for _, subset := range dr.Subsets {
 			newEndpoint := copyEndpoint(endpoint)
 			newEndpoint.Labels = subset.Labels
-
-			////create a service entry with name subsetSeName
-			//if localCluster {
-			//	subsetSeName := seName + common.Dash + subset.Name
-			//	subsetSeAddress := strings.Split(se.Hosts[0], common.DotMesh)[0] + common.Sep + subset.Name + common.DotMesh BROKEN MUST FIX //todo fix the cname format here
-			//
-			//	//TODO uncomment the line below when subset routing across clusters is fixed
-			//	//newEndpoint.Address = subsetSeAddress
-			//
-			//	subSetSe := createSeWithPodIps(remoteController, identityId, subsetSeName, subsetSeAddress, newSe, newEndpoint, subset, seAddressMap)
-			//	if subSetSe != nil {
-			//		allSes[subsetSeName] = subSetSe
-			//		//TODO create default DestinationRules for these subset SEs
-			//	}
-			//}
-
 			endpoints = append(endpoints, newEndpoint)
-
 		}
 	}
 	newSe.Endpoints = endpoints
 	allSes[seName] = newSe
+
+	//every subset gets its own host, as the gateways route the traffic to a host of another cluster without its subset
+	for _, subset := range dr.Subsets {
+		subsetHost := getSubsetHost(se.Hosts[0], subset.Name)
+		subsetSeName := getIstioResourceName(subsetHost, "-se")
+		subsetAddress := getUniqueAddress(admiralCache, subsetHost)
+		if len(subsetAddress) == 0 {
+			log.Warnf(LogFormat, "Create", "ServiceEntry", subsetSeName, "", "Skipped as no address could be allocated")
+			continue
+		}
+		subsetSe := createSubsetServiceEntry(remoteController, identityId, subsetHost, subsetAddress, se, subset)
+		if subsetSe != nil {
+			allSes[subsetSeName] = subsetSe
+		}
+	}
 	return allSes
 }
 
+//the service entry and destination rule of a host to write to a cluster
+type clusterSeDr struct {
+	identityId string
+	seDr       *SeDrTuple
+}
+
 //This will create the default service entries and also additional ones specified in GTP
-func AddServiceEntriesWithDr(cache *AdmiralCache, sourceClusters map[string]string, rcs map[string]*RemoteController, serviceEntries map[string]*networking.ServiceEntry) {
-	syncNamespace := common.GetSyncNamespace()
+//Returns the errors encountered while writing, keyed by cluster
+func AddServiceEntriesWithDr(cache *AdmiralCache, sourceClusters map[string]string, rcs map[string]*RemoteController, serviceEntries map[string]*networking.ServiceEntry) map[string]error {
+	//the objects of every cluster are generated before any is written, the writes to the clusters run concurrently
+	clusterSeDrs := make(map[string][]clusterSeDr)
+	//the identities are synced again when the writes to a cluster are skipped
+	identities := make(map[identityKey]bool)
 	for _, se := range serviceEntries {
 
 		var identityId string
@@ -446,8 +625,12 @@ func AddServiceEntriesWithDr(cache *AdmiralCache, sourceClusters map[string]stri
 
 		splitByEnv := strings.Split(se.Hosts[0], common.Sep)
 		var env = splitByEnv[0]
+		if len(identityId) > 0 {
+			identities[identityKey{identity: identityId, env: env}] = true
+		}
 
 		globalTrafficPolicy := cache.GlobalTrafficCache.GetFromIdentity(identityId, env)
+		knownRegions := getKnownRegions(cache, rcs)
 
 		for _, sourceCluster := range sourceClusters {
 
@@ -459,47 +642,97 @@ func AddServiceEntriesWithDr(cache *AdmiralCache, sourceClusters map[string]stri
 			}
 
 			//check if there is a gtp and add additional hosts/destination rules
-			var seDrSet = createSeAndDrSetFromGtp(env, rc.NodeController.Locality.Region, se, globalTrafficPolicy, cache)
+			var seDrSet = createSeAndDrSetFromGtp(env, rc.NodeController.GetLocality().String(), se, globalTrafficPolicy, cache, knownRegions)
 
 			for _, seDr := range seDrSet {
-				oldServiceEntry, err := rc.ServiceEntryController.IstioClient.NetworkingV1alpha3().ServiceEntries(syncNamespace).Get(seDr.SeName, v12.GetOptions{})
-				// if old service entry not find, just create a new service entry instead
-				if err != nil {
-					log.Infof(LogFormat, "Get (error)", "old ServiceEntry", seDr.SeName, sourceCluster, err)
-					oldServiceEntry = nil
-				}
-				oldDestinationRule, err := rc.DestinationRuleController.IstioClient.NetworkingV1alpha3().DestinationRules(syncNamespace).Get(seDr.DrName, v12.GetOptions{})
+				//copied as the caller keeps modifying the service entries
+				clusterSeDrs[sourceCluster] = append(clusterSeDrs[sourceCluster], clusterSeDr{identityId: identityId, seDr: &SeDrTuple{
+					SeName:          seDr.SeName,
+					DrName:          seDr.DrName,
+					ServiceEntry:    copyServiceEntry(seDr.ServiceEntry),
+					DestinationRule: copyDestinationRule(seDr.DestinationRule),
+				}})
+			}
+		}
+	}
 
-				if err != nil {
-					log.Infof(LogFormat, "Get (error)", "old DestinationRule", seDr.DrName, sourceCluster, err)
-					oldDestinationRule = nil
-				}
+	clusters := make([]string, 0, len(clusterSeDrs))
+	for sourceCluster := range clusterSeDrs {
+		clusters = append(clusters, sourceCluster)
+	}
+	identityKeys := make([]identityKey, 0, len(identities))
+	for key := range identities {
+		identityKeys = append(identityKeys, key)
+	}
+	return cache.ClusterWriter.Write(identityKeys, clusters, func(sourceCluster string) error {
+		return addServiceEntriesWithDrToCluster(cache, rcs[sourceCluster], clusterSeDrs[sourceCluster])
+	})
+}
+
+//Writes the service entries and destination rules to the cluster, the writes stop at the first error showing the cluster is unavailable
+func addServiceEntriesWithDrToCluster(cache *AdmiralCache, rc *RemoteController, seDrs []clusterSeDr) error {
+	syncNamespace := common.GetSyncNamespace()
+	var clusterErr error
+	for _, clusterSeDr := range seDrs {
+		seDr := clusterSeDr.seDr
+		oldServiceEntry, err := rc.ServiceEntryController.IstioClient.NetworkingV1alpha3().ServiceEntries(syncNamespace).Get(seDr.SeName, v12.GetOptions{})
+		// if old service entry not find, just create a new service entry instead
+		if err != nil {
+			log.Infof(LogFormat, "Get (error)", "old ServiceEntry", seDr.SeName, rc.ClusterID, err)
+			if !k8sErrors.IsNotFound(err) && isClusterUnavailableError(err) {
+				return err
+			}
+			oldServiceEntry = nil
+		}
+		oldDestinationRule, err := rc.DestinationRuleController.IstioClient.NetworkingV1alpha3().DestinationRules(syncNamespace).Get(seDr.DrName, v12.GetOptions{})
+
+		if err != nil {
+			log.Infof(LogFormat, "Get (error)", "old DestinationRule", seDr.DrName, rc.ClusterID, err)
+			if !k8sErrors.IsNotFound(err) && isClusterUnavailableError(err) {
+				return err
+			}
+			oldDestinationRule = nil
+		}
+
+		if len(seDr.ServiceEntry.Endpoints) == 0 {
+			//a source cluster briefly reporting no endpoints mustn't remove the service entry either
+			if !deleteGuardedServiceEntry(oldServiceEntry, syncNamespace, rc) {
+				continue
+			}
+			cache.SeClusterCache.Delete(seDr.ServiceEntry.Hosts[0])
+			// after deleting the service entry, destination rule also need to be deleted if the service entry host no longer exists
+			deleteDestinationRule(oldDestinationRule, syncNamespace, rc)
+		} else {
+			newServiceEntry := createServiceEntrySkeletion(*seDr.ServiceEntry, seDr.SeName, syncNamespace)
 
-				if len(seDr.ServiceEntry.Endpoints) == 0 {
-					deleteServiceEntry(oldServiceEntry, syncNamespace, rc)
-					cache.SeClusterCache.Delete(seDr.ServiceEntry.Hosts[0])
-					// after deleting the service entry, destination rule also need to be deleted if the service entry host no longer exists
-					deleteDestinationRule(oldDestinationRule, syncNamespace, rc)
-				} else {
-					newServiceEntry := createServiceEntrySkeletion(*seDr.ServiceEntry, seDr.SeName, syncNamespace)
-
-					if newServiceEntry != nil {
-						newServiceEntry.Labels = map[string]string{common.GetWorkloadIdentifier(): fmt.Sprintf("%v", identityId)}
-						addUpdateServiceEntry(newServiceEntry, oldServiceEntry, syncNamespace, rc)
-						cache.SeClusterCache.Put(newServiceEntry.Spec.Hosts[0], rc.ClusterID, rc.ClusterID)
+			if newServiceEntry != nil {
+				newServiceEntry.Labels = map[string]string{common.GetWorkloadIdentifier(): fmt.Sprintf("%v", clusterSeDr.identityId)}
+				err = addUpdateServiceEntry(newServiceEntry, oldServiceEntry, syncNamespace, rc)
+				if err != nil {
+					if isClusterUnavailableError(err) {
+						return err
 					}
+					clusterErr = err
+				}
+				cache.SeClusterCache.Put(newServiceEntry.Spec.Hosts[0], rc.ClusterID, rc.ClusterID)
+			}
 
-					newDestinationRule := createDestinationRuleSkeletion(*seDr.DestinationRule, seDr.DrName, syncNamespace)
-					// if event was deletion when this function was called, then GlobalTrafficCache should already deleted the cache globalTrafficPolicy is an empty shell object
-					addUpdateDestinationRule(newDestinationRule, oldDestinationRule, syncNamespace, rc)
+			newDestinationRule := createDestinationRuleSkeletion(*seDr.DestinationRule, seDr.DrName, syncNamespace)
+			// if event was deletion when this function was called, then GlobalTrafficCache should already deleted the cache globalTrafficPolicy is an empty shell object
+			err = addUpdateDestinationRule(newDestinationRule, oldDestinationRule, syncNamespace, rc)
+			if err != nil {
+				if isClusterUnavailableError(err) {
+					return err
 				}
+				clusterErr = err
 			}
 		}
 	}
+	return clusterErr
 }
 
-func createSeAndDrSetFromGtp(env, region string, se *networking.ServiceEntry, globalTrafficPolicy *v1.GlobalTrafficPolicy,
-	cache *AdmiralCache) map[string]*SeDrTuple {
+func createSeAndDrSetFromGtp(env, locality string, se *networking.ServiceEntry, globalTrafficPolicy *v1.GlobalTrafficPolicy,
+	cache *AdmiralCache, knownRegions map[string]string) map[string]*SeDrTuple {
 	var defaultDrName = getIstioResourceName(se.Hosts[0], "-default-dr")
 	var defaultSeName = getIstioResourceName(se.Hosts[0], "-se")
 	var seDrSet = make(map[string]*SeDrTuple)
@@ -512,6 +745,11 @@ func createSeAndDrSetFromGtp(env, region string, se *networking.ServiceEntry, gl
 			if gtpTrafficPolicy.Dns != "" {
 				log.Warnf("Using the deprecated field `dns` in gtp: %v in namespace: %v", globalTrafficPolicy.Name, globalTrafficPolicy.Namespace)
 			}
+			if err := validateFailover(gtpTrafficPolicy, knownRegions); err != nil {
+				log.Errorf("Ignoring failover for dnsPrefix: %v in gtp: %v in namespace: %v, err: %v", gtpTrafficPolicy.DnsPrefix, globalTrafficPolicy.Name, globalTrafficPolicy.Namespace, err)
+				gtpTrafficPolicy = gtpTrafficPolicy.DeepCopy()
+				gtpTrafficPolicy.Failover = nil
+			}
 			if gtpTrafficPolicy.DnsPrefix != env && gtpTrafficPolicy.DnsPrefix != common.Default &&
 				gtpTrafficPolicy.Dns != host {
 				host = common.GetCnameVal([]string{gtpTrafficPolicy.DnsPrefix, se.Hosts[0]})
@@ -523,7 +761,7 @@ func createSeAndDrSetFromGtp(env, region string, se *networking.ServiceEntry, gl
 			var seDr = &SeDrTuple{
 				DrName:          drName,
 				SeName:          seName,
-				DestinationRule: getDestinationRule(modifiedSe, region, gtpTrafficPolicy),
+				DestinationRule: getDestinationRule(modifiedSe, locality, gtpTrafficPolicy),
 				ServiceEntry:    modifiedSe,
 			}
 			seDrSet[host] = seDr
@@ -534,7 +772,7 @@ func createSeAndDrSetFromGtp(env, region string, se *networking.ServiceEntry, gl
 		var seDr = &SeDrTuple{
 			DrName:          defaultDrName,
 			SeName:          defaultSeName,
-			DestinationRule: getDestinationRule(se, region, nil),
+			DestinationRule: getDestinationRule(se, locality, nil),
 			ServiceEntry:    se,
 		}
 		seDrSet[se.Hosts[0]] = seDr
@@ -542,10 +780,111 @@ func createSeAndDrSetFromGtp(env, region string, se *networking.ServiceEntry, gl
 	return seDrSet
 }
 
-func makeRemoteEndpointForServiceEntry(address string, locality string, portName string, portNumber int) *networking.ServiceEntry_Endpoint {
+//Returns the regions of all clusters monitored by admiral, a cluster spanning several regions contributes the region of each of its nodes
+func getKnownRegions(cache *AdmiralCache, rcs map[string]*RemoteController) map[string]string {
+	knownRegions := make(map[string]string)
+	for _, rc := range rcs {
+		if rc == nil {
+			continue
+		}
+		if locality := rc.NodeController.GetLocality(); locality != nil && locality.Region != "" {
+			knownRegions[locality.Region] = locality.Region
+		}
+	}
+	if cache == nil || cache.ClusterLocalityCache == nil {
+		return knownRegions
+	}
+	cache.ClusterLocalityCache.Range(func(cluster string, localities *common.Map) {
+		localities.Range(func(locality string, nodes string) {
+			if l := admiral.ParseLocality(locality); l != nil {
+				knownRegions[l.Region] = l.Region
+			}
+		})
+	})
+	return knownRegions
+}
+
+//Returns an endpoint that sends the traffic of every service entry port to the given port of the remote address in the network
+func makeRemoteEndpointForServiceEntry(address string, locality string, network string, sePorts []*networking.Port, portNumber int) *networking.ServiceEntry_Endpoint {
+	var ports = make(map[string]uint32)
+	for _, sePort := range sePorts {
+		ports[sePort.Name] = uint32(portNumber)
+	}
 	return &networking.ServiceEntry_Endpoint{Address: address,
 		Locality: locality,
-		Ports:    map[string]uint32{portName: uint32(portNumber)}} //
+		Network:  network,
+		Ports:    ports}
+}
+
+//Returns the east west gateways of the cluster, or a dummy gateway when none of the gateway services has an address yet
+func getGatewayEndpoints(rc *RemoteController) []admiral.GatewayEndpoint {
+	gateways := rc.ServiceController.Cache.GetGateways(common.GetGatewaySelector(), common.GetGatewayNamespaces(), common.GetGatewayPortName())
+	if len(gateways) > 0 {
+		return gateways
+	}
+	gateway := admiral.GatewayEndpoint{Address: common.DummyGatewayAddress, Port: common.DefaultMtlsPort}
+	namespaces := common.GetGatewayNamespaces()
+	if len(namespaces) == 1 && len(rc.ServiceController.Cache.Get(namespaces[0])) == 0 {
+		gateway.Port = 0
+	}
+	return []admiral.GatewayEndpoint{gateway}
+}
+
+//Returns an endpoint per east west gateway of the cluster
+func getGatewayServiceEntryEndpoints(rc *RemoteController, sePorts []*networking.Port) []*networking.ServiceEntry_Endpoint {
+	locality := rc.NodeController.GetLocality().String()
+	var seEndpoints []*networking.ServiceEntry_Endpoint
+	for _, gateway := range getGatewayEndpoints(rc) {
+		seEndpoints = append(seEndpoints, makeRemoteEndpointForServiceEntry(gateway.Address,
+			locality, gateway.Network, sePorts, gateway.Port))
+	}
+	return seEndpoints
+}
+
+//Returns the service entry with only the first of the endpoints pointing to the gateways of the cluster, the one replaced by the local
+//endpoints when writing to the cluster itself
+func removeExtraGatewayEndpoints(se *networking.ServiceEntry, gatewayAddresses map[string]bool) *networking.ServiceEntry {
+	var gatewayEndpoints int
+	for _, ep := range se.Endpoints {
+		if gatewayAddresses[ep.Address] {
+			gatewayEndpoints++
+		}
+	}
+	if gatewayEndpoints < 2 {
+		return se
+	}
+	newSe := copyServiceEntry(se)
+	endpoints := newSe.Endpoints
+	newSe.Endpoints = []*networking.ServiceEntry_Endpoint{}
+	var found bool
+	for _, ep := range endpoints {
+		if gatewayAddresses[ep.Address] {
+			if found {
+				continue
+			}
+			found = true
+		}
+		newSe.Endpoints = append(newSe.Endpoints, ep)
+	}
+	return newSe
+}
+
+func containsEndpoint(endpoints []*networking.ServiceEntry_Endpoint, endpoint *networking.ServiceEntry_Endpoint) bool {
+	for _, ep := range endpoints {
+		if reflect.DeepEqual(ep, endpoint) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasServiceEntryPort(sePorts []*networking.Port, name string) bool {
+	for _, sePort := range sePorts {
+		if sePort.Name == name {
+			return true
+		}
+	}
+	return false
 }
 
 func copyServiceEntry(se *networking.ServiceEntry) *networking.ServiceEntry {
@@ -554,6 +893,12 @@ func copyServiceEntry(se *networking.ServiceEntry) *networking.ServiceEntry {
 	return newSe
 }
 
+func copyDestinationRule(dr *networking.DestinationRule) *networking.DestinationRule {
+	var newDr = &networking.DestinationRule{}
+	dr.DeepCopyInto(newDr)
+	return newDr
+}
+
 func loadServiceEntryCacheData(c admiral.ConfigMapControllerInterface, admiralCache *AdmiralCache) {
 	configmap, err := c.GetConfigMap()
 	if err != nil {
@@ -598,6 +943,14 @@ func GetServiceEntriesByCluster(clusterID string, remoteRegistry *RemoteRegistry
 	}
 }
 
+//Returns the global traffic policies currently shadowed by another policy, grouped by identity and env
+func GetGlobalTrafficPolicyConflicts(remoteRegistry *RemoteRegistry) []GtpConflict {
+	if remoteRegistry.AdmiralCache == nil || remoteRegistry.AdmiralCache.GlobalTrafficCache == nil {
+		return []GtpConflict{}
+	}
+	return remoteRegistry.AdmiralCache.GlobalTrafficCache.GetConflicts()
+}
+
 //an atomic fetch and update operation against the configmap (using K8s built in optimistic consistency mechanism via resource version)
 func GenerateNewAddressAndAddToConfigMap(seName string, configMapController admiral.ConfigMapControllerInterface) (string, error) {
 	//1. get cm, see if there. 2. gen new uq address. 3. put configmap. RETURN SUCCESSFULLY IFF CONFIGMAP PUT SUCCEEDS
@@ -616,18 +969,10 @@ func GenerateNewAddressAndAddToConfigMap(seName string, configMapController admi
 		return val, nil
 	}
 
-	secondIndex := (len(newAddressState.Addresses) / 255) + 10
-	firstIndex := (len(newAddressState.Addresses) % 255) + 1
-	address := common.LocalAddressPrefix + common.Sep + strconv.Itoa(secondIndex) + common.Sep + strconv.Itoa(firstIndex)
-
-	for util.Contains(newAddressState.Addresses, address) {
-		if firstIndex < 255 {
-			firstIndex++
-		} else {
-			secondIndex++
-			firstIndex = 0
-		}
-		address = common.LocalAddressPrefix + common.Sep + strconv.Itoa(secondIndex) + common.Sep + strconv.Itoa(firstIndex)
+	//the lowest free address is used so that the addresses released by the reclaimer get reused
+	address, err := getFreeServiceEntryAddress(newAddressState.Addresses)
+	if err != nil {
+		return "", err
 	}
 	newAddressState.Addresses = append(newAddressState.Addresses, address)
 	newAddressState.EntryAddresses[seName] = address
@@ -684,10 +1029,11 @@ func createServiceEntryForRollout(event admiral.EventType, rc *RemoteController,
 
 	san := getSanForRollout(destRollout, workloadIdentityKey)
 
-	if destRollout.Spec.Strategy.BlueGreen != nil && destRollout.Spec.Strategy.BlueGreen.PreviewService != "" {
+	//the preview service of a blue green rollout gets its own host until the rollout is promoted
+	if destRollout.Spec.Strategy.BlueGreen != nil && destRollout.Spec.Strategy.BlueGreen.PreviewService != "" && !isBlueGreenPromoted(destRollout) {
 		rolloutServices := getServiceForRollout(rc, destRollout)
 		if _, ok := rolloutServices[destRollout.Spec.Strategy.BlueGreen.PreviewService]; ok {
-			previewGlobalFqdn := common.BlueGreenRolloutPreviewPrefix + common.Sep + common.GetCnameForRollout(destRollout, workloadIdentityKey, common.GetHostnameSuffix())
+			previewGlobalFqdn := common.GetPreviewCnameForRollout(destRollout, workloadIdentityKey, common.GetHostnameSuffix())
 			previewAddress := getUniqueAddress(admiralCache, previewGlobalFqdn)
 			if len(previewGlobalFqdn) != 0 && len(previewAddress) != 0 {
 				generateServiceEntry(event, admiralCache, meshPorts, previewGlobalFqdn, rc, serviceEntries, previewAddress, san)
@@ -695,10 +1041,71 @@ func createServiceEntryForRollout(event admiral.EventType, rc *RemoteController,
 		}
 	}
 
+	//the canary service of a canary rollout gets its own host, whatever its weight
+	if canaryGlobalFqdn := getCanaryHost(destRollout); len(canaryGlobalFqdn) != 0 && getServiceForRolloutCanary(rc, destRollout) != nil {
+		canaryAddress := getUniqueAddress(admiralCache, canaryGlobalFqdn)
+		if len(canaryAddress) != 0 {
+			generateServiceEntry(event, admiralCache, meshPorts, canaryGlobalFqdn, rc, serviceEntries, canaryAddress, san)
+		}
+	}
+
 	tmpSe := generateServiceEntry(event, admiralCache, meshPorts, globalFqdn, rc, serviceEntries, address, san)
 	return tmpSe
 }
 
+func createServiceEntryForStatefulSet(event admiral.EventType, rc *RemoteController, admiralCache *AdmiralCache,
+	meshPorts map[string]uint32, destStatefulSet *k8sAppsV1.StatefulSet, destService *k8sV1.Service, serviceEntries map[string]*networking.ServiceEntry) *networking.ServiceEntry {
+
+	workloadIdentityKey := common.GetWorkloadIdentifier()
+	globalFqdn := common.GetCnameForStatefulSet(destStatefulSet, workloadIdentityKey, common.GetHostnameSuffix())
+
+	//Handling retries for getting/putting service entries from/in cache
+
+	address := getUniqueAddress(admiralCache, globalFqdn)
+
+	if len(globalFqdn) == 0 || len(address) == 0 {
+		return nil
+	}
+
+	san := getSanForStatefulSet(destStatefulSet, workloadIdentityKey)
+
+	//every pod gets its own global name when requested, so that clients can address a specific replica
+	for podFqdn := range getStatefulSetPodHosts(destStatefulSet, destService, globalFqdn) {
+		podAddress := getUniqueAddress(admiralCache, podFqdn)
+		if len(podAddress) != 0 {
+			generateServiceEntry(event, admiralCache, meshPorts, podFqdn, rc, serviceEntries, podAddress, san)
+		}
+	}
+
+	tmpSe := generateServiceEntry(event, admiralCache, meshPorts, globalFqdn, rc, serviceEntries, address, san)
+	return tmpSe
+}
+
+//Returns the global names of the pods of a statefulset mapped to their local fqdn, in the format <pod name>.<global fqdn>
+//The pods only get global names if the statefulset has the admiral.io/statefulset-pod-hosts annotation and the service is headless
+func getStatefulSetPodHosts(statefulSet *k8sAppsV1.StatefulSet, service *k8sV1.Service, globalFqdn string) map[string]string {
+	podHosts := make(map[string]string)
+	if statefulSet == nil || service == nil || len(globalFqdn) == 0 {
+		return podHosts
+	}
+	if statefulSet.Spec.Template.Annotations[common.StatefulSetPodHostsAnnotation] != "true" {
+		return podHosts
+	}
+	if service.Spec.ClusterIP != k8sV1.ClusterIPNone {
+		log.Warnf(LogFormat, "Skip", "statefulset-pod-hosts", statefulSet.Name, "", "service="+service.Name+" is not headless, namespace="+statefulSet.Namespace)
+		return podHosts
+	}
+	var replicas int32 = 1
+	if statefulSet.Spec.Replicas != nil {
+		replicas = *statefulSet.Spec.Replicas
+	}
+	for i := int32(0); i < replicas; i++ {
+		podName := statefulSet.Name + common.Dash + strconv.Itoa(int(i))
+		podHosts[strings.ToLower(podName)+common.Sep+globalFqdn] = podName + common.Sep + service.Name + common.Sep + service.Namespace + common.DotLocalDomainSuffix
+	}
+	return podHosts
+}
+
 func getSanForDeployment(destDeployment *k8sAppsV1.Deployment, workloadIdentityKey string) (san []string) {
 	if common.GetEnableSAN() {
 		tmpSan := common.GetSAN(common.GetSANPrefix(), destDeployment, workloadIdentityKey)
@@ -721,6 +1128,17 @@ func getSanForRollout(destRollout *argo.Rollout, workloadIdentityKey string) (sa
 
 }
 
+func getSanForStatefulSet(destStatefulSet *k8sAppsV1.StatefulSet, workloadIdentityKey string) (san []string) {
+	if common.GetEnableSAN() {
+		tmpSan := common.GetSANForStatefulSet(common.GetSANPrefix(), destStatefulSet, workloadIdentityKey)
+		if len(tmpSan) > 0 {
+			return []string{tmpSan}
+		}
+	}
+	return nil
+
+}
+
 func getUniqueAddress(admiralCache *AdmiralCache, globalFqdn string) (address string) {
 
 	//initializations
@@ -728,15 +1146,15 @@ func getUniqueAddress(admiralCache *AdmiralCache, globalFqdn string) (address st
 	maxRetries := 3
 	counter := 0
 	address = ""
-	needsCacheUpdate := false
+	allocator := getAddressAllocator(admiralCache)
 
-	for err == nil && counter < maxRetries {
-		address, needsCacheUpdate, err = GetLocalAddressForSe(getIstioResourceName(globalFqdn, "-se"), admiralCache.ServiceEntryAddressStore, admiralCache.ConfigMapController)
+	for counter < maxRetries {
+		address, _, err = allocator.GetAddress(getIstioResourceName(globalFqdn, "-se"))
 
-		if err != nil {
-			log.Errorf("Error getting local address for Service Entry. Err: %v", err)
+		if err == nil {
 			break
 		}
+		log.Errorf("Error getting local address for Service Entry. Err: %v", err)
 
 		//random expo backoff
 		timeToBackoff := rand.Intn(int(math.Pow(100.0, float64(counter)))) //get a random number between 0 and 100^counter. Will always be 0 the first time, will be 0-100 the second, and 0-1000 the third
@@ -750,10 +1168,6 @@ func getUniqueAddress(admiralCache *AdmiralCache, globalFqdn string) (address st
 		return address
 	}
 
-	if needsCacheUpdate {
-		loadServiceEntryCacheData(admiralCache.ConfigMapController, admiralCache)
-	}
-
 	return address
 }
 
@@ -762,16 +1176,7 @@ func generateServiceEntry(event admiral.EventType, admiralCache *AdmiralCache, m
 
 	tmpSe := serviceEntries[globalFqdn]
 
-	var finalProtocol = common.Http
-
-	var sePorts = []*networking.Port{{Number: uint32(common.DefaultServiceEntryPort),
-		Name: finalProtocol, Protocol: finalProtocol}}
-
-	for protocol := range meshPorts {
-		sePorts = []*networking.Port{{Number: uint32(common.DefaultServiceEntryPort),
-			Name: protocol, Protocol: protocol}}
-		finalProtocol = protocol
-	}
+	var sePorts = getServiceEntryPorts(meshPorts)
 
 	if tmpSe == nil {
 		tmpSe = &networking.ServiceEntry{
@@ -783,26 +1188,26 @@ func generateServiceEntry(event admiral.EventType, admiralCache *AdmiralCache, m
 			SubjectAltNames: san,
 		}
 		tmpSe.Endpoints = []*networking.ServiceEntry_Endpoint{}
+	} else {
+		//carry over the mesh ports exposed only by the workload in this cluster
+		for _, sePort := range sePorts {
+			if !hasServiceEntryPort(tmpSe.Ports, sePort.Name) {
+				tmpSe.Ports = append(tmpSe.Ports, sePort)
+			}
+		}
 	}
 
-	endpointAddress, port := rc.ServiceController.Cache.GetLoadBalancer(common.GetAdmiralParams().LabelSet.GatewayApp, common.NamespaceIstioSystem)
-
-	var locality string
-	if rc.NodeController.Locality != nil {
-		locality = rc.NodeController.Locality.Region
-	}
-	seEndpoint := makeRemoteEndpointForServiceEntry(endpointAddress,
-		locality, finalProtocol, port)
+	seEndpoints := getGatewayServiceEntryEndpoints(rc, sePorts)
 
 	// if the action is deleting an endpoint from service entry, loop through the list and delete matching ones
 	if event == admiral.Add || event == admiral.Update {
-		tmpSe.Endpoints = append(tmpSe.Endpoints, seEndpoint)
+		tmpSe.Endpoints = append(tmpSe.Endpoints, seEndpoints...)
 	} else if event == admiral.Delete {
 		// create a tmp endpoint list to store all the endpoints that we intend to keep
 		remainEndpoints := []*networking.ServiceEntry_Endpoint{}
-		// if the endpoint is not equal to the endpoint we intend to delete, append it to remainEndpoint list
+		// if the endpoint is not equal to any of the endpoints we intend to delete, append it to remainEndpoint list
 		for _, existingEndpoint := range tmpSe.Endpoints {
-			if !reflect.DeepEqual(existingEndpoint, seEndpoint) {
+			if !containsEndpoint(seEndpoints, existingEndpoint) {
 				remainEndpoints = append(remainEndpoints, existingEndpoint)
 			}
 		}
@@ -816,6 +1221,123 @@ func generateServiceEntry(event admiral.EventType, admiralCache *AdmiralCache, m
 	return tmpSe
 }
 
+//Returns true once the preview service of a blue green rollout serves the same replica set as its active service
+func isBlueGreenPromoted(rollout *argo.Rollout) bool {
+	status := rollout.Status.BlueGreen
+	return len(status.PreviewSelector) > 0 && status.PreviewSelector == status.ActiveSelector
+}
+
+//Returns the host of the canary service of a canary rollout, or an empty string if the rollout has none
+func getCanaryHost(rollout *argo.Rollout) string {
+	if rollout == nil || rollout.Spec.Strategy.Canary == nil || len(rollout.Spec.Strategy.Canary.CanaryService) == 0 {
+		return ""
+	}
+	return common.GetCanaryCnameForRollout(rollout, common.GetWorkloadIdentifier(), common.GetHostnameSuffix())
+}
+
+//Returns the canary service of a canary rollout, if it selects the pods of the rollout
+func getServiceForRolloutCanary(rc *RemoteController, rollout *argo.Rollout) *k8sV1.Service {
+	if rollout == nil || rollout.Spec.Strategy.Canary == nil || len(rollout.Spec.Strategy.Canary.CanaryService) == 0 {
+		return nil
+	}
+	for _, service := range rc.ServiceController.Cache.Get(rollout.Namespace) {
+		if service.Name == rollout.Spec.Strategy.Canary.CanaryService && common.IsServiceMatch(service.Spec.Selector, rollout.Spec.Selector) {
+			return service
+		}
+	}
+	return nil
+}
+
+//Deletes the service entries and destination rules of the hosts generated on top of the cname which aren't generated anymore, e.g. the
+//preview host of a blue green rollout once its preview service is gone or it is promoted, or the hosts of the pods a statefulset was
+//scaled down by, from the source and dependent clusters. The deletes go through the cluster writer and are guarded like the other
+//deletes of service entries, a host is forgotten once it's deleted from every cluster and kept to be deleted on a later sync otherwise
+func deleteStaleHosts(remoteRegistry *RemoteRegistry, identity string, env string, cname string, serviceEntries map[string]*networking.ServiceEntry) {
+	cache := remoteRegistry.AdmiralCache
+	staleHosts := make(map[string]map[string]string)
+	cache.CnameClusterCache.Range(func(host string, clusters *common.Map) {
+		if _, ok := serviceEntries[host]; ok || !strings.HasSuffix(host, common.Sep+cname) || strings.Contains(strings.TrimSuffix(host, common.Sep+cname), common.Sep) {
+			return
+		}
+		staleHosts[host] = clusters.Copy()
+	})
+	if len(staleHosts) == 0 {
+		return
+	}
+	clusterHosts := make(map[string][]string)
+	for host, clusters := range staleHosts {
+		//the host was written to the dependent clusters it was recorded for, and to the ones of the cname before hosts were recorded
+		util.MapCopy(clusters, cache.CnameDependentClusterCache.Get(host).Copy())
+		util.MapCopy(clusters, cache.CnameDependentClusterCache.Get(cname).Copy())
+		for _, clusterId := range clusters {
+			if remoteRegistry.RemoteControllers[clusterId] != nil {
+				clusterHosts[clusterId] = append(clusterHosts[clusterId], host)
+			}
+		}
+	}
+
+	var mutex sync.Mutex
+	pendingHosts := make(map[string]bool)
+	clusters := make([]string, 0, len(clusterHosts))
+	for clusterId := range clusterHosts {
+		clusters = append(clusters, clusterId)
+	}
+	clusterErrors := cache.ClusterWriter.Write([]identityKey{{identity: identity, env: env}}, clusters, func(clusterId string) error {
+		pending, err := deleteServiceEntriesForHosts(clusterHosts[clusterId], remoteRegistry.RemoteControllers[clusterId], cache)
+		mutex.Lock()
+		for _, host := range pending {
+			pendingHosts[host] = true
+		}
+		mutex.Unlock()
+		return err
+	})
+	for clusterId, err := range clusterErrors {
+		log.Warnf(LogErrFormat, "Delete", "ServiceEntry", cname, clusterId, err)
+		for _, host := range clusterHosts[clusterId] {
+			pendingHosts[host] = true
+		}
+	}
+
+	for host, clusters := range staleHosts {
+		if pendingHosts[host] {
+			log.Infof(LogFormat, "Delete", "ServiceEntry", host, "", "stale host of "+cname+" kept until it's deleted from every cluster")
+			continue
+		}
+		log.Infof(LogFormat, "Delete", "ServiceEntry", host, "", fmt.Sprintf("stale host of %s deleted from clusters=%v", cname, clusters))
+		cache.CnameClusterCache.Delete(host)
+		cache.CnameDependentClusterCache.Delete(host)
+		cache.CnameIdentityCache.Delete(host)
+	}
+}
+
+//Deletes the service entries and destination rules of the hosts from the cluster, the destination rule of a host is only deleted along
+//with its service entry. Returns the hosts whose service entries are kept as their deletes were skipped or blocked, the deletes stop at
+//the first error showing the cluster is unavailable
+func deleteServiceEntriesForHosts(hosts []string, rc *RemoteController, cache *AdmiralCache) ([]string, error) {
+	syncNamespace := common.GetSyncNamespace()
+	var pending []string
+	for i, host := range hosts {
+		serviceEntry, err := rc.ServiceEntryController.IstioClient.NetworkingV1alpha3().ServiceEntries(syncNamespace).Get(getIstioResourceName(host, "-se"), v12.GetOptions{})
+		if err != nil && !k8sErrors.IsNotFound(err) {
+			if isClusterUnavailableError(err) {
+				return append(pending, hosts[i:]...), err
+			}
+			pending = append(pending, host)
+			continue
+		}
+		if err == nil && !deleteGuardedServiceEntry(serviceEntry, syncNamespace, rc) {
+			pending = append(pending, host)
+			continue
+		}
+		destinationRule, err := rc.DestinationRuleController.IstioClient.NetworkingV1alpha3().DestinationRules(syncNamespace).Get(getIstioResourceName(host, "-default-dr"), v12.GetOptions{})
+		if err == nil {
+			deleteDestinationRule(destinationRule, syncNamespace, rc)
+		}
+		cache.SeClusterCache.DeleteMap(host, rc.ClusterID)
+	}
+	return pending, nil
+}
+
 func isBlueGreenStrategy(rollout *argo.Rollout) bool {
 	if rollout != nil && &rollout.Spec != (&argo.RolloutSpec{}) && rollout.Spec.Strategy != (argo.RolloutStrategy{}) {
 		if rollout.Spec.Strategy.BlueGreen != nil {
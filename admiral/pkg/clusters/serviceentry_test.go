@@ -58,7 +58,7 @@ func TestCreateSeWithDrLabels(t *testing.T) {
 		ConfigmapToReturn: buildFakeConfigMapFromAddressStore(&cacheWithNoEntry, "123"),
 	}
 
-	res := createSeWithDrLabels(nil, false, "", "test-se", &se, &des, &cacheWithNoEntry, &emptyCacheController)
+	res := createSeWithDrLabels(nil, "", "test-se", &se, &des, &AdmiralCache{ServiceEntryAddressStore: &cacheWithNoEntry, ConfigMapController: &emptyCacheController})
 
 	if res == nil {
 		t.Fail()
@@ -266,7 +266,7 @@ func TestCreateSeAndDrSetFromGtp(t *testing.T) {
 	//Run the test for every provided case
 	for _, c := range testCases {
 		t.Run(c.name, func(t *testing.T) {
-			result := createSeAndDrSetFromGtp(c.env, c.locality, c.se, c.gtp, &admiralCache)
+			result := createSeAndDrSetFromGtp(c.env, c.locality, c.se, c.gtp, &admiralCache, map[string]string{"us-west-2": "us-west-2", "us-east-2": "us-east-2"})
 			generatedHosts := make([]string, 0, len(result))
 			for generatedHost := range result {
 				generatedHosts = append(generatedHosts, generatedHost)
@@ -281,6 +281,45 @@ func TestCreateSeAndDrSetFromGtp(t *testing.T) {
 		})
 	}
 
+	//failover chains are only applied when valid
+	gtpWithFailover := &v13.GlobalTrafficPolicy{
+		Spec: model.GlobalTrafficPolicy{
+			Policy: []*model.TrafficPolicy{{
+				LbType:    model.TrafficPolicy_TOPOLOGY,
+				DnsPrefix: common.Default,
+				Failover:  []*model.TrafficPolicy_Failover{{From: "us-west-2", To: "us-east-2"}},
+			}},
+		},
+	}
+	result := createSeAndDrSetFromGtp("dev", "us-west-2", se, gtpWithFailover, &admiralCache, map[string]string{"us-west-2": "us-west-2", "us-east-2": "us-east-2"})
+	if len(result[host].DestinationRule.TrafficPolicy.LoadBalancer.LocalityLbSetting.Failover) != 1 {
+		t.Errorf("Expected failover to be set for known regions, got %v", result[host].DestinationRule)
+	}
+	result = createSeAndDrSetFromGtp("dev", "us-west-2", se, gtpWithFailover, &admiralCache, map[string]string{"us-west-2": "us-west-2"})
+	if result[host].DestinationRule.TrafficPolicy.LoadBalancer != nil {
+		t.Errorf("Expected failover to be ignored for unknown regions, got %v", result[host].DestinationRule)
+	}
+	if len(gtpWithFailover.Spec.Policy[0].Failover) != 1 {
+		t.Errorf("Expected the gtp to be left untouched, got %v", gtpWithFailover.Spec.Policy[0])
+	}
+}
+
+func TestGetKnownRegions(t *testing.T) {
+	rcs := map[string]*RemoteController{
+		"cluster1": {NodeController: &admiral.NodeController{Locality: &admiral.Locality{Region: "us-west-2"}}},
+		"cluster2": {NodeController: &admiral.NodeController{}},
+	}
+	cache := &AdmiralCache{ClusterLocalityCache: common.NewMapOfMaps()}
+	cache.ClusterLocalityCache.Put("cluster1", "us-west-2/us-west-2a", "3")
+	//the nodes of a cluster can span several regions
+	cache.ClusterLocalityCache.Put("cluster2", "us-east-2/us-east-2a", "2")
+	cache.ClusterLocalityCache.Put("cluster2", "eu-west-1", "1")
+
+	knownRegions := getKnownRegions(cache, rcs)
+	expected := map[string]string{"us-west-2": "us-west-2", "us-east-2": "us-east-2", "eu-west-1": "eu-west-1"}
+	if !reflect.DeepEqual(knownRegions, expected) {
+		t.Errorf("Expected %v, got %v", expected, knownRegions)
+	}
 }
 
 func TestCreateServiceEntryForNewServiceOrPod(t *testing.T) {
@@ -289,7 +328,7 @@ func TestCreateServiceEntryForNewServiceOrPod(t *testing.T) {
 		KubeconfigPath: "testdata/fake.config",
 	}
 	rr, _ := InitAdmiral(context.Background(), p)
-	rr.StartTime = time.Now().Add(-60*time.Second)
+	rr.informersSynced = nil
 
 	config := rest.Config{
 		Host: "localhost",
@@ -470,8 +509,9 @@ func TestMakeRemoteEndpointForServiceEntry(t *testing.T) {
 	address := "1.2.3.4"
 	locality := "us-west-2"
 	portName := "port"
+	sePorts := []*istionetworkingv1alpha3.Port{{Number: 80, Name: portName, Protocol: "http"}, {Number: 8091, Name: "grpc-8091", Protocol: "grpc"}}
 
-	endpoint := makeRemoteEndpointForServiceEntry(address, locality, portName, common.DefaultMtlsPort)
+	endpoint := makeRemoteEndpointForServiceEntry(address, locality, "", sePorts, common.DefaultMtlsPort)
 
 	if endpoint.Address != address {
 		t.Errorf("Address mismatch. Got: %v, expected: %v", endpoint.Address, address)
@@ -479,11 +519,78 @@ func TestMakeRemoteEndpointForServiceEntry(t *testing.T) {
 	if endpoint.Locality != locality {
 		t.Errorf("Locality mismatch. Got: %v, expected: %v", endpoint.Locality, locality)
 	}
-	if endpoint.Ports[portName] != 15443 {
+	if endpoint.Ports[portName] != 15443 || endpoint.Ports["grpc-8091"] != 15443 {
 		t.Errorf("Incorrect port found")
 	}
 }
 
+func TestCreateServiceEntryWithMultipleGateways(t *testing.T) {
+	config := rest.Config{
+		Host: "localhost",
+	}
+	stop := make(chan struct{})
+	s, e := admiral.NewServiceController("test", stop, &test.MockServiceHandler{}, &config, time.Second*time.Duration(300))
+	if e != nil {
+		t.Fatalf("%v", e)
+	}
+
+	var gatewayApp string
+	if common.GetAdmiralParams().LabelSet != nil {
+		gatewayApp = common.GetAdmiralParams().LabelSet.GatewayApp
+	}
+	for i, network := range []string{"network1", "network2"} {
+		gateway := &v1.Service{}
+		gateway.Name = "eastwest-" + network
+		gateway.Namespace = common.NamespaceIstioSystem
+		gateway.CreationTimestamp = v12.NewTime(time.Now().Add(-time.Duration(i) * time.Hour))
+		gateway.Labels = map[string]string{"app": gatewayApp, common.NetworkLabel: network}
+		gateway.Status.LoadBalancer.Ingress = []v1.LoadBalancerIngress{{Hostname: network + ".elb"}}
+		s.Cache.Put(gateway)
+	}
+
+	admiralCache := AdmiralCache{}
+	localAddress := common.LocalAddressPrefix + ".10.1"
+	admiralCache.CnameClusterCache = common.NewMapOfMaps()
+	admiralCache.ServiceEntryAddressStore = &ServiceEntryAddressStore{
+		EntryAddresses: map[string]string{"e2e.my-first-service.mesh-se": localAddress},
+		Addresses:      []string{localAddress},
+	}
+	admiralCache.ConfigMapController = &test.FakeConfigMapController{
+		ConfigmapToReturn: buildFakeConfigMapFromAddressStore(admiralCache.ServiceEntryAddressStore, "123"),
+	}
+	rc := &RemoteController{
+		NodeController:    &admiral.NodeController{Locality: &admiral.Locality{Region: "us-west-2"}},
+		ServiceController: s,
+	}
+
+	deployment := v14.Deployment{}
+	deployment.Spec.Template.Labels = map[string]string{"env": "e2e", "identity": "my-first-service"}
+	serviceEntries := map[string]*istionetworkingv1alpha3.ServiceEntry{}
+
+	expected := []*istionetworkingv1alpha3.ServiceEntry_Endpoint{
+		{Address: "network1.elb", Ports: map[string]uint32{"http": common.DefaultMtlsPort}, Locality: "us-west-2", Network: "network1"},
+		{Address: "network2.elb", Ports: map[string]uint32{"http": common.DefaultMtlsPort}, Locality: "us-west-2", Network: "network2"},
+	}
+	se := createServiceEntry(admiral.Add, rc, &admiralCache, map[string]uint32{"http": uint32(80)}, &deployment, serviceEntries)
+	if !reflect.DeepEqual(se.Endpoints, expected) {
+		t.Errorf("Expected an endpoint per gateway, got %v", se.Endpoints)
+	}
+
+	gatewayAddresses := map[string]bool{"network1.elb": true, "network2.elb": true}
+	local := removeExtraGatewayEndpoints(se, gatewayAddresses)
+	if len(local.Endpoints) != 1 || local.Endpoints[0].Address != "network1.elb" || len(se.Endpoints) != 2 {
+		t.Errorf("Expected a copy with only the first gateway endpoint, got %v", local.Endpoints)
+	}
+	if removeExtraGatewayEndpoints(se, map[string]bool{"network1.elb": true}) != se {
+		t.Errorf("Expected the service entry to be returned as is with a single gateway endpoint")
+	}
+
+	se = createServiceEntry(admiral.Delete, rc, &admiralCache, map[string]uint32{"http": uint32(80)}, &deployment, serviceEntries)
+	if len(se.Endpoints) != 0 {
+		t.Errorf("Expected the endpoints of all the gateways to be deleted, got %v", se.Endpoints)
+	}
+}
+
 func buildFakeConfigMapFromAddressStore(addressStore *ServiceEntryAddressStore, resourceVersion string) *v1.ConfigMap {
 	bytes, _ := yaml.Marshal(addressStore)
 
@@ -743,6 +850,19 @@ func TestCreateServiceEntry(t *testing.T) {
 		},
 	}
 
+	multiPortSe := istionetworkingv1alpha3.ServiceEntry{
+		Hosts:     []string{"e2e.my-first-service.mesh"},
+		Addresses: []string{localAddress},
+		Ports: []*istionetworkingv1alpha3.Port{{Number: uint32(common.DefaultServiceEntryPort), Name: "http", Protocol: "http"},
+			{Number: 8091, Name: "grpc-8091", Protocol: "grpc"}},
+		Location:        istionetworkingv1alpha3.ServiceEntry_MESH_INTERNAL,
+		Resolution:      istionetworkingv1alpha3.ServiceEntry_DNS,
+		SubjectAltNames: []string{"spiffe://prefix/my-first-service"},
+		Endpoints: []*istionetworkingv1alpha3.ServiceEntry_Endpoint{
+			{Address: "dummy.admiral.global", Ports: map[string]uint32{"http": 0, "grpc-8091": 0}, Locality: "us-west-2"},
+		},
+	}
+
 	deploymentSeCreationTestCases := []struct {
 		name           string
 		action         admiral.EventType
@@ -773,6 +893,16 @@ func TestCreateServiceEntry(t *testing.T) {
 			serviceEntries: map[string]*istionetworkingv1alpha3.ServiceEntry{},
 			expectedResult: &se,
 		},
+		{
+			name:           "Should return a created service entry with all the mesh ports",
+			action:         admiral.Add,
+			rc:             rc,
+			admiralCache:   admiralCache,
+			meshPorts:      map[string]uint32{"http": uint32(8090), "grpc-8091": uint32(8091)},
+			deployment:     deployment,
+			serviceEntries: map[string]*istionetworkingv1alpha3.ServiceEntry{},
+			expectedResult: &multiPortSe,
+		},
 		{
 			name:         "Delete the service entry with one endpoint",
 			action:       admiral.Delete,
@@ -876,7 +1006,7 @@ func TestCreateServiceEntryForNewServiceOrPodRolloutsUsecase(t *testing.T) {
 
 	rr, _ := InitAdmiral(context.Background(), p)
 
-	rr.StartTime = time.Now().Add(-60*time.Second)
+	rr.informersSynced = nil
 
 	config := rest.Config{
 		Host: "localhost",
@@ -1014,7 +1144,7 @@ func TestCreateServiceEntryForBlueGreenRolloutsUsecase(t *testing.T) {
 	config := rest.Config{
 		Host: "localhost",
 	}
-	rr.StartTime = time.Now().Add(-60*time.Second)
+	rr.informersSynced = nil
 
 	d, e := admiral.NewDeploymentController("", make(chan struct{}), &test.MockDeploymentHandler{}, &config, time.Second*time.Duration(300))
 
@@ -1359,14 +1489,31 @@ func TestUpdateGlobalGtpCache(t *testing.T) {
 		gtp3 = &v13.GlobalTrafficPolicy{ObjectMeta: v12.ObjectMeta{Name: "gtp3", Namespace: "namespace2", CreationTimestamp: v12.NewTime(time.Now()), Labels: map[string]string{"identity": identity1, "env": env_stage}}, Spec: model.GlobalTrafficPolicy{
 			Policy: []*model.TrafficPolicy{{DnsPrefix: "hellogtp3"}},
 		}}
+
+		gtp4 = &v13.GlobalTrafficPolicy{ObjectMeta: v12.ObjectMeta{Name: "gtp4", Namespace: "namespace1", CreationTimestamp: v12.NewTime(time.Now().Add(-time.Hour)), Labels: map[string]string{"identity": identity1, "env": env_stage}}, Spec: model.GlobalTrafficPolicy{
+			Policy: []*model.TrafficPolicy{{DnsPrefix: "hellogtp4"}}, Priority: 10,
+		}}
+
+		sameCreationTime = v12.NewTime(time.Now().Add(-time.Minute))
+
+		gtp5 = &v13.GlobalTrafficPolicy{ObjectMeta: v12.ObjectMeta{Name: "gtp5", Namespace: "namespace1", CreationTimestamp: sameCreationTime, Labels: map[string]string{"identity": identity1, "env": env_stage}}, Spec: model.GlobalTrafficPolicy{
+			Policy: []*model.TrafficPolicy{{DnsPrefix: "hellogtp5"}},
+		}}
+
+		gtp5Copy = gtp5.DeepCopy()
+
+		gtp6 = &v13.GlobalTrafficPolicy{ObjectMeta: v12.ObjectMeta{Name: "gtp6", Namespace: "namespace1", CreationTimestamp: sameCreationTime, Labels: map[string]string{"identity": identity1, "env": env_stage}}, Spec: model.GlobalTrafficPolicy{
+			Policy: []*model.TrafficPolicy{{DnsPrefix: "hellogtp6"}},
+		}}
 	)
 
 	testCases := []struct {
-		name        string
-		identity    string
-		env         string
-		gtps        map[string][]*v13.GlobalTrafficPolicy
-		expectedGtp *v13.GlobalTrafficPolicy
+		name             string
+		identity         string
+		env              string
+		gtps             map[string][]*v13.GlobalTrafficPolicy
+		expectedGtp      *v13.GlobalTrafficPolicy
+		expectedShadowed []string
 	}{{
 		name:        "Should return nil when no GTP present",
 		gtps:        map[string][]*v13.GlobalTrafficPolicy{},
@@ -1382,28 +1529,65 @@ func TestUpdateGlobalGtpCache(t *testing.T) {
 			expectedGtp: gtp,
 		},
 		{
-			name:        "Should return the gtp recently created within the cluster",
-			gtps:        map[string][]*v13.GlobalTrafficPolicy{"c1": {gtp, gtp2}},
-			identity:    identity1,
-			env:         env_stage,
-			expectedGtp: gtp2,
+			name:             "Should return the gtp recently created within the cluster",
+			gtps:             map[string][]*v13.GlobalTrafficPolicy{"c1": {gtp, gtp2}},
+			identity:         identity1,
+			env:              env_stage,
+			expectedGtp:      gtp2,
+			expectedShadowed: []string{"c1/namespace1/gtp"},
 		},
 		{
-			name:        "Should return the gtp recently created from another cluster",
-			gtps:        map[string][]*v13.GlobalTrafficPolicy{"c1": {gtp, gtp2}, "c2": {gtp3}},
-			identity:    identity1,
-			env:         env_stage,
-			expectedGtp: gtp3,
+			name:             "Should return the gtp recently created from another cluster",
+			gtps:             map[string][]*v13.GlobalTrafficPolicy{"c1": {gtp, gtp2}, "c2": {gtp3}},
+			identity:         identity1,
+			env:              env_stage,
+			expectedGtp:      gtp3,
+			expectedShadowed: []string{"c1/namespace1/gtp2", "c1/namespace1/gtp"},
+		},
+		{
+			name:             "Should return the gtp with the highest priority over the recently created ones",
+			gtps:             map[string][]*v13.GlobalTrafficPolicy{"c1": {gtp, gtp2, gtp4}, "c2": {gtp3}},
+			identity:         identity1,
+			env:              env_stage,
+			expectedGtp:      gtp4,
+			expectedShadowed: []string{"c2/namespace2/gtp3", "c1/namespace1/gtp2", "c1/namespace1/gtp"},
+		},
+		{
+			name:             "Should return the gtp with the lowest name when created at the same time",
+			gtps:             map[string][]*v13.GlobalTrafficPolicy{"c1": {gtp6}, "c2": {gtp5}},
+			identity:         identity1,
+			env:              env_stage,
+			expectedGtp:      gtp5,
+			expectedShadowed: []string{"c1/namespace1/gtp6"},
+		},
+		{
+			name:             "Should return the gtp from the lowest cluster when the same gtp exists in several clusters",
+			gtps:             map[string][]*v13.GlobalTrafficPolicy{"c2": {gtp5}, "c1": {gtp5Copy}},
+			identity:         identity1,
+			env:              env_stage,
+			expectedGtp:      gtp5Copy,
+			expectedShadowed: []string{"c2/namespace1/gtp5"},
 		},
 	}
 
 	for _, c := range testCases {
 		t.Run(c.name, func(t *testing.T) {
-			updateGlobalGtpCache(admiralCache, c.identity, c.env, c.gtps)
+			activeGtp := updateGlobalGtpCache(admiralCache, c.identity, c.env, c.gtps)
 			gtp := admiralCache.GlobalTrafficCache.GetFromIdentity(c.identity, c.env)
 			if !reflect.DeepEqual(c.expectedGtp, gtp) {
 				t.Errorf("Test %s failed expected gtp: %v got %v", c.name, c.expectedGtp, gtp)
 			}
+			if c.expectedGtp != activeGtp {
+				t.Errorf("Test %s failed expected returned gtp: %v got %v", c.name, c.expectedGtp, activeGtp)
+			}
+			conflicts := admiralCache.GlobalTrafficCache.GetConflicts()
+			if len(c.expectedShadowed) == 0 {
+				if len(conflicts) != 0 {
+					t.Errorf("Test %s failed expected no conflicts got %v", c.name, conflicts)
+				}
+			} else if len(conflicts) != 1 || !reflect.DeepEqual(c.expectedShadowed, conflicts[0].Shadowed) {
+				t.Errorf("Test %s failed expected shadowed gtps: %v got %v", c.name, c.expectedShadowed, conflicts)
+			}
 		})
 	}
 }
@@ -1494,3 +1678,401 @@ func TestIsBlueGreenStrategy(t *testing.T) {
 		})
 	}
 }
+
+func TestCreateServiceEntryForNewServiceOrPodStatefulSetUsecase(t *testing.T) {
+
+	const NAMESPACE = "test-test"
+
+	p := common.AdmiralParams{
+		KubeconfigPath: "testdata/fake.config",
+	}
+
+	rr, _ := InitAdmiral(context.Background(), p)
+
+	rr.informersSynced = nil
+
+	config := rest.Config{
+		Host: "localhost",
+	}
+
+	d, e := admiral.NewDeploymentController("", make(chan struct{}), &test.MockDeploymentHandler{}, &config, time.Second*time.Duration(300))
+	ss, e := admiral.NewStatefulSetController("", make(chan struct{}), &test.MockStatefulSetHandler{}, &config, time.Second*time.Duration(300))
+	if e != nil {
+		t.Fail()
+	}
+	s, e := admiral.NewServiceController("test", make(chan struct{}), &test.MockServiceHandler{}, &config, time.Second*time.Duration(300))
+
+	gtpc, e := admiral.NewGlobalTrafficController("", make(chan struct{}), &test.MockGlobalTrafficHandler{}, &config, time.Second*time.Duration(300))
+
+	cacheWithEntry := ServiceEntryAddressStore{
+		EntryAddresses: map[string]string{
+			"test.test.mesh-se":         common.LocalAddressPrefix + ".10.1",
+			"redis-0.test.test.mesh-se": common.LocalAddressPrefix + ".10.2",
+			"redis-1.test.test.mesh-se": common.LocalAddressPrefix + ".10.3",
+		},
+		Addresses: []string{common.LocalAddressPrefix + ".10.1", common.LocalAddressPrefix + ".10.2", common.LocalAddressPrefix + ".10.3"},
+	}
+
+	fakeIstioClient := istiofake.NewSimpleClientset()
+	rc := &RemoteController{
+		ServiceEntryController: &istio.ServiceEntryController{
+			IstioClient: fakeIstioClient,
+		},
+		DestinationRuleController: &istio.DestinationRuleController{
+			IstioClient: fakeIstioClient,
+		},
+		NodeController: &admiral.NodeController{
+			Locality: &admiral.Locality{
+				Region: "us-west-2",
+			},
+		},
+		DeploymentController:  d,
+		StatefulSetController: ss,
+		ServiceController:     s,
+		GlobalTraffic:         gtpc,
+	}
+	rc.ClusterID = "test.cluster"
+	rr.RemoteControllers["test.cluster"] = rc
+
+	admiralCache := &AdmiralCache{
+		IdentityClusterCache:       common.NewMapOfMaps(),
+		ServiceEntryAddressStore:   &cacheWithEntry,
+		CnameClusterCache:          common.NewMapOfMaps(),
+		CnameIdentityCache:         &sync.Map{},
+		CnameDependentClusterCache: common.NewMapOfMaps(),
+		IdentityDependencyCache:    common.NewMapOfMaps(),
+		GlobalTrafficCache:         &globalTrafficCache{},
+		DependencyNamespaceCache:   common.NewSidecarEgressMap(),
+		SeClusterCache:             common.NewMapOfMaps(),
+	}
+	rr.AdmiralCache = admiralCache
+
+	var replicas int32 = 2
+	statefulSet := v14.StatefulSet{
+		ObjectMeta: v12.ObjectMeta{Name: "redis", Namespace: NAMESPACE},
+		Spec: v14.StatefulSetSpec{
+			Replicas:    &replicas,
+			ServiceName: "redis",
+			Selector:    &v12.LabelSelector{MatchLabels: map[string]string{"app": "redis"}},
+			Template: coreV1.PodTemplateSpec{
+				ObjectMeta: v12.ObjectMeta{
+					Labels:      map[string]string{"identity": "test"},
+					Annotations: map[string]string{common.StatefulSetPodHostsAnnotation: "true"},
+				},
+			},
+		},
+	}
+	ss.Cache.UpdateStatefulSetToClusterCache("bar", &statefulSet)
+
+	service := &coreV1.Service{
+		ObjectMeta: v12.ObjectMeta{Name: "redis", Namespace: NAMESPACE},
+		Spec: coreV1.ServiceSpec{
+			ClusterIP: coreV1.ClusterIPNone,
+			Selector:  map[string]string{"app": "redis"},
+			Ports:     []coreV1.ServicePort{{Name: "tcp-redis", Port: 6379}},
+		},
+	}
+	s.Cache.Put(service)
+
+	dependentIstioClient := istiofake.NewSimpleClientset()
+	rr.RemoteControllers["dependent.cluster"] = &RemoteController{
+		ClusterID:                 "dependent.cluster",
+		DeploymentController:      d,
+		ServiceEntryController:    &istio.ServiceEntryController{IstioClient: dependentIstioClient},
+		DestinationRuleController: &istio.DestinationRuleController{IstioClient: dependentIstioClient},
+	}
+	admiralCache.IdentityDependencyCache.Put("bar", "webapp", "webapp")
+	admiralCache.IdentityClusterCache.Put("webapp", "dependent.cluster", "dependent.cluster")
+
+	se := modifyServiceEntryForNewServiceOrPod(admiral.Add, "test", "bar", rr)
+	if len(se) != 3 {
+		t.Fatalf("Expected 3 service entries, got %v", len(se))
+	}
+
+	expectedEndpoints := map[string]string{
+		"test.test.mesh":         "redis.test-test.svc.cluster.local",
+		"redis-0.test.test.mesh": "redis-0.redis.test-test.svc.cluster.local",
+		"redis-1.test.test.mesh": "redis-1.redis.test-test.svc.cluster.local",
+	}
+	for host, endpoint := range expectedEndpoints {
+		if se[host] == nil {
+			t.Errorf("Service entry for host %v should have been generated", host)
+			continue
+		}
+		createdSe, err := fakeIstioClient.NetworkingV1alpha3().ServiceEntries(common.GetSyncNamespace()).Get(getIstioResourceName(host, "-se"), v12.GetOptions{})
+		if err != nil {
+			t.Errorf("Service entry for host %v should have been written to the source cluster, err: %v", host, err)
+			continue
+		}
+		if len(createdSe.Spec.Endpoints) != 1 || createdSe.Spec.Endpoints[0].Address != endpoint {
+			t.Errorf("Unexpected endpoints for host %v, got %v expected %v", host, createdSe.Spec.Endpoints, endpoint)
+		}
+	}
+
+	//the pod hosts are recorded for the dependent clusters they are written to
+	if admiralCache.CnameDependentClusterCache.Get("redis-1.test.test.mesh").Get("dependent.cluster") == "" {
+		t.Errorf("Expected redis-1 to be recorded for the dependent cluster")
+	}
+	if _, err := dependentIstioClient.NetworkingV1alpha3().ServiceEntries(common.GetSyncNamespace()).Get("redis-1.test.test.mesh-se", v12.GetOptions{}); err != nil {
+		t.Errorf("Expected the service entry of redis-1 to be written to the dependent cluster, err: %v", err)
+	}
+
+	//the service entries of the pods the statefulset is scaled down by are deleted from the source and dependent clusters
+	replicas = 1
+	se = modifyServiceEntryForNewServiceOrPod(admiral.Update, "test", "bar", rr)
+	if len(se) != 2 || se["redis-1.test.test.mesh"] != nil {
+		t.Fatalf("Expected the service entry of redis-1 not to be generated anymore, got %v", se)
+	}
+	for clusterId, istioClient := range map[string]*istiofake.Clientset{"test.cluster": fakeIstioClient, "dependent.cluster": dependentIstioClient} {
+		if _, err := istioClient.NetworkingV1alpha3().ServiceEntries(common.GetSyncNamespace()).Get("redis-1.test.test.mesh-se", v12.GetOptions{}); err == nil {
+			t.Errorf("Expected the service entry of redis-1 to be deleted from %v", clusterId)
+		}
+	}
+	if admiralCache.CnameClusterCache.Get("redis-1.test.test.mesh") != nil || admiralCache.CnameDependentClusterCache.Get("redis-1.test.test.mesh") != nil {
+		t.Errorf("Expected redis-1 to be removed from the caches")
+	}
+	if _, err := fakeIstioClient.NetworkingV1alpha3().ServiceEntries(common.GetSyncNamespace()).Get("redis-0.test.test.mesh-se", v12.GetOptions{}); err != nil {
+		t.Errorf("Expected the service entry of redis-0 to be kept, err: %v", err)
+	}
+}
+
+func TestGetStatefulSetPodHosts(t *testing.T) {
+	var replicas int32 = 2
+	statefulSet := &v14.StatefulSet{
+		ObjectMeta: v12.ObjectMeta{Name: "Redis", Namespace: "ns"},
+		Spec: v14.StatefulSetSpec{
+			Replicas: &replicas,
+			Template: coreV1.PodTemplateSpec{
+				ObjectMeta: v12.ObjectMeta{
+					Annotations: map[string]string{common.StatefulSetPodHostsAnnotation: "true"},
+				},
+			},
+		},
+	}
+	statefulSetWithoutAnnotation := statefulSet.DeepCopy()
+	statefulSetWithoutAnnotation.Spec.Template.Annotations = nil
+
+	headlessService := &coreV1.Service{
+		ObjectMeta: v12.ObjectMeta{Name: "redis", Namespace: "ns"},
+		Spec:       coreV1.ServiceSpec{ClusterIP: coreV1.ClusterIPNone},
+	}
+	service := &coreV1.Service{
+		ObjectMeta: v12.ObjectMeta{Name: "redis", Namespace: "ns"},
+		Spec:       coreV1.ServiceSpec{ClusterIP: "10.0.0.1"},
+	}
+
+	testCases := []struct {
+		name        string
+		statefulSet *v14.StatefulSet
+		service     *coreV1.Service
+		expected    map[string]string
+	}{
+		{
+			name:        "Given a headless service and the pod hosts annotation, should return a host per replica",
+			statefulSet: statefulSet,
+			service:     headlessService,
+			expected: map[string]string{
+				"redis-0.stage.redis.global": "Redis-0.redis.ns.svc.cluster.local",
+				"redis-1.stage.redis.global": "Redis-1.redis.ns.svc.cluster.local",
+			},
+		},
+		{
+			name:        "Given a service that is not headless, should return no hosts",
+			statefulSet: statefulSet,
+			service:     service,
+			expected:    map[string]string{},
+		},
+		{
+			name:        "Given a statefulset without the pod hosts annotation, should return no hosts",
+			statefulSet: statefulSetWithoutAnnotation,
+			service:     headlessService,
+			expected:    map[string]string{},
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			podHosts := getStatefulSetPodHosts(c.statefulSet, c.service, "stage.redis.global")
+			if !reflect.DeepEqual(podHosts, c.expected) {
+				t.Errorf("Unexpected pod hosts, got %v expected %v", podHosts, c.expected)
+			}
+		})
+	}
+}
+
+func TestCreateServiceEntryForRolloutHosts(t *testing.T) {
+	config := rest.Config{Host: "localhost"}
+	serviceController, err := admiral.NewServiceController("cluster1", make(chan struct{}), &test.MockServiceHandler{}, &config, time.Minute)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	selector := map[string]string{"app": "greeting"}
+	for _, name := range []string{"greeting-stable", "greeting-canary", "greeting-active", "greeting-preview"} {
+		serviceController.Cache.Put(&v1.Service{
+			ObjectMeta: v12.ObjectMeta{Name: name, Namespace: "ns"},
+			Spec:       v1.ServiceSpec{Selector: selector, Ports: []v1.ServicePort{{Name: "http", Port: 80}}},
+		})
+	}
+	rc := &RemoteController{ClusterID: "cluster1", ServiceController: serviceController,
+		NodeController: &admiral.NodeController{Locality: &admiral.Locality{Region: "us-west-2"}}}
+	admiralCache := &AdmiralCache{
+		CnameClusterCache: common.NewMapOfMaps(),
+		ServiceEntryAddressStore: &ServiceEntryAddressStore{EntryAddresses: map[string]string{
+			"stage.greeting.mesh-se":         "240.0.10.1",
+			"canary.stage.greeting.mesh-se":  "240.0.10.2",
+			"preview.stage.greeting.mesh-se": "240.0.10.3",
+			"blue.stage.greeting.mesh-se":    "240.0.10.4",
+		}},
+		ConfigMapController: &test.FakeConfigMapController{},
+	}
+
+	canaryRollout := &argo.Rollout{ObjectMeta: v12.ObjectMeta{Name: "greeting", Namespace: "ns"}}
+	canaryRollout.Spec.Selector = &v12.LabelSelector{MatchLabels: selector}
+	canaryRollout.Spec.Template.Labels = map[string]string{"env": "stage", "identity": "greeting"}
+	canaryRollout.Spec.Strategy.Canary = &argo.CanaryStrategy{StableService: "greeting-stable", CanaryService: "greeting-canary"}
+
+	serviceEntries := make(map[string]*istionetworkingv1alpha3.ServiceEntry)
+	createServiceEntryForRollout(admiral.Add, rc, admiralCache, map[string]uint32{"http": 80}, canaryRollout, serviceEntries)
+	if serviceEntries["canary.stage.greeting.mesh"] == nil || serviceEntries["stage.greeting.mesh"] == nil {
+		t.Errorf("Expected a service entry for the canary host of the rollout, got %v", serviceEntries)
+	}
+	if service := getServiceForRolloutCanary(rc, canaryRollout); service == nil || service.Name != "greeting-canary" {
+		t.Errorf("Expected the canary service of the rollout, got %v", service)
+	}
+
+	blueGreenRollout := canaryRollout.DeepCopy()
+	blueGreenRollout.Spec.Strategy = argo.RolloutStrategy{BlueGreen: &argo.BlueGreenStrategy{ActiveService: "greeting-active", PreviewService: "greeting-preview"}}
+	blueGreenRollout.Status.BlueGreen = argo.BlueGreenStatus{ActiveSelector: "1234", PreviewSelector: "5678"}
+
+	serviceEntries = make(map[string]*istionetworkingv1alpha3.ServiceEntry)
+	createServiceEntryForRollout(admiral.Add, rc, admiralCache, map[string]uint32{"http": 80}, blueGreenRollout, serviceEntries)
+	if serviceEntries["preview.stage.greeting.mesh"] == nil || serviceEntries["canary.stage.greeting.mesh"] != nil {
+		t.Errorf("Expected a service entry for the preview host of the rollout only, got %v", serviceEntries)
+	}
+
+	blueGreenRollout.Spec.Template.Annotations = map[string]string{common.PreviewPrefixAnnotation: "Blue"}
+	serviceEntries = make(map[string]*istionetworkingv1alpha3.ServiceEntry)
+	createServiceEntryForRollout(admiral.Add, rc, admiralCache, map[string]uint32{"http": 80}, blueGreenRollout, serviceEntries)
+	if serviceEntries["blue.stage.greeting.mesh"] == nil || serviceEntries["preview.stage.greeting.mesh"] != nil {
+		t.Errorf("Expected the preview host to use the prefix of the rollout, got %v", serviceEntries)
+	}
+
+	//the preview service serves the active replica set once the rollout is promoted
+	blueGreenRollout.Status.BlueGreen.PreviewSelector = "1234"
+	serviceEntries = make(map[string]*istionetworkingv1alpha3.ServiceEntry)
+	createServiceEntryForRollout(admiral.Add, rc, admiralCache, map[string]uint32{"http": 80}, blueGreenRollout, serviceEntries)
+	if len(serviceEntries) != 1 || serviceEntries["stage.greeting.mesh"] == nil {
+		t.Errorf("Expected no preview host once the rollout is promoted, got %v", serviceEntries)
+	}
+}
+
+func TestDeleteStaleHosts(t *testing.T) {
+	syncNamespace := common.GetSyncNamespace()
+	istioClient := istiofake.NewSimpleClientset()
+	rc := &RemoteController{
+		ClusterID:                 "cluster1",
+		ServiceEntryController:    &istio.ServiceEntryController{IstioClient: istioClient},
+		DestinationRuleController: &istio.DestinationRuleController{IstioClient: istioClient},
+	}
+	remoteIstioClient := istiofake.NewSimpleClientset()
+	remoteRc := &RemoteController{
+		ClusterID:                 "cluster2",
+		ServiceEntryController:    &istio.ServiceEntryController{IstioClient: remoteIstioClient},
+		DestinationRuleController: &istio.DestinationRuleController{IstioClient: remoteIstioClient},
+	}
+	rr := &RemoteRegistry{
+		RemoteControllers: map[string]*RemoteController{"cluster1": rc, "cluster2": remoteRc},
+		AdmiralCache: &AdmiralCache{
+			CnameClusterCache:          common.NewMapOfMaps(),
+			CnameDependentClusterCache: common.NewMapOfMaps(),
+			CnameIdentityCache:         &sync.Map{},
+			SeClusterCache:             common.NewMapOfMaps(),
+		},
+	}
+	for _, host := range []string{"stage.greeting.mesh", "preview.stage.greeting.mesh", "canary.stage.greeting.mesh"} {
+		rr.AdmiralCache.CnameClusterCache.Put(host, "cluster1", "cluster1")
+		for _, client := range []*istiofake.Clientset{istioClient, remoteIstioClient} {
+			client.NetworkingV1alpha3().ServiceEntries(syncNamespace).Create(&v1alpha3.ServiceEntry{ObjectMeta: v12.ObjectMeta{Name: getIstioResourceName(host, "-se")}})
+			client.NetworkingV1alpha3().DestinationRules(syncNamespace).Create(&v1alpha3.DestinationRule{ObjectMeta: v12.ObjectMeta{Name: getIstioResourceName(host, "-default-dr")}})
+		}
+	}
+	rr.AdmiralCache.CnameDependentClusterCache.Put("stage.greeting.mesh", "cluster2", "cluster2")
+	rr.AdmiralCache.CnameIdentityCache.Store("preview.stage.greeting.mesh", "greeting")
+
+	serviceEntries := map[string]*istionetworkingv1alpha3.ServiceEntry{
+		"stage.greeting.mesh":        {Hosts: []string{"stage.greeting.mesh"}},
+		"canary.stage.greeting.mesh": {Hosts: []string{"canary.stage.greeting.mesh"}},
+	}
+	deleteStaleHosts(rr, "greeting", "stage", "stage.greeting.mesh", serviceEntries)
+
+	for _, client := range []*istiofake.Clientset{istioClient, remoteIstioClient} {
+		if _, err := client.NetworkingV1alpha3().ServiceEntries(syncNamespace).Get("preview.stage.greeting.mesh-se", v12.GetOptions{}); err == nil {
+			t.Errorf("Expected the service entry of the stale host to be deleted")
+		}
+		if _, err := client.NetworkingV1alpha3().DestinationRules(syncNamespace).Get("preview.stage.greeting.mesh-default-dr", v12.GetOptions{}); err == nil {
+			t.Errorf("Expected the destination rule of the stale host to be deleted")
+		}
+		for _, name := range []string{"stage.greeting.mesh-se", "canary.stage.greeting.mesh-se"} {
+			if _, err := client.NetworkingV1alpha3().ServiceEntries(syncNamespace).Get(name, v12.GetOptions{}); err != nil {
+				t.Errorf("Expected the service entry %v to be kept, got %v", name, err)
+			}
+		}
+	}
+	if rr.AdmiralCache.CnameClusterCache.Get("preview.stage.greeting.mesh") != nil {
+		t.Errorf("Expected the stale host to be removed from the cache")
+	}
+	if _, ok := rr.AdmiralCache.CnameIdentityCache.Load("preview.stage.greeting.mesh"); ok {
+		t.Errorf("Expected the stale host to be removed from the identity cache")
+	}
+}
+
+func TestDeleteStaleHostsWhileNotSynced(t *testing.T) {
+	syncNamespace := common.GetSyncNamespace()
+	istioClient := istiofake.NewSimpleClientset()
+	rc := &RemoteController{
+		ClusterID:                 "cluster1",
+		ServiceEntryController:    &istio.ServiceEntryController{IstioClient: istioClient},
+		DestinationRuleController: &istio.DestinationRuleController{IstioClient: istioClient},
+		informersSynced:           map[string]func() bool{"serviceentry": func() bool { return false }},
+	}
+	rr := &RemoteRegistry{
+		RemoteControllers: map[string]*RemoteController{"cluster1": rc},
+		AdmiralCache: &AdmiralCache{
+			CnameClusterCache:          common.NewMapOfMaps(),
+			CnameDependentClusterCache: common.NewMapOfMaps(),
+			CnameIdentityCache:         &sync.Map{},
+			SeClusterCache:             common.NewMapOfMaps(),
+			ClusterWriter:              NewClusterWriter(2, time.Minute),
+		},
+	}
+	rr.AdmiralCache.CnameClusterCache.Put("preview.stage.greeting.mesh", "cluster1", "cluster1")
+	istioClient.NetworkingV1alpha3().ServiceEntries(syncNamespace).Create(&v1alpha3.ServiceEntry{
+		ObjectMeta: v12.ObjectMeta{Name: "preview.stage.greeting.mesh-se"},
+		Spec: istionetworkingv1alpha3.ServiceEntry{
+			Hosts:     []string{"preview.stage.greeting.mesh"},
+			Endpoints: []*istionetworkingv1alpha3.ServiceEntry_Endpoint{{Address: "greeting-preview.ns.svc.cluster.local"}},
+		},
+	})
+	istioClient.NetworkingV1alpha3().DestinationRules(syncNamespace).Create(&v1alpha3.DestinationRule{ObjectMeta: v12.ObjectMeta{Name: "preview.stage.greeting.mesh-default-dr"}})
+
+	deleteStaleHosts(rr, "greeting", "stage", "stage.greeting.mesh", map[string]*istionetworkingv1alpha3.ServiceEntry{})
+
+	if _, err := istioClient.NetworkingV1alpha3().ServiceEntries(syncNamespace).Get("preview.stage.greeting.mesh-se", v12.GetOptions{}); err != nil {
+		t.Errorf("Expected the delete of the service entry to be skipped while the cluster isn't synced, got %v", err)
+	}
+	if _, err := istioClient.NetworkingV1alpha3().DestinationRules(syncNamespace).Get("preview.stage.greeting.mesh-default-dr", v12.GetOptions{}); err != nil {
+		t.Errorf("Expected the destination rule to be kept along with its service entry, got %v", err)
+	}
+	if rr.AdmiralCache.CnameClusterCache.Get("preview.stage.greeting.mesh") == nil {
+		t.Errorf("Expected the stale host to be kept in the cache to be deleted on a later sync")
+	}
+
+	rc.informersSynced = nil
+	deleteStaleHosts(rr, "greeting", "stage", "stage.greeting.mesh", map[string]*istionetworkingv1alpha3.ServiceEntry{})
+	if _, err := istioClient.NetworkingV1alpha3().ServiceEntries(syncNamespace).Get("preview.stage.greeting.mesh-se", v12.GetOptions{}); err == nil {
+		t.Errorf("Expected the service entry to be deleted once the cluster is synced")
+	}
+	if rr.AdmiralCache.CnameClusterCache.Get("preview.stage.greeting.mesh") != nil {
+		t.Errorf("Expected the stale host to be removed from the cache once it's deleted")
+	}
+}
@@ -0,0 +1,235 @@
+package clusters
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/common"
+	log "github.com/sirupsen/logrus"
+	"istio.io/client-go/pkg/apis/networking/v1alpha3"
+)
+
+//ServiceEntryGuard blocks the service entry updates of a cluster that remove more than a percentage of the endpoints or all of them,
+//until the same update is observed again once the confirmation delay has passed. A source cluster briefly reporting no endpoints,
+//e.g. while its gateway is replaced, then doesn't strip the endpoints from the service entries of every cluster. The pod endpoints of
+//the clusters on a flat network follow the scaling of the workloads, only the removal of all the endpoints is guarded for them
+type ServiceEntryGuard struct {
+	clusterId string
+	//percentage of the endpoints an update can remove without being confirmed
+	threshold int
+	delay     time.Duration
+	mutex     sync.Mutex
+	//key=namespace/name
+	blocked map[string]*BlockedUpdate
+	//syncs the identity of a blocked update again once its confirmation delay has passed, the update is otherwise only confirmed by the
+	//next event of the identity
+	Requeue func(env string, identity string)
+}
+
+//BlockedUpdate is a service entry update waiting to be confirmed
+type BlockedUpdate struct {
+	Cluster   string `json:"cluster"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Reason    string `json:"reason"`
+	//the endpoints of the service entry in the cluster
+	Endpoints []string `json:"endpoints"`
+	//the endpoints the update removes
+	RemovedEndpoints []string `json:"removedEndpoints"`
+	//when the update was first blocked
+	Since time.Time `json:"since"`
+	//the endpoints after the update, a different update restarts the confirmation
+	updatedEndpoints string
+}
+
+func NewServiceEntryGuard(clusterId string, threshold int, delay time.Duration) *ServiceEntryGuard {
+	return &ServiceEntryGuard{clusterId: clusterId, threshold: threshold, delay: delay, blocked: make(map[string]*BlockedUpdate)}
+}
+
+//Allow returns true if the update of exist to obj can be applied, otherwise the reason it is blocked. A nil guard allows every update
+func (g *ServiceEntryGuard) Allow(obj *v1alpha3.ServiceEntry, exist *v1alpha3.ServiceEntry) (bool, string) {
+	if g == nil || exist == nil {
+		return true, ""
+	}
+	endpoints := getEndpointAddresses(exist)
+	updatedEndpoints := getEndpointAddresses(obj)
+	guardedEndpoints := getGuardedEndpointAddresses(exist)
+	removed := getRemovedEndpoints(guardedEndpoints, updatedEndpoints)
+
+	var reason string
+	if len(endpoints) > 0 && len(updatedEndpoints) == 0 {
+		reason = "removes all the endpoints"
+	} else if len(removed)*100 > g.threshold*len(guardedEndpoints) {
+		reason = fmt.Sprintf("removes %d of %d endpoints, more than %d%%", len(removed), len(guardedEndpoints), g.threshold)
+	}
+
+	key := exist.Namespace + common.Slash + exist.Name
+	defer g.mutex.Unlock()
+	g.mutex.Lock()
+	if reason == "" {
+		if _, ok := g.blocked[key]; ok {
+			delete(g.blocked, key)
+			log.Infof(LogFormat, "Update", "ServiceEntry", exist.Name, g.clusterId, "Blocked update cleared as the endpoints are not removed anymore")
+		}
+		common.ServiceEntryUpdatesBlocked.With(g.clusterId).Set(float64(len(g.blocked)))
+		return true, ""
+	}
+
+	now := time.Now()
+	signature := strings.Join(updatedEndpoints, ",")
+	if blocked, ok := g.blocked[key]; ok && blocked.updatedEndpoints == signature {
+		if now.Sub(blocked.Since) < g.delay {
+			return false, reason
+		}
+		delete(g.blocked, key)
+		common.ServiceEntryUpdatesBlocked.With(g.clusterId).Set(float64(len(g.blocked)))
+		log.Infof(LogFormat, "Update", "ServiceEntry", exist.Name, g.clusterId, fmt.Sprintf("Blocked update confirmed after %v", now.Sub(blocked.Since)))
+		return true, ""
+	}
+
+	g.blocked[key] = &BlockedUpdate{
+		Cluster:          g.clusterId,
+		Namespace:        exist.Namespace,
+		Name:             exist.Name,
+		Reason:           reason,
+		Endpoints:        endpoints,
+		RemovedEndpoints: removed,
+		Since:            now,
+		updatedEndpoints: signature,
+	}
+	common.ServiceEntryUpdatesBlockedTotal.With(g.clusterId).Inc()
+	common.ServiceEntryUpdatesBlocked.With(g.clusterId).Set(float64(len(g.blocked)))
+	g.scheduleRequeue(key, signature, obj, exist)
+	return false, reason
+}
+
+//Requeues the identity of the service entry once the confirmation delay of its blocked update has passed, unless the update is cleared
+//or replaced by then
+func (g *ServiceEntryGuard) scheduleRequeue(key string, signature string, obj *v1alpha3.ServiceEntry, exist *v1alpha3.ServiceEntry) {
+	if g.Requeue == nil || len(exist.Spec.Hosts) == 0 {
+		return
+	}
+	identity := obj.Labels[common.GetWorkloadIdentifier()]
+	if identity == "" {
+		identity = exist.Labels[common.GetWorkloadIdentifier()]
+	}
+	env := getEnvForHost(exist.Spec.Hosts[0], identity)
+	if env == "" {
+		log.Warnf(LogFormat, "Requeue", "ServiceEntry", exist.Name, g.clusterId, "Blocked update not requeued as its identity is unknown")
+		return
+	}
+	time.AfterFunc(g.delay, func() {
+		g.mutex.Lock()
+		blocked, ok := g.blocked[key]
+		g.mutex.Unlock()
+		if !ok || blocked.updatedEndpoints != signature {
+			return
+		}
+		log.Infof(LogFormat, "Requeue", "ServiceEntry", exist.Name, g.clusterId, "Blocked update observed again after the confirmation delay, identity="+identity)
+		g.Requeue(env, identity)
+	})
+}
+
+//Forget drops the blocked update of a service entry, e.g. once it is deleted
+func (g *ServiceEntryGuard) Forget(namespace string, name string) {
+	if g == nil {
+		return
+	}
+	defer g.mutex.Unlock()
+	g.mutex.Lock()
+	delete(g.blocked, namespace+common.Slash+name)
+	common.ServiceEntryUpdatesBlocked.With(g.clusterId).Set(float64(len(g.blocked)))
+}
+
+//GetBlockedUpdates returns copies of the updates waiting to be confirmed, sorted by namespace and name
+func (g *ServiceEntryGuard) GetBlockedUpdates() []BlockedUpdate {
+	if g == nil {
+		return []BlockedUpdate{}
+	}
+	defer g.mutex.Unlock()
+	g.mutex.Lock()
+	blockedUpdates := make([]BlockedUpdate, 0, len(g.blocked))
+	for _, blocked := range g.blocked {
+		blockedUpdates = append(blockedUpdates, *blocked)
+	}
+	sort.Slice(blockedUpdates, func(i, j int) bool {
+		if blockedUpdates[i].Namespace != blockedUpdates[j].Namespace {
+			return blockedUpdates[i].Namespace < blockedUpdates[j].Namespace
+		}
+		return blockedUpdates[i].Name < blockedUpdates[j].Name
+	})
+	return blockedUpdates
+}
+
+//GetBlockedServiceEntryUpdates returns the service entry updates waiting to be confirmed in every cluster, sorted by cluster
+func GetBlockedServiceEntryUpdates(remoteRegistry *RemoteRegistry) []BlockedUpdate {
+	remoteRegistry.Lock()
+	clusterIds := make([]string, 0, len(remoteRegistry.RemoteControllers))
+	guards := make(map[string]*ServiceEntryGuard, len(remoteRegistry.RemoteControllers))
+	for clusterId, rc := range remoteRegistry.RemoteControllers {
+		clusterIds = append(clusterIds, clusterId)
+		guards[clusterId] = rc.ServiceEntryGuard
+	}
+	remoteRegistry.Unlock()
+
+	sort.Strings(clusterIds)
+	blockedUpdates := make([]BlockedUpdate, 0)
+	for _, clusterId := range clusterIds {
+		blockedUpdates = append(blockedUpdates, guards[clusterId].GetBlockedUpdates()...)
+	}
+	return blockedUpdates
+}
+
+//Returns the env of a host generated for the identity, the hosts are made of the env, the identity and the hostname suffix and can be
+//prefixed, e.g. with the dns prefix of a global traffic policy. Returns an empty string if the identity isn't in the host
+func getEnvForHost(host string, identity string) string {
+	if identity == "" {
+		return ""
+	}
+	index := strings.Index(strings.ToLower(host), common.Sep+strings.ToLower(identity)+common.Sep)
+	if index <= 0 {
+		return ""
+	}
+	envAndPrefix := host[:index]
+	return envAndPrefix[strings.LastIndex(envAndPrefix, common.Sep)+1:]
+}
+
+//Returns the sorted addresses of the endpoints of the service entry
+func getEndpointAddresses(se *v1alpha3.ServiceEntry) []string {
+	addresses := make([]string, 0, len(se.Spec.Endpoints))
+	for _, endpoint := range se.Spec.Endpoints {
+		addresses = append(addresses, endpoint.Address)
+	}
+	sort.Strings(addresses)
+	return addresses
+}
+
+//Returns the sorted addresses of the endpoints of the service entry whose removal is guarded, all but the pod endpoints
+func getGuardedEndpointAddresses(se *v1alpha3.ServiceEntry) []string {
+	addresses := make([]string, 0, len(se.Spec.Endpoints))
+	for _, endpoint := range se.Spec.Endpoints {
+		if endpoint.Labels[common.FlatNetworkPodEndpointLabel] != "true" {
+			addresses = append(addresses, endpoint.Address)
+		}
+	}
+	sort.Strings(addresses)
+	return addresses
+}
+
+//Returns the endpoint addresses that are not in updated
+func getRemovedEndpoints(endpoints []string, updated []string) []string {
+	kept := make(map[string]bool, len(updated))
+	for _, address := range updated {
+		kept[address] = true
+	}
+	removed := make([]string, 0)
+	for _, address := range endpoints {
+		if !kept[address] {
+			removed = append(removed, address)
+		}
+	}
+	return removed
+}
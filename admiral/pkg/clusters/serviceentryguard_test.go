@@ -0,0 +1,307 @@
+package clusters
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/common"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/istio"
+	istionetworkingv1alpha3 "istio.io/api/networking/v1alpha3"
+	"istio.io/client-go/pkg/apis/networking/v1alpha3"
+	istiofake "istio.io/client-go/pkg/clientset/versioned/fake"
+	v12 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newGuardTestServiceEntry(addresses ...string) *v1alpha3.ServiceEntry {
+	se := &v1alpha3.ServiceEntry{
+		ObjectMeta: v12.ObjectMeta{Name: "stage.a.global-se", Namespace: "ns"},
+		Spec:       istionetworkingv1alpha3.ServiceEntry{Hosts: []string{"stage.a.global"}},
+	}
+	for _, address := range addresses {
+		se.Spec.Endpoints = append(se.Spec.Endpoints, &istionetworkingv1alpha3.ServiceEntry_Endpoint{Address: address, Ports: map[string]uint32{"http": 15443}})
+	}
+	return se
+}
+
+func TestServiceEntryGuard_Allow(t *testing.T) {
+	common.InitializeConfig(common.AdmiralParams{SyncNamespace: "ns"})
+	exist := newGuardTestServiceEntry("a.elb", "b.elb", "c.elb", "d.elb")
+
+	testCases := []struct {
+		name    string
+		obj     *v1alpha3.ServiceEntry
+		allowed bool
+		reason  string
+	}{
+		{
+			name:    "Given an update adding endpoints, should allow it",
+			obj:     newGuardTestServiceEntry("a.elb", "b.elb", "c.elb", "d.elb", "e.elb"),
+			allowed: true,
+		},
+		{
+			name:    "Given an update removing the threshold of the endpoints, should allow it",
+			obj:     newGuardTestServiceEntry("a.elb", "b.elb"),
+			allowed: true,
+		},
+		{
+			name:    "Given an update removing more than the threshold of the endpoints, should block it",
+			obj:     newGuardTestServiceEntry("a.elb"),
+			allowed: false,
+			reason:  "removes 3 of 4 endpoints, more than 50%",
+		},
+		{
+			name:    "Given an update removing all the endpoints, should block it",
+			obj:     newGuardTestServiceEntry(),
+			allowed: false,
+			reason:  "removes all the endpoints",
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			guard := NewServiceEntryGuard("cluster1", 50, time.Minute)
+			allowed, reason := guard.Allow(c.obj, exist)
+			if allowed != c.allowed || reason != c.reason {
+				t.Errorf("Expected %v %q, got %v %q", c.allowed, c.reason, allowed, reason)
+			}
+		})
+	}
+
+	var guard *ServiceEntryGuard
+	if allowed, _ := guard.Allow(newGuardTestServiceEntry(), exist); !allowed {
+		t.Errorf("Expected a nil guard to allow every update")
+	}
+}
+
+func TestServiceEntryGuard_AllowPodEndpoints(t *testing.T) {
+	common.InitializeConfig(common.AdmiralParams{SyncNamespace: "ns"})
+	withPods := func(se *v1alpha3.ServiceEntry, ips ...string) *v1alpha3.ServiceEntry {
+		for _, ip := range ips {
+			se.Spec.Endpoints = append(se.Spec.Endpoints, &istionetworkingv1alpha3.ServiceEntry_Endpoint{Address: ip, Ports: map[string]uint32{"http": 8080},
+				Labels: map[string]string{common.FlatNetworkPodEndpointLabel: "true"}})
+		}
+		return se
+	}
+
+	testCases := []struct {
+		name    string
+		exist   *v1alpha3.ServiceEntry
+		obj     *v1alpha3.ServiceEntry
+		allowed bool
+		reason  string
+	}{
+		{
+			name:    "Given a pod mode service entry scaling from 4 pods to 1, should allow it",
+			exist:   withPods(newGuardTestServiceEntry("b.elb"), "10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4"),
+			obj:     withPods(newGuardTestServiceEntry("b.elb"), "10.0.0.1"),
+			allowed: true,
+		},
+		{
+			name:    "Given a service entry with only pod endpoints scaling from 4 pods to 1, should allow it",
+			exist:   withPods(newGuardTestServiceEntry(), "10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4"),
+			obj:     withPods(newGuardTestServiceEntry(), "10.0.0.1"),
+			allowed: true,
+		},
+		{
+			name:    "Given a pod mode service entry losing the gateway of the other cluster, should block it",
+			exist:   withPods(newGuardTestServiceEntry("b.elb"), "10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4"),
+			obj:     withPods(newGuardTestServiceEntry(), "10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4"),
+			allowed: false,
+			reason:  "removes 1 of 1 endpoints, more than 50%",
+		},
+		{
+			name:    "Given a service entry with only pod endpoints losing all of them, should block it",
+			exist:   withPods(newGuardTestServiceEntry(), "10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4"),
+			obj:     newGuardTestServiceEntry(),
+			allowed: false,
+			reason:  "removes all the endpoints",
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			guard := NewServiceEntryGuard("cluster1", 50, time.Minute)
+			allowed, reason := guard.Allow(c.obj, c.exist)
+			if allowed != c.allowed || reason != c.reason {
+				t.Errorf("Expected %v %q, got %v %q", c.allowed, c.reason, allowed, reason)
+			}
+		})
+	}
+}
+
+func TestServiceEntryGuard_Confirmation(t *testing.T) {
+	common.InitializeConfig(common.AdmiralParams{SyncNamespace: "ns"})
+	guard := NewServiceEntryGuard("cluster1", 50, 50*time.Millisecond)
+	exist := newGuardTestServiceEntry("a.elb", "b.elb")
+	empty := newGuardTestServiceEntry()
+
+	if allowed, _ := guard.Allow(empty, exist); allowed {
+		t.Fatalf("Expected the update to be blocked")
+	}
+	blocked := guard.GetBlockedUpdates()
+	if len(blocked) != 1 || !reflect.DeepEqual(blocked[0].RemovedEndpoints, []string{"a.elb", "b.elb"}) {
+		t.Fatalf("Expected the blocked update to be listed, got %v", blocked)
+	}
+	if allowed, _ := guard.Allow(empty, exist); allowed {
+		t.Errorf("Expected the update not to be confirmed before the delay")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if allowed, _ := guard.Allow(newGuardTestServiceEntry("c.elb"), exist); allowed {
+		t.Errorf("Expected a different update to restart the confirmation")
+	}
+	if allowed, _ := guard.Allow(empty, exist); allowed {
+		t.Errorf("Expected the update to wait for its own confirmation")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if allowed, _ := guard.Allow(empty, exist); !allowed {
+		t.Errorf("Expected the update to be confirmed once observed again after the delay")
+	}
+	if blocked := guard.GetBlockedUpdates(); len(blocked) != 0 {
+		t.Errorf("Expected no blocked update once confirmed, got %v", blocked)
+	}
+
+	guard.Allow(empty, exist)
+	if allowed, _ := guard.Allow(newGuardTestServiceEntry("a.elb", "b.elb"), exist); !allowed {
+		t.Errorf("Expected an update keeping the endpoints to be allowed")
+	}
+	if blocked := guard.GetBlockedUpdates(); len(blocked) != 0 {
+		t.Errorf("Expected the blocked update to be cleared once the endpoints are back, got %v", blocked)
+	}
+}
+
+func TestAddUpdateServiceEntryWithGuard(t *testing.T) {
+	common.InitializeConfig(common.AdmiralParams{SyncNamespace: "ns"})
+	fakeIstioClient := istiofake.NewSimpleClientset()
+	serviceEntries := fakeIstioClient.NetworkingV1alpha3().ServiceEntries("ns")
+	serviceEntries.Create(newGuardTestServiceEntry("a.elb", "b.elb"))
+
+	rc := &RemoteController{
+		ClusterID:              "cluster1",
+		ServiceEntryController: &istio.ServiceEntryController{IstioClient: fakeIstioClient},
+		ServiceEntryGuard:      NewServiceEntryGuard("cluster1", 50, time.Hour),
+	}
+	rr := &RemoteRegistry{RemoteControllers: map[string]*RemoteController{"cluster1": rc}}
+
+	exist, _ := serviceEntries.Get("stage.a.global-se", v12.GetOptions{})
+	if err := addUpdateServiceEntry(newGuardTestServiceEntry("b.elb"), exist, "ns", rc); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	exist, _ = serviceEntries.Get("stage.a.global-se", v12.GetOptions{})
+	if len(exist.Spec.Endpoints) != 1 {
+		t.Errorf("Expected an update removing half of the endpoints to be applied, got %v", exist.Spec.Endpoints)
+	}
+
+	if err := addUpdateServiceEntry(newGuardTestServiceEntry(), exist, "ns", rc); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	exist, _ = serviceEntries.Get("stage.a.global-se", v12.GetOptions{})
+	if len(exist.Spec.Endpoints) != 1 {
+		t.Errorf("Expected an update removing all the endpoints to be blocked, got %v", exist.Spec.Endpoints)
+	}
+	blocked := GetBlockedServiceEntryUpdates(rr)
+	if len(blocked) != 1 || blocked[0].Cluster != "cluster1" || blocked[0].Name != "stage.a.global-se" {
+		t.Errorf("Expected the blocked update to be listed, got %v", blocked)
+	}
+
+	deleteServiceEntry(exist, "ns", rc)
+	if blocked := GetBlockedServiceEntryUpdates(rr); len(blocked) != 0 {
+		t.Errorf("Expected the blocked update to be dropped with the service entry, got %v", blocked)
+	}
+}
+
+func TestServiceEntryGuard_Requeue(t *testing.T) {
+	guard := NewServiceEntryGuard("cluster1", 50, 20*time.Millisecond)
+	requeued := make(chan identityKey, 2)
+	guard.Requeue = func(env string, identity string) {
+		requeued <- identityKey{env: env, identity: identity}
+	}
+	exist := newGuardTestServiceEntry("a.elb", "b.elb")
+	exist.Labels = map[string]string{common.GetWorkloadIdentifier(): "a"}
+	empty := newGuardTestServiceEntry()
+
+	if allowed, _ := guard.Allow(empty, exist); allowed {
+		t.Fatalf("Expected the update to be blocked")
+	}
+	select {
+	case key := <-requeued:
+		if key.env != "stage" || key.identity != "a" {
+			t.Errorf("Expected the identity of the service entry to be requeued, got %v", key)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the identity to be requeued once the confirmation delay has passed")
+	}
+	if allowed, _ := guard.Allow(empty, exist); !allowed {
+		t.Errorf("Expected the requeued update to be confirmed")
+	}
+
+	//an update cleared before the delay isn't requeued
+	guard.Allow(empty, exist)
+	guard.Allow(newGuardTestServiceEntry("a.elb", "b.elb"), exist)
+	select {
+	case key := <-requeued:
+		t.Errorf("Expected the cleared update not to be requeued, got %v", key)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestGetEnvForHost(t *testing.T) {
+	testCases := []struct {
+		host     string
+		identity string
+		env      string
+	}{
+		{host: "stage.greeting.global", identity: "greeting", env: "stage"},
+		{host: "west.stage.greeting.global", identity: "greeting", env: "stage"},
+		{host: "stage.Greeting.global", identity: "greeting", env: "stage"},
+		{host: "stage.payments.greeting.global", identity: "payments.greeting", env: "stage"},
+		{host: "greeting.global", identity: "greeting", env: ""},
+		{host: "stage.greeting.global", identity: "other", env: ""},
+		{host: "stage.greeting.global", identity: "", env: ""},
+	}
+	for _, c := range testCases {
+		if env := getEnvForHost(c.host, c.identity); env != c.env {
+			t.Errorf("Expected env %q for host %v and identity %v, got %q", c.env, c.host, c.identity, env)
+		}
+	}
+}
+
+func TestAddServiceEntriesWithDrToClusterWithGuard(t *testing.T) {
+	common.InitializeConfig(common.AdmiralParams{SyncNamespace: "ns"})
+	fakeIstioClient := istiofake.NewSimpleClientset()
+	serviceEntries := fakeIstioClient.NetworkingV1alpha3().ServiceEntries("ns")
+	serviceEntries.Create(newGuardTestServiceEntry("a.elb"))
+
+	rc := &RemoteController{
+		ClusterID:                 "cluster1",
+		ServiceEntryController:    &istio.ServiceEntryController{IstioClient: fakeIstioClient},
+		DestinationRuleController: &istio.DestinationRuleController{IstioClient: fakeIstioClient},
+		ServiceEntryGuard:         NewServiceEntryGuard("cluster1", 50, 50*time.Millisecond),
+	}
+	cache := &AdmiralCache{SeClusterCache: common.NewMapOfMaps()}
+	seDrs := []clusterSeDr{{identityId: "a", seDr: &SeDrTuple{
+		SeName:          "stage.a.global-se",
+		DrName:          "stage.a.global-default-dr",
+		ServiceEntry:    &istionetworkingv1alpha3.ServiceEntry{Hosts: []string{"stage.a.global"}},
+		DestinationRule: &istionetworkingv1alpha3.DestinationRule{Host: "stage.a.global"},
+	}}}
+
+	if err := addServiceEntriesWithDrToCluster(cache, rc, seDrs); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if _, err := serviceEntries.Get("stage.a.global-se", v12.GetOptions{}); err != nil {
+		t.Errorf("Expected the delete of the service entry without endpoints to be blocked")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if err := addServiceEntriesWithDrToCluster(cache, rc, seDrs); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if _, err := serviceEntries.Get("stage.a.global-se", v12.GetOptions{}); err == nil {
+		t.Errorf("Expected the service entry to be deleted once the delete is confirmed")
+	}
+	if blocked := rc.ServiceEntryGuard.GetBlockedUpdates(); len(blocked) != 0 {
+		t.Errorf("Expected no blocked update once the service entry is deleted, got %v", blocked)
+	}
+}
@@ -0,0 +1,324 @@
+package clusters
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/common"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/util"
+	log "github.com/sirupsen/logrus"
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/client-go/pkg/apis/networking/v1alpha3"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	v12 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+//Writes the sidecars owned by Admiral for the workloads of the identities in every cluster they run in, the egress hosts of a sidecar
+//are the dependencies of its workloads recorded in the dependency namespace cache
+func syncWorkloadSidecars(remoteRegistry *RemoteRegistry, identities map[string]string) {
+	if common.GetWorkloadSidecarUpdate() != common.WorkloadSidecarUpdateManaged || len(identities) == 0 {
+		return
+	}
+	cache := remoteRegistry.AdmiralCache
+	clusters := make(map[string]string)
+	for identity := range identities {
+		for clusterID := range cache.IdentityClusterCache.Get(identity).Copy() {
+			clusters[clusterID] = clusterID
+		}
+	}
+	for clusterID := range clusters {
+		rc := remoteRegistry.RemoteControllers[clusterID]
+		if rc == nil || rc.SidecarController == nil {
+			continue
+		}
+		for namespace, namespaceIdentities := range getIdentitiesByNamespace(rc, identities) {
+			if common.GetWorkloadSidecarScope() == common.WorkloadSidecarScopeWorkload {
+				for identity := range namespaceIdentities {
+					if _, ok := identities[identity]; ok {
+						hosts := getWorkloadSidecarHosts(cache.DependencyNamespaceCache, map[string]bool{identity: true})
+						selector := &networking.WorkloadSelector{Labels: map[string]string{common.GetWorkloadIdentifier(): identity}}
+						writeWorkloadSidecar(rc, namespace, getWorkloadSidecarName(identity), selector, hosts)
+					}
+				}
+				continue
+			}
+			for identity := range namespaceIdentities {
+				if _, ok := identities[identity]; ok {
+					hosts := getWorkloadSidecarHosts(cache.DependencyNamespaceCache, namespaceIdentities)
+					writeWorkloadSidecar(rc, namespace, common.GetWorkloadSidecarName(), nil, hosts)
+					break
+				}
+			}
+		}
+	}
+}
+
+//Removes the egress of the dependency in an env (every env if empty) from the identities, and writes the sidecars of the ones it was recorded for.
+//The sidecars left without workloads by the dependency are deleted
+func pruneWorkloadSidecars(remoteRegistry *RemoteRegistry, dependency string, env string, identities map[string]string) {
+	if common.GetWorkloadSidecarUpdate() != common.WorkloadSidecarUpdateManaged {
+		return
+	}
+	pruned := make(map[string]string)
+	for identity := range identities {
+		if remoteRegistry.AdmiralCache.DependencyNamespaceCache.DeleteDependency(identity, dependency, env) {
+			pruned[identity] = identity
+		}
+	}
+	syncWorkloadSidecars(remoteRegistry, pruned)
+	deleteOrphanedWorkloadSidecars(remoteRegistry, dependency)
+}
+
+//Removes the egress of the destinations the source doesn't depend on anymore, and writes the sidecars of the source. The sidecars are
+//only written once the caches are warm, so the egress is pruned then as well
+func pruneDependencySidecars(sourceIdentity string, destinations map[string]string, remoteRegistry *RemoteRegistry) {
+	if common.GetWorkloadSidecarUpdate() != common.WorkloadSidecarUpdateManaged || len(destinations) == 0 {
+		return
+	}
+	if IsCacheWarmupTime(remoteRegistry) {
+		log.Infof(LogFormat, "Update", "Sidecar", sourceIdentity, "", "Egress pruning deferred until the cache warm up is over")
+		runAfterCacheWarmup(remoteRegistry, func() { pruneDependencySidecars(sourceIdentity, destinations, remoteRegistry) })
+		return
+	}
+	cache := remoteRegistry.AdmiralCache
+	var pruned bool
+	for dIdentity := range destinations {
+		//the source may depend on the destination again by the time a deferred pruning runs
+		if sources := cache.IdentityDependencyCache.Get(dIdentity); sources != nil && len(sources.Copy()[sourceIdentity]) > 0 {
+			continue
+		}
+		if cache.DependencyNamespaceCache.DeleteDependency(sourceIdentity, dIdentity, "") {
+			pruned = true
+		}
+	}
+	if pruned {
+		syncWorkloadSidecars(remoteRegistry, map[string]string{sourceIdentity: sourceIdentity})
+	}
+}
+
+//Deletes the sidecars Admiral created for the identity in the namespaces its workloads are gone from, in the clusters whose caches are
+//complete. With the namespace scope the sidecar of a namespace is deleted once it has no workloads left, and written again otherwise.
+//The sidecars of the users only lose the hosts owned by Admiral. The sidecars are looked up in the cache of the sidecar controller
+func deleteOrphanedWorkloadSidecars(remoteRegistry *RemoteRegistry, identity string) {
+	namespaceScope := common.GetWorkloadSidecarScope() != common.WorkloadSidecarScopeWorkload
+	name := getWorkloadSidecarName(identity)
+	if namespaceScope {
+		name = common.GetWorkloadSidecarName()
+	}
+	for clusterID := range remoteRegistry.AdmiralCache.IdentityClusterCache.Get(identity).Copy() {
+		rc := remoteRegistry.RemoteControllers[clusterID]
+		if rc == nil || rc.SidecarController == nil || rc.SidecarController.Cache == nil || !rc.HasSynced() {
+			continue
+		}
+		for _, sidecar := range rc.SidecarController.Cache.GetByName(name) {
+			namespaceIdentities := getIdentitiesInNamespace(rc, sidecar.Namespace)
+			if namespaceIdentities[identity] {
+				continue
+			}
+			if namespaceScope && len(namespaceIdentities) > 0 {
+				writeWorkloadSidecar(rc, sidecar.Namespace, name, nil, getWorkloadSidecarHosts(remoteRegistry.AdmiralCache.DependencyNamespaceCache, namespaceIdentities))
+				continue
+			}
+			if sidecar.Annotations[common.CreatedByAnnotation] != common.Admiral {
+				stripWorkloadSidecarHosts(rc, sidecar.Namespace, name)
+				continue
+			}
+			err := rc.SidecarController.IstioClient.NetworkingV1alpha3().Sidecars(sidecar.Namespace).Delete(name, &v12.DeleteOptions{})
+			if err != nil && !k8sErrors.IsNotFound(err) {
+				log.Errorf(LogErrFormat, "Delete", "Sidecar", sidecar.Namespace+common.Slash+name, rc.ClusterID, err)
+			} else {
+				log.Infof(LogFormat, "Delete", "Sidecar", sidecar.Namespace+common.Slash+name, rc.ClusterID, "Success")
+			}
+		}
+	}
+}
+
+//Returns the identities of the workloads of the cluster in the namespaces the given identities run in, key=namespace
+func getIdentitiesByNamespace(rc *RemoteController, identities map[string]string) map[string]map[string]bool {
+	namespaces := make(map[string]bool)
+	for identity := range identities {
+		if rc.DeploymentController != nil {
+			if entry := rc.DeploymentController.Cache.Get(identity); entry != nil {
+				for _, deployment := range entry.Deployments {
+					namespaces[deployment.Namespace] = true
+				}
+			}
+		}
+		if rc.RolloutController != nil {
+			if entry := rc.RolloutController.Cache.Get(identity); entry != nil {
+				for _, rollout := range entry.Rollouts {
+					namespaces[rollout.Namespace] = true
+				}
+			}
+		}
+		if rc.StatefulSetController != nil {
+			if entry := rc.StatefulSetController.Cache.Get(identity); entry != nil {
+				for _, statefulSet := range entry.StatefulSets {
+					namespaces[statefulSet.Namespace] = true
+				}
+			}
+		}
+	}
+	identitiesByNamespace := make(map[string]map[string]bool, len(namespaces))
+	for namespace := range namespaces {
+		identitiesByNamespace[namespace] = getIdentitiesInNamespace(rc, namespace)
+	}
+	return identitiesByNamespace
+}
+
+//Returns the identities of the workloads of the namespace in the cluster
+func getIdentitiesInNamespace(rc *RemoteController, namespace string) map[string]bool {
+	var identities []string
+	if rc.DeploymentController != nil {
+		identities = append(identities, rc.DeploymentController.Cache.GetIdentitiesInNamespace(namespace)...)
+	}
+	if rc.RolloutController != nil {
+		identities = append(identities, rc.RolloutController.Cache.GetIdentitiesInNamespace(namespace)...)
+	}
+	if rc.StatefulSetController != nil {
+		identities = append(identities, rc.StatefulSetController.Cache.GetIdentitiesInNamespace(namespace)...)
+	}
+	namespaceIdentities := make(map[string]bool, len(identities))
+	for _, identity := range identities {
+		namespaceIdentities[identity] = true
+	}
+	return namespaceIdentities
+}
+
+//Returns the sorted egress hosts of the dependencies of the identities, the local fqdn of a dependency is scoped to its namespace and its
+//cnames to the sync namespace the service entries are written to
+func getWorkloadSidecarHosts(dependencyNamespaceCache *common.SidecarEgressMap, identities map[string]bool) []string {
+	egressHosts := make(map[string]bool)
+	for identity := range identities {
+		for _, sidecarEgress := range dependencyNamespaceCache.Get(identity) {
+			egressHosts[sidecarEgress.Namespace+common.Slash+sidecarEgress.FQDN] = true
+			for cname := range sidecarEgress.CNAMEs {
+				egressHosts[common.GetSyncNamespace()+common.Slash+cname] = true
+			}
+		}
+	}
+	hosts := make([]string, 0, len(egressHosts))
+	for host := range egressHosts {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+func getWorkloadSidecarName(identity string) string {
+	return strings.ToLower(identity + common.Dash + common.GetWorkloadSidecarName())
+}
+
+//Creates or updates the sidecar. Admiral only owns the hosts it added to the egress listener without a port, recorded in an annotation,
+//the hosts added by the users and the other egress listeners are kept as is
+func writeWorkloadSidecar(rc *RemoteController, namespace string, name string, selector *networking.WorkloadSelector, hosts []string) {
+	sidecars := rc.SidecarController.IstioClient.NetworkingV1alpha3().Sidecars(namespace)
+	desiredHosts := make([]string, 0, len(common.GetWorkloadSidecarEgressHosts())+len(hosts))
+	for _, host := range append(append([]string{}, common.GetWorkloadSidecarEgressHosts()...), hosts...) {
+		if !util.Contains(desiredHosts, host) {
+			desiredHosts = append(desiredHosts, host)
+		}
+	}
+
+	sidecar, err := sidecars.Get(name, v12.GetOptions{})
+	if k8sErrors.IsNotFound(err) {
+		newSidecar := &v1alpha3.Sidecar{
+			ObjectMeta: v12.ObjectMeta{Name: name, Namespace: namespace, Annotations: map[string]string{
+				common.CreatedByAnnotation:          common.Admiral,
+				common.SidecarEgressHostsAnnotation: strings.Join(desiredHosts, ","),
+			}},
+			Spec: networking.Sidecar{WorkloadSelector: selector, Egress: []*networking.IstioEgressListener{{Hosts: desiredHosts}}},
+		}
+		_, err = sidecars.Create(newSidecar)
+		if err != nil {
+			log.Errorf(LogErrFormat, "Create", "Sidecar", namespace+common.Slash+name, rc.ClusterID, err)
+		} else {
+			log.Infof(LogFormat, "Create", "Sidecar", namespace+common.Slash+name, rc.ClusterID, "Success")
+		}
+		return
+	}
+	if err != nil || sidecar == nil {
+		log.Errorf(LogErrFormat, "Get", "Sidecar", namespace+common.Slash+name, rc.ClusterID, err)
+		return
+	}
+	updateWorkloadSidecarHosts(rc, sidecar, desiredHosts)
+}
+
+//Removes the hosts owned by Admiral from the sidecar of the users, the default egress hosts aren't added back. The hosts of the users
+//are kept as is, and the egress listener left without hosts is removed
+func stripWorkloadSidecarHosts(rc *RemoteController, namespace string, name string) {
+	sidecar, err := rc.SidecarController.IstioClient.NetworkingV1alpha3().Sidecars(namespace).Get(name, v12.GetOptions{})
+	if k8sErrors.IsNotFound(err) {
+		return
+	}
+	if err != nil || sidecar == nil {
+		log.Errorf(LogErrFormat, "Get", "Sidecar", namespace+common.Slash+name, rc.ClusterID, err)
+		return
+	}
+	updateWorkloadSidecarHosts(rc, sidecar, nil)
+}
+
+//Updates the hosts owned by Admiral in the egress listener without a port of the existing sidecar to the desired hosts
+func updateWorkloadSidecarHosts(rc *RemoteController, sidecar *v1alpha3.Sidecar, desiredHosts []string) {
+	namespace, name := sidecar.Namespace, sidecar.Name
+	var managedHosts []string
+	if annotation := sidecar.Annotations[common.SidecarEgressHostsAnnotation]; len(annotation) > 0 {
+		managedHosts = strings.Split(annotation, ",")
+	}
+	egress := make([]*networking.IstioEgressListener, 0, len(sidecar.Spec.Egress)+1)
+	listenerIndex := -1
+	for _, listener := range sidecar.Spec.Egress {
+		if listenerIndex < 0 && listener.Port == nil {
+			listenerIndex = len(egress)
+		}
+		egress = append(egress, listener)
+	}
+	listener := &networking.IstioEgressListener{}
+	if listenerIndex < 0 && len(desiredHosts) == 0 {
+		return
+	} else if listenerIndex < 0 {
+		listenerIndex = len(egress)
+		egress = append(egress, listener)
+	} else {
+		old := egress[listenerIndex]
+		listener = &networking.IstioEgressListener{Port: old.Port, Bind: old.Bind, CaptureMode: old.CaptureMode, Hosts: old.Hosts}
+	}
+
+	//the hosts of the users are kept first, the hosts they added themselves aren't recorded as owned by Admiral
+	newHosts := make([]string, 0, len(listener.Hosts)+len(desiredHosts))
+	for _, host := range listener.Hosts {
+		if !util.Contains(managedHosts, host) {
+			newHosts = append(newHosts, host)
+		}
+	}
+	newManagedHosts := make([]string, 0, len(desiredHosts))
+	for _, host := range desiredHosts {
+		if !util.Contains(newHosts, host) {
+			newManagedHosts = append(newManagedHosts, host)
+		}
+	}
+	newHosts = append(newHosts, newManagedHosts...)
+	annotation := strings.Join(newManagedHosts, ",")
+	if reflect.DeepEqual(newHosts, listener.Hosts) && annotation == sidecar.Annotations[common.SidecarEgressHostsAnnotation] {
+		return
+	}
+	listener.Hosts = newHosts
+	egress[listenerIndex] = listener
+	if len(newHosts) == 0 {
+		egress = append(egress[:listenerIndex], egress[listenerIndex+1:]...)
+	}
+
+	sidecar = sidecar.DeepCopy()
+	if sidecar.Annotations == nil {
+		sidecar.Annotations = make(map[string]string)
+	}
+	sidecar.Annotations[common.SidecarEgressHostsAnnotation] = annotation
+	sidecar.Spec.Egress = egress
+	_, err := rc.SidecarController.IstioClient.NetworkingV1alpha3().Sidecars(namespace).Update(sidecar)
+	if err != nil {
+		log.Errorf(LogErrFormat, "Update", "Sidecar", namespace+common.Slash+name, rc.ClusterID, err)
+	} else {
+		log.Infof(LogFormat, "Update", "Sidecar", namespace+common.Slash+name, rc.ClusterID, "Success")
+	}
+}
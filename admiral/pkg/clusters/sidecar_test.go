@@ -0,0 +1,231 @@
+package clusters
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/admiral"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/common"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/istio"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/util"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/test"
+	istionetworkingv1alpha3 "istio.io/api/networking/v1alpha3"
+	"istio.io/client-go/pkg/apis/networking/v1alpha3"
+	istiofake "istio.io/client-go/pkg/clientset/versioned/fake"
+	k8sAppsV1 "k8s.io/api/apps/v1"
+	v12 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+)
+
+func TestGetWorkloadSidecarHosts(t *testing.T) {
+	dependencyNamespaceCache := common.NewSidecarEgressMap()
+	dependencyNamespaceCache.PutDependency("orders", "payments", "prod", "payments-ns", "payments.payments-ns.svc.cluster.local", map[string]string{"prod.payments.global": "1"})
+	dependencyNamespaceCache.PutDependency("orders", "users", "prod", "users-ns", "users.users-ns.svc.cluster.local", map[string]string{"prod.users.global": "1"})
+	dependencyNamespaceCache.PutDependency("carts", "payments", "prod", "payments-ns", "payments.payments-ns.svc.cluster.local", map[string]string{"prod.payments.global": "1"})
+
+	syncNamespace := common.GetSyncNamespace()
+	hosts := getWorkloadSidecarHosts(dependencyNamespaceCache, map[string]bool{"orders": true, "carts": true, "none": true})
+	expected := []string{
+		"payments-ns/payments.payments-ns.svc.cluster.local",
+		syncNamespace + "/prod.payments.global",
+		syncNamespace + "/prod.users.global",
+		"users-ns/users.users-ns.svc.cluster.local",
+	}
+	sort.Strings(expected)
+	if !reflect.DeepEqual(hosts, expected) {
+		t.Errorf("Expected the hosts of every dependency once, got %v", hosts)
+	}
+
+	dependencyNamespaceCache.DeleteDependency("orders", "users", "")
+	hosts = getWorkloadSidecarHosts(dependencyNamespaceCache, map[string]bool{"orders": true})
+	expected = []string{"payments-ns/payments.payments-ns.svc.cluster.local", syncNamespace + "/prod.payments.global"}
+	sort.Strings(expected)
+	if !reflect.DeepEqual(hosts, expected) {
+		t.Errorf("Expected the hosts of the removed dependency to be pruned, got %v", hosts)
+	}
+}
+
+func TestWriteWorkloadSidecar(t *testing.T) {
+	rc := &RemoteController{ClusterID: "cluster1", SidecarController: &istio.SidecarController{IstioClient: istiofake.NewSimpleClientset()}}
+	sidecars := rc.SidecarController.IstioClient.NetworkingV1alpha3().Sidecars("ns1")
+	selector := &istionetworkingv1alpha3.WorkloadSelector{Labels: map[string]string{"identity": "orders"}}
+
+	writeWorkloadSidecar(rc, "ns1", "orders-default", selector, []string{"ns2/payments.ns2.svc.cluster.local", "ns2/payments.ns2.svc.cluster.local"})
+	sidecar, err := sidecars.Get("orders-default", v12.GetOptions{})
+	if err != nil {
+		t.Fatalf("Expected the sidecar to be created, got %v", err)
+	}
+	if sidecar.Annotations[common.CreatedByAnnotation] != common.Admiral || !reflect.DeepEqual(sidecar.Spec.WorkloadSelector, selector) {
+		t.Errorf("Expected the sidecar to be owned by admiral and select the workload, got %v", sidecar)
+	}
+	if len(sidecar.Spec.Egress) != 1 || !reflect.DeepEqual(sidecar.Spec.Egress[0].Hosts, []string{"./*", "istio-system/*", "ns2/payments.ns2.svc.cluster.local"}) {
+		t.Errorf("Expected a single egress listener with the default and dependency hosts, got %v", sidecar.Spec.Egress)
+	}
+
+	//the users add their own hosts and listener
+	sidecar.Spec.Egress[0].Hosts = append(sidecar.Spec.Egress[0].Hosts, "ns3/*")
+	sidecar.Spec.Egress = append(sidecar.Spec.Egress, &istionetworkingv1alpha3.IstioEgressListener{Port: &istionetworkingv1alpha3.Port{Number: 9080, Protocol: "HTTP", Name: "http"}, Hosts: []string{"ns4/*"}})
+	if _, err = sidecars.Update(sidecar); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	writeWorkloadSidecar(rc, "ns1", "orders-default", selector, []string{"ns5/users.ns5.svc.cluster.local"})
+	sidecar, _ = sidecars.Get("orders-default", v12.GetOptions{})
+	if len(sidecar.Spec.Egress) != 2 || !reflect.DeepEqual(sidecar.Spec.Egress[0].Hosts, []string{"ns3/*", "./*", "istio-system/*", "ns5/users.ns5.svc.cluster.local"}) ||
+		!reflect.DeepEqual(sidecar.Spec.Egress[1].Hosts, []string{"ns4/*"}) {
+		t.Errorf("Expected the removed dependency to be replaced and the hosts of the users to be kept, got %v", sidecar.Spec.Egress)
+	}
+	if sidecar.Annotations[common.SidecarEgressHostsAnnotation] != "./*,istio-system/*,ns5/users.ns5.svc.cluster.local" {
+		t.Errorf("Expected the hosts owned by admiral to be recorded, got %v", sidecar.Annotations)
+	}
+}
+
+func TestWriteWorkloadSidecarToExistingSidecar(t *testing.T) {
+	istioClient := istiofake.NewSimpleClientset()
+	rc := &RemoteController{ClusterID: "cluster1", SidecarController: &istio.SidecarController{IstioClient: istioClient}}
+	sidecars := istioClient.NetworkingV1alpha3().Sidecars("ns1")
+	existing := &v1alpha3.Sidecar{
+		ObjectMeta: v12.ObjectMeta{Name: "default", Namespace: "ns1"},
+		Spec: istionetworkingv1alpha3.Sidecar{Egress: []*istionetworkingv1alpha3.IstioEgressListener{
+			{Port: &istionetworkingv1alpha3.Port{Number: 9080, Protocol: "HTTP", Name: "http"}, Hosts: []string{"ns4/*"}},
+		}},
+	}
+	if _, err := sidecars.Create(existing); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	writeWorkloadSidecar(rc, "ns1", "default", nil, []string{"ns2/payments.ns2.svc.cluster.local"})
+	sidecar, _ := sidecars.Get("default", v12.GetOptions{})
+	if len(sidecar.Spec.Egress) != 2 || !reflect.DeepEqual(sidecar.Spec.Egress[0], existing.Spec.Egress[0]) ||
+		!reflect.DeepEqual(sidecar.Spec.Egress[1].Hosts, []string{"./*", "istio-system/*", "ns2/payments.ns2.svc.cluster.local"}) {
+		t.Errorf("Expected an egress listener to be added next to the one of the users, got %v", sidecar.Spec.Egress)
+	}
+	if sidecar.Annotations[common.CreatedByAnnotation] == common.Admiral {
+		t.Errorf("Expected the sidecar of the users not to be marked as created by admiral")
+	}
+	actions := len(istioClient.Actions())
+
+	writeWorkloadSidecar(rc, "ns1", "default", nil, []string{"ns2/payments.ns2.svc.cluster.local"})
+	for _, action := range istioClient.Actions()[actions:] {
+		if action.GetVerb() != "get" {
+			t.Errorf("Expected the sidecar not to be updated when its hosts didn't change, got %v", action)
+		}
+	}
+}
+
+func TestDeleteOrphanedWorkloadSidecars(t *testing.T) {
+	config := rest.Config{Host: "localhost"}
+	deploymentController, err := admiral.NewDeploymentController("cluster1", make(chan struct{}), &test.MockDeploymentHandler{}, &config, time.Minute)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	sidecarController, err := istio.NewSidecarController("cluster1", make(chan struct{}), &test.MockSidecarHandler{}, &config, time.Minute)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	deploymentController.Cache.UpdateDeploymentToClusterCache("orders", &k8sAppsV1.Deployment{ObjectMeta: v12.ObjectMeta{Name: "orders", Namespace: "ns4"}})
+	deploymentController.Cache.UpdateDeploymentToClusterCache("carts", &k8sAppsV1.Deployment{ObjectMeta: v12.ObjectMeta{Name: "carts", Namespace: "ns2"}})
+	istioClient := istiofake.NewSimpleClientset()
+	sidecarController.IstioClient = istioClient
+	rc := &RemoteController{ClusterID: "cluster1", DeploymentController: deploymentController, SidecarController: sidecarController}
+	rr := &RemoteRegistry{
+		RemoteControllers: map[string]*RemoteController{"cluster1": rc},
+		AdmiralCache:      &AdmiralCache{IdentityClusterCache: common.NewMapOfMaps(), DependencyNamespaceCache: common.NewSidecarEgressMap()},
+	}
+	rr.AdmiralCache.IdentityClusterCache.Put("orders", "cluster1", "cluster1")
+	rr.AdmiralCache.DependencyNamespaceCache.PutDependency("carts", "payments", "prod", "payments-ns", "payments.payments-ns.svc.cluster.local", nil)
+
+	name := common.GetWorkloadSidecarName()
+	//the orders workloads are gone from ns1 to ns3, the sidecar of ns3 was created by the users before admiral added its hosts
+	userEgress := []*istionetworkingv1alpha3.IstioEgressListener{
+		{Hosts: []string{"ns5/*", "istio-system/*"}},
+		{Port: &istionetworkingv1alpha3.Port{Number: 5432, Protocol: "TCP", Name: "db"}, Hosts: []string{"db/*"}},
+	}
+	istioClient.NetworkingV1alpha3().Sidecars("ns3").Create(&v1alpha3.Sidecar{
+		ObjectMeta: v12.ObjectMeta{Name: name, Namespace: "ns3"},
+		Spec:       istionetworkingv1alpha3.Sidecar{Egress: userEgress},
+	})
+	for _, namespace := range []string{"ns1", "ns2", "ns3", "ns4"} {
+		writeWorkloadSidecar(rc, namespace, name, nil, []string{"orders-ns/orders.orders-ns.svc.cluster.local"})
+	}
+	//the sidecars are looked up in the cache of the controller
+	sidecars, _ := istioClient.NetworkingV1alpha3().Sidecars(v12.NamespaceAll).List(v12.ListOptions{})
+	for i := range sidecars.Items {
+		sidecarController.Added(&sidecars.Items[i])
+	}
+	sidecar, _ := istioClient.NetworkingV1alpha3().Sidecars("ns3").Get(name, v12.GetOptions{})
+	if !util.Contains(sidecar.Spec.Egress[0].Hosts, "orders-ns/orders.orders-ns.svc.cluster.local") {
+		t.Fatalf("Expected admiral to add its hosts to the sidecar of the users, got %v", sidecar.Spec.Egress)
+	}
+
+	deleteOrphanedWorkloadSidecars(rr, "orders")
+
+	if _, err := istioClient.NetworkingV1alpha3().Sidecars("ns1").Get(name, v12.GetOptions{}); err == nil {
+		t.Errorf("Expected the sidecar created by admiral to be deleted from the namespace without workloads")
+	}
+	sidecar, err = istioClient.NetworkingV1alpha3().Sidecars("ns2").Get(name, v12.GetOptions{})
+	if err != nil || util.Contains(sidecar.Spec.Egress[0].Hosts, "orders-ns/orders.orders-ns.svc.cluster.local") ||
+		!util.Contains(sidecar.Spec.Egress[0].Hosts, "payments-ns/payments.payments-ns.svc.cluster.local") {
+		t.Errorf("Expected the sidecar of the namespace with other workloads to be written again, got %v %v", sidecar, err)
+	}
+	//the sidecar of the users gets back the egress they wrote, without the default hosts of admiral
+	sidecar, err = istioClient.NetworkingV1alpha3().Sidecars("ns3").Get(name, v12.GetOptions{})
+	if err != nil || !reflect.DeepEqual(sidecar.Spec.Egress, userEgress) || sidecar.Annotations[common.SidecarEgressHostsAnnotation] != "" {
+		t.Errorf("Expected the sidecar of the users to only lose the hosts owned by admiral, got %v %v", sidecar, err)
+	}
+	sidecar, err = istioClient.NetworkingV1alpha3().Sidecars("ns4").Get(name, v12.GetOptions{})
+	if err != nil || !util.Contains(sidecar.Spec.Egress[0].Hosts, "orders-ns/orders.orders-ns.svc.cluster.local") {
+		t.Errorf("Expected the sidecar of the namespace the identity still runs in to be kept, got %v %v", sidecar, err)
+	}
+}
+
+func TestStripWorkloadSidecarHosts(t *testing.T) {
+	istioClient := istiofake.NewSimpleClientset()
+	rc := &RemoteController{ClusterID: "cluster1", SidecarController: &istio.SidecarController{IstioClient: istioClient}}
+	sidecars := istioClient.NetworkingV1alpha3().Sidecars("ns1")
+	//every host of the listener without a port is owned by admiral
+	sidecars.Create(&v1alpha3.Sidecar{
+		ObjectMeta: v12.ObjectMeta{Name: "default", Namespace: "ns1", Annotations: map[string]string{common.SidecarEgressHostsAnnotation: "./*,ns2/payments.ns2.svc.cluster.local"}},
+		Spec: istionetworkingv1alpha3.Sidecar{Egress: []*istionetworkingv1alpha3.IstioEgressListener{
+			{Port: &istionetworkingv1alpha3.Port{Number: 5432, Protocol: "TCP", Name: "db"}, Hosts: []string{"db/*"}},
+			{Hosts: []string{"./*", "ns2/payments.ns2.svc.cluster.local"}},
+		}},
+	})
+
+	stripWorkloadSidecarHosts(rc, "ns1", "default")
+
+	sidecar, _ := sidecars.Get("default", v12.GetOptions{})
+	expected := []*istionetworkingv1alpha3.IstioEgressListener{{Port: &istionetworkingv1alpha3.Port{Number: 5432, Protocol: "TCP", Name: "db"}, Hosts: []string{"db/*"}}}
+	if !reflect.DeepEqual(sidecar.Spec.Egress, expected) {
+		t.Errorf("Expected the listener left without hosts to be removed, got %v", sidecar.Spec.Egress)
+	}
+	actions := len(istioClient.Actions())
+
+	stripWorkloadSidecarHosts(rc, "ns1", "default")
+	stripWorkloadSidecarHosts(rc, "ns1", "missing")
+	for _, action := range istioClient.Actions()[actions:] {
+		if action.GetVerb() != "get" {
+			t.Errorf("Expected nothing to be written without hosts owned by admiral, got %v", action)
+		}
+	}
+}
+
+func TestGetIdentitiesByNamespace(t *testing.T) {
+	config := rest.Config{Host: "localhost"}
+	deploymentController, err := admiral.NewDeploymentController("cluster1", make(chan struct{}), &test.MockDeploymentHandler{}, &config, time.Minute)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	deploymentController.Cache.UpdateDeploymentToClusterCache("orders", &k8sAppsV1.Deployment{ObjectMeta: v12.ObjectMeta{Name: "orders", Namespace: "ns1"}})
+	deploymentController.Cache.UpdateDeploymentToClusterCache("carts", &k8sAppsV1.Deployment{ObjectMeta: v12.ObjectMeta{Name: "carts", Namespace: "ns1"}})
+	deploymentController.Cache.UpdateDeploymentToClusterCache("users", &k8sAppsV1.Deployment{ObjectMeta: v12.ObjectMeta{Name: "users", Namespace: "ns2"}})
+	rc := &RemoteController{ClusterID: "cluster1", DeploymentController: deploymentController}
+
+	identitiesByNamespace := getIdentitiesByNamespace(rc, map[string]string{"orders": "orders", "none": "none"})
+	expected := map[string]map[string]bool{"ns1": {"orders": true, "carts": true}}
+	if !reflect.DeepEqual(identitiesByNamespace, expected) {
+		t.Errorf("Expected the identities of the namespaces of the given identities only, got %v", identitiesByNamespace)
+	}
+}
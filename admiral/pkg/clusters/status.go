@@ -0,0 +1,185 @@
+package clusters
+
+import (
+	"fmt"
+	"reflect"
+
+	v1 "github.com/istio-ecosystem/admiral/admiral/pkg/apis/admiral/v1"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/common"
+	log "github.com/sirupsen/logrus"
+	k8sV1 "k8s.io/api/core/v1"
+	v12 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const GtpConflictEventReason = "GlobalTrafficPolicyConflict"
+
+//Writes the outcome of the latest sync back to the status of every GTP found for an identity
+//The GTP picked by updateGlobalGtpCache is marked active, the rest are marked as shadowed by it
+func updateGlobalTrafficPolicyStatus(remoteRegistry *RemoteRegistry, gtps map[string][]*v1.GlobalTrafficPolicy, activeGtp *v1.GlobalTrafficPolicy,
+	syncedClusters map[string]string, clusterErrors map[string]error) {
+	//the status would only reflect writes that didn't happen
+	if activeGtp == nil || remoteRegistry.ChangeRecorder != nil {
+		return
+	}
+	var activeGtpName string
+	for clusterId, gtpsInCluster := range gtps {
+		for _, gtp := range gtpsInCluster {
+			if gtp == activeGtp {
+				activeGtpName = getGtpStatusName(clusterId, gtp)
+			}
+		}
+	}
+	now := v12.Now()
+	for clusterId, gtpsInCluster := range gtps {
+		rc := remoteRegistry.RemoteControllers[clusterId]
+		if rc == nil || rc.GlobalTraffic == nil || rc.GlobalTraffic.CrdClient == nil {
+			log.Warnf(LogFormat, "UpdateStatus", "GlobalTrafficPolicy", "", clusterId, "remote controller not initialized for cluster")
+			continue
+		}
+		for _, gtp := range gtpsInCluster {
+			updatedGtp := gtp.DeepCopy()
+			updatedGtp.Status = getGlobalTrafficPolicyStatus(gtp == activeGtp, activeGtpName, now, syncedClusters, clusterErrors)
+			//report a conflict only once, when the gtp gets shadowed
+			if updatedGtp.Status.State == v1.GtpStateShadowed &&
+				(gtp.Status.State != v1.GtpStateShadowed || gtp.Status.ShadowedBy != activeGtpName) {
+				reportGtpConflict(rc, gtp, activeGtpName)
+			}
+			if isGtpStatusUnchanged(gtp.Status, updatedGtp.Status) {
+				continue
+			}
+			_, err := rc.GlobalTraffic.CrdClient.AdmiralV1().GlobalTrafficPolicies(gtp.Namespace).UpdateStatus(updatedGtp)
+			if err != nil {
+				log.Errorf(LogErrFormat, "UpdateStatus", "GlobalTrafficPolicy", gtp.Name, clusterId, err)
+			} else {
+				log.Debugf(LogFormat, "UpdateStatus", "GlobalTrafficPolicy", gtp.Name, clusterId, "Success")
+			}
+		}
+	}
+}
+
+func getGlobalTrafficPolicyStatus(active bool, activeGtpName string, syncTime v12.Time, syncedClusters map[string]string, clusterErrors map[string]error) v1.GlobalTrafficPolicyStatus {
+	status := v1.GlobalTrafficPolicyStatus{
+		LastSyncTime: syncTime,
+		State:        v1.GtpStateActive,
+	}
+	if !active {
+		status.State = v1.GtpStateShadowed
+		status.ShadowedBy = activeGtpName
+	}
+	for cluster := range syncedClusters {
+		if err, ok := clusterErrors[cluster]; ok && err != nil {
+			if status.ClusterErrors == nil {
+				status.ClusterErrors = make(map[string]string)
+			}
+			status.ClusterErrors[cluster] = err.Error()
+		} else {
+			status.ClusterSynced++
+		}
+	}
+	return status
+}
+
+func reportGtpConflict(rc *RemoteController, gtp *v1.GlobalTrafficPolicy, activeGtpName string) {
+	identity := gtp.Labels[common.GetGlobalTrafficDeploymentLabel()]
+	env := common.GetGtpEnv(gtp)
+	common.GtpConflicts.With(rc.ClusterID, identity, env).Inc()
+	if rc.GlobalTraffic.K8sClient == nil {
+		return
+	}
+	now := v12.Now()
+	event := &k8sV1.Event{
+		ObjectMeta: v12.ObjectMeta{
+			GenerateName: gtp.Name + "-",
+			Namespace:    gtp.Namespace,
+		},
+		InvolvedObject: k8sV1.ObjectReference{
+			Kind:            "GlobalTrafficPolicy",
+			APIVersion:      v1.SchemeGroupVersion.String(),
+			Name:            gtp.Name,
+			Namespace:       gtp.Namespace,
+			UID:             gtp.UID,
+			ResourceVersion: gtp.ResourceVersion,
+		},
+		Reason:         GtpConflictEventReason,
+		Message:        fmt.Sprintf("Shadowed by %s for identity=%s env=%s, see spec.priority to change the policy applied", activeGtpName, identity, env),
+		Type:           k8sV1.EventTypeWarning,
+		Source:         k8sV1.EventSource{Component: "admiral"},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+	_, err := rc.GlobalTraffic.K8sClient.CoreV1().Events(gtp.Namespace).Create(event)
+	if err != nil {
+		log.Errorf(LogErrFormat, "Create", "Event", gtp.Name, rc.ClusterID, err)
+	}
+}
+
+func getGtpStatusName(clusterId string, gtp *v1.GlobalTrafficPolicy) string {
+	return fmt.Sprintf("%s/%s/%s", clusterId, gtp.Namespace, gtp.Name)
+}
+
+//Writes the outcome of the latest sync back to the status of the dependency records of the dependents
+//A dependency record gets config from several destinations, so errors are tracked per cluster of the source identity
+//and an error is only cleared once a later sync to that cluster succeeds
+func updateDependencyStatus(remoteRegistry *RemoteRegistry, dependents map[string]string, syncedClusters map[string]string, clusterErrors map[string]error) {
+	if remoteRegistry.DependencyController == nil || remoteRegistry.DependencyController.DepCrdClient == nil || remoteRegistry.ChangeRecorder != nil {
+		return
+	}
+	now := v12.Now()
+	for dependent := range dependents {
+		dependentClusters := remoteRegistry.AdmiralCache.IdentityClusterCache.Get(dependent)
+		if dependentClusters == nil {
+			continue
+		}
+		for _, dep := range remoteRegistry.DependencyController.Cache.GetBySource(dependent) {
+			status := getDependencyStatus(dep.Status, dependentClusters.Copy(), now, syncedClusters, clusterErrors)
+			if isDependencyStatusUnchanged(dep.Status, status) {
+				continue
+			}
+			updatedDep := dep.DeepCopy()
+			updatedDep.Status = status
+			_, err := remoteRegistry.DependencyController.DepCrdClient.AdmiralV1().Dependencies(dep.Namespace).UpdateStatus(updatedDep)
+			if err != nil {
+				log.Errorf(LogErrFormat, "UpdateStatus", "Dependency", dep.Name, "", err)
+			} else {
+				log.Debugf(LogFormat, "UpdateStatus", "Dependency", dep.Name, "", "Success")
+			}
+		}
+	}
+}
+
+//The sync time alone doesn't warrant a write, it would trigger an update event of the gtp for nothing
+func isGtpStatusUnchanged(oldStatus v1.GlobalTrafficPolicyStatus, newStatus v1.GlobalTrafficPolicyStatus) bool {
+	newStatus.LastSyncTime = oldStatus.LastSyncTime
+	return reflect.DeepEqual(oldStatus, newStatus)
+}
+
+func isDependencyStatusUnchanged(oldStatus v1.DependencyStatus, newStatus v1.DependencyStatus) bool {
+	newStatus.LastSyncTime = oldStatus.LastSyncTime
+	return reflect.DeepEqual(oldStatus, newStatus)
+}
+
+func getDependencyStatus(oldStatus v1.DependencyStatus, sourceClusters map[string]string, syncTime v12.Time,
+	syncedClusters map[string]string, clusterErrors map[string]error) v1.DependencyStatus {
+	status := v1.DependencyStatus{
+		LastSyncTime: syncTime,
+		State:        v1.DependencyStateSynced,
+	}
+	errorsByCluster := make(map[string]string)
+	for cluster := range sourceClusters {
+		if _, ok := syncedClusters[cluster]; !ok {
+			//not part of this sync, retain the previous outcome
+			if oldErr, ok := oldStatus.ClusterErrors[cluster]; ok {
+				errorsByCluster[cluster] = oldErr
+			}
+		} else if err, ok := clusterErrors[cluster]; ok && err != nil {
+			errorsByCluster[cluster] = err.Error()
+		}
+	}
+	if len(errorsByCluster) > 0 {
+		status.State = v1.DependencyStateError
+		status.ClusterErrors = errorsByCluster
+	}
+	status.ClusterSynced = int32(len(sourceClusters) - len(errorsByCluster))
+	return status
+}
@@ -0,0 +1,221 @@
+package clusters
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/istio-ecosystem/admiral/admiral/pkg/apis/admiral/model"
+	v1 "github.com/istio-ecosystem/admiral/admiral/pkg/apis/admiral/v1"
+	admiralFake "github.com/istio-ecosystem/admiral/admiral/pkg/client/clientset/versioned/fake"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/admiral"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/common"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/test"
+	v12 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sFake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestUpdateGlobalTrafficPolicyStatus(t *testing.T) {
+
+	var (
+		gtp1 = &v1.GlobalTrafficPolicy{ObjectMeta: v12.ObjectMeta{Name: "gtp1", Namespace: "namespace1", CreationTimestamp: v12.NewTime(time.Now())},
+			Spec: model.GlobalTrafficPolicy{Policy: []*model.TrafficPolicy{{DnsPrefix: "hello"}}}}
+		gtp2 = &v1.GlobalTrafficPolicy{ObjectMeta: v12.ObjectMeta{Name: "gtp2", Namespace: "namespace1", CreationTimestamp: v12.NewTime(time.Now().Add(-time.Hour))},
+			Spec: model.GlobalTrafficPolicy{Policy: []*model.TrafficPolicy{{DnsPrefix: "hello2"}}}}
+		gtp3 = &v1.GlobalTrafficPolicy{ObjectMeta: v12.ObjectMeta{Name: "gtp1", Namespace: "namespace1", CreationTimestamp: v12.NewTime(time.Now().Add(-time.Minute))},
+			Spec: model.GlobalTrafficPolicy{Policy: []*model.TrafficPolicy{{DnsPrefix: "hello"}}}}
+		crdClient1 = admiralFake.NewSimpleClientset()
+		crdClient2 = admiralFake.NewSimpleClientset()
+		k8sClient1 = k8sFake.NewSimpleClientset()
+		k8sClient2 = k8sFake.NewSimpleClientset()
+		registry   = &RemoteRegistry{
+			RemoteControllers: map[string]*RemoteController{
+				"cluster-1": {ClusterID: "cluster-1", GlobalTraffic: &admiral.GlobalTrafficController{CrdClient: crdClient1, K8sClient: k8sClient1}},
+				"cluster-2": {ClusterID: "cluster-2", GlobalTraffic: &admiral.GlobalTrafficController{CrdClient: crdClient2, K8sClient: k8sClient2}},
+			},
+		}
+		gtps = map[string][]*v1.GlobalTrafficPolicy{
+			"cluster-1": {gtp1, gtp2},
+			"cluster-2": {gtp3},
+		}
+		syncedClusters = map[string]string{"cluster-1": "cluster-1", "cluster-2": "cluster-2", "cluster-3": "cluster-3"}
+		clusterErrors  = map[string]error{"cluster-3": errors.New("failed")}
+	)
+
+	crdClient1.AdmiralV1().GlobalTrafficPolicies("namespace1").Create(gtp1.DeepCopy())
+	crdClient1.AdmiralV1().GlobalTrafficPolicies("namespace1").Create(gtp2.DeepCopy())
+	crdClient2.AdmiralV1().GlobalTrafficPolicies("namespace1").Create(gtp3.DeepCopy())
+
+	updateGlobalTrafficPolicyStatus(registry, gtps, gtp1, syncedClusters, clusterErrors)
+
+	testCases := []struct {
+		name               string
+		crdClient          *admiralFake.Clientset
+		gtpName            string
+		expectedState      string
+		expectedShadowedBy string
+	}{
+		{
+			name:          "Should mark the selected gtp as active",
+			crdClient:     crdClient1,
+			gtpName:       "gtp1",
+			expectedState: v1.GtpStateActive,
+		},
+		{
+			name:               "Should mark an older gtp in the same cluster as shadowed",
+			crdClient:          crdClient1,
+			gtpName:            "gtp2",
+			expectedState:      v1.GtpStateShadowed,
+			expectedShadowedBy: "cluster-1/namespace1/gtp1",
+		},
+		{
+			name:               "Should mark a copy of the gtp in another cluster as shadowed",
+			crdClient:          crdClient2,
+			gtpName:            "gtp1",
+			expectedState:      v1.GtpStateShadowed,
+			expectedShadowedBy: "cluster-1/namespace1/gtp1",
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			gtp, err := c.crdClient.AdmiralV1().GlobalTrafficPolicies("namespace1").Get(c.gtpName, v12.GetOptions{})
+			if err != nil {
+				t.Fatalf("Unexpected error %v", err)
+			}
+			if gtp.Status.State != c.expectedState {
+				t.Errorf("expected state %s, got %s", c.expectedState, gtp.Status.State)
+			}
+			if gtp.Status.ShadowedBy != c.expectedShadowedBy {
+				t.Errorf("expected shadowedBy %s, got %s", c.expectedShadowedBy, gtp.Status.ShadowedBy)
+			}
+			if gtp.Status.ClusterSynced != 2 {
+				t.Errorf("expected 2 clusters synced, got %v", gtp.Status.ClusterSynced)
+			}
+			if !reflect.DeepEqual(gtp.Status.ClusterErrors, map[string]string{"cluster-3": "failed"}) {
+				t.Errorf("expected cluster errors for cluster-3, got %v", gtp.Status.ClusterErrors)
+			}
+			if gtp.Status.LastSyncTime.IsZero() {
+				t.Errorf("expected last sync time to be set")
+			}
+		})
+	}
+
+	//a conflict is reported once per shadowed gtp
+	gtp2.Status = v1.GlobalTrafficPolicyStatus{State: v1.GtpStateShadowed, ShadowedBy: "cluster-1/namespace1/gtp1"}
+	gtp3.Status = v1.GlobalTrafficPolicyStatus{State: v1.GtpStateShadowed, ShadowedBy: "cluster-1/namespace1/gtp1"}
+	updateGlobalTrafficPolicyStatus(registry, gtps, gtp1, syncedClusters, clusterErrors)
+
+	for cluster, k8sClient := range map[string]*k8sFake.Clientset{"cluster-1": k8sClient1, "cluster-2": k8sClient2} {
+		events, _ := k8sClient.CoreV1().Events("namespace1").List(v12.ListOptions{})
+		if len(events.Items) != 1 || events.Items[0].Reason != GtpConflictEventReason {
+			t.Errorf("expected one conflict event in %s, got %v", cluster, events.Items)
+		}
+	}
+
+	//the same outcome isn't written again
+	for _, gtp := range []*v1.GlobalTrafficPolicy{gtp1, gtp2} {
+		written, _ := crdClient1.AdmiralV1().GlobalTrafficPolicies("namespace1").Get(gtp.Name, v12.GetOptions{})
+		gtp.Status = written.Status
+	}
+	writes := len(crdClient1.Actions())
+	updateGlobalTrafficPolicyStatus(registry, gtps, gtp1, syncedClusters, clusterErrors)
+	if len(crdClient1.Actions()) != writes {
+		t.Errorf("expected no status update for an unchanged outcome, got %v", crdClient1.Actions()[writes:])
+	}
+}
+
+func TestGetDependencyStatus(t *testing.T) {
+
+	syncTime := v12.Now()
+
+	testCases := []struct {
+		name           string
+		oldStatus      v1.DependencyStatus
+		sourceClusters map[string]string
+		syncedClusters map[string]string
+		clusterErrors  map[string]error
+		expectedStatus v1.DependencyStatus
+	}{
+		{
+			name:           "Should be synced when there are no errors",
+			sourceClusters: map[string]string{"cluster-1": "cluster-1", "cluster-2": "cluster-2"},
+			syncedClusters: map[string]string{"cluster-1": "cluster-1"},
+			clusterErrors:  map[string]error{},
+			expectedStatus: v1.DependencyStatus{ClusterSynced: 2, State: v1.DependencyStateSynced, LastSyncTime: syncTime},
+		},
+		{
+			name:           "Should record errors for the source clusters",
+			sourceClusters: map[string]string{"cluster-1": "cluster-1", "cluster-2": "cluster-2"},
+			syncedClusters: map[string]string{"cluster-1": "cluster-1", "cluster-3": "cluster-3"},
+			clusterErrors:  map[string]error{"cluster-1": errors.New("failed"), "cluster-3": errors.New("failed")},
+			expectedStatus: v1.DependencyStatus{ClusterSynced: 1, State: v1.DependencyStateError, LastSyncTime: syncTime,
+				ClusterErrors: map[string]string{"cluster-1": "failed"}},
+		},
+		{
+			name: "Should retain errors for clusters that were not part of the sync",
+			oldStatus: v1.DependencyStatus{ClusterSynced: 0, State: v1.DependencyStateError,
+				ClusterErrors: map[string]string{"cluster-1": "failed", "cluster-2": "failed", "cluster-3": "failed"}},
+			sourceClusters: map[string]string{"cluster-1": "cluster-1", "cluster-2": "cluster-2"},
+			syncedClusters: map[string]string{"cluster-1": "cluster-1"},
+			clusterErrors:  map[string]error{},
+			expectedStatus: v1.DependencyStatus{ClusterSynced: 1, State: v1.DependencyStateError, LastSyncTime: syncTime,
+				ClusterErrors: map[string]string{"cluster-2": "failed"}},
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			status := getDependencyStatus(c.oldStatus, c.sourceClusters, syncTime, c.syncedClusters, c.clusterErrors)
+			if !reflect.DeepEqual(status, c.expectedStatus) {
+				t.Errorf("expected %v, got %v", c.expectedStatus, status)
+			}
+		})
+	}
+}
+
+func TestUpdateDependencyStatus(t *testing.T) {
+
+	dep := &v1.Dependency{ObjectMeta: v12.ObjectMeta{Name: "dep1", Namespace: "admiral"},
+		Spec: model.Dependency{Source: "webapp", IdentityLabel: "identity", Destinations: []string{"greeting"}}}
+
+	depController, err := admiral.NewDependencyController(make(chan struct{}), &test.MockDependencyHandler{}, "testdata/fake.config", "admiral", time.Minute)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	crdClient := admiralFake.NewSimpleClientset()
+	crdClient.AdmiralV1().Dependencies("admiral").Create(dep.DeepCopy())
+	depController.DepCrdClient = crdClient
+	depController.Added(dep.DeepCopy())
+
+	registry := &RemoteRegistry{
+		DependencyController: depController,
+		AdmiralCache:         &AdmiralCache{IdentityClusterCache: common.NewMapOfMaps()},
+	}
+	registry.AdmiralCache.IdentityClusterCache.Put("webapp", "cluster-1", "cluster-1")
+	registry.AdmiralCache.IdentityClusterCache.Put("webapp", "cluster-2", "cluster-2")
+
+	updateDependencyStatus(registry, map[string]string{"webapp": "webapp"}, map[string]string{"cluster-1": "cluster-1", "cluster-2": "cluster-2"},
+		map[string]error{"cluster-2": errors.New("failed")})
+
+	updatedDep, err := crdClient.AdmiralV1().Dependencies("admiral").Get("dep1", v12.GetOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if updatedDep.Status.State != v1.DependencyStateError || updatedDep.Status.ClusterSynced != 1 {
+		t.Errorf("expected 1 cluster synced with state %s, got %v", v1.DependencyStateError, updatedDep.Status)
+	}
+	if !reflect.DeepEqual(updatedDep.Status.ClusterErrors, map[string]string{"cluster-2": "failed"}) {
+		t.Errorf("expected cluster errors for cluster-2, got %v", updatedDep.Status.ClusterErrors)
+	}
+
+	//the same outcome isn't written again
+	depController.Updated(updatedDep, dep)
+	writes := len(crdClient.Actions())
+	updateDependencyStatus(registry, map[string]string{"webapp": "webapp"}, map[string]string{"cluster-1": "cluster-1", "cluster-2": "cluster-2"},
+		map[string]error{"cluster-2": errors.New("failed")})
+	if len(crdClient.Actions()) != writes {
+		t.Errorf("expected no status update for an unchanged outcome, got %v", crdClient.Actions()[writes:])
+	}
+}
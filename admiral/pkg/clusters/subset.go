@@ -0,0 +1,218 @@
+package clusters
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/common"
+	log "github.com/sirupsen/logrus"
+	networking "istio.io/api/networking/v1alpha3"
+	k8sV1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	v12 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+//Returns the host of a subset of the destination rule of a cname, e.g. v1.stage.greeting.global
+func getSubsetHost(cname string, subset string) string {
+	return strings.ToLower(subset) + common.Sep + cname
+}
+
+//Returns the name of the service selecting the pods of a subset of the service
+func getSubsetServiceName(serviceName string, subset string) string {
+	name := strings.ToLower(serviceName + common.Dash + subset)
+	if len(name) > 63 {
+		name = strings.TrimRight(name[:63], common.Dash)
+	}
+	return name
+}
+
+//Returns true if the host is the host of a subset of the destination rule of the cname
+func isSubsetHost(cache *AdmiralCache, cname string, prefix string) bool {
+	if cache.CnameSubsetCache == nil {
+		return false
+	}
+	_, ok := cache.CnameSubsetCache.Get(cname).Copy()[prefix]
+	return ok
+}
+
+//Returns the service entry of a subset of the destination rule for the cluster. Its local endpoints point at a service selecting the pods of
+//the subset only, and the endpoints of the other clusters at their gateways, which route the subset host to their own subset service
+func createSubsetServiceEntry(rc *RemoteController, identityId string, subsetHost string, address string, se *networking.ServiceEntry, subset *networking.Subset) *networking.ServiceEntry {
+	subsetSe := copyServiceEntry(se)
+	subsetSe.Hosts = []string{subsetHost}
+	subsetSe.Addresses = []string{address}
+	subsetSe.Endpoints = make([]*networking.ServiceEntry_Endpoint, 0, len(se.Endpoints))
+	for _, endpoint := range se.Endpoints {
+		newEndpoint := copyEndpoint(endpoint)
+		newEndpoint.Labels = nil
+		if strings.HasSuffix(endpoint.Address, common.DotLocalDomainSuffix) {
+			subsetService := addUpdateSubsetService(rc, identityId, endpoint.Address, subset)
+			if subsetService == nil {
+				continue
+			}
+			newEndpoint.Address = subsetService.Name + common.Sep + subsetService.Namespace + common.DotLocalDomainSuffix
+		}
+		subsetSe.Endpoints = append(subsetSe.Endpoints, newEndpoint)
+	}
+	if len(subsetSe.Endpoints) == 0 {
+		return nil
+	}
+	return subsetSe
+}
+
+//Returns the destination rule of a subset host, with the traffic policy of the subset falling back to the one of the destination rule
+func getSubsetDestinationRule(subsetHost string, dr *networking.DestinationRule, subset *networking.Subset) *networking.DestinationRule {
+	trafficPolicy := &networking.TrafficPolicy{}
+	if subset.TrafficPolicy != nil {
+		*trafficPolicy = *subset.TrafficPolicy
+	} else if dr.TrafficPolicy != nil {
+		*trafficPolicy = *dr.TrafficPolicy
+	}
+	//the remote gateways route on the sni set by istio mutual tls
+	if trafficPolicy.Tls == nil {
+		trafficPolicy.Tls = &networking.TLSSettings{Mode: networking.TLSSettings_ISTIO_MUTUAL}
+	}
+	return &networking.DestinationRule{Host: subsetHost, TrafficPolicy: trafficPolicy, ExportTo: dr.ExportTo}
+}
+
+//Creates or updates the service selecting the pods of the subset of the service with the local fqdn, returns nil if the service isn't
+//in the cluster. The service is ignored by Admiral
+func addUpdateSubsetService(rc *RemoteController, identityId string, localFqdn string, subset *networking.Subset) *k8sV1.Service {
+	if rc == nil || rc.ServiceController == nil || rc.ServiceController.K8sClient == nil {
+		return nil
+	}
+	nameAndNamespace := strings.Split(strings.TrimSuffix(localFqdn, common.DotLocalDomainSuffix), common.Sep)
+	if len(nameAndNamespace) != 2 {
+		return nil
+	}
+	var service *k8sV1.Service
+	for _, cachedService := range rc.ServiceController.Cache.Get(nameAndNamespace[1]) {
+		if cachedService.Name == nameAndNamespace[0] {
+			service = cachedService
+		}
+	}
+	if service == nil || len(service.Spec.Selector) == 0 {
+		return nil
+	}
+
+	subsetService := &k8sV1.Service{
+		ObjectMeta: v12.ObjectMeta{
+			Name:      getSubsetServiceName(service.Name, subset.Name),
+			Namespace: service.Namespace,
+			Labels:    map[string]string{common.GetWorkloadIdentifier(): identityId},
+			Annotations: map[string]string{
+				common.CreatedByAnnotation:     common.Admiral,
+				common.AdmiralIgnoreAnnotation: "true",
+			},
+		},
+		Spec: k8sV1.ServiceSpec{Type: k8sV1.ServiceTypeClusterIP, Selector: make(map[string]string, len(service.Spec.Selector)+len(subset.Labels))},
+	}
+	for key, value := range service.Spec.Selector {
+		subsetService.Spec.Selector[key] = value
+	}
+	for key, value := range subset.Labels {
+		subsetService.Spec.Selector[key] = value
+	}
+	for _, port := range service.Spec.Ports {
+		subsetService.Spec.Ports = append(subsetService.Spec.Ports, k8sV1.ServicePort{Name: port.Name, Protocol: port.Protocol, Port: port.Port, TargetPort: port.TargetPort})
+	}
+	services := rc.ServiceController.K8sClient.CoreV1().Services(service.Namespace)
+	exist, err := services.Get(subsetService.Name, v12.GetOptions{})
+	if k8sErrors.IsNotFound(err) {
+		_, err = services.Create(subsetService)
+		if err != nil {
+			log.Errorf(LogErrFormat, "Create", "Service", subsetService.Namespace+common.Slash+subsetService.Name, rc.ClusterID, err)
+			return nil
+		}
+		log.Infof(LogFormat, "Create", "Service", subsetService.Namespace+common.Slash+subsetService.Name, rc.ClusterID, "Success")
+		return subsetService
+	}
+	if err != nil {
+		log.Errorf(LogErrFormat, "Get", "Service", subsetService.Namespace+common.Slash+subsetService.Name, rc.ClusterID, err)
+		return nil
+	}
+	if exist.Annotations[common.CreatedByAnnotation] != common.Admiral {
+		log.Warnf(LogFormat, "Update", "Service", subsetService.Namespace+common.Slash+subsetService.Name, rc.ClusterID, "Skipped as it isn't owned by admiral")
+		return nil
+	}
+	if reflect.DeepEqual(exist.Spec.Selector, subsetService.Spec.Selector) && isSamePorts(exist.Spec.Ports, subsetService.Spec.Ports) {
+		return exist
+	}
+	exist = exist.DeepCopy()
+	exist.Labels = subsetService.Labels
+	exist.Annotations = subsetService.Annotations
+	exist.Spec.Selector = subsetService.Spec.Selector
+	exist.Spec.Ports = subsetService.Spec.Ports
+	_, err = services.Update(exist)
+	if err != nil {
+		log.Errorf(LogErrFormat, "Update", "Service", exist.Namespace+common.Slash+exist.Name, rc.ClusterID, err)
+		return nil
+	}
+	log.Infof(LogFormat, "Update", "Service", exist.Namespace+common.Slash+exist.Name, rc.ClusterID, "Success")
+	return exist
+}
+
+func isSamePorts(ports []k8sV1.ServicePort, otherPorts []k8sV1.ServicePort) bool {
+	if len(ports) != len(otherPorts) {
+		return false
+	}
+	for i := range ports {
+		if ports[i].Name != otherPorts[i].Name || ports[i].Protocol != otherPorts[i].Protocol || ports[i].Port != otherPorts[i].Port || ports[i].TargetPort != otherPorts[i].TargetPort {
+			return false
+		}
+	}
+	return true
+}
+
+//Deletes the service entry, destination rule and service of the subset from the cluster
+func deleteSubset(rc *RemoteController, cname string, subset string) {
+	syncNamespace := common.GetSyncNamespace()
+	subsetHost := getSubsetHost(cname, subset)
+	seName, drName := getIstioResourceName(subsetHost, "-se"), getIstioResourceName(subsetHost, "-dr")
+
+	se, err := rc.ServiceEntryController.IstioClient.NetworkingV1alpha3().ServiceEntries(syncNamespace).Get(seName, v12.GetOptions{})
+	if err == nil && se != nil {
+		for _, endpoint := range se.Spec.Endpoints {
+			if !strings.HasSuffix(endpoint.Address, common.DotLocalDomainSuffix) || rc.ServiceController == nil || rc.ServiceController.K8sClient == nil {
+				continue
+			}
+			nameAndNamespace := strings.Split(strings.TrimSuffix(endpoint.Address, common.DotLocalDomainSuffix), common.Sep)
+			if len(nameAndNamespace) != 2 {
+				continue
+			}
+			services := rc.ServiceController.K8sClient.CoreV1().Services(nameAndNamespace[1])
+			if service, err := services.Get(nameAndNamespace[0], v12.GetOptions{}); err != nil || !isCreatedByAdmiral(service.Annotations) {
+				continue
+			}
+			if err := services.Delete(nameAndNamespace[0], &v12.DeleteOptions{}); err != nil {
+				log.Errorf(LogErrFormat, "Delete", "Service", endpoint.Address, rc.ClusterID, err)
+			}
+		}
+		err = rc.ServiceEntryController.IstioClient.NetworkingV1alpha3().ServiceEntries(syncNamespace).Delete(seName, &v12.DeleteOptions{})
+		if err != nil {
+			log.Errorf(LogErrFormat, "Delete", "ServiceEntry", seName, rc.ClusterID, err)
+		} else {
+			log.Infof(LogFormat, "Delete", "ServiceEntry", seName, rc.ClusterID, "Success")
+		}
+	}
+	err = rc.DestinationRuleController.IstioClient.NetworkingV1alpha3().DestinationRules(syncNamespace).Delete(drName, &v12.DeleteOptions{})
+	if err != nil && !k8sErrors.IsNotFound(err) {
+		log.Errorf(LogErrFormat, "Delete", "DestinationRule", drName, rc.ClusterID, err)
+	}
+}
+
+//Points the routes to a subset of the host of the virtual service at the host of the subset, the gateways can't route to the subsets of
+//a host of another cluster
+func useSubsetHosts(cache *AdmiralCache, host string, destination *networking.Destination) {
+	if destination == nil || len(destination.Subset) == 0 {
+		return
+	}
+	if destination.Host != host && !strings.HasSuffix(destination.Host, common.DotLocalDomainSuffix) {
+		return
+	}
+	if !isSubsetHost(cache, host, strings.ToLower(destination.Subset)) {
+		return
+	}
+	destination.Host = getSubsetHost(host, destination.Subset)
+	destination.Subset = ""
+}
@@ -0,0 +1,147 @@
+package clusters
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	admiralFake "github.com/istio-ecosystem/admiral/admiral/pkg/client/clientset/versioned/fake"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/admiral"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/common"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/test"
+	istionetworkingv1alpha3 "istio.io/api/networking/v1alpha3"
+	k8sV1 "k8s.io/api/core/v1"
+	v12 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	k8sFake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+)
+
+func TestCreateSeWithDrSubsets(t *testing.T) {
+	config := rest.Config{Host: "localhost"}
+	serviceController, err := admiral.NewServiceController("cluster1", make(chan struct{}), &test.MockServiceHandler{}, &config, time.Minute)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	k8sClient := k8sFake.NewSimpleClientset()
+	serviceController.K8sClient = k8sClient
+	serviceController.Cache.Put(&k8sV1.Service{
+		ObjectMeta: v12.ObjectMeta{Name: "greeting", Namespace: "ns"},
+		Spec: k8sV1.ServiceSpec{
+			Selector: map[string]string{"app": "greeting"},
+			Ports:    []k8sV1.ServicePort{{Name: "http", Port: 80, TargetPort: intstr.FromInt(8080)}},
+		},
+	})
+	rc := &RemoteController{ClusterID: "cluster1", ServiceController: serviceController}
+
+	se := istionetworkingv1alpha3.ServiceEntry{
+		Hosts: []string{"stage.greeting.global"},
+		Endpoints: []*istionetworkingv1alpha3.ServiceEntry_Endpoint{
+			{Address: "greeting.ns.svc.cluster.local", Ports: map[string]uint32{"http": 80}, Locality: "us-west-2"},
+			{Address: "cluster2.elb", Ports: map[string]uint32{"http": 15443}, Locality: "us-east-2"},
+		},
+	}
+	dr := istionetworkingv1alpha3.DestinationRule{
+		Host: "stage.greeting.global",
+		Subsets: []*istionetworkingv1alpha3.Subset{
+			{Name: "V1", Labels: map[string]string{"version": "v1"}},
+		},
+	}
+	//the addresses of the subset hosts are allocated like the ones of the other hosts
+	stop := make(chan struct{})
+	defer close(stop)
+	addressController := admiral.NewAddressAllocationController(stop, admiralFake.NewSimpleClientset(), k8sFake.NewSimpleClientset(), "ns", 0)
+	addressController.Create("stage.greeting.global-se", "240.0.10.1")
+	addressController.Create("v1.stage.greeting.global-se", "240.0.10.2")
+
+	serviceEntries := createSeWithDrLabels(rc, "greeting", "stage.greeting.global-se", &se, &dr, &AdmiralCache{AddressAllocator: NewAddressAllocationAllocator(addressController)})
+	subsetSe := serviceEntries["v1.stage.greeting.global-se"]
+	if subsetSe == nil {
+		t.Fatalf("Expected a service entry for the subset, got %v", serviceEntries)
+	}
+	expected := []*istionetworkingv1alpha3.ServiceEntry_Endpoint{
+		{Address: "greeting-v1.ns.svc.cluster.local", Ports: map[string]uint32{"http": 80}, Locality: "us-west-2"},
+		{Address: "cluster2.elb", Ports: map[string]uint32{"http": 15443}, Locality: "us-east-2"},
+	}
+	if !reflect.DeepEqual(subsetSe.Hosts, []string{"v1.stage.greeting.global"}) || !reflect.DeepEqual(subsetSe.Addresses, []string{"240.0.10.2"}) ||
+		!reflect.DeepEqual(subsetSe.Endpoints, expected) {
+		t.Errorf("Expected the subset host to point at the subset service locally and at the gateways remotely, got %v", subsetSe)
+	}
+
+	subsetService, err := k8sClient.CoreV1().Services("ns").Get("greeting-v1", v12.GetOptions{})
+	if err != nil {
+		t.Fatalf("Expected the subset service to be created, got %v", err)
+	}
+	if !reflect.DeepEqual(subsetService.Spec.Selector, map[string]string{"app": "greeting", "version": "v1"}) || subsetService.Spec.Ports[0].TargetPort.IntValue() != 8080 {
+		t.Errorf("Expected the subset service to select the pods of the subset, got %v", subsetService.Spec)
+	}
+	if subsetService.Annotations[common.AdmiralIgnoreAnnotation] != "true" || !isCreatedByAdmiral(subsetService.Annotations) {
+		t.Errorf("Expected the subset service to be owned and ignored by admiral, got %v", subsetService.Annotations)
+	}
+
+	//the clusters without the service only point at the gateways
+	remoteSe := createSubsetServiceEntry(&RemoteController{ClusterID: "cluster3"}, "greeting", "v1.stage.greeting.global", "240.0.10.2", &se, dr.Subsets[0])
+	if len(remoteSe.Endpoints) != 1 || remoteSe.Endpoints[0].Address != "cluster2.elb" {
+		t.Errorf("Expected the local endpoint to be skipped without the service, got %v", remoteSe.Endpoints)
+	}
+}
+
+func TestGetSubsetDestinationRule(t *testing.T) {
+	lb := &istionetworkingv1alpha3.LoadBalancerSettings{LbPolicy: &istionetworkingv1alpha3.LoadBalancerSettings_Simple{Simple: istionetworkingv1alpha3.LoadBalancerSettings_RANDOM}}
+	dr := &istionetworkingv1alpha3.DestinationRule{Host: "stage.greeting.global", TrafficPolicy: &istionetworkingv1alpha3.TrafficPolicy{LoadBalancer: lb}}
+
+	subsetDr := getSubsetDestinationRule("v1.stage.greeting.global", dr, &istionetworkingv1alpha3.Subset{Name: "v1"})
+	if subsetDr.Host != "v1.stage.greeting.global" || subsetDr.TrafficPolicy.LoadBalancer != lb || subsetDr.TrafficPolicy.Tls.Mode != istionetworkingv1alpha3.TLSSettings_ISTIO_MUTUAL {
+		t.Errorf("Expected the traffic policy of the destination rule with istio mutual tls, got %v", subsetDr)
+	}
+	if dr.TrafficPolicy.Tls != nil {
+		t.Errorf("Expected the destination rule not to be modified")
+	}
+
+	tls := &istionetworkingv1alpha3.TLSSettings{Mode: istionetworkingv1alpha3.TLSSettings_DISABLE}
+	subsetDr = getSubsetDestinationRule("v1.stage.greeting.global", dr, &istionetworkingv1alpha3.Subset{Name: "v1", TrafficPolicy: &istionetworkingv1alpha3.TrafficPolicy{Tls: tls}})
+	if subsetDr.TrafficPolicy.LoadBalancer != nil || subsetDr.TrafficPolicy.Tls != tls {
+		t.Errorf("Expected the traffic policy of the subset, got %v", subsetDr)
+	}
+}
+
+func TestUseSubsetHosts(t *testing.T) {
+	cache := &AdmiralCache{CnameSubsetCache: common.NewMapOfMaps()}
+	cache.CnameSubsetCache.Put("stage.greeting.global", "v1", "V1")
+
+	testCases := []struct {
+		name        string
+		destination *istionetworkingv1alpha3.Destination
+		expected    *istionetworkingv1alpha3.Destination
+	}{
+		{
+			name:        "Given a subset of the local service, should route to the subset host",
+			destination: &istionetworkingv1alpha3.Destination{Host: "greeting.ns.svc.cluster.local", Subset: "V1"},
+			expected:    &istionetworkingv1alpha3.Destination{Host: "v1.stage.greeting.global"},
+		},
+		{
+			name:        "Given a subset of the cname, should route to the subset host",
+			destination: &istionetworkingv1alpha3.Destination{Host: "stage.greeting.global", Subset: "V1"},
+			expected:    &istionetworkingv1alpha3.Destination{Host: "v1.stage.greeting.global"},
+		},
+		{
+			name:        "Given an unknown subset, should keep the destination",
+			destination: &istionetworkingv1alpha3.Destination{Host: "stage.greeting.global", Subset: "v2"},
+			expected:    &istionetworkingv1alpha3.Destination{Host: "stage.greeting.global", Subset: "v2"},
+		},
+		{
+			name:        "Given a subset of another host, should keep the destination",
+			destination: &istionetworkingv1alpha3.Destination{Host: "stage.other.global", Subset: "V1"},
+			expected:    &istionetworkingv1alpha3.Destination{Host: "stage.other.global", Subset: "V1"},
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			useSubsetHosts(cache, "stage.greeting.global", c.destination)
+			if !reflect.DeepEqual(c.destination, c.expected) {
+				t.Errorf("Expected %v, got %v", c.expected, c.destination)
+			}
+		})
+	}
+}
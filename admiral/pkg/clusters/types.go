@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
+	"strconv"
 	"sync"
 	"time"
 
@@ -32,7 +34,19 @@ type RemoteController struct {
 	VirtualServiceController  *istio.VirtualServiceController
 	SidecarController         *istio.SidecarController
 	RolloutController         *admiral.RolloutController
+	StatefulSetController     *admiral.StatefulSetController
+	FlaggerController         *admiral.FlaggerController   //only started when flagger is enabled
+	ServiceEntryGuard         *ServiceEntryGuard           //the service entry updates are not guarded when not set
+	EndpointsController       *admiral.EndpointsController //only started when the service entries point at the pods of the cluster
+	FlatNetwork               string                       //the clusters on the same flat network point their service entries straight at each other's workloads, empty if none
+	FlatNetworkEndpoints      string                       //the addresses the other clusters on the flat network point at, those of the service load balancers or of the pods
 	stop                      chan struct{}
+	//the HasSynced funcs of the controllers started for the cluster, key=controller name
+	informersSynced map[string]func() bool
+	//the cluster is left out of the readiness of Admiral if its controllers haven't synced by then, never when zero
+	syncDeadline time.Time
+	//the readiness of Admiral, the destructive updates of the service entries of the cluster are skipped until all the clusters have synced
+	registrySynced func() bool
 	//listener for normal types
 }
 
@@ -46,21 +60,38 @@ type AdmiralCache struct {
 	SubsetServiceEntryIdentityCache *sync.Map
 	ServiceEntryAddressStore        *ServiceEntryAddressStore
 	ConfigMapController             admiral.ConfigMapControllerInterface //todo this should be in the remotecontrollers map once we expand it to have one configmap per cluster
+	AddressAllocator                ServiceEntryAddressAllocator         //the configmap allocator is used when not set
+	ClusterWriter                   *ClusterWriter                       //the clusters are written one after the other when not set
 	GlobalTrafficCache              *globalTrafficCache                  //The cache needs to live in the handler because it needs access to deployments
 	DependencyNamespaceCache        *common.SidecarEgressMap
 	SeClusterCache                  *common.MapOfMaps
+	CnameSubsetCache                *common.MapOfMaps //subsets of the destination rules of the cnames, cname -> subset host prefix -> subset name
 
 	argoRolloutsEnabled bool
 }
 
 type RemoteRegistry struct {
 	sync.Mutex
-	RemoteControllers map[string]*RemoteController
-	SecretController  *secret.Controller
-	secretClient      k8s.Interface
-	ctx               context.Context
-	AdmiralCache      *AdmiralCache
-	StartTime         time.Time
+	RemoteControllers    map[string]*RemoteController
+	SecretController     *secret.Controller
+	secretClient         k8s.Interface
+	ctx                  context.Context
+	AdmiralCache         *AdmiralCache
+	StartTime            time.Time
+	DependencyController *admiral.DependencyController
+	//records the changes to the clusters in dry run mode, nil otherwise
+	ChangeRecorder *ChangeRecorder
+	//coalesces the syncs of the identities, nil when the events are processed synchronously
+	IdentityQueue *IdentityQueue
+	//the HasSynced funcs of the dependency and secret controllers, key=controller name
+	informersSynced map[string]func() bool
+	//the cleanups requested during the cache warm up, they depend on the complete caches so they run once it is over
+	deferredMutex sync.Mutex
+	deferred      []func()
+	//a cleanup waits for the informers to sync, only when the deferred cleanups aren't drained by the readiness watcher
+	deferredWaiting bool
+	//the readiness watcher runs the deferred cleanups when Admiral becomes ready
+	readinessWatched bool
 }
 
 func (r *RemoteRegistry) shutdown() {
@@ -77,7 +108,8 @@ func (r *RemoteRegistry) shutdown() {
 
 type ServiceEntryAddressStore struct {
 	EntryAddresses map[string]string `yaml:"entry-addresses,omitempty"`
-	Addresses      []string          `yaml:"addresses,omitempty"` //trading space for efficiency - this will give a quick way to validate that the address is unique
+	Addresses      []string          `yaml:"addresses,omitempty"`    //trading space for efficiency - this will give a quick way to validate that the address is unique
+	UnusedSince    map[string]string `yaml:"unused-since,omitempty"` //service entries that no longer exist in any cluster, mapped to the time (RFC3339) they were first found missing
 }
 
 type DependencyHandler struct {
@@ -95,11 +127,29 @@ type RolloutHandler struct {
 	ClusterID      string
 }
 
+type StatefulSetHandler struct {
+	RemoteRegistry *RemoteRegistry
+	ClusterID      string
+}
+
 type globalTrafficCache struct {
 	//map of global traffic policies key=environment.identity, value: GlobalTrafficPolicy object
 	identityCache map[string]*v1.GlobalTrafficPolicy
 
 	mutex *sync.Mutex
+
+	//map of conflicting global traffic policies key=environment.identity, value: GtpConflict object
+	conflicts     map[string]*GtpConflict
+	conflictMutex sync.Mutex
+}
+
+//GtpConflict lists the global traffic policies shadowed by the active one for an identity and env
+//policies are referenced as cluster/namespace/name
+type GtpConflict struct {
+	Identity string   `json:"identity"`
+	Env      string   `json:"env"`
+	Active   string   `json:"active"`
+	Shadowed []string `json:"shadowed"`
 }
 
 func (g *globalTrafficCache) GetFromIdentity(identity string, environment string) *v1.GlobalTrafficPolicy {
@@ -124,6 +174,37 @@ func (g *globalTrafficCache) Put(gtp *v1.GlobalTrafficPolicy) error {
 	return nil
 }
 
+func (g *globalTrafficCache) PutConflict(conflict *GtpConflict) {
+	defer g.conflictMutex.Unlock()
+	g.conflictMutex.Lock()
+	if g.conflicts == nil {
+		g.conflicts = make(map[string]*GtpConflict)
+	}
+	g.conflicts[common.ConstructGtpKey(conflict.Env, conflict.Identity)] = conflict
+}
+
+func (g *globalTrafficCache) DeleteConflict(identity string, environment string) {
+	defer g.conflictMutex.Unlock()
+	g.conflictMutex.Lock()
+	delete(g.conflicts, common.ConstructGtpKey(environment, identity))
+}
+
+//returns all current conflicts ordered by key
+func (g *globalTrafficCache) GetConflicts() []GtpConflict {
+	defer g.conflictMutex.Unlock()
+	g.conflictMutex.Lock()
+	keys := make([]string, 0, len(g.conflicts))
+	for key := range g.conflicts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	conflicts := make([]GtpConflict, 0, len(keys))
+	for _, key := range keys {
+		conflicts = append(conflicts, *g.conflicts[key])
+	}
+	return conflicts
+}
+
 func (g *globalTrafficCache) Delete(identity string, environment string) {
 	key := common.ConstructGtpKey(environment, identity)
 	if _, ok := g.identityCache[key]; ok {
@@ -142,6 +223,19 @@ type NodeHandler struct {
 	ClusterID      string
 }
 
+//Keeps the number of nodes per locality of the cluster in the ClusterLocalityCache
+func (nh *NodeHandler) LocalitiesUpdated(localities map[string]int) {
+	if nh.RemoteRegistry == nil || nh.RemoteRegistry.AdmiralCache == nil || nh.RemoteRegistry.AdmiralCache.ClusterLocalityCache == nil {
+		return
+	}
+	counts := make(map[string]string, len(localities))
+	for locality, count := range localities {
+		counts[locality] = strconv.Itoa(count)
+	}
+	nh.RemoteRegistry.AdmiralCache.ClusterLocalityCache.PutMap(nh.ClusterID, counts)
+	log.Debugf(LogFormat, "Update", "locality", "", nh.ClusterID, fmt.Sprintf("node localities=%v", localities))
+}
+
 type ServiceHandler struct {
 	RemoteRegistry *RemoteRegistry
 	ClusterID      string
@@ -171,6 +265,30 @@ func (sh *ServiceHandler) Deleted(obj *k8sV1.Service) {
 	}
 }
 
+type EndpointsHandler struct {
+	RemoteRegistry *RemoteRegistry
+	ClusterID      string
+}
+
+//Updated regenerates the config of the identity behind the service, for the clusters on the same flat network to follow its pods
+func (eh *EndpointsHandler) Updated(obj *k8sV1.Endpoints) {
+	rc := eh.RemoteRegistry.RemoteControllers[eh.ClusterID]
+	if rc == nil || rc.ServiceController == nil {
+		return
+	}
+	for _, svc := range rc.ServiceController.Cache.Get(obj.Namespace) {
+		//the services without selector have their endpoints managed by hand, they don't front any workload
+		if svc.Name != obj.Name || svc.Spec.Selector == nil {
+			continue
+		}
+		log.Debugf(LogFormat, "Updated", "endpoints", obj.Name, eh.ClusterID, "received")
+		if err := HandleEventForService(svc, eh.RemoteRegistry, eh.ClusterID); err != nil {
+			log.Errorf(LogErrFormat, "Error", "endpoints", obj.Name, eh.ClusterID, err)
+		}
+		return
+	}
+}
+
 func HandleEventForService(svc *k8sV1.Service, remoteRegistry *RemoteRegistry, clusterName string) error {
 	if svc.Spec.Selector == nil {
 		return fmt.Errorf("selector missing on service=%s in namespace=%s cluster=%s", svc.Name, svc.Namespace, clusterName);
@@ -188,6 +306,12 @@ func HandleEventForService(svc *k8sV1.Service, remoteRegistry *RemoteRegistry, c
 			}
 		}
 	}
+	if statefulSetController := remoteRegistry.RemoteControllers[clusterName].StatefulSetController; statefulSetController != nil {
+		matchingStatefulSets := statefulSetController.GetStatefulSetBySelectorInNamespace(svc.Spec.Selector, svc.Namespace)
+		for _, statefulSet := range matchingStatefulSets {
+			HandleEventForStatefulSet(admiral.Update, &statefulSet, remoteRegistry, clusterName)
+		}
+	}
 	if common.GetAdmiralParams().ArgoRolloutsEnabled && rolloutController != nil {
 		matchingRollouts := remoteRegistry.RemoteControllers[clusterName].RolloutController.GetRolloutBySelectorInNamespace(svc.Spec.Selector, svc.Namespace)
 
@@ -212,9 +336,8 @@ func (dh *DependencyHandler) Updated(obj *v1.Dependency) {
 
 	log.Infof(LogFormat, "Update", "dependency-record", obj.Name, "", "Received=true namespace="+obj.Namespace)
 
-	// need clean up before handle it as added, I need to handle update that delete the dependency, find diff first
-	// this is more complex cos want to make sure no other service depend on the same service (which we just removed the dependancy).
-	// need to make sure nothing depend on that before cleaning up the SE for that service
+	// destinations removed from the record are cleaned up from the dependent clusters
+	// only if no other source depends on them in those clusters
 	HandleDependencyRecord(obj, dh.RemoteRegistry)
 
 }
@@ -224,15 +347,31 @@ func HandleDependencyRecord(obj *v1.Dependency, remoteRegitry *RemoteRegistry) {
 
 	if len(sourceIdentity) == 0 {
 		log.Infof(LogFormat, "Event", "dependency-record", obj.Name, "", "No identity found namespace="+obj.Namespace)
+		return
 	}
 
-	updateIdentityDependencyCache(sourceIdentity, remoteRegitry.AdmiralCache.IdentityDependencyCache, obj)
+	removedDestinations := updateIdentityDependencyCache(sourceIdentity, remoteRegitry.AdmiralCache.IdentityDependencyCache, obj)
+
+	cleanupDependentClusters(removedDestinations, remoteRegitry)
+	pruneDependencySidecars(sourceIdentity, removedDestinations, remoteRegitry)
 }
 
 func (dh *DependencyHandler) Deleted(obj *v1.Dependency) {
-	// special case of update, delete the dependency crd file for one service, need to loop through all ones we plan to update
-	// and make sure nobody else is relying on the same SE in same cluster
-	log.Infof(LogFormat, "Deleted", "dependency", obj.Name, "", "Skipping, not implemented")
+	// special case of update, all the destinations of the source are removed and cleaned up from the
+	// dependent clusters unless another source still depends on them in the same cluster
+	log.Infof(LogFormat, "Deleted", "dependency-record", obj.Name, "", "Received=true namespace="+obj.Namespace)
+
+	sourceIdentity := obj.Spec.Source
+
+	if len(sourceIdentity) == 0 {
+		log.Infof(LogFormat, "Event", "dependency-record", obj.Name, "", "No identity found namespace="+obj.Namespace)
+		return
+	}
+
+	removedDestinations := deleteIdentityDependencyCache(sourceIdentity, dh.RemoteRegistry.AdmiralCache.IdentityDependencyCache, obj)
+
+	cleanupDependentClusters(removedDestinations, dh.RemoteRegistry)
+	pruneDependencySidecars(sourceIdentity, removedDestinations, dh.RemoteRegistry)
 }
 
 func (gtp *GlobalTrafficHandler) Added(obj *v1.GlobalTrafficPolicy) {
@@ -272,14 +411,14 @@ func (rh *RolloutHandler) Added(obj *argo.Rollout) {
 }
 
 func (rh *RolloutHandler) Updated(obj *argo.Rollout) {
-	log.Infof(LogFormat, "Updated", "rollout", obj.Name, rh.ClusterID, "received")
+	HandleEventForRollout(admiral.Update, obj, rh.RemoteRegistry, rh.ClusterID)
 }
 
 func (rh *RolloutHandler) Deleted(obj *argo.Rollout) {
 	HandleEventForRollout(admiral.Delete, obj, rh.RemoteRegistry, rh.ClusterID)
 }
 
-// helper function to handle add and delete for RolloutHandler
+// helper function to handle add, update and delete for RolloutHandler
 func HandleEventForRollout(event admiral.EventType, obj *argo.Rollout, remoteRegistry *RemoteRegistry, clusterName string) {
 
 	log.Infof(LogFormat, event, "rollout", obj.Name, clusterName, "Received")
@@ -293,7 +432,7 @@ func HandleEventForRollout(event admiral.EventType, obj *argo.Rollout, remoteReg
 	env := common.GetEnvForRollout(obj)
 
 	// Use the same function as added deployment function to update and put new service entry in place to replace old one
-	modifyServiceEntryForNewServiceOrPod(event, env, globalIdentifier, remoteRegistry)
+	syncIdentity(event, env, globalIdentifier, remoteRegistry)
 }
 
 // helper function to handle add and delete for DeploymentHandler
@@ -309,7 +448,31 @@ func HandleEventForDeployment(event admiral.EventType, obj *k8sAppsV1.Deployment
 	env := common.GetEnv(obj)
 
 	// Use the same function as added deployment function to update and put new service entry in place to replace old one
-	modifyServiceEntryForNewServiceOrPod(event, env, globalIdentifier, remoteRegistry)
+	syncIdentity(event, env, globalIdentifier, remoteRegistry)
+}
+
+func (sh *StatefulSetHandler) Added(obj *k8sAppsV1.StatefulSet) {
+	HandleEventForStatefulSet(admiral.Add, obj, sh.RemoteRegistry, sh.ClusterID)
+}
+
+func (sh *StatefulSetHandler) Deleted(obj *k8sAppsV1.StatefulSet) {
+	HandleEventForStatefulSet(admiral.Delete, obj, sh.RemoteRegistry, sh.ClusterID)
+}
+
+// helper function to handle add and delete for StatefulSetHandler
+func HandleEventForStatefulSet(event admiral.EventType, obj *k8sAppsV1.StatefulSet, remoteRegistry *RemoteRegistry, clusterName string) {
+
+	globalIdentifier := common.GetStatefulSetGlobalIdentifier(obj)
+
+	if len(globalIdentifier) == 0 {
+		log.Infof(LogFormat, "Event", "statefulset", obj.Name, clusterName, "Skipped as '"+common.GetWorkloadIdentifier()+" was not found', namespace="+obj.Namespace)
+		return
+	}
+
+	env := common.GetEnvForStatefulSet(obj)
+
+	// Use the same function as added deployment function to update and put new service entry in place to replace old one
+	syncIdentity(event, env, globalIdentifier, remoteRegistry)
 }
 
 // HandleEventForGlobalTrafficPolicy processes all the events related to GTPs
@@ -327,6 +490,6 @@ func HandleEventForGlobalTrafficPolicy(gtp *v1.GlobalTrafficPolicy, remoteRegist
 	// the endpoints from being deleted.
 	// TODO: Need to come up with a way to prevent deleting default endpoints so that this hack can be removed.
 	// Use the same function as added deployment function to update and put new service entry in place to replace old one
-	modifyServiceEntryForNewServiceOrPod(admiral.Update, env, globalIdentifier, remoteRegistry)
+	syncIdentity(admiral.Update, env, globalIdentifier, remoteRegistry)
 	return nil
 }
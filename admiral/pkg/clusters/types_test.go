@@ -2,6 +2,7 @@ package clusters
 
 import (
 	"context"
+	"reflect"
 	"sync"
 	"testing"
 	"time"
@@ -258,3 +259,16 @@ func TestHandleEventForGlobalTrafficPolicy(t *testing.T) {
 	}
 
 }
+
+func TestNodeHandlerLocalitiesUpdated(t *testing.T) {
+	registry := &RemoteRegistry{AdmiralCache: &AdmiralCache{ClusterLocalityCache: common.NewMapOfMaps()}}
+	handler := NodeHandler{RemoteRegistry: registry, ClusterID: "cluster-1"}
+
+	handler.LocalitiesUpdated(map[string]int{"us-west-2/us-west-2a": 2, "us-west-2/us-west-2b": 1})
+	handler.LocalitiesUpdated(map[string]int{"us-west-2/us-west-2a": 3})
+
+	expected := map[string]string{"us-west-2/us-west-2a": "3"}
+	if localities := registry.AdmiralCache.ClusterLocalityCache.Get("cluster-1").Copy(); !reflect.DeepEqual(localities, expected) {
+		t.Errorf("expected %v, got %v", expected, localities)
+	}
+}
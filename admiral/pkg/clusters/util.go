@@ -1,18 +1,25 @@
 package clusters
 
 import (
+	"context"
 	"errors"
 	argo "github.com/argoproj/argo-rollouts/pkg/apis/rollouts/v1alpha1"
 	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/common"
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v2"
+	networking "istio.io/api/networking/v1alpha3"
 	k8sAppsV1 "k8s.io/api/apps/v1"
 	k8sV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 )
 
+//how often the informers are checked for the sync when the deferred cleanups wait for it without a readiness watcher
+var deferredCleanupPollInterval = time.Second
+
 func GetMeshPorts(clusterName string, destService *k8sV1.Service,
 	destDeployment *k8sAppsV1.Deployment) map[string]uint32 {
 	var meshPorts = destDeployment.Spec.Template.Annotations[common.SidecarEnabledPorts]
@@ -27,6 +34,13 @@ func GetMeshPortsForRollout(clusterName string, destService *k8sV1.Service,
 	return ports
 }
 
+func GetMeshPortsForStatefulSet(clusterName string, destService *k8sV1.Service,
+	destStatefulSet *k8sAppsV1.StatefulSet) map[string]uint32 {
+	var meshPorts = destStatefulSet.Spec.Template.Annotations[common.SidecarEnabledPorts]
+	ports := getMeshPortsHelper(meshPorts, destService, clusterName)
+	return ports
+}
+
 func getMeshPortsHelper(meshPorts string, destService *k8sV1.Service, clusterName string) map[string]uint32 {
 	var ports = make(map[string]uint32)
 
@@ -36,7 +50,7 @@ func getMeshPortsHelper(meshPorts string, destService *k8sV1.Service, clusterNam
 	if len(meshPorts) == 0 {
 		log.Infof(LogFormat, "GetMeshPorts", "service", destService.Name, clusterName, "No mesh ports present, defaulting to first port")
 		if destService.Spec.Ports != nil && len(destService.Spec.Ports) > 0 {
-			var protocol = GetPortProtocol(destService.Spec.Ports[0].Name)
+			var protocol = getServicePortProtocol(destService, destService.Spec.Ports[0])
 			ports[protocol] = uint32(destService.Spec.Ports[0].Port)
 		}
 		return ports
@@ -44,20 +58,19 @@ func getMeshPortsHelper(meshPorts string, destService *k8sV1.Service, clusterNam
 
 	meshPortsSplit := strings.Split(meshPorts, ",")
 
-	if len(meshPortsSplit) > 1 {
-		log.Warnf(LogErrFormat, "Get", "MeshPorts", "", clusterName,
-			"Multiple inbound mesh ports detected, admiral generates service entry with first matched port and protocol")
-	}
-
-	//fetch the first valid port if there is more than one mesh port
-	var meshPortMap = make(map[uint32]uint32)
+	//the order of the mesh ports in the annotation decides which port is the primary one
+	var meshPortOrder = make(map[uint32]int)
 	for _, meshPort := range meshPortsSplit {
-		port, err := strconv.ParseUint(meshPort, 10, 32)
-		if err == nil {
-			meshPortMap[uint32(port)] = uint32(port)
-			break
+		port, err := strconv.ParseUint(strings.TrimSpace(meshPort), 10, 32)
+		if err != nil {
+			log.Warnf(LogErrFormat, "GetMeshPorts", "MeshPorts", destService.Name, clusterName, err)
+			continue
+		}
+		if _, ok := meshPortOrder[uint32(port)]; !ok {
+			meshPortOrder[uint32(port)] = len(meshPortOrder)
 		}
 	}
+	var matchedPorts = make([]meshPort, 0)
 	for _, servicePort := range destService.Spec.Ports {
 		//handling relevant protocols from here:
 		// https://istio.io/latest/docs/ops/configuration/traffic-management/protocol-selection/#manual-protocol-selection
@@ -76,26 +89,102 @@ func getMeshPortsHelper(meshPorts string, destService *k8sV1.Service, clusterNam
 		if servicePort.TargetPort.IntVal != 0 {
 			targetPort = uint32(servicePort.TargetPort.IntVal)
 		}
-		if _, ok := meshPortMap[targetPort]; ok {
-			var protocol = GetPortProtocol(servicePort.Name)
-			log.Debugf(LogFormat, "GetMeshPorts", servicePort.Port, destService.Name, clusterName, "Adding mesh port for protocol: " + protocol)
-			ports[protocol] = uint32(servicePort.Port)
-			break
+		if order, ok := meshPortOrder[targetPort]; ok {
+			matchedPorts = append(matchedPorts, meshPort{order: order, protocol: getServicePortProtocol(destService, servicePort), port: uint32(servicePort.Port)})
+		}
+	}
+	sort.SliceStable(matchedPorts, func(i, j int) bool {
+		if matchedPorts[i].order != matchedPorts[j].order {
+			return matchedPorts[i].order < matchedPorts[j].order
+		}
+		return matchedPorts[i].port < matchedPorts[j].port
+	})
+	for i, matchedPort := range matchedPorts {
+		//the primary port is named after its protocol, the additional ones after their protocol and port number
+		var name = matchedPort.protocol
+		if i > 0 {
+			name = matchedPort.protocol + common.Dash + strconv.Itoa(int(matchedPort.port))
 		}
+		log.Debugf(LogFormat, "GetMeshPorts", matchedPort.port, destService.Name, clusterName, "Adding mesh port: "+name)
+		ports[name] = matchedPort.port
 	}
 	return ports
 }
 
+type meshPort struct {
+	order    int
+	protocol string
+	port     uint32
+}
+
+//Returns the service entry ports for the mesh ports of a workload
+//The primary mesh port is exposed on the default service entry port and the additional ones on their own port number
+func getServiceEntryPorts(meshPorts map[string]uint32) []*networking.Port {
+	if len(meshPorts) == 0 {
+		return []*networking.Port{{Number: uint32(common.DefaultServiceEntryPort), Name: common.Http, Protocol: common.Http}}
+	}
+	var sePorts = make([]*networking.Port, 0, len(meshPorts))
+	for name, port := range meshPorts {
+		var protocol = GetPortProtocol(name)
+		var number = uint32(common.DefaultServiceEntryPort)
+		if name != protocol {
+			number = port
+			if number == uint32(common.DefaultServiceEntryPort) {
+				log.Warnf(LogFormat, "GetServiceEntryPorts", "port", name, "", "skipping additional mesh port that clashes with the default service entry port")
+				continue
+			}
+		}
+		sePorts = append(sePorts, &networking.Port{Number: number, Name: name, Protocol: protocol})
+	}
+	sort.Slice(sePorts, func(i, j int) bool {
+		return sePorts[i].Number < sePorts[j].Number
+	})
+	return sePorts
+}
+
+//protocols supported by istio for manual protocol selection, protocols sharing a prefix are listed longest first
+var portProtocols = []string{common.GrpcWeb, common.Grpc, common.Http2, common.Https, common.Http,
+	common.Tcp, common.Tls, common.Mongo, common.Mysql, common.Redis}
+
+//Returns the protocol of a service port, set with the admiral.io/app-protocols annotation of the service or else based on the
+//prefix of the port name. The annotation stands in for the appProtocol field of the service ports, which the k8s api admiral
+//is built with doesn't have. Ex: admiral.io/app-protocols: "db=tcp,5443=tls" maps ports by name or number
+func getServicePortProtocol(service *k8sV1.Service, servicePort k8sV1.ServicePort) string {
+	for _, appProtocol := range strings.Split(service.Annotations[common.AppProtocolsAnnotation], ",") {
+		portProtocol := strings.SplitN(strings.TrimSpace(appProtocol), "=", 2)
+		if len(portProtocol) != 2 || (portProtocol[0] != servicePort.Name && portProtocol[0] != strconv.Itoa(int(servicePort.Port))) {
+			continue
+		}
+		protocol := strings.ToLower(strings.TrimSpace(portProtocol[1]))
+		for _, knownProtocol := range portProtocols {
+			if protocol == knownProtocol {
+				return protocol
+			}
+		}
+		log.Warnf(LogFormat, "GetPortProtocol", "service", service.Name, "", "ignoring unknown app protocol="+protocol+" for port="+portProtocol[0])
+	}
+	return GetPortProtocol(servicePort.Name)
+}
+
+//Returns true for the protocols istio proxies as opaque tcp, without looking into the requests
+func isOpaqueTcpProtocol(protocol string) bool {
+	switch protocol {
+	case common.Tcp, common.Tls, common.Https, common.Mongo, common.Mysql, common.Redis:
+		return true
+	}
+	return false
+}
+
+//Returns the protocol of a service port based on the prefix of its name, defaults to http
+//https://istio.io/latest/docs/ops/configuration/traffic-management/protocol-selection/#manual-protocol-selection
 func GetPortProtocol(name string) string {
-	var protocol = common.Http
-	if strings.Index(name, common.GrpcWeb) == 0 {
-		protocol = common.GrpcWeb
-	} else if strings.Index(name, common.Grpc) == 0 {
-		protocol = common.Grpc
-	} else if strings.Index(name, common.Http2) == 0 {
-		protocol = common.Http2
-	}
-	return protocol
+	name = strings.ToLower(name)
+	for _, protocol := range portProtocols {
+		if strings.Index(name, protocol) == 0 {
+			return protocol
+		}
+	}
+	return common.Http
 }
 
 func GetServiceEntryStateFromConfigmap(configmap *k8sV1.ConfigMap) *ServiceEntryAddressStore {
@@ -129,6 +218,53 @@ func ValidateConfigmapBeforePutting(cm *k8sV1.ConfigMap) error {
 	return nil
 }
 
+//Returns true until the informers of all the controllers have synced, the caches are incomplete until then
 func IsCacheWarmupTime(remoteRegistry *RemoteRegistry) bool {
-	return time.Since(remoteRegistry.StartTime) < common.GetAdmiralParams().CacheRefreshDuration
+	return !remoteRegistry.HasSynced()
+}
+
+//Runs the cleanup once the cache warm up is over, right away if it already is. The cleanups deferred during the warm up are run by
+//the readiness watcher when Admiral becomes ready, or as soon as the informers have synced when the watcher isn't started
+func runAfterCacheWarmup(remoteRegistry *RemoteRegistry, cleanup func()) {
+	if IsCacheWarmupTime(remoteRegistry) {
+		remoteRegistry.deferredMutex.Lock()
+		remoteRegistry.deferred = append(remoteRegistry.deferred, cleanup)
+		startWaiter := !remoteRegistry.readinessWatched && !remoteRegistry.deferredWaiting
+		remoteRegistry.deferredWaiting = remoteRegistry.deferredWaiting || startWaiter
+		remoteRegistry.deferredMutex.Unlock()
+		if startWaiter {
+			go waitForCacheWarmup(remoteRegistry)
+		}
+		return
+	}
+	cleanup()
+}
+
+//Runs the deferred cleanups once the informers have synced, used when no readiness watcher drains them
+func waitForCacheWarmup(remoteRegistry *RemoteRegistry) {
+	done := context.Background().Done()
+	if remoteRegistry.ctx != nil {
+		done = remoteRegistry.ctx.Done()
+	}
+	_ = wait.PollImmediateUntil(deferredCleanupPollInterval, func() (bool, error) {
+		return remoteRegistry.HasSynced(), nil
+	}, done)
+	remoteRegistry.deferredMutex.Lock()
+	remoteRegistry.deferredWaiting = false
+	remoteRegistry.deferredMutex.Unlock()
+	runDeferredCleanups(remoteRegistry)
+}
+
+//Runs the cleanups deferred during the cache warm up in the order they were requested, does nothing until the warm up is over
+func runDeferredCleanups(remoteRegistry *RemoteRegistry) {
+	if IsCacheWarmupTime(remoteRegistry) {
+		return
+	}
+	remoteRegistry.deferredMutex.Lock()
+	deferred := remoteRegistry.deferred
+	remoteRegistry.deferred = nil
+	remoteRegistry.deferredMutex.Unlock()
+	for _, cleanup := range deferred {
+		cleanup()
+	}
 }
@@ -4,6 +4,7 @@ import (
 	"errors"
 	argo "github.com/argoproj/argo-rollouts/pkg/apis/rollouts/v1alpha1"
 	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/common"
+	networking "istio.io/api/networking/v1alpha3"
 	k8sAppsV1 "k8s.io/api/apps/v1"
 	coreV1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -137,14 +138,24 @@ func TestGetMeshPorts(t *testing.T) {
 			expected: emptyPorts,
 		},
 		{
-			name:       "should return a http port if the port name doesn't start with a protocol name",
+			name:       "should return all the mesh ports, naming the additional ones after their port",
+			service:    k8sV1.Service{
+				ObjectMeta: v1.ObjectMeta{Name: "server", Labels: map[string]string{"asset": "Intuit.platform.mesh.server"}},
+				Spec:       k8sV1.ServiceSpec{Ports: []k8sV1.ServicePort{{Name: "grpc", Port: int32(annotatedSecondPort)},
+					{Name: "http", Port: int32(annotatedPort)}, defaultK8sSvcPort}},
+			},
+			deployment: deploymentWithMultipleMeshPorts,
+			expected:   map[string]uint32{"http": uint32(annotatedPort), "grpc-8091": uint32(annotatedSecondPort)},
+		},
+		{
+			name:       "should name ports with the same protocol uniquely",
 			service:    k8sV1.Service{
 				ObjectMeta: v1.ObjectMeta{Name: "server", Labels: map[string]string{"asset": "Intuit.platform.mesh.server"}},
 				Spec:       k8sV1.ServiceSpec{Ports: []k8sV1.ServicePort{{Name: "http", Port: int32(annotatedPort)},
-					{Name: "grpc", Port: int32(annotatedSecondPort)}}},
+					{Name: "http-admin", Port: int32(annotatedSecondPort)}}},
 			},
 			deployment: deploymentWithMultipleMeshPorts,
-			expected:   ports,
+			expected:   map[string]uint32{"http": uint32(annotatedPort), "http-8091": uint32(annotatedSecondPort)},
 		},
 	}
 
@@ -158,6 +169,102 @@ func TestGetMeshPorts(t *testing.T) {
 	}
 }
 
+func TestGetPortProtocol(t *testing.T) {
+
+	testCases := []struct {
+		portName string
+		expected string
+	}{
+		{portName: "", expected: "http"},
+		{portName: "hello-grpc", expected: "http"},
+		{portName: "http-admin", expected: "http"},
+		{portName: "HTTP2", expected: "http2"},
+		{portName: "https-web", expected: "https"},
+		{portName: "grpc", expected: "grpc"},
+		{portName: "grpc-web", expected: "grpc-web"},
+		{portName: "tcp-db", expected: "tcp"},
+		{portName: "tls", expected: "tls"},
+		{portName: "mongo", expected: "mongo"},
+		{portName: "mysql-primary", expected: "mysql"},
+		{portName: "redis", expected: "redis"},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.portName, func(t *testing.T) {
+			protocol := GetPortProtocol(c.portName)
+			if protocol != c.expected {
+				t.Errorf("Wanted protocol: %v, got: %v", c.expected, protocol)
+			}
+		})
+	}
+}
+
+func TestGetServicePortProtocol(t *testing.T) {
+
+	service := &k8sV1.Service{ObjectMeta: v1.ObjectMeta{Name: "db", Annotations: map[string]string{common.AppProtocolsAnnotation: "db=TCP, 5443=tls,admin=unknown"}}}
+
+	testCases := []struct {
+		name        string
+		servicePort k8sV1.ServicePort
+		expected    string
+	}{
+		{name: "Should use the app protocol of the port name", servicePort: k8sV1.ServicePort{Name: "db", Port: 5432}, expected: "tcp"},
+		{name: "Should use the app protocol of the port number", servicePort: k8sV1.ServicePort{Name: "secure", Port: 5443}, expected: "tls"},
+		{name: "Should ignore unknown app protocols", servicePort: k8sV1.ServicePort{Name: "admin", Port: 8080}, expected: "http"},
+		{name: "Should fall back to the port name", servicePort: k8sV1.ServicePort{Name: "grpc-api", Port: 9090}, expected: "grpc"},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			protocol := getServicePortProtocol(service, c.servicePort)
+			if protocol != c.expected {
+				t.Errorf("Wanted protocol: %v, got: %v", c.expected, protocol)
+			}
+		})
+	}
+}
+
+func TestGetServiceEntryPorts(t *testing.T) {
+
+	testCases := []struct {
+		name      string
+		meshPorts map[string]uint32
+		expected  []*networking.Port
+	}{
+		{
+			name:      "should default to a http port",
+			meshPorts: map[string]uint32{},
+			expected:  []*networking.Port{{Number: 80, Name: "http", Protocol: "http"}},
+		},
+		{
+			name:      "should expose the primary port on the default port and the additional ones on their own port",
+			meshPorts: map[string]uint32{"grpc-8091": 8091, "http": 8090, "http-9000": 9000},
+			expected: []*networking.Port{{Number: 80, Name: "http", Protocol: "http"},
+				{Number: 8091, Name: "grpc-8091", Protocol: "grpc"}, {Number: 9000, Name: "http-9000", Protocol: "http"}},
+		},
+		{
+			name:      "should skip an additional port clashing with the default port",
+			meshPorts: map[string]uint32{"grpc-web": 8090, "http-80": 80},
+			expected:  []*networking.Port{{Number: 80, Name: "grpc-web", Protocol: "grpc-web"}},
+		},
+		{
+			name:      "should expose tcp ports with the tcp protocol",
+			meshPorts: map[string]uint32{"tcp": 5432, "tls-9443": 9443},
+			expected: []*networking.Port{{Number: 80, Name: "tcp", Protocol: "tcp"},
+				{Number: 9443, Name: "tls-9443", Protocol: "tls"}},
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			sePorts := getServiceEntryPorts(c.meshPorts)
+			if !reflect.DeepEqual(sePorts, c.expected) {
+				t.Errorf("Wanted se ports: %v, got: %v", c.expected, sePorts)
+			}
+		})
+	}
+}
+
 func TestValidateConfigmapBeforePutting(t *testing.T) {
 
 	legalStore := ServiceEntryAddressStore{
@@ -0,0 +1,133 @@
+package clusters
+
+import (
+	"strings"
+
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/common"
+	networking "istio.io/api/networking/v1alpha3"
+)
+
+//Returns the cname of the workload behind a local host of a virtual service in the namespace, e.g. greeting, greeting.ns or
+//greeting.ns.svc.cluster.local, or an empty string if the host isn't the one of a service of a workload known to Admiral
+func getCnameForLocalHost(rc *RemoteController, cache *AdmiralCache, host string, namespace string) string {
+	if rc == nil || rc.ServiceController == nil || cache.CnameIdentityCache == nil {
+		return ""
+	}
+	if strings.HasSuffix(host, common.Sep+common.GetHostnameSuffix()) {
+		return ""
+	}
+	name, serviceNamespace := getLocalHostService(host, namespace)
+	if len(name) == 0 {
+		return ""
+	}
+	//only the workloads of the namespace of the service are looked up, only the cnames Admiral generated service entries for can be
+	//routed to from the other clusters
+	for identity := range getIdentitiesInNamespace(rc, serviceNamespace) {
+		cname := getCnameForIdentityService(rc, identity, name, serviceNamespace)
+		if len(cname) == 0 {
+			continue
+		}
+		if cnameIdentity, ok := cache.CnameIdentityCache.Load(cname); ok && cnameIdentity == identity {
+			return cname
+		}
+	}
+	return ""
+}
+
+//Returns the cname of the workload of the identity in the namespace selected by the service, or an empty string if there's none
+func getCnameForIdentityService(rc *RemoteController, identity string, name string, namespace string) string {
+	if rc.DeploymentController != nil {
+		if entry := rc.DeploymentController.Cache.Get(identity); entry != nil {
+			for _, deployment := range entry.Deployments {
+				if deployment.Namespace != namespace {
+					continue
+				}
+				if service := getServiceForDeployment(rc, deployment); service != nil && service.Name == name {
+					return common.GetCname(deployment, common.GetWorkloadIdentifier(), common.GetHostnameSuffix())
+				}
+			}
+		}
+	}
+	if rc.RolloutController != nil {
+		if entry := rc.RolloutController.Cache.Get(identity); entry != nil {
+			for _, rollout := range entry.Rollouts {
+				if rollout.Namespace != namespace {
+					continue
+				}
+				for _, weightedService := range getServiceForRollout(rc, rollout) {
+					if weightedService.Service.Name == name {
+						return common.GetCnameForRollout(rollout, common.GetWorkloadIdentifier(), common.GetHostnameSuffix())
+					}
+				}
+			}
+		}
+	}
+	if rc.StatefulSetController != nil {
+		if entry := rc.StatefulSetController.Cache.Get(identity); entry != nil {
+			for _, statefulSet := range entry.StatefulSets {
+				if statefulSet.Namespace != namespace {
+					continue
+				}
+				if service := getServiceForStatefulSet(rc, statefulSet); service != nil && service.Name == name {
+					return common.GetCnameForStatefulSet(statefulSet, common.GetWorkloadIdentifier(), common.GetHostnameSuffix())
+				}
+			}
+		}
+	}
+	return ""
+}
+
+//Returns the name and namespace of the service of a local host, the short names are in the namespace of the virtual service
+func getLocalHostService(host string, namespace string) (string, string) {
+	host = strings.TrimSuffix(strings.TrimSuffix(host, common.DotLocalDomainSuffix), ".svc")
+	parts := strings.Split(host, common.Sep)
+	switch len(parts) {
+	case 1:
+		return parts[0], namespace
+	case 2:
+		return parts[0], parts[1]
+	}
+	return "", ""
+}
+
+//Returns the destinations of every route of the virtual service, including the mirror targets
+func getVirtualServiceDestinations(virtualService *networking.VirtualService) []*networking.Destination {
+	destinations := make([]*networking.Destination, 0)
+	for _, httpRoute := range virtualService.Http {
+		for _, route := range httpRoute.Route {
+			destinations = append(destinations, route.Destination)
+		}
+		if httpRoute.Mirror != nil {
+			destinations = append(destinations, httpRoute.Mirror)
+		}
+	}
+	for _, tcpRoute := range virtualService.Tcp {
+		for _, route := range tcpRoute.Route {
+			destinations = append(destinations, route.Destination)
+		}
+	}
+	for _, tlsRoute := range virtualService.Tls {
+		for _, route := range tlsRoute.Route {
+			destinations = append(destinations, route.Destination)
+		}
+	}
+	return destinations
+}
+
+//Points the local destinations of the virtual service at their cnames, the local destinations of workloads unknown to Admiral fall back
+//to the first host of the virtual service, the cnames are keyed by the local hosts. The subsets are routed to through their own hosts
+func rewriteVirtualServiceDestinations(virtualService *networking.VirtualService, cnames map[string]string, cache *AdmiralCache) {
+	for _, destination := range getVirtualServiceDestinations(virtualService) {
+		if destination == nil {
+			continue
+		}
+		cname, ok := cnames[destination.Host]
+		if !ok && strings.HasSuffix(destination.Host, common.DotLocalDomainSuffix) && len(virtualService.Hosts) > 0 {
+			cname, ok = virtualService.Hosts[0], true
+		}
+		if ok {
+			destination.Host = cname
+		}
+		useSubsetHosts(cache, destination.Host, destination)
+	}
+}
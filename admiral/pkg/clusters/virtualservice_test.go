@@ -0,0 +1,178 @@
+package clusters
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/admiral"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/common"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/istio"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/test"
+	istionetworkingv1alpha3 "istio.io/api/networking/v1alpha3"
+	"istio.io/client-go/pkg/apis/networking/v1alpha3"
+	istiofake "istio.io/client-go/pkg/clientset/versioned/fake"
+	k8sAppsV1 "k8s.io/api/apps/v1"
+	k8sV1 "k8s.io/api/core/v1"
+	v12 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+)
+
+func newVirtualServiceTestRegistry(t *testing.T) (*RemoteRegistry, string) {
+	config := rest.Config{Host: "localhost"}
+	serviceController, err := admiral.NewServiceController("cluster1", make(chan struct{}), &test.MockServiceHandler{}, &config, time.Minute)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	deploymentController, err := admiral.NewDeploymentController("cluster1", make(chan struct{}), &test.MockDeploymentHandler{}, &config, time.Minute)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	serviceController.Cache.Put(&k8sV1.Service{
+		ObjectMeta: v12.ObjectMeta{Name: "greeting", Namespace: "greeting-ns"},
+		Spec: k8sV1.ServiceSpec{
+			Selector: map[string]string{"app": "greeting"},
+			Ports:    []k8sV1.ServicePort{{Name: "http", Port: 80}},
+		},
+	})
+	deployment := &k8sAppsV1.Deployment{
+		ObjectMeta: v12.ObjectMeta{Name: "greeting", Namespace: "greeting-ns"},
+		Spec: k8sAppsV1.DeploymentSpec{
+			Selector: &v12.LabelSelector{MatchLabels: map[string]string{"app": "greeting"}},
+			Template: k8sV1.PodTemplateSpec{ObjectMeta: v12.ObjectMeta{
+				Labels: map[string]string{"app": "greeting", common.GetWorkloadIdentifier(): "greeting", common.Env: "stage"},
+			}},
+		},
+	}
+	deploymentController.Cache.UpdateDeploymentToClusterCache("greeting", deployment)
+	cname := common.GetCname(deployment, common.GetWorkloadIdentifier(), common.GetHostnameSuffix())
+
+	rr := &RemoteRegistry{
+		RemoteControllers: map[string]*RemoteController{
+			"cluster1": {ClusterID: "cluster1", ServiceController: serviceController, DeploymentController: deploymentController},
+		},
+		AdmiralCache: &AdmiralCache{
+			CnameDependentClusterCache: common.NewMapOfMaps(),
+			CnameIdentityCache:         &sync.Map{},
+			CnameSubsetCache:           common.NewMapOfMaps(),
+		},
+		StartTime: time.Now(),
+	}
+	for _, clusterID := range []string{"cluster2", "cluster3"} {
+		rr.RemoteControllers[clusterID] = &RemoteController{
+			ClusterID:                clusterID,
+			VirtualServiceController: &istio.VirtualServiceController{IstioClient: istiofake.NewSimpleClientset()},
+		}
+	}
+	rr.AdmiralCache.CnameIdentityCache.Store(cname, "greeting")
+	return rr, cname
+}
+
+func TestGetCnameForLocalHost(t *testing.T) {
+	rr, cname := newVirtualServiceTestRegistry(t)
+	rc := rr.RemoteControllers["cluster1"]
+
+	testCases := []struct {
+		name      string
+		host      string
+		namespace string
+		expected  string
+	}{
+		{name: "Given the short name of the service, should return its cname", host: "greeting", namespace: "greeting-ns", expected: cname},
+		{name: "Given the name and namespace of the service, should return its cname", host: "greeting.greeting-ns", namespace: "other-ns", expected: cname},
+		{name: "Given the fqdn of the service, should return its cname", host: "greeting.greeting-ns.svc.cluster.local", namespace: "other-ns", expected: cname},
+		{name: "Given the short name of the service in another namespace, should return nothing", host: "greeting", namespace: "other-ns", expected: ""},
+		{name: "Given a cname, should return nothing", host: cname, namespace: "greeting-ns", expected: ""},
+		{name: "Given an unknown service, should return nothing", host: "unknown.greeting-ns.svc.cluster.local", namespace: "greeting-ns", expected: ""},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			if result := getCnameForLocalHost(rc, rr.AdmiralCache, c.host, c.namespace); result != c.expected {
+				t.Errorf("Expected %v, got %v", c.expected, result)
+			}
+		})
+	}
+}
+
+func TestHandleVirtualServiceEventWithMultipleHosts(t *testing.T) {
+	rr, cname := newVirtualServiceTestRegistry(t)
+	rr.AdmiralCache.CnameDependentClusterCache.Put(cname, "cluster2", "cluster2")
+	rr.AdmiralCache.CnameDependentClusterCache.Put("stage.other.global", "cluster2", "cluster2")
+	rr.AdmiralCache.CnameDependentClusterCache.Put("stage.other.global", "cluster3", "cluster3")
+	handler := &VirtualServiceHandler{ClusterID: "cluster1", RemoteRegistry: rr}
+
+	vs := &v1alpha3.VirtualService{
+		ObjectMeta: v12.ObjectMeta{Name: "greeting-vs", Namespace: "greeting-ns"},
+		Spec: istionetworkingv1alpha3.VirtualService{
+			Hosts: []string{"greeting", "stage.other.global"},
+			Http: []*istionetworkingv1alpha3.HTTPRoute{{
+				Route:  []*istionetworkingv1alpha3.HTTPRouteDestination{{Destination: &istionetworkingv1alpha3.Destination{Host: "greeting.greeting-ns.svc.cluster.local"}}},
+				Mirror: &istionetworkingv1alpha3.Destination{Host: "greeting"},
+			}},
+			Tcp: []*istionetworkingv1alpha3.TCPRoute{{
+				Route: []*istionetworkingv1alpha3.RouteDestination{{Destination: &istionetworkingv1alpha3.Destination{Host: "greeting"}}},
+			}},
+			Tls: []*istionetworkingv1alpha3.TLSRoute{{
+				Route: []*istionetworkingv1alpha3.RouteDestination{{Destination: &istionetworkingv1alpha3.Destination{Host: "stage.other.global"}}},
+			}},
+		},
+	}
+	if err := handleVirtualServiceEvent(vs, handler, common.Add, common.VirtualService); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	syncNamespace := common.GetSyncNamespace()
+	cluster2Vs, err := rr.RemoteControllers["cluster2"].VirtualServiceController.IstioClient.NetworkingV1alpha3().VirtualServices(syncNamespace).Get("greeting-vs", v12.GetOptions{})
+	if err != nil {
+		t.Fatalf("Expected the virtual service to be written to the dependent cluster, got %v", err)
+	}
+	if !reflect.DeepEqual(cluster2Vs.Spec.Hosts, []string{cname, "stage.other.global"}) {
+		t.Errorf("Expected the local host to be mapped to its cname, got %v", cluster2Vs.Spec.Hosts)
+	}
+	for _, destination := range []*istionetworkingv1alpha3.Destination{cluster2Vs.Spec.Http[0].Route[0].Destination, cluster2Vs.Spec.Http[0].Mirror, cluster2Vs.Spec.Tcp[0].Route[0].Destination} {
+		if destination.Host != cname {
+			t.Errorf("Expected the local destinations of every route to be mapped to their cname, got %v", destination.Host)
+		}
+	}
+	if cluster2Vs.Spec.Tls[0].Route[0].Destination.Host != "stage.other.global" {
+		t.Errorf("Expected the other destinations to be kept, got %v", cluster2Vs.Spec.Tls[0].Route[0].Destination.Host)
+	}
+
+	cluster3Vs, err := rr.RemoteControllers["cluster3"].VirtualServiceController.IstioClient.NetworkingV1alpha3().VirtualServices(syncNamespace).Get("greeting-vs", v12.GetOptions{})
+	if err != nil {
+		t.Fatalf("Expected the virtual service to be written to the dependent cluster, got %v", err)
+	}
+	if !reflect.DeepEqual(cluster3Vs.Spec.Hosts, []string{"stage.other.global"}) {
+		t.Errorf("Expected only the hosts the cluster depends on, got %v", cluster3Vs.Spec.Hosts)
+	}
+	if vs.Spec.Hosts[0] != "greeting" || vs.Spec.Http[0].Mirror.Host != "greeting" {
+		t.Errorf("Expected the virtual service of the source cluster not to be modified, got %v", vs.Spec)
+	}
+}
+
+func TestHandleVirtualServiceEventWithoutHosts(t *testing.T) {
+	rr, cname := newVirtualServiceTestRegistry(t)
+	rr.AdmiralCache.CnameDependentClusterCache.Put(cname, "cluster2", "cluster2")
+	handler := &VirtualServiceHandler{ClusterID: "cluster1", RemoteRegistry: rr}
+
+	vs := &v1alpha3.VirtualService{
+		ObjectMeta: v12.ObjectMeta{Name: "greeting-delegate", Namespace: "greeting-ns"},
+		Spec: istionetworkingv1alpha3.VirtualService{
+			Http: []*istionetworkingv1alpha3.HTTPRoute{{
+				Route: []*istionetworkingv1alpha3.HTTPRouteDestination{{Destination: &istionetworkingv1alpha3.Destination{Host: "greeting"}}},
+			}},
+		},
+	}
+	if err := handleVirtualServiceEvent(vs, handler, common.Add, common.VirtualService); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	syncNamespace := common.GetSyncNamespace()
+	for _, clusterID := range []string{"cluster2", "cluster3"} {
+		if _, err := rr.RemoteControllers[clusterID].VirtualServiceController.IstioClient.NetworkingV1alpha3().VirtualServices(syncNamespace).Get("greeting-delegate", v12.GetOptions{}); err == nil {
+			t.Errorf("Expected the virtual service without hosts not to be written to %v", clusterID)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package admiral
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/istio-ecosystem/admiral/admiral/pkg/apis/admiral/v1"
+	clientset "github.com/istio-ecosystem/admiral/admiral/pkg/client/clientset/versioned"
+	informerV1 "github.com/istio-ecosystem/admiral/admiral/pkg/client/informers/externalversions/admiral/v1"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/common"
+	log "github.com/sirupsen/logrus"
+	coordinationV1 "k8s.io/api/coordination/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+const addressLeasePrefix = "se-address-"
+
+//ErrAddressLeased is returned when the address is leased to another service entry, possibly by another admiral instance
+var ErrAddressLeased = errors.New("address is leased to another service entry")
+
+//AddressAllocationController keeps track of the AddressAllocation objects, each of them records the address of the ServiceEntry it is named after.
+//Every allocated address is also held by a Lease named after the address, so that admiral instances allocating concurrently
+//never hand out the same address
+type AddressAllocationController struct {
+	CrdClient  clientset.Interface
+	K8sClient  kubernetes.Interface
+	Namespace  string
+	Cache      *addressAllocationCache
+	informer   cache.SharedIndexInformer
+	controller *Controller
+}
+
+type addressAllocationCache struct {
+	//map of address allocations key=service entry name
+	cache map[string]*v1.AddressAllocation
+	mutex *sync.Mutex
+}
+
+func (a *addressAllocationCache) Put(allocation *v1.AddressAllocation) {
+	defer a.mutex.Unlock()
+	a.mutex.Lock()
+	a.cache[allocation.Name] = allocation
+}
+
+func (a *addressAllocationCache) Get(seName string) *v1.AddressAllocation {
+	defer a.mutex.Unlock()
+	a.mutex.Lock()
+	return a.cache[seName]
+}
+
+func (a *addressAllocationCache) Delete(seName string) {
+	defer a.mutex.Unlock()
+	a.mutex.Lock()
+	delete(a.cache, seName)
+}
+
+//fetch copies of all the address allocations
+func (a *addressAllocationCache) List() []*v1.AddressAllocation {
+	defer a.mutex.Unlock()
+	a.mutex.Lock()
+	allocations := make([]*v1.AddressAllocation, 0, len(a.cache))
+	for _, allocation := range a.cache {
+		allocations = append(allocations, allocation.DeepCopy())
+	}
+	return allocations
+}
+
+//fetch the allocated addresses
+func (a *addressAllocationCache) GetAddresses() []string {
+	defer a.mutex.Unlock()
+	a.mutex.Lock()
+	addresses := make([]string, 0, len(a.cache))
+	for _, allocation := range a.cache {
+		addresses = append(addresses, allocation.Spec.Address)
+	}
+	return addresses
+}
+
+func NewAddressAllocationController(stopCh <-chan struct{}, crdClient clientset.Interface, k8sClient kubernetes.Interface, namespace string, resyncPeriod time.Duration) *AddressAllocationController {
+
+	allocationController := AddressAllocationController{}
+	allocationController.CrdClient = crdClient
+	allocationController.K8sClient = k8sClient
+	allocationController.Namespace = namespace
+
+	allocationCache := addressAllocationCache{}
+	allocationCache.cache = make(map[string]*v1.AddressAllocation)
+	allocationCache.mutex = &sync.Mutex{}
+
+	allocationController.Cache = &allocationCache
+
+	allocationController.informer = informerV1.NewAddressAllocationInformer(
+		crdClient,
+		namespace,
+		resyncPeriod,
+		cache.Indexers{},
+	)
+
+	mcd := NewMonitoredDelegator(&allocationController, "primary", "addressallocation")
+	allocationController.controller = NewController("addressallocation-ctrl-"+namespace, stopCh, mcd, allocationController.informer)
+
+	return &allocationController
+}
+
+//HasSynced returns true once all the existing address allocations have been listed by the informer and processed
+func (c *AddressAllocationController) HasSynced() bool {
+	return c.controller.HasSynced()
+}
+
+func (c *AddressAllocationController) Added(obj interface{}) {
+	c.Cache.Put(obj.(*v1.AddressAllocation))
+}
+
+func (c *AddressAllocationController) Updated(obj interface{}, oldObj interface{}) {
+	c.Cache.Put(obj.(*v1.AddressAllocation))
+}
+
+func (c *AddressAllocationController) Deleted(obj interface{}) {
+	allocation, ok := obj.(*v1.AddressAllocation)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			return
+		}
+		if allocation, ok = tombstone.Obj.(*v1.AddressAllocation); !ok {
+			return
+		}
+	}
+	c.Cache.Delete(allocation.Name)
+}
+
+//Create leases the address and records it as the address of the service entry. The existing allocation is returned if the service
+//entry has one already, and ErrAddressLeased if the address is held by another service entry
+func (c *AddressAllocationController) Create(seName string, address string) (*v1.AddressAllocation, error) {
+	leased, err := c.acquireAddressLease(seName, address)
+	if err != nil {
+		return nil, err
+	}
+	if !leased {
+		return nil, ErrAddressLeased
+	}
+	allocation := &v1.AddressAllocation{
+		ObjectMeta: meta_v1.ObjectMeta{Name: seName, Namespace: c.Namespace},
+		Spec:       v1.AddressAllocationSpec{Address: address},
+	}
+	created, err := c.CrdClient.AdmiralV1().AddressAllocations(c.Namespace).Create(allocation)
+	if k8serrors.IsAlreadyExists(err) {
+		created, err = c.CrdClient.AdmiralV1().AddressAllocations(c.Namespace).Get(seName, meta_v1.GetOptions{})
+	}
+	if err != nil || created.Spec.Address != address {
+		//the service entry got an address from another admiral instance first
+		c.releaseAddressLease(seName, address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.Cache.Put(created)
+	return created, nil
+}
+
+//EnsureAddressLeases leases the addresses allocated before the leases were introduced, an address leased to another service entry is logged
+func (c *AddressAllocationController) EnsureAddressLeases() error {
+	leases, err := c.K8sClient.CoordinationV1().Leases(c.Namespace).List(meta_v1.ListOptions{LabelSelector: common.CreatedByAnnotation + "=" + common.Admiral})
+	if err != nil {
+		return err
+	}
+	leased := make(map[string]bool, len(leases.Items))
+	for _, lease := range leases.Items {
+		leased[lease.Name] = true
+	}
+	for _, allocation := range c.Cache.List() {
+		if leased[getAddressLeaseName(allocation.Spec.Address)] {
+			continue
+		}
+		ok, err := c.acquireAddressLease(allocation.Name, allocation.Spec.Address)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			log.Warnf("Address=%s of address allocation=%s is leased to another service entry", allocation.Spec.Address, allocation.Name)
+		}
+	}
+	return nil
+}
+
+func getAddressLeaseName(address string) string {
+	return addressLeasePrefix + strings.ReplaceAll(address, ".", "-")
+}
+
+//Takes the lease of the address for the service entry, returns false if another service entry holds it
+func (c *AddressAllocationController) acquireAddressLease(seName string, address string) (bool, error) {
+	holder := seName
+	now := meta_v1.NewMicroTime(time.Now())
+	lease := &coordinationV1.Lease{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      getAddressLeaseName(address),
+			Namespace: c.Namespace,
+			Labels:    map[string]string{common.CreatedByAnnotation: common.Admiral},
+		},
+		Spec: coordinationV1.LeaseSpec{HolderIdentity: &holder, AcquireTime: &now},
+	}
+	_, err := c.K8sClient.CoordinationV1().Leases(c.Namespace).Create(lease)
+	if err == nil {
+		return true, nil
+	}
+	if !k8serrors.IsAlreadyExists(err) {
+		return false, err
+	}
+	existing, err := c.K8sClient.CoordinationV1().Leases(c.Namespace).Get(lease.Name, meta_v1.GetOptions{})
+	if err != nil {
+		return false, err
+	}
+	return existing.Spec.HolderIdentity != nil && *existing.Spec.HolderIdentity == seName, nil
+}
+
+//Releases the lease of the address if it is held by the service entry
+func (c *AddressAllocationController) releaseAddressLease(seName string, address string) {
+	leaseName := getAddressLeaseName(address)
+	lease, err := c.K8sClient.CoordinationV1().Leases(c.Namespace).Get(leaseName, meta_v1.GetOptions{})
+	if err != nil {
+		if !k8serrors.IsNotFound(err) {
+			log.Errorf("Failed to get lease=%s of address allocation=%s: %v", leaseName, seName, err)
+		}
+		return
+	}
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != seName {
+		return
+	}
+	err = c.K8sClient.CoordinationV1().Leases(c.Namespace).Delete(leaseName, &meta_v1.DeleteOptions{})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		log.Errorf("Failed to release lease=%s of address allocation=%s: %v", leaseName, seName, err)
+	}
+}
+
+//UpdateStatus updates the status of the address allocation, used to mark the address as unused
+func (c *AddressAllocationController) UpdateStatus(allocation *v1.AddressAllocation) (*v1.AddressAllocation, error) {
+	updated, err := c.CrdClient.AdmiralV1().AddressAllocations(c.Namespace).UpdateStatus(allocation)
+	if err != nil {
+		return nil, err
+	}
+	c.Cache.Put(updated)
+	return updated, nil
+}
+
+//Delete releases the address of the service entry
+func (c *AddressAllocationController) Delete(seName string) error {
+	allocation := c.Cache.Get(seName)
+	err := c.CrdClient.AdmiralV1().AddressAllocations(c.Namespace).Delete(seName, &meta_v1.DeleteOptions{})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return err
+	}
+	c.Cache.Delete(seName)
+	if allocation != nil {
+		c.releaseAddressLease(seName, allocation.Spec.Address)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package admiral
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/istio-ecosystem/admiral/admiral/pkg/apis/admiral/v1"
+	admiralFake "github.com/istio-ecosystem/admiral/admiral/pkg/client/clientset/versioned/fake"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sFake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestNewAddressAllocationController(t *testing.T) {
+	stop := make(chan struct{})
+	defer close(stop)
+
+	controller := NewAddressAllocationController(stop, admiralFake.NewSimpleClientset(), k8sFake.NewSimpleClientset(), "ns", time.Duration(1000))
+
+	if controller == nil {
+		t.Errorf("AddressAllocation controller should not be nil")
+	}
+}
+
+func TestAddressAllocationController_Create(t *testing.T) {
+	existing := &v1.AddressAllocation{
+		ObjectMeta: metaV1.ObjectMeta{Name: "a-se", Namespace: "ns"},
+		Spec:       v1.AddressAllocationSpec{Address: "240.0.10.1"},
+	}
+	controller := AddressAllocationController{
+		CrdClient: admiralFake.NewSimpleClientset(),
+		K8sClient: k8sFake.NewSimpleClientset(),
+		Namespace: "ns",
+		Cache:     &addressAllocationCache{cache: map[string]*v1.AddressAllocation{}, mutex: &sync.Mutex{}},
+	}
+	controller.CrdClient.AdmiralV1().AddressAllocations("ns").Create(existing)
+
+	testCases := []struct {
+		name            string
+		seName          string
+		address         string
+		expectedAddress string
+	}{
+		{
+			name:            "Expects a new allocation to be created with the given address",
+			seName:          "b-se",
+			address:         "240.0.10.2",
+			expectedAddress: "240.0.10.2",
+		},
+		{
+			name:            "Expects the existing allocation to be returned when the service entry has one already",
+			seName:          "a-se",
+			address:         "240.0.10.3",
+			expectedAddress: "240.0.10.1",
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			allocation, err := controller.Create(c.seName, c.address)
+			if err != nil {
+				t.Fatalf("Unexpected error %v", err)
+			}
+			if allocation.Spec.Address != c.expectedAddress {
+				t.Errorf("Expected address %v, got %v", c.expectedAddress, allocation.Spec.Address)
+			}
+			if cached := controller.Cache.Get(c.seName); cached == nil || cached.Spec.Address != c.expectedAddress {
+				t.Errorf("Expected the allocation to be cached with address %v, got %v", c.expectedAddress, cached)
+			}
+		})
+	}
+}
+
+func TestAddressAllocationController_CreateLeasedAddress(t *testing.T) {
+	controller := AddressAllocationController{
+		CrdClient: admiralFake.NewSimpleClientset(),
+		K8sClient: k8sFake.NewSimpleClientset(),
+		Namespace: "ns",
+		Cache:     &addressAllocationCache{cache: map[string]*v1.AddressAllocation{}, mutex: &sync.Mutex{}},
+	}
+	//another admiral instance leased the address to another service entry
+	leased, err := controller.acquireAddressLease("other-se", "240.0.10.1")
+	if err != nil || !leased {
+		t.Fatalf("Expected the address to be leased, got %v (err=%v)", leased, err)
+	}
+
+	if _, err := controller.Create("a-se", "240.0.10.1"); err != ErrAddressLeased {
+		t.Errorf("Expected the address to be refused, got %v", err)
+	}
+	if _, err := controller.CrdClient.AdmiralV1().AddressAllocations("ns").Get("a-se", metaV1.GetOptions{}); err == nil {
+		t.Errorf("Expected no allocation for the leased address")
+	}
+
+	if _, err := controller.Create("a-se", "240.0.10.2"); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	lease, err := controller.K8sClient.CoordinationV1().Leases("ns").Get("se-address-240-0-10-2", metaV1.GetOptions{})
+	if err != nil || *lease.Spec.HolderIdentity != "a-se" {
+		t.Fatalf("Expected the address to be leased to the service entry, got %v (err=%v)", lease, err)
+	}
+	if err := controller.Delete("a-se"); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if _, err := controller.K8sClient.CoordinationV1().Leases("ns").Get("se-address-240-0-10-2", metaV1.GetOptions{}); err == nil {
+		t.Errorf("Expected the lease to be released with the allocation")
+	}
+	if _, err := controller.K8sClient.CoordinationV1().Leases("ns").Get("se-address-240-0-10-1", metaV1.GetOptions{}); err != nil {
+		t.Errorf("Expected the lease of the other service entry to be kept, got %v", err)
+	}
+}
+
+func TestAddressAllocationController_Delete(t *testing.T) {
+	existing := &v1.AddressAllocation{
+		ObjectMeta: metaV1.ObjectMeta{Name: "a-se", Namespace: "ns"},
+		Spec:       v1.AddressAllocationSpec{Address: "240.0.10.1"},
+	}
+	controller := AddressAllocationController{
+		CrdClient: admiralFake.NewSimpleClientset(),
+		K8sClient: k8sFake.NewSimpleClientset(),
+		Namespace: "ns",
+		Cache:     &addressAllocationCache{cache: map[string]*v1.AddressAllocation{}, mutex: &sync.Mutex{}},
+	}
+	controller.CrdClient.AdmiralV1().AddressAllocations("ns").Create(existing)
+	controller.Cache.Put(existing)
+
+	if err := controller.Delete("a-se"); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	if controller.Cache.Get("a-se") != nil {
+		t.Errorf("Allocation should have been removed from the cache")
+	}
+	if _, err := controller.CrdClient.AdmiralV1().AddressAllocations("ns").Get("a-se", metaV1.GetOptions{}); err == nil {
+		t.Errorf("Allocation should have been deleted")
+	}
+	if err := controller.Delete("a-se"); err != nil {
+		t.Errorf("Deleting a missing allocation should not fail, got %v", err)
+	}
+}
+
+func TestAddressAllocationController_Deleted(t *testing.T) {
+	allocation := &v1.AddressAllocation{ObjectMeta: metaV1.ObjectMeta{Name: "a-se", Namespace: "ns"}}
+	controller := AddressAllocationController{
+		Cache: &addressAllocationCache{cache: map[string]*v1.AddressAllocation{}, mutex: &sync.Mutex{}},
+	}
+
+	testCases := []struct {
+		name string
+		obj  interface{}
+	}{
+		{
+			name: "Expects allocation to be deleted from the cache",
+			obj:  allocation,
+		},
+		{
+			name: "Expects allocation to be deleted from the cache when the final state is unknown",
+			obj:  cache.DeletedFinalStateUnknown{Key: "ns/a-se", Obj: allocation},
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			controller.Added(allocation)
+			controller.Deleted(c.obj)
+			if controller.Cache.Get("a-se") != nil {
+				t.Errorf("Allocation should have been removed from the cache")
+			}
+		})
+	}
+}
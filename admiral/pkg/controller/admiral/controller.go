@@ -3,6 +3,7 @@ package admiral
 import (
 	"fmt"
 	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/common"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/util"
 	log "github.com/sirupsen/logrus"
 	"time"
 
@@ -44,15 +45,18 @@ type Controller struct {
 	delegator Delegator
 	queue     workqueue.RateLimitingInterface
 	informer  cache.SharedIndexInformer
+	//the objects listed at startup that haven't been processed yet
+	initialSync *util.InitialSync
 }
 
-func NewController(name string, stopCh <-chan struct{}, delegator Delegator, informer cache.SharedIndexInformer) Controller {
+func NewController(name string, stopCh <-chan struct{}, delegator Delegator, informer cache.SharedIndexInformer) *Controller {
 
-	controller := Controller{
-		name:      name,
-		informer:  informer,
-		delegator: delegator,
-		queue:     workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+	controller := &Controller{
+		name:        name,
+		informer:    informer,
+		delegator:   delegator,
+		queue:       workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		initialSync: &util.InitialSync{},
 	}
 
 	controller.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
@@ -101,7 +105,10 @@ func (c *Controller) Run(stopCh <-chan struct{}) {
 		return
 	}
 
-	log.Infof("Informer caches synced for controller=%v, current keys=%v", c.name, c.informer.GetStore().ListKeys())
+	keys := c.informer.GetStore().ListKeys()
+	log.Infof("Informer caches synced for controller=%v, current keys=%v", c.name, keys)
+	//the workers aren't started yet, so none of the listed objects has been processed
+	c.initialSync.Listed(keys)
 
 	wait.Until(c.runWorker, 5 * time.Second, stopCh)
 }
@@ -127,15 +134,25 @@ func (c *Controller) processNextItem() bool {
 	} else if c.queue.NumRequeues(item) < maxRetries {
 		log.Errorf("Error processing %s (will retry): %v", item, err)
 		c.queue.AddRateLimited(item)
+		//the object is processed again, it isn't done yet
+		return true
 	} else {
 		log.Errorf("Error processing %s (giving up): %v", item, err)
 		c.queue.Forget(item)
 		utilruntime.HandleError(err)
 	}
 
+	c.initialSync.Processed(item.(InformerCacheObj).key)
+
 	return true
 }
 
+//HasSynced returns true once the objects the informer listed at startup have all been processed, the caches filled by the delegator
+//are complete from then on
+func (c *Controller) HasSynced() bool {
+	return c != nil && c.initialSync.HasSynced()
+}
+
 func (c *Controller) processItem(informerCacheObj InformerCacheObj) error {
 
 	if informerCacheObj.eventType == Delete {
@@ -3,6 +3,12 @@ package admiral
 import (
 	"github.com/stretchr/testify/assert"
 	"testing"
+
+	k8sV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sV1Informers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
 )
 
 func TestMonitoredDelegator_Added(t *testing.T) {
@@ -35,6 +41,46 @@ func TestMonitoredDelegator_Updated(t *testing.T) {
 	assert.True(t, td.UpdatedInvoked)
 }
 
+func TestController_HasSynced(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&k8sV1.Service{ObjectMeta: metaV1.ObjectMeta{Name: "a", Namespace: "ns"}},
+		&k8sV1.Service{ObjectMeta: metaV1.ObjectMeta{Name: "b", Namespace: "ns"}},
+	)
+	informer := k8sV1Informers.NewServiceInformer(client, "ns", 0, cache.Indexers{})
+	delegator := &blockingDelegator{release: make(chan struct{})}
+	stop := make(chan struct{})
+	defer close(stop)
+
+	controller := NewController("test", stop, delegator, informer)
+
+	//the informer syncs, but the listed services are not processed yet
+	assert.True(t, cache.WaitForCacheSync(stop, informer.HasSynced))
+	assert.False(t, controller.HasSynced())
+
+	close(delegator.release)
+	assert.True(t, cache.WaitForCacheSync(stop, controller.HasSynced))
+
+	var nilController *Controller
+	assert.False(t, nilController.HasSynced())
+}
+
+//blocks the processing of the events until it is released
+type blockingDelegator struct {
+	release chan struct{}
+}
+
+func (b *blockingDelegator) Added(obj interface{}) {
+	<-b.release
+}
+
+func (b *blockingDelegator) Updated(obj interface{}, oldObj interface{}) {
+	<-b.release
+}
+
+func (b *blockingDelegator) Deleted(obj interface{}) {
+	<-b.release
+}
+
 type TestDelegator struct {
 	AddedInvoked   bool
 	UpdatedInvoked bool
@@ -3,8 +3,10 @@ package admiral
 import (
 	"fmt"
 	"github.com/istio-ecosystem/admiral/admiral/pkg/apis/admiral/v1"
+	log "github.com/sirupsen/logrus"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
+	"reflect"
 	"sync"
 	"time"
 
@@ -25,6 +27,7 @@ type DependencyController struct {
 	DepHandler   DepHandler
 	Cache        *depCache
 	informer     cache.SharedIndexInformer
+	controller   *Controller
 }
 
 type depCache struct {
@@ -48,6 +51,19 @@ func (d *depCache) Get(identity string) *v1.Dependency {
 	return d.cache[identity]
 }
 
+//fetch copies of the dependency records with the given source identity
+func (d *depCache) GetBySource(source string) []*v1.Dependency {
+	defer d.mutex.Unlock()
+	d.mutex.Lock()
+	matchedDeps := make([]*v1.Dependency, 0)
+	for _, dep := range d.cache {
+		if dep.Spec.Source == source {
+			matchedDeps = append(matchedDeps, dep.DeepCopy())
+		}
+	}
+	return matchedDeps
+}
+
 func (d *depCache) Delete(dep *v1.Dependency) {
 	defer d.mutex.Unlock()
 	d.mutex.Lock()
@@ -85,11 +101,16 @@ func NewDependencyController(stopCh <-chan struct{}, handler DepHandler, configP
 	)
 
 	mcd := NewMonitoredDelegator(&depController, "primary", "dependency")
-	NewController("dependency-ctrl-"+namespace, stopCh, mcd, depController.informer)
+	depController.controller = NewController("dependency-ctrl-"+namespace, stopCh, mcd, depController.informer)
 
 	return &depController, nil
 }
 
+//HasSynced returns true once all the existing dependencies have been listed by the informer and processed
+func (d *DependencyController) HasSynced() bool {
+	return d.controller.HasSynced()
+}
+
 func (d *DependencyController) Added(ojb interface{}) {
 	dep := ojb.(*v1.Dependency)
 	d.Cache.Put(dep)
@@ -99,9 +120,21 @@ func (d *DependencyController) Added(ojb interface{}) {
 func (d *DependencyController) Updated(obj interface{}, oldObj interface{}) {
 	dep := obj.(*v1.Dependency)
 	d.Cache.Put(dep)
+	//admiral writes the outcome of every sync to the status, syncing again on it would loop
+	if oldDep, ok := oldObj.(*v1.Dependency); ok && isDependencyStatusOnlyUpdate(oldDep, dep) {
+		log.Debugf("Skipping status only update for dependency=%s in namespace=%s", dep.Name, dep.Namespace)
+		return
+	}
 	d.DepHandler.Updated(dep)
 }
 
+func isDependencyStatusOnlyUpdate(oldDep *v1.Dependency, newDep *v1.Dependency) bool {
+	return !reflect.DeepEqual(oldDep.Status, newDep.Status) &&
+		reflect.DeepEqual(oldDep.Spec, newDep.Spec) &&
+		reflect.DeepEqual(oldDep.Labels, newDep.Labels) &&
+		reflect.DeepEqual(oldDep.Annotations, newDep.Annotations)
+}
+
 func (d *DependencyController) Deleted(ojb interface{}) {
 	dep := ojb.(*v1.Dependency)
 	d.Cache.Delete(dep)
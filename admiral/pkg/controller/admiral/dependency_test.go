@@ -51,6 +51,14 @@ func TestDependencyAddUpdateAndDelete(t *testing.T) {
 		t.Errorf("dep update failed, expected: %v got %v", depObj, newDepObj)
 	}
 
+	if deps := dependencyController.Cache.GetBySource("webapp"); len(deps) != 1 || deps[0].Name != depName {
+		t.Errorf("dep lookup by source failed, expected: %v got %v", depName, deps)
+	}
+
+	if deps := dependencyController.Cache.GetBySource("greeting"); len(deps) != 0 {
+		t.Errorf("dep lookup by source failed, expected no records got %v", deps)
+	}
+
 	//test update
 	updatedDep := model.Dependency{IdentityLabel: "identity", Destinations: []string{"greeting", "payments", "newservice"}, Source: "webapp"}
 	updatedObj := makeK8sDependencyObj(depName, "namespace1", updatedDep)
@@ -62,6 +70,14 @@ func TestDependencyAddUpdateAndDelete(t *testing.T) {
 		t.Errorf("dep update failed, expected: %v got %v", updatedObj, updatedDepObj)
 	}
 
+	//the status written by admiral isn't synced again
+	statusObj := updatedDepObj.DeepCopy()
+	statusObj.Status = v1.DependencyStatus{State: v1.DependencyStateSynced, ClusterSynced: 1}
+	dependencyController.Updated(statusObj, updatedDepObj)
+	if handler.Updates != 1 {
+		t.Errorf("expected status only updates to be skipped, got %v updates handled", handler.Updates)
+	}
+
 	//test delete
 	dependencyController.Deleted(updatedDepObj)
 
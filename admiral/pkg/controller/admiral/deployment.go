@@ -32,6 +32,7 @@ type DeploymentController struct {
 	DeploymentHandler DeploymentHandler
 	Cache             *deploymentCache
 	informer          cache.SharedIndexInformer
+	controller        *Controller
 	labelSet          *common.LabelSet
 }
 
@@ -39,6 +40,8 @@ type deploymentCache struct {
 	//map of dependencies key=identity value array of onboarded identities
 	cache map[string]*DeploymentClusterEntry
 	mutex *sync.Mutex
+	//the identities of the deployments of every namespace
+	namespaces namespaceIndex
 }
 
 func (p *deploymentCache) getKey(deployment *k8sAppsV1.Deployment) string {
@@ -49,6 +52,42 @@ func (p *deploymentCache) Get(key string) *DeploymentClusterEntry {
 	return p.cache[key]
 }
 
+//fetch the envs of every identity in the cache
+func (p *deploymentCache) GetEnvsByIdentity() map[string][]string {
+	defer p.mutex.Unlock()
+	p.mutex.Lock()
+	envsByIdentity := make(map[string][]string, len(p.cache))
+	for identity, entry := range p.cache {
+		for env := range entry.Deployments {
+			envsByIdentity[identity] = append(envsByIdentity[identity], env)
+		}
+	}
+	return envsByIdentity
+}
+
+//GetIdentitiesInNamespace returns the sorted identities of the deployments of the namespace
+func (p *deploymentCache) GetIdentitiesInNamespace(namespace string) []string {
+	defer p.mutex.Unlock()
+	p.mutex.Lock()
+	return p.namespaces.get(namespace)
+}
+
+//GetDeploymentInNamespace returns the cached deployment with the name in the namespace, nil if there's none
+func (p *deploymentCache) GetDeploymentInNamespace(namespace string, name string) *k8sAppsV1.Deployment {
+	defer p.mutex.Unlock()
+	p.mutex.Lock()
+	for _, identity := range p.namespaces.get(namespace) {
+		if entry := p.cache[identity]; entry != nil {
+			for _, deployment := range entry.Deployments {
+				if deployment.Namespace == namespace && deployment.Name == name {
+					return deployment
+				}
+			}
+		}
+	}
+	return nil
+}
+
 func (p *deploymentCache) UpdateDeploymentToClusterCache(key string, deployment *k8sAppsV1.Deployment) {
 	defer p.mutex.Unlock()
 	p.mutex.Lock()
@@ -63,7 +102,11 @@ func (p *deploymentCache) UpdateDeploymentToClusterCache(key string, deployment
 		p.cache[v.Identity] = v
 	}
 	env := common.GetEnv(deployment)
+	if old := v.Deployments[env]; old != nil {
+		p.namespaces.remove(old.Namespace, key, env)
+	}
 	v.Deployments[env] = deployment
+	p.namespaces.add(deployment.Namespace, key, env)
 }
 
 func (p *deploymentCache) DeleteFromDeploymentClusterCache(key string, deployment *k8sAppsV1.Deployment) {
@@ -74,6 +117,9 @@ func (p *deploymentCache) DeleteFromDeploymentClusterCache(key string, deploymen
 
 	if v != nil {
 		env := common.GetEnv(deployment)
+		if old := v.Deployments[env]; old != nil {
+			p.namespaces.remove(old.Namespace, key, env)
+		}
 		delete(v.Deployments, env)
 	}
 }
@@ -104,7 +150,7 @@ func NewDeploymentController(clusterID string, stopCh <-chan struct{}, handler D
 	)
 
 	wc := NewMonitoredDelegator(&deploymentController, clusterID, "deployment")
-	NewController("deployment-ctrl-"+config.Host, stopCh, wc, deploymentController.informer)
+	deploymentController.controller = NewController("deployment-ctrl-"+config.Host, stopCh, wc, deploymentController.informer)
 
 	return &deploymentController, nil
 }
@@ -116,6 +162,11 @@ func NewDeploymentControllerWithLabelOverride(stopCh <-chan struct{}, handler De
 	return dc, err
 }
 
+//HasSynced returns true once all the existing deployments have been listed by the informer and processed
+func (d *DeploymentController) HasSynced() bool {
+	return d.controller.HasSynced()
+}
+
 func (d *DeploymentController) Added(obj interface{}) {
 	HandleAddUpdateDeployment(obj, d)
 }
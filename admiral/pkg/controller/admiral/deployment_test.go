@@ -9,6 +9,7 @@ import (
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/fake"
 	"k8s.io/client-go/tools/clientcmd"
+	"reflect"
 	"sort"
 	"sync"
 	"testing"
@@ -320,3 +321,40 @@ func TestDeploymentController_GetDeploymentBySelectorInNamespace(t *testing.T) {
 		})
 	}
 }
+
+func TestDeploymentCache_GetIdentitiesInNamespace(t *testing.T) {
+	cache := deploymentCache{
+		cache: map[string]*DeploymentClusterEntry{},
+		mutex: &sync.Mutex{},
+	}
+	newDeployment := func(name string, namespace string) *k8sAppsV1.Deployment {
+		return &k8sAppsV1.Deployment{ObjectMeta: v1.ObjectMeta{Name: name, Namespace: namespace}}
+	}
+
+	cache.UpdateDeploymentToClusterCache("orders", newDeployment("orders", "ns1"))
+	cache.UpdateDeploymentToClusterCache("carts", newDeployment("carts", "ns2"))
+	if identities := cache.GetIdentitiesInNamespace("ns1"); !reflect.DeepEqual(identities, []string{"orders"}) {
+		t.Errorf("Expected the identity of the deployment of the namespace, got %v", identities)
+	}
+
+	//the deployment is replaced by one in another namespace
+	cache.UpdateDeploymentToClusterCache("orders", newDeployment("orders", "ns2"))
+	if identities := cache.GetIdentitiesInNamespace("ns1"); len(identities) != 0 {
+		t.Errorf("Expected no identity left in the namespace the deployment moved from, got %v", identities)
+	}
+	if identities := cache.GetIdentitiesInNamespace("ns2"); !reflect.DeepEqual(identities, []string{"carts", "orders"}) {
+		t.Errorf("Expected the sorted identities of the namespace, got %v", identities)
+	}
+
+	if deployment := cache.GetDeploymentInNamespace("ns2", "orders"); deployment == nil || deployment.Namespace != "ns2" {
+		t.Errorf("Expected the deployment to be found by its namespace and name, got %v", deployment)
+	}
+	if deployment := cache.GetDeploymentInNamespace("ns1", "orders"); deployment != nil {
+		t.Errorf("Expected no deployment in the namespace it moved from, got %v", deployment)
+	}
+
+	cache.DeleteFromDeploymentClusterCache("orders", newDeployment("orders", "ns2"))
+	if identities := cache.GetIdentitiesInNamespace("ns2"); !reflect.DeepEqual(identities, []string{"carts"}) {
+		t.Errorf("Expected the identity of the deleted deployment to be removed, got %v", identities)
+	}
+}
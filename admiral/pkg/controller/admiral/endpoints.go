@@ -0,0 +1,130 @@
+package admiral
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/common"
+	k8sV1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sV1Informers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Handler interface contains the methods that are required
+type EndpointsHandler interface {
+	//called with the endpoints of a service every time its pods change
+	Updated(obj *k8sV1.Endpoints)
+}
+
+//EndpointsController watches the addresses of the pods behind the services, they are only needed to point the service entries of the
+//clusters on the same flat network straight at the pods
+type EndpointsController struct {
+	K8sClient        kubernetes.Interface
+	EndpointsHandler EndpointsHandler
+	Cache            *endpointsCache
+	informer         cache.SharedIndexInformer
+	controller       *Controller
+}
+
+type endpointsCache struct {
+	//map of endpoints key=namespace/name of the service
+	cache map[string]*k8sV1.Endpoints
+	mutex *sync.Mutex
+}
+
+func (e *endpointsCache) getKey(namespace string, name string) string {
+	return namespace + common.Slash + name
+}
+
+//Get returns the endpoints of the service, nil if there are none
+func (e *endpointsCache) Get(namespace string, name string) *k8sV1.Endpoints {
+	defer e.mutex.Unlock()
+	e.mutex.Lock()
+	return e.cache[e.getKey(namespace, name)]
+}
+
+func (e *endpointsCache) Put(endpoints *k8sV1.Endpoints) {
+	defer e.mutex.Unlock()
+	e.mutex.Lock()
+	e.cache[e.getKey(endpoints.Namespace, endpoints.Name)] = endpoints
+}
+
+func (e *endpointsCache) Delete(namespace string, name string) {
+	defer e.mutex.Unlock()
+	e.mutex.Lock()
+	delete(e.cache, e.getKey(namespace, name))
+}
+
+func NewEndpointsController(clusterID string, stopCh <-chan struct{}, handler EndpointsHandler, config *rest.Config, resyncPeriod time.Duration) (*EndpointsController, error) {
+
+	endpointsController := EndpointsController{}
+	endpointsController.EndpointsHandler = handler
+
+	endpointsCache := endpointsCache{}
+	endpointsCache.cache = make(map[string]*k8sV1.Endpoints)
+	endpointsCache.mutex = &sync.Mutex{}
+
+	endpointsController.Cache = &endpointsCache
+	var err error
+
+	endpointsController.K8sClient, err = K8sClientFromConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create endpoints controller k8s client: %v", err)
+	}
+
+	endpointsController.informer = k8sV1Informers.NewEndpointsInformer(
+		endpointsController.K8sClient,
+		meta_v1.NamespaceAll,
+		resyncPeriod,
+		cache.Indexers{},
+	)
+
+	mcd := NewMonitoredDelegator(&endpointsController, clusterID, "endpoints")
+	endpointsController.controller = NewController("endpoints-ctrl-"+config.Host, stopCh, mcd, endpointsController.informer)
+
+	return &endpointsController, nil
+}
+
+//HasSynced returns true once all the existing endpoints have been listed by the informer and processed
+func (e *EndpointsController) HasSynced() bool {
+	return e.controller.HasSynced()
+}
+
+func (e *EndpointsController) Added(obj interface{}) {
+	endpoints := obj.(*k8sV1.Endpoints)
+	e.Cache.Put(endpoints)
+	e.EndpointsHandler.Updated(endpoints)
+}
+
+func (e *EndpointsController) Updated(obj interface{}, oldObj interface{}) {
+	endpoints := obj.(*k8sV1.Endpoints)
+	e.Cache.Put(endpoints)
+	//the endpoints used for leader election are updated every few seconds without their addresses changing
+	if old, ok := oldObj.(*k8sV1.Endpoints); ok && reflect.DeepEqual(old.Subsets, endpoints.Subsets) {
+		return
+	}
+	e.EndpointsHandler.Updated(endpoints)
+}
+
+func (e *EndpointsController) Deleted(obj interface{}) {
+	var endpoints *k8sV1.Endpoints
+	switch deleted := obj.(type) {
+	case *k8sV1.Endpoints:
+		endpoints = deleted
+	case cache.DeletedFinalStateUnknown:
+		endpoints, _ = deleted.Obj.(*k8sV1.Endpoints)
+	}
+	if endpoints == nil {
+		return
+	}
+	e.Cache.Delete(endpoints.Namespace, endpoints.Name)
+	//the handler is called with no addresses left
+	endpoints = endpoints.DeepCopy()
+	endpoints.Subsets = nil
+	e.EndpointsHandler.Updated(endpoints)
+}
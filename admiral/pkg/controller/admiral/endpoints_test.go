@@ -0,0 +1,56 @@
+package admiral
+
+import (
+	"testing"
+
+	"github.com/istio-ecosystem/admiral/admiral/pkg/test"
+	k8sV1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+func TestEndpointsAddUpdateDelete(t *testing.T) {
+	config, err := clientcmd.BuildConfigFromFlags("", "../../test/resources/admins@fake-cluster.k8s.local")
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+	stop := make(chan struct{})
+	handler := test.MockEndpointsHandler{}
+
+	endpointsController, err := NewEndpointsController("", stop, &handler, config, 0)
+	if err != nil {
+		t.Fatalf("Unexpected err %v", err)
+	}
+
+	endpoints := &k8sV1.Endpoints{
+		ObjectMeta: v1.ObjectMeta{Name: "foo", Namespace: "ns"},
+		Subsets:    []k8sV1.EndpointSubset{{Addresses: []k8sV1.EndpointAddress{{IP: "10.0.0.1"}}, Ports: []k8sV1.EndpointPort{{Name: "http", Port: 8080}}}},
+	}
+	endpointsController.Added(endpoints)
+	if endpointsController.Cache.Get("ns", "foo") != endpoints || handler.Updates != 1 {
+		t.Errorf("Expected the endpoints to be cached and the handler called, got %v", handler.Updates)
+	}
+
+	resynced := endpoints.DeepCopy()
+	resynced.Annotations = map[string]string{"control-plane.alpha.kubernetes.io/leader": "{}"}
+	endpointsController.Updated(resynced, endpoints)
+	if endpointsController.Cache.Get("ns", "foo") != resynced || handler.Updates != 1 {
+		t.Errorf("Expected the handler not to be called when the addresses don't change, got %v", handler.Updates)
+	}
+
+	updated := resynced.DeepCopy()
+	updated.Subsets[0].Addresses = append(updated.Subsets[0].Addresses, k8sV1.EndpointAddress{IP: "10.0.0.2"})
+	endpointsController.Updated(updated, resynced)
+	if handler.Updates != 2 || handler.Obj != updated {
+		t.Errorf("Expected the handler to be called when the addresses change, got %v", handler.Updates)
+	}
+
+	endpointsController.Deleted(cache.DeletedFinalStateUnknown{Key: "ns/foo", Obj: updated})
+	if endpointsController.Cache.Get("ns", "foo") != nil {
+		t.Errorf("Expected the endpoints to be removed from the cache")
+	}
+	if handler.Updates != 3 || len(handler.Obj.Subsets) != 0 || len(updated.Subsets) != 1 {
+		t.Errorf("Expected the handler to be called with no addresses left, got %v", handler.Obj)
+	}
+}
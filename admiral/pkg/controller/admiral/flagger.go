@@ -0,0 +1,161 @@
+package admiral
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	flagger "github.com/istio-ecosystem/admiral/admiral/pkg/apis/flagger/v1beta1"
+	log "github.com/sirupsen/logrus"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Handler interface contains the methods that are required
+type FlaggerHandler interface {
+	Added(obj *flagger.Canary)
+	Updated(obj *flagger.Canary)
+	Deleted(obj *flagger.Canary)
+}
+
+type FlaggerController struct {
+	FlaggerHandler FlaggerHandler
+	Cache          *flaggerCanaryCache
+	informer       cache.SharedIndexInformer
+	controller     *Controller
+}
+
+type flaggerCanaryCache struct {
+	//map of canaries key=namespace value=canaries by the name of their target
+	cache map[string]map[string]*flagger.Canary
+	mutex *sync.Mutex
+}
+
+func (p *flaggerCanaryCache) Put(canary *flagger.Canary) {
+	defer p.mutex.Unlock()
+	p.mutex.Lock()
+	canaries := p.cache[canary.Namespace]
+	if canaries == nil {
+		canaries = make(map[string]*flagger.Canary)
+		p.cache[canary.Namespace] = canaries
+	}
+	canaries[canary.Spec.TargetRef.Name] = canary
+}
+
+func (p *flaggerCanaryCache) Get(namespace string, targetName string) *flagger.Canary {
+	defer p.mutex.Unlock()
+	p.mutex.Lock()
+	return p.cache[namespace][targetName]
+}
+
+//fetch the canaries of the namespace
+func (p *flaggerCanaryCache) List(namespace string) []*flagger.Canary {
+	defer p.mutex.Unlock()
+	p.mutex.Lock()
+	canaries := make([]*flagger.Canary, 0, len(p.cache[namespace]))
+	for _, canary := range p.cache[namespace] {
+		canaries = append(canaries, canary)
+	}
+	return canaries
+}
+
+func (p *flaggerCanaryCache) Delete(canary *flagger.Canary) {
+	defer p.mutex.Unlock()
+	p.mutex.Lock()
+	if canaries := p.cache[canary.Namespace]; canaries != nil && canaries[canary.Spec.TargetRef.Name] != nil &&
+		canaries[canary.Spec.TargetRef.Name].Name == canary.Name {
+		delete(canaries, canary.Spec.TargetRef.Name)
+	}
+}
+
+func NewFlaggerController(clusterID string, stopCh <-chan struct{}, handler FlaggerHandler, config *rest.Config, resyncPeriod time.Duration) (*FlaggerController, error) {
+
+	flaggerController := FlaggerController{}
+	flaggerController.FlaggerHandler = handler
+
+	canaryCache := flaggerCanaryCache{}
+	canaryCache.cache = make(map[string]map[string]*flagger.Canary)
+	canaryCache.mutex = &sync.Mutex{}
+
+	flaggerController.Cache = &canaryCache
+
+	//the canaries are watched with the dynamic client, so Admiral doesn't depend on the flagger api
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create flagger controller dynamic client: %v", err)
+	}
+
+	flaggerController.informer = dynamicinformer.NewFilteredDynamicInformer(
+		dynamicClient,
+		flagger.CanaryResource,
+		meta_v1.NamespaceAll,
+		resyncPeriod,
+		cache.Indexers{},
+		nil,
+	).Informer()
+
+	mcd := NewMonitoredDelegator(&flaggerController, clusterID, "flaggercanary")
+	flaggerController.controller = NewController("flagger-canary-ctrl-"+clusterID, stopCh, mcd, flaggerController.informer)
+	return &flaggerController, nil
+}
+
+//HasSynced returns true once all the existing canaries have been listed by the informer and processed
+func (f *FlaggerController) HasSynced() bool {
+	return f.controller.HasSynced()
+}
+
+func (f *FlaggerController) Added(obj interface{}) {
+	if canary := toFlaggerCanary(obj); canary != nil {
+		f.Cache.Put(canary)
+		f.FlaggerHandler.Added(canary)
+	}
+}
+
+func (f *FlaggerController) Updated(obj interface{}, oldObj interface{}) {
+	if canary := toFlaggerCanary(obj); canary != nil {
+		f.Cache.Put(canary)
+		f.FlaggerHandler.Updated(canary)
+	}
+}
+
+func (f *FlaggerController) Deleted(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	if canary := toFlaggerCanary(obj); canary != nil {
+		f.Cache.Delete(canary)
+		f.FlaggerHandler.Deleted(canary)
+	}
+}
+
+//GetCanaryForWorkload returns the canary of the workload, either its target or the primary copy Flagger made of it, nil if there's none
+func (f *FlaggerController) GetCanaryForWorkload(namespace string, name string) *flagger.Canary {
+	if canary := f.Cache.Get(namespace, name); canary != nil {
+		return canary
+	}
+	if strings.HasSuffix(name, flagger.PrimarySuffix) {
+		return f.Cache.Get(namespace, strings.TrimSuffix(name, flagger.PrimarySuffix))
+	}
+	return nil
+}
+
+//Converts a canary listed by the dynamic informer, returns nil if it isn't one
+func toFlaggerCanary(obj interface{}) *flagger.Canary {
+	object, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil
+	}
+	canary := &flagger.Canary{}
+	err := runtime.DefaultUnstructuredConverter.FromUnstructured(object.UnstructuredContent(), canary)
+	if err != nil {
+		log.Errorf("Failed to convert flagger canary %v in namespace %v, err: %v", object.GetName(), object.GetNamespace(), err)
+		return nil
+	}
+	return canary
+}
@@ -0,0 +1,109 @@
+package admiral
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	flagger "github.com/istio-ecosystem/admiral/admiral/pkg/apis/flagger/v1beta1"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/test"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+func newUnstructuredCanary(name string, target string, canaryWeight int64) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "flagger.app/v1beta1",
+		"kind":       "Canary",
+		"metadata":   map[string]interface{}{"name": name, "namespace": "ns"},
+		"spec": map[string]interface{}{
+			"targetRef": map[string]interface{}{"apiVersion": "apps/v1", "kind": "Deployment", "name": target},
+			"service":   map[string]interface{}{"port": int64(80)},
+			"analysis":  map[string]interface{}{"maxWeight": int64(50), "stepWeight": int64(10)},
+		},
+		"status": map[string]interface{}{"phase": "Progressing", "canaryWeight": canaryWeight},
+	}}
+}
+
+func TestNewFlaggerController(t *testing.T) {
+	config, err := clientcmd.BuildConfigFromFlags("", "../../test/resources/admins@fake-cluster.k8s.local")
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+	stop := make(chan struct{})
+	handler := test.MockFlaggerHandler{}
+
+	flaggerController, err := NewFlaggerController("test", stop, &handler, config, time.Duration(1000))
+
+	if err != nil {
+		t.Errorf("Unexpected err %v", err)
+	}
+
+	if flaggerController == nil {
+		t.Errorf("Flagger controller should never be nil without an error thrown")
+	}
+}
+
+func TestFlaggerController_Added(t *testing.T) {
+	handler := test.MockFlaggerHandler{}
+	flaggerController := FlaggerController{
+		FlaggerHandler: &handler,
+		Cache:          &flaggerCanaryCache{cache: map[string]map[string]*flagger.Canary{}, mutex: &sync.Mutex{}},
+	}
+
+	flaggerController.Added(newUnstructuredCanary("greeting", "greeting", 20))
+
+	canary := flaggerController.GetCanaryForWorkload("ns", "greeting")
+	if canary == nil || handler.Obj != canary {
+		t.Fatalf("Expected the canary to be cached and handled, got %v", canary)
+	}
+	if canary.Spec.TargetRef.Kind != flagger.DeploymentKind || canary.Spec.Service.Port != 80 || canary.Status.CanaryWeight != 20 {
+		t.Errorf("Expected the fields of the canary to be converted, got %v", canary)
+	}
+	if canary.GetServiceName() != "greeting" {
+		t.Errorf("Expected the apex service to be named after the target, got %v", canary.GetServiceName())
+	}
+	if flaggerController.GetCanaryForWorkload("ns", "greeting-primary") != canary {
+		t.Errorf("Expected the canary of the primary copy of the target")
+	}
+	if flaggerController.GetCanaryForWorkload("ns", "other") != nil || flaggerController.GetCanaryForWorkload("other-ns", "greeting") != nil {
+		t.Errorf("Expected no canary for the other workloads")
+	}
+
+	flaggerController.Updated(newUnstructuredCanary("greeting", "greeting", 30), nil)
+	if handler.Updates != 1 || flaggerController.GetCanaryForWorkload("ns", "greeting").Status.CanaryWeight != 30 {
+		t.Errorf("Expected the updated canary to be cached and handled")
+	}
+
+	//the objects the informer can't have listed are skipped
+	handler.Obj = nil
+	flaggerController.Added(&flagger.Canary{})
+	if handler.Obj != nil {
+		t.Errorf("Expected objects which aren't unstructured to be skipped")
+	}
+}
+
+func TestFlaggerController_Deleted(t *testing.T) {
+	handler := test.MockFlaggerHandler{}
+	flaggerController := FlaggerController{
+		FlaggerHandler: &handler,
+		Cache:          &flaggerCanaryCache{cache: map[string]map[string]*flagger.Canary{}, mutex: &sync.Mutex{}},
+	}
+	flaggerController.Added(newUnstructuredCanary("greeting", "greeting", 0))
+	flaggerController.Added(newUnstructuredCanary("other", "other", 0))
+
+	//another canary of the same target doesn't remove the cached one
+	flaggerController.Deleted(newUnstructuredCanary("renamed", "greeting", 0))
+	if flaggerController.GetCanaryForWorkload("ns", "greeting") == nil {
+		t.Errorf("Expected the canary to be kept")
+	}
+
+	flaggerController.Deleted(cache.DeletedFinalStateUnknown{Key: "ns/greeting", Obj: newUnstructuredCanary("greeting", "greeting", 0)})
+	if flaggerController.GetCanaryForWorkload("ns", "greeting") != nil || handler.Obj != nil {
+		t.Errorf("Expected the canary to be deleted from the cache")
+	}
+	if canaries := flaggerController.Cache.List("ns"); len(canaries) != 1 || canaries[0].Name != "other" {
+		t.Errorf("Expected the other canaries of the namespace to be kept, got %v", canaries)
+	}
+}
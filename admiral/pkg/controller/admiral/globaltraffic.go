@@ -6,8 +6,10 @@ import (
 	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/common"
 	"github.com/sirupsen/logrus"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
+	"reflect"
 	"sync"
 	"time"
 
@@ -23,10 +25,12 @@ type GlobalTrafficHandler interface {
 }
 
 type GlobalTrafficController struct {
+	K8sClient            kubernetes.Interface
 	CrdClient            clientset.Interface
 	GlobalTrafficHandler GlobalTrafficHandler
 	Cache                *gtpCache
 	informer             cache.SharedIndexInformer
+	controller           *Controller
 }
 
 type gtpCache struct {
@@ -109,6 +113,11 @@ func NewGlobalTrafficController(clusterID string, stopCh <-chan struct{}, handle
 
 	var err error
 
+	globalTrafficController.K8sClient, err = K8sClientFromConfig(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create global traffic controller k8s client: %v", err)
+	}
+
 	globalTrafficController.CrdClient, err = AdmiralCrdClientFromConfig(configPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create global traffic controller crd client: %v", err)
@@ -122,11 +131,16 @@ func NewGlobalTrafficController(clusterID string, stopCh <-chan struct{}, handle
 	)
 
 	mcd := NewMonitoredDelegator(&globalTrafficController, clusterID, "globaltrafficpolicy")
-	NewController("gtp-ctrl-"+configPath.Host, stopCh, mcd, globalTrafficController.informer)
+	globalTrafficController.controller = NewController("gtp-ctrl-"+configPath.Host, stopCh, mcd, globalTrafficController.informer)
 
 	return &globalTrafficController, nil
 }
 
+//HasSynced returns true once all the existing global traffic policies have been listed by the informer and processed
+func (d *GlobalTrafficController) HasSynced() bool {
+	return d.controller.HasSynced()
+}
+
 func (d *GlobalTrafficController) Added(ojb interface{}) {
 	gtp := ojb.(*v1.GlobalTrafficPolicy)
 	d.Cache.Put(gtp)
@@ -136,6 +150,11 @@ func (d *GlobalTrafficController) Added(ojb interface{}) {
 func (d *GlobalTrafficController) Updated(ojb interface{}, oldObj interface{}) {
 	gtp := ojb.(*v1.GlobalTrafficPolicy)
 	d.Cache.Put(gtp)
+	//status is written back by admiral after every sync, reprocessing those updates would loop forever
+	if oldGtp, ok := oldObj.(*v1.GlobalTrafficPolicy); ok && isStatusOnlyUpdate(oldGtp, gtp) {
+		logrus.Debugf("Skipping status only update for gtp=%s in namespace=%s", gtp.Name, gtp.Namespace)
+		return
+	}
 	d.GlobalTrafficHandler.Updated(gtp)
 }
 
@@ -144,3 +163,10 @@ func (d *GlobalTrafficController) Deleted(ojb interface{}) {
 	d.Cache.Delete(gtp)
 	d.GlobalTrafficHandler.Deleted(gtp)
 }
+
+func isStatusOnlyUpdate(oldGtp *v1.GlobalTrafficPolicy, newGtp *v1.GlobalTrafficPolicy) bool {
+	return !reflect.DeepEqual(oldGtp.Status, newGtp.Status) &&
+		reflect.DeepEqual(oldGtp.Spec, newGtp.Spec) &&
+		reflect.DeepEqual(oldGtp.Labels, newGtp.Labels) &&
+		reflect.DeepEqual(oldGtp.Annotations, newGtp.Annotations)
+}
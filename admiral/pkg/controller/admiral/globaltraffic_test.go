@@ -71,6 +71,16 @@ func TestGlobalTrafficAddUpdateDelete(t *testing.T) {
 		t.Errorf("Update should call the handler with the updated object")
 	}
 
+	statusUpdatedGtpObj := updatedGtpObj.DeepCopy()
+	statusUpdatedGtpObj.Status.State = v1.GtpStateActive
+	handler.Obj = nil
+
+	globalTrafficController.Updated(statusUpdatedGtpObj, updatedGtpObj)
+
+	if handler.Obj != nil {
+		t.Errorf("Status only update should not call the handler")
+	}
+
 	globalTrafficController.Deleted(updatedGtpObj)
 
 	if handler.Obj != nil {
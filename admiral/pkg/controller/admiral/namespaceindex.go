@@ -0,0 +1,49 @@
+package admiral
+
+import "sort"
+
+//namespaceIndex records the identities of the workloads of every namespace, the workload caches are keyed by identity. It isn't safe for
+//concurrent use, the caches update it under their own lock
+type namespaceIndex struct {
+	//key=namespace value=identity -> envs of its workloads in the namespace
+	namespaces map[string]map[string]map[string]bool
+}
+
+func (n *namespaceIndex) add(namespace string, identity string, env string) {
+	if n.namespaces == nil {
+		n.namespaces = make(map[string]map[string]map[string]bool)
+	}
+	identities := n.namespaces[namespace]
+	if identities == nil {
+		identities = make(map[string]map[string]bool)
+		n.namespaces[namespace] = identities
+	}
+	if identities[identity] == nil {
+		identities[identity] = make(map[string]bool)
+	}
+	identities[identity][env] = true
+}
+
+func (n *namespaceIndex) remove(namespace string, identity string, env string) {
+	identities := n.namespaces[namespace]
+	if identities == nil || identities[identity] == nil {
+		return
+	}
+	delete(identities[identity], env)
+	if len(identities[identity]) == 0 {
+		delete(identities, identity)
+	}
+	if len(identities) == 0 {
+		delete(n.namespaces, namespace)
+	}
+}
+
+//Returns the sorted identities of the workloads of the namespace
+func (n *namespaceIndex) get(namespace string) []string {
+	identities := make([]string, 0, len(n.namespaces[namespace]))
+	for identity := range n.namespaces[namespace] {
+		identities = append(identities, identity)
+	}
+	sort.Strings(identities)
+	return identities
+}
@@ -3,8 +3,11 @@ package admiral
 import (
 	"fmt"
 	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/common"
+	log "github.com/sirupsen/logrus"
 	k8sV1Informers "k8s.io/client-go/informers/core/v1"
 	"k8s.io/client-go/rest"
+	"strings"
+	"sync"
 
 	k8sV1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes"
@@ -13,17 +16,61 @@ import (
 
 // Handler interface contains the methods that are required
 type NodeHandler interface {
+	//called with the number of nodes per locality (region/zone/subzone) every time it changes
+	LocalitiesUpdated(localities map[string]int)
 }
 
 type NodeController struct {
 	K8sClient   kubernetes.Interface
 	NodeHandler NodeHandler
-	Locality    *Locality
-	informer    cache.SharedIndexInformer
+	//reassigned as the nodes change, read it with GetLocality
+	Locality   *Locality
+	informer   cache.SharedIndexInformer
+	controller *Controller
+	override   *Locality
+	nodes      map[string]string //node name -> locality
+	localities map[string]int    //locality -> number of nodes
+	mutex      sync.Mutex
 }
 
 type Locality struct {
-	Region string
+	Region  string
+	Zone    string
+	Subzone string
+}
+
+//Returns the locality in the istio format region/zone/subzone, the parts that are not set are left out
+func (l *Locality) String() string {
+	if l == nil || l.Region == "" {
+		return ""
+	}
+	if l.Zone == "" {
+		return l.Region
+	}
+	if l.Subzone == "" {
+		return l.Region + common.Slash + l.Zone
+	}
+	return l.Region + common.Slash + l.Zone + common.Slash + l.Subzone
+}
+
+//Parses a locality in the format region/zone/subzone, returns nil if it has no region
+func ParseLocality(locality string) *Locality {
+	parts := strings.SplitN(locality, common.Slash, 3)
+	if parts[0] == "" {
+		return nil
+	}
+	l := &Locality{Region: parts[0]}
+	if len(parts) > 1 {
+		l.Zone = parts[1]
+	}
+	if len(parts) > 2 {
+		l.Subzone = parts[2]
+	}
+	return l
+}
+
+func getNodeLocality(node *k8sV1.Node) *Locality {
+	return &Locality{Region: common.GetNodeLocality(node), Zone: common.GetNodeZone(node), Subzone: common.GetNodeSubzone(node)}
 }
 
 func NewNodeController(clusterID string, stopCh <-chan struct{}, handler NodeHandler, config *rest.Config) (*NodeController, error) {
@@ -31,6 +78,14 @@ func NewNodeController(clusterID string, stopCh <-chan struct{}, handler NodeHan
 	nodeController := NodeController{}
 	nodeController.NodeHandler = handler
 
+	if override := common.GetClusterLocality(clusterID); override != "" {
+		nodeController.override = ParseLocality(override)
+		if nodeController.override == nil {
+			log.Warnf("Ignoring locality override=%v for cluster=%v, it has no region", override, clusterID)
+		}
+		nodeController.Locality = nodeController.override
+	}
+
 	var err error
 
 	nodeController.K8sClient, err = K8sClientFromConfig(config)
@@ -45,22 +100,116 @@ func NewNodeController(clusterID string, stopCh <-chan struct{}, handler NodeHan
 	)
 
 	mcd := NewMonitoredDelegator(&nodeController, clusterID, "node")
-	NewController("node-ctrl-"+config.Host, stopCh, mcd, nodeController.informer)
+	nodeController.controller = NewController("node-ctrl-"+config.Host, stopCh, mcd, nodeController.informer)
 
 	return &nodeController, nil
 }
 
+//HasSynced returns true once all the existing nodes have been listed by the informer and processed
+func (p *NodeController) HasSynced() bool {
+	return p.controller.HasSynced()
+}
+
 func (p *NodeController) Added(obj interface{}) {
 	node := obj.(*k8sV1.Node)
-	if p.Locality == nil {
-		p.Locality = &Locality{Region: common.GetNodeLocality(node)}
-	}
+	p.updateLocalities(node.Name, getNodeLocality(node).String())
 }
 
 func (p *NodeController) Updated(obj interface{}, oldObj interface{}) {
-	//ignore
+	node := obj.(*k8sV1.Node)
+	p.updateLocalities(node.Name, getNodeLocality(node).String())
 }
 
 func (p *NodeController) Deleted(obj interface{}) {
-	//ignore
+	var name string
+	switch node := obj.(type) {
+	case *k8sV1.Node:
+		name = node.Name
+	case cache.DeletedFinalStateUnknown:
+		name = node.Key
+	default:
+		return
+	}
+	p.updateLocalities(name, "")
+}
+
+//Moves the node to the given locality (an empty locality removes it) and recomputes the locality of the cluster
+func (p *NodeController) updateLocalities(name string, locality string) {
+	p.mutex.Lock()
+	if p.nodes == nil {
+		p.nodes = make(map[string]string)
+		p.localities = make(map[string]int)
+	}
+	oldLocality, exists := p.nodes[name]
+	if (exists && oldLocality == locality) || (!exists && locality == "") {
+		p.mutex.Unlock()
+		return
+	}
+	if exists {
+		p.localities[oldLocality]--
+		if p.localities[oldLocality] <= 0 {
+			delete(p.localities, oldLocality)
+		}
+		delete(p.nodes, name)
+	}
+	if locality != "" {
+		p.nodes[name] = locality
+		p.localities[locality]++
+	}
+	if p.override == nil {
+		//the locality with the most nodes wins, the last known locality is kept once there are no nodes left
+		if clusterLocality := getMostCommonLocality(p.localities); clusterLocality != "" {
+			p.Locality = ParseLocality(clusterLocality)
+		}
+	}
+	localities := p.copyLocalities()
+	p.mutex.Unlock()
+
+	if p.NodeHandler != nil {
+		p.NodeHandler.LocalitiesUpdated(localities)
+	}
+}
+
+//Returns the locality with the highest node count, ties are broken by the lowest locality in lexicographical order
+func getMostCommonLocality(localities map[string]int) string {
+	var result string
+	var max int
+	for locality, count := range localities {
+		if count > max || (count == max && locality < result) {
+			result, max = locality, count
+		}
+	}
+	return result
+}
+
+//GetLocality returns the locality of the cluster, nil if it isn't known yet
+func (p *NodeController) GetLocality() *Locality {
+	if p == nil {
+		return nil
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.Locality
+}
+
+//GetNodeLocality returns the locality of the node in the istio format region/zone/subzone, empty if the node isn't known
+func (p *NodeController) GetNodeLocality(name string) string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.nodes[name]
+}
+
+//Returns the number of nodes per locality (region/zone/subzone) in the cluster
+func (p *NodeController) GetLocalities() map[string]int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.copyLocalities()
+}
+
+func (p *NodeController) copyLocalities() map[string]int {
+	localities := make(map[string]int, len(p.localities))
+	for k, v := range p.localities {
+		localities[k] = v
+	}
+	return localities
 }
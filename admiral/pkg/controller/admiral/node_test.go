@@ -1,10 +1,13 @@
 package admiral
 
 import (
+	"reflect"
+
 	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/common"
 	"github.com/istio-ecosystem/admiral/admiral/pkg/test"
 	k8sV1 "k8s.io/api/core/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
 	"testing"
 )
@@ -68,3 +71,77 @@ func TestNodeAddUpdateDelete(t *testing.T) {
 		t.Errorf("region expected %v, got: %v", region, locality.Region)
 	}
 }
+
+func TestNodeLocalities(t *testing.T) {
+	handler := test.MockNodeHandler{}
+	nodeController := &NodeController{NodeHandler: &handler}
+
+	newNode := func(name string, labels map[string]string) *k8sV1.Node {
+		return &k8sV1.Node{ObjectMeta: v1.ObjectMeta{Name: name, Labels: labels}}
+	}
+	westA := map[string]string{common.NodeTopologyRegionLabel: "us-west-2", common.NodeTopologyZoneLabel: "us-west-2a"}
+	westB := map[string]string{common.NodeRegionLabel: "us-west-2", common.NodeZoneLabel: "us-west-2b", common.NodeSubzoneLabel: "rack1"}
+
+	nodeController.Added(newNode("node1", westA))
+	nodeController.Added(newNode("node2", westB))
+	nodeController.Added(newNode("node3", westB))
+	nodeController.Added(newNode("node4", map[string]string{}))
+
+	expected := map[string]int{"us-west-2/us-west-2a": 1, "us-west-2/us-west-2b/rack1": 2}
+	if !reflect.DeepEqual(handler.Localities, expected) || !reflect.DeepEqual(nodeController.GetLocalities(), expected) {
+		t.Errorf("localities expected %v, got: %v", expected, handler.Localities)
+	}
+	if nodeController.GetLocality().String() != "us-west-2/us-west-2b/rack1" {
+		t.Errorf("locality expected the most common one, got: %v", nodeController.GetLocality())
+	}
+
+	nodeController.Updated(newNode("node3", westA), newNode("node3", westB))
+	expected = map[string]int{"us-west-2/us-west-2a": 2, "us-west-2/us-west-2b/rack1": 1}
+	if !reflect.DeepEqual(handler.Localities, expected) {
+		t.Errorf("localities expected %v, got: %v", expected, handler.Localities)
+	}
+	if nodeController.GetLocality().String() != "us-west-2/us-west-2a" {
+		t.Errorf("locality expected to follow the node update, got: %v", nodeController.GetLocality())
+	}
+
+	nodeController.Deleted(newNode("node1", westA))
+	nodeController.Deleted(cache.DeletedFinalStateUnknown{Key: "node3"})
+	nodeController.Deleted(newNode("node2", westB))
+	if len(handler.Localities) != 0 {
+		t.Errorf("localities expected to be empty, got: %v", handler.Localities)
+	}
+	if nodeController.GetLocality().String() != "us-west-2/us-west-2b/rack1" {
+		t.Errorf("locality expected to keep the last known value, got: %v", nodeController.GetLocality())
+	}
+
+	//an override takes precedence over the nodes
+	nodeController = &NodeController{NodeHandler: &handler, override: ParseLocality("us-east-2/us-east-2a")}
+	nodeController.Added(newNode("node1", westA))
+	if nodeController.Locality != nil {
+		t.Errorf("locality expected to be left to the override, got: %v", nodeController.Locality)
+	}
+}
+
+func TestParseLocality(t *testing.T) {
+	testCases := []struct {
+		locality string
+		expected *Locality
+	}{
+		{locality: "", expected: nil},
+		{locality: "/us-west-2a", expected: nil},
+		{locality: "us-west-2", expected: &Locality{Region: "us-west-2"}},
+		{locality: "us-west-2/us-west-2a", expected: &Locality{Region: "us-west-2", Zone: "us-west-2a"}},
+		{locality: "us-west-2/us-west-2a/rack1", expected: &Locality{Region: "us-west-2", Zone: "us-west-2a", Subzone: "rack1"}},
+	}
+	for _, c := range testCases {
+		t.Run(c.locality, func(t *testing.T) {
+			locality := ParseLocality(c.locality)
+			if !reflect.DeepEqual(locality, c.expected) {
+				t.Errorf("expected %v, got: %v", c.expected, locality)
+			}
+			if locality != nil && locality.String() != c.locality {
+				t.Errorf("expected %v, got: %v", c.locality, locality.String())
+			}
+		})
+	}
+}
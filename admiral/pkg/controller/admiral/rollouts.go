@@ -2,6 +2,7 @@ package admiral
 
 import (
 	"fmt"
+	"reflect"
 	"sync"
 	"time"
 
@@ -40,6 +41,7 @@ type RolloutController struct {
 	RolloutClient  argoprojv1alpha1.ArgoprojV1alpha1Interface
 	RolloutHandler RolloutHandler
 	informer       cache.SharedIndexInformer
+	controller     *Controller
 	Cache          *rolloutCache
 	labelSet       *common.LabelSet
 }
@@ -48,13 +50,23 @@ type rolloutCache struct {
 	//map of dependencies key=identity value array of onboarded identities
 	cache map[string]*RolloutClusterEntry
 	mutex *sync.Mutex
+	//the identities of the rollouts of every namespace
+	namespaces namespaceIndex
 }
 
 func (p *rolloutCache) Put(rolloutEntry *RolloutClusterEntry) {
 	defer p.mutex.Unlock()
 	p.mutex.Lock()
 
+	if old := p.cache[rolloutEntry.Identity]; old != nil {
+		for env, rollout := range old.Rollouts {
+			p.namespaces.remove(rollout.Namespace, old.Identity, env)
+		}
+	}
 	p.cache[rolloutEntry.Identity] = rolloutEntry
+	for env, rollout := range rolloutEntry.Rollouts {
+		p.namespaces.add(rollout.Namespace, rolloutEntry.Identity, env)
+	}
 }
 
 func (p *rolloutCache) getKey(rollout *argo.Rollout) string {
@@ -65,9 +77,34 @@ func (p *rolloutCache) Get(key string) *RolloutClusterEntry {
 	return p.cache[key]
 }
 
+//fetch the envs of every identity in the cache
+func (p *rolloutCache) GetEnvsByIdentity() map[string][]string {
+	defer p.mutex.Unlock()
+	p.mutex.Lock()
+	envsByIdentity := make(map[string][]string, len(p.cache))
+	for identity, entry := range p.cache {
+		for env := range entry.Rollouts {
+			envsByIdentity[identity] = append(envsByIdentity[identity], env)
+		}
+	}
+	return envsByIdentity
+}
+
+//GetIdentitiesInNamespace returns the sorted identities of the rollouts of the namespace
+func (p *rolloutCache) GetIdentitiesInNamespace(namespace string) []string {
+	defer p.mutex.Unlock()
+	p.mutex.Lock()
+	return p.namespaces.get(namespace)
+}
+
 func (p *rolloutCache) Delete(pod *RolloutClusterEntry) {
 	defer p.mutex.Unlock()
 	p.mutex.Lock()
+	if old := p.cache[pod.Identity]; old != nil {
+		for env, rollout := range old.Rollouts {
+			p.namespaces.remove(rollout.Namespace, old.Identity, env)
+		}
+	}
 	delete(p.cache, pod.Identity)
 }
 
@@ -85,7 +122,11 @@ func (p *rolloutCache) UpdateRolloutToClusterCache(key string, rollout *argo.Rol
 		p.cache[v.Identity] = v
 	}
 	env := common.GetEnvForRollout(rollout)
+	if old := v.Rollouts[env]; old != nil {
+		p.namespaces.remove(old.Namespace, key, env)
+	}
 	v.Rollouts[env] = rollout
+	p.namespaces.add(rollout.Namespace, key, env)
 }
 
 func (p *rolloutCache) DeleteFromRolloutToClusterCache(key string, rollout *argo.Rollout) {
@@ -96,6 +137,9 @@ func (p *rolloutCache) DeleteFromRolloutToClusterCache(key string, rollout *argo
 
 	if v != nil {
 		env := common.GetEnvForRollout(rollout)
+		if old := v.Rollouts[env]; old != nil {
+			p.namespaces.remove(old.Namespace, key, env)
+		}
 		delete(v.Rollouts, env)
 	}
 }
@@ -159,30 +203,68 @@ func NewRolloutsController(clusterID string, stopCh <-chan struct{}, handler Rol
 	roController.informer = argoRolloutsInformerFactory.Argoproj().V1alpha1().Rollouts().Informer()
 
 	mcd := NewMonitoredDelegator(&roController, clusterID, "rollout")
-	NewController("rollouts-ctrl-"+clusterID, stopCh, mcd, roController.informer)
+	roController.controller = NewController("rollouts-ctrl-"+clusterID, stopCh, mcd, roController.informer)
 	return &roController, nil
 }
 
+//HasSynced returns true once all the existing rollouts have been listed by the informer and processed
+func (roc *RolloutController) HasSynced() bool {
+	return roc.controller.HasSynced()
+}
+
 func (roc *RolloutController) Added(ojb interface{}) {
 	HandleAddUpdateRollout(ojb, roc)
 }
 
 func (roc *RolloutController) Updated(ojb interface{}, oldObj interface{}) {
-	HandleAddUpdateRollout(ojb, roc)
+	rollout := updateRolloutCache(ojb, roc)
+	if rollout == nil {
+		return
+	}
+	//argo updates the status of the rollouts all the time, e.g. on every replica that becomes ready, the cache keeps the latest status
+	if oldRollout, ok := oldObj.(*argo.Rollout); ok && !isRolloutRoutingUpdate(oldRollout, rollout) {
+		logrus.Debugf("Skipping update of rollout=%s in namespace=%s as nothing Admiral routes on changed", oldRollout.Name, oldRollout.Namespace)
+		return
+	}
+	roc.RolloutHandler.Updated(rollout)
+}
+
+//Returns true if the spec or metadata of the rollout changed, or the status fields its services are routed on: the selectors of the
+//active and preview services of a blue green rollout, or the step and replica sets that set the weights of a canary rollout
+func isRolloutRoutingUpdate(oldRollout *argo.Rollout, newRollout *argo.Rollout) bool {
+	oldStatus, newStatus := oldRollout.Status, newRollout.Status
+	return oldRollout.Generation != newRollout.Generation ||
+		!reflect.DeepEqual(oldRollout.Spec, newRollout.Spec) ||
+		!reflect.DeepEqual(oldRollout.Labels, newRollout.Labels) ||
+		!reflect.DeepEqual(oldRollout.Annotations, newRollout.Annotations) ||
+		oldStatus.BlueGreen.ActiveSelector != newStatus.BlueGreen.ActiveSelector ||
+		oldStatus.BlueGreen.PreviewSelector != newStatus.BlueGreen.PreviewSelector ||
+		!reflect.DeepEqual(oldStatus.CurrentStepIndex, newStatus.CurrentStepIndex) ||
+		oldStatus.CurrentPodHash != newStatus.CurrentPodHash ||
+		oldStatus.StableRS != newStatus.StableRS ||
+		oldStatus.Canary.StableRS != newStatus.Canary.StableRS
 }
 
 func HandleAddUpdateRollout(ojb interface{}, roc *RolloutController) {
+	if rollout := updateRolloutCache(ojb, roc); rollout != nil {
+		roc.RolloutHandler.Added(rollout)
+	}
+}
+
+//Caches the rollout, returns nil if it has no identity or is ignored
+func updateRolloutCache(ojb interface{}, roc *RolloutController) *argo.Rollout {
 	rollout := ojb.(*argo.Rollout)
 	key := roc.Cache.getKey(rollout)
-	if len(key) > 0 {
-		if !roc.shouldIgnoreBasedOnLabelsForRollout(rollout) {
-			roc.Cache.UpdateRolloutToClusterCache(key, rollout)
-			roc.RolloutHandler.Added(rollout)
-		} else {
-			roc.Cache.DeleteFromRolloutToClusterCache(key, rollout)
-			log.Debugf("ignoring rollout %v based on labels", rollout.Name)
-		}
+	if len(key) == 0 {
+		return nil
+	}
+	if roc.shouldIgnoreBasedOnLabelsForRollout(rollout) {
+		roc.Cache.DeleteFromRolloutToClusterCache(key, rollout)
+		log.Debugf("ignoring rollout %v based on labels", rollout.Name)
+		return nil
 	}
+	roc.Cache.UpdateRolloutToClusterCache(key, rollout)
+	return rollout
 }
 
 func (roc *RolloutController) Deleted(ojb interface{}) {
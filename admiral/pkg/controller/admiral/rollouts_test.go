@@ -134,6 +134,68 @@ func TestRolloutController_Added(t *testing.T) {
 	}
 }
 
+func TestRolloutController_Updated(t *testing.T) {
+	mdh := test.MockRolloutHandler{}
+	cache := rolloutCache{
+		cache: map[string]*RolloutClusterEntry{},
+		mutex: &sync.Mutex{},
+	}
+	labelset := common.LabelSet{
+		DeploymentAnnotation: "sidecar.istio.io/inject",
+		AdmiralIgnoreLabel:   "admiral-ignore",
+	}
+	depController := RolloutController{
+		RolloutHandler: &mdh,
+		Cache:          &cache,
+		labelSet:       &labelset,
+		K8sClient:      fake.NewSimpleClientset(),
+	}
+	rollout := argo.Rollout{}
+	rollout.Spec.Template.Labels = map[string]string{"identity": "id", "istio-injected": "true"}
+	rollout.Spec.Template.Annotations = map[string]string{"sidecar.istio.io/inject": "true"}
+	updatedRollout := rollout.DeepCopy()
+	updatedRollout.Spec.Strategy.Canary = &argo.CanaryStrategy{StableService: "stable", CanaryService: "canary"}
+
+	depController.Updated(updatedRollout, &rollout)
+	if mdh.Updates != 1 || mdh.Obj != updatedRollout {
+		t.Errorf("Expected the handler to be notified of the update, got %v updates", mdh.Updates)
+	}
+	if depController.Cache.cache["id"] == nil || depController.Cache.cache["id"].Rollouts[common.Default] != updatedRollout {
+		t.Errorf("Expected the updated rollout to be cached")
+	}
+
+	//the status updates that don't change the routing are cached without being handled
+	readyRollout := updatedRollout.DeepCopy()
+	readyRollout.Status.ReadyReplicas = 1
+	depController.Updated(readyRollout, updatedRollout)
+	if mdh.Updates != 1 || depController.Cache.cache["id"].Rollouts[common.Default] != readyRollout {
+		t.Errorf("Expected the update of the ready replicas to be cached and skipped, got %v updates", mdh.Updates)
+	}
+	stepIndex := int32(1)
+	steppedRollout := readyRollout.DeepCopy()
+	steppedRollout.Status.CurrentStepIndex = &stepIndex
+	depController.Updated(steppedRollout, readyRollout)
+	if mdh.Updates != 2 || depController.Cache.cache["id"].Rollouts[common.Default] != steppedRollout {
+		t.Errorf("Expected the update of the canary step to be handled, got %v updates", mdh.Updates)
+	}
+	promotedRollout := steppedRollout.DeepCopy()
+	promotedRollout.Status.BlueGreen.ActiveSelector = "hash"
+	depController.Updated(promotedRollout, steppedRollout)
+	if mdh.Updates != 3 {
+		t.Errorf("Expected the update of the active selector to be handled, got %v updates", mdh.Updates)
+	}
+
+	ignoredRollout := promotedRollout.DeepCopy()
+	ignoredRollout.Spec.Template.Labels["admiral-ignore"] = "true"
+	depController.Updated(ignoredRollout, promotedRollout)
+	if mdh.Updates != 3 {
+		t.Errorf("Expected the handler not to be notified of the update of an ignored rollout")
+	}
+	if len(depController.Cache.cache["id"].Rollouts) != 0 {
+		t.Errorf("Expected the ignored rollout to be removed from the cache")
+	}
+}
+
 func TestRolloutController_Deleted(t *testing.T) {
 	//Rollouts with the correct label are added to the cache
 	mdh := test.MockRolloutHandler{}
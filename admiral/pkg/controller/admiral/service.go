@@ -15,6 +15,7 @@ import (
 
 	k8sV1 "k8s.io/api/core/v1"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 )
@@ -36,6 +37,14 @@ type ServiceController struct {
 	ServiceHandler ServiceHandler
 	Cache          *serviceCache
 	informer       cache.SharedIndexInformer
+	controller     *Controller
+}
+
+//GatewayEndpoint is the address and port of an east west gateway the cross cluster traffic is sent to, and the network of the gateway
+type GatewayEndpoint struct {
+	Address string
+	Port    int
+	Network string
 }
 
 type serviceCache struct {
@@ -117,34 +126,67 @@ func (s *serviceCache) Delete(service *k8sV1.Service) {
 
 func (s *serviceCache) GetLoadBalancer(key string, namespace string) (string, int) {
 	var (
-		lb     = "dummy.admiral.global"
+		lb     = common.DummyGatewayAddress
 		lbPort = common.DefaultMtlsPort
 	)
 	services := s.Get(namespace)
 	if len(services) == 0 {
 		return lb, 0
 	}
-	for _, service := range services {
-		if service.Labels["app"] == key {
+	gateways := s.GetGateways(labels.SelectorFromSet(labels.Set{"app": key}), []string{namespace}, "")
+	if len(gateways) > 0 {
+		return gateways[0].Address, gateways[0].Port
+	}
+	return lb, lbPort
+}
+
+//GetGateways returns an endpoint for each service in the namespaces matching the selector that has a load balancer or external ip,
+//the newest services of each namespace first. The port named portName is used, or the 15443 port when the service has no such port
+func (s *serviceCache) GetGateways(selector labels.Selector, namespaces []string, portName string) []GatewayEndpoint {
+	gateways := make([]GatewayEndpoint, 0)
+	for _, namespace := range namespaces {
+		for _, service := range s.Get(namespace) {
+			if !selector.Matches(labels.Set(service.Labels)) {
+				continue
+			}
+			gatewayPort := getGatewayPort(service, portName)
+			gateway := GatewayEndpoint{Port: common.DefaultMtlsPort, Network: service.Labels[common.NetworkLabel]}
 			loadBalancerStatus := service.Status.LoadBalancer.Ingress
 			if len(loadBalancerStatus) > 0 {
 				if len(loadBalancerStatus[0].Hostname) > 0 {
-					return loadBalancerStatus[0].Hostname, common.DefaultMtlsPort
+					gateway.Address = loadBalancerStatus[0].Hostname
 				} else {
-					return loadBalancerStatus[0].IP, common.DefaultMtlsPort
+					gateway.Address = loadBalancerStatus[0].IP
+				}
+				if gatewayPort != nil {
+					gateway.Port = int(gatewayPort.Port)
 				}
 			} else if len(service.Spec.ExternalIPs) > 0 {
-				lb = service.Spec.ExternalIPs[0]
-				for _, port := range service.Spec.Ports {
-					if port.Port == common.DefaultMtlsPort {
-						lbPort = int(port.NodePort)
-						return lb, lbPort
-					}
+				gateway.Address = service.Spec.ExternalIPs[0]
+				if gatewayPort != nil {
+					gateway.Port = int(gatewayPort.NodePort)
 				}
 			}
+			if gateway.Address != "" {
+				gateways = append(gateways, gateway)
+			}
 		}
 	}
-	return lb, lbPort
+	return gateways
+}
+
+//Returns the port of the gateway service named portName, otherwise the 15443 port
+func getGatewayPort(service *k8sV1.Service, portName string) *k8sV1.ServicePort {
+	var mtlsPort *k8sV1.ServicePort
+	for i, port := range service.Spec.Ports {
+		if portName != "" && port.Name == portName {
+			return &service.Spec.Ports[i]
+		}
+		if port.Port == common.DefaultMtlsPort && mtlsPort == nil {
+			mtlsPort = &service.Spec.Ports[i]
+		}
+	}
+	return mtlsPort
 }
 
 func NewServiceController(clusterID string, stopCh <-chan struct{}, handler ServiceHandler, config *rest.Config, resyncPeriod time.Duration) (*ServiceController, error) {
@@ -177,11 +219,16 @@ func NewServiceController(clusterID string, stopCh <-chan struct{}, handler Serv
 	)
 
 	mcd := NewMonitoredDelegator(&serviceController, clusterID, "service")
-	NewController("service-ctrl-"+config.Host, stopCh, mcd, serviceController.informer)
+	serviceController.controller = NewController("service-ctrl-"+config.Host, stopCh, mcd, serviceController.informer)
 
 	return &serviceController, nil
 }
 
+//HasSynced returns true once all the existing services have been listed by the informer and processed
+func (s *ServiceController) HasSynced() bool {
+	return s.controller.HasSynced()
+}
+
 func (s *ServiceController) Added(obj interface{}) {
 	service := obj.(*k8sV1.Service)
 	s.Cache.Put(service)
@@ -12,6 +12,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	v1 "k8s.io/api/core/v1"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/util/uuid"
 	"k8s.io/client-go/tools/clientcmd"
@@ -254,6 +255,86 @@ func TestServiceCache_GetLoadBalancer(t *testing.T) {
 	}
 }
 
+func TestServiceCache_GetGateways(t *testing.T) {
+	sc := serviceCache{}
+	sc.cache = make(map[string]*ServiceClusterEntry)
+	sc.mutex = &sync.Mutex{}
+
+	newGateway := func(name string, namespace string, network string, created time.Time) *v1.Service {
+		service := &v1.Service{}
+		service.Name = name
+		service.Namespace = namespace
+		service.CreationTimestamp = metaV1.NewTime(created)
+		service.Labels = map[string]string{"istio": "eastwestgateway", common.NetworkLabel: network}
+		return service
+	}
+	now := time.Now()
+
+	network1 := newGateway("eastwest1", "gw1", "network1", now)
+	network1.Status.LoadBalancer.Ingress = []v1.LoadBalancerIngress{{Hostname: "network1.elb"}}
+	network1.Spec.Ports = []v1.ServicePort{{Name: "status-port", Port: 15021}, {Name: "tls", Port: 16443, NodePort: 31443}}
+
+	network1Old := newGateway("eastwest1-old", "gw1", "network1", now.Add(-time.Hour))
+	network1Old.Status.LoadBalancer.Ingress = []v1.LoadBalancerIngress{{IP: "1.2.3.4"}}
+
+	network2 := newGateway("eastwest2", "gw2", "network2", now)
+	network2.Spec.ExternalIPs = []string{"5.6.7.8"}
+	network2.Spec.Ports = []v1.ServicePort{{Name: "http", Port: common.DefaultMtlsPort, NodePort: 30800}}
+
+	pending := newGateway("eastwest-pending", "gw2", "network2", now.Add(-time.Hour))
+
+	ingress := newGateway("ingress", "gw2", "", now)
+	ingress.Labels = map[string]string{"app": "istio-ingressgateway"}
+	ingress.Status.LoadBalancer.Ingress = []v1.LoadBalancerIngress{{Hostname: "ingress.elb"}}
+
+	for _, service := range []*v1.Service{network1, network1Old, network2, pending, ingress} {
+		sc.Put(service)
+	}
+
+	selector, _ := labels.Parse("istio=eastwestgateway")
+	testCases := []struct {
+		name       string
+		namespaces []string
+		portName   string
+		expected   []GatewayEndpoint
+	}{
+		{
+			name:       "Given gateways in several namespaces, should return all of them with their network, newest first",
+			namespaces: []string{"gw1", "gw2"},
+			portName:   common.Tls,
+			expected: []GatewayEndpoint{
+				{Address: "network1.elb", Port: 16443, Network: "network1"},
+				{Address: "1.2.3.4", Port: common.DefaultMtlsPort, Network: "network1"},
+				{Address: "5.6.7.8", Port: 30800, Network: "network2"},
+			},
+		},
+		{
+			name:       "Given no port with the port name, should use the 15443 port",
+			namespaces: []string{"gw1"},
+			portName:   "mtls",
+			expected: []GatewayEndpoint{
+				{Address: "network1.elb", Port: common.DefaultMtlsPort, Network: "network1"},
+				{Address: "1.2.3.4", Port: common.DefaultMtlsPort, Network: "network1"},
+			},
+		},
+		{
+			name:       "Given no matching gateway, should return none",
+			namespaces: []string{"istio-system"},
+			portName:   common.Tls,
+			expected:   []GatewayEndpoint{},
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			gateways := sc.GetGateways(selector, c.namespaces, c.portName)
+			if !cmp.Equal(gateways, c.expected) {
+				t.Errorf("Unexpected gateways, diff %v", cmp.Diff(gateways, c.expected))
+			}
+		})
+	}
+}
+
 func TestConcurrentGetAndPut(t *testing.T) {
 	serviceCache := serviceCache{}
 	serviceCache.cache = make(map[string]*ServiceClusterEntry)
@@ -0,0 +1,230 @@
+package admiral
+
+import (
+	"fmt"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/common"
+	k8sAppsV1 "k8s.io/api/apps/v1"
+	k8sAppsinformers "k8s.io/client-go/informers/apps/v1"
+	"k8s.io/client-go/rest"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"sync"
+)
+
+// Handler interface contains the methods that are required
+type StatefulSetHandler interface {
+	Added(obj *k8sAppsV1.StatefulSet)
+	Deleted(obj *k8sAppsV1.StatefulSet)
+}
+
+type StatefulSetClusterEntry struct {
+	Identity     string
+	StatefulSets map[string]*k8sAppsV1.StatefulSet
+}
+
+type StatefulSetController struct {
+	K8sClient          kubernetes.Interface
+	StatefulSetHandler StatefulSetHandler
+	Cache              *statefulSetCache
+	informer           cache.SharedIndexInformer
+	controller         *Controller
+	labelSet           *common.LabelSet
+}
+
+type statefulSetCache struct {
+	//map of statefulsets key=identity value=statefulsets by env
+	cache map[string]*StatefulSetClusterEntry
+	mutex *sync.Mutex
+	//the identities of the statefulsets of every namespace
+	namespaces namespaceIndex
+}
+
+func (p *statefulSetCache) getKey(statefulSet *k8sAppsV1.StatefulSet) string {
+	return common.GetStatefulSetGlobalIdentifier(statefulSet)
+}
+
+func (p *statefulSetCache) Get(key string) *StatefulSetClusterEntry {
+	return p.cache[key]
+}
+
+//fetch the envs of every identity in the cache
+func (p *statefulSetCache) GetEnvsByIdentity() map[string][]string {
+	defer p.mutex.Unlock()
+	p.mutex.Lock()
+	envsByIdentity := make(map[string][]string, len(p.cache))
+	for identity, entry := range p.cache {
+		for env := range entry.StatefulSets {
+			envsByIdentity[identity] = append(envsByIdentity[identity], env)
+		}
+	}
+	return envsByIdentity
+}
+
+//GetIdentitiesInNamespace returns the sorted identities of the statefulsets of the namespace
+func (p *statefulSetCache) GetIdentitiesInNamespace(namespace string) []string {
+	defer p.mutex.Unlock()
+	p.mutex.Lock()
+	return p.namespaces.get(namespace)
+}
+
+func (p *statefulSetCache) UpdateStatefulSetToClusterCache(key string, statefulSet *k8sAppsV1.StatefulSet) {
+	defer p.mutex.Unlock()
+	p.mutex.Lock()
+
+	v := p.Get(key)
+
+	if v == nil {
+		v = &StatefulSetClusterEntry{
+			Identity:     key,
+			StatefulSets: make(map[string]*k8sAppsV1.StatefulSet),
+		}
+		p.cache[v.Identity] = v
+	}
+	env := common.GetEnvForStatefulSet(statefulSet)
+	if old := v.StatefulSets[env]; old != nil {
+		p.namespaces.remove(old.Namespace, key, env)
+	}
+	v.StatefulSets[env] = statefulSet
+	p.namespaces.add(statefulSet.Namespace, key, env)
+}
+
+func (p *statefulSetCache) DeleteFromStatefulSetClusterCache(key string, statefulSet *k8sAppsV1.StatefulSet) {
+	defer p.mutex.Unlock()
+	p.mutex.Lock()
+
+	v := p.Get(key)
+
+	if v != nil {
+		env := common.GetEnvForStatefulSet(statefulSet)
+		if old := v.StatefulSets[env]; old != nil {
+			p.namespaces.remove(old.Namespace, key, env)
+		}
+		delete(v.StatefulSets, env)
+	}
+}
+
+func NewStatefulSetController(clusterID string, stopCh <-chan struct{}, handler StatefulSetHandler, config *rest.Config, resyncPeriod time.Duration) (*StatefulSetController, error) {
+
+	statefulSetController := StatefulSetController{}
+	statefulSetController.StatefulSetHandler = handler
+	statefulSetController.labelSet = common.GetLabelSet()
+
+	statefulSetCache := statefulSetCache{}
+	statefulSetCache.cache = make(map[string]*StatefulSetClusterEntry)
+	statefulSetCache.mutex = &sync.Mutex{}
+
+	statefulSetController.Cache = &statefulSetCache
+	var err error
+
+	statefulSetController.K8sClient, err = K8sClientFromConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create statefulset controller k8s client: %v", err)
+	}
+
+	statefulSetController.informer = k8sAppsinformers.NewStatefulSetInformer(
+		statefulSetController.K8sClient,
+		meta_v1.NamespaceAll,
+		resyncPeriod,
+		cache.Indexers{},
+	)
+
+	wc := NewMonitoredDelegator(&statefulSetController, clusterID, "statefulset")
+	statefulSetController.controller = NewController("statefulset-ctrl-"+config.Host, stopCh, wc, statefulSetController.informer)
+
+	return &statefulSetController, nil
+}
+
+//HasSynced returns true once all the existing statefulsets have been listed by the informer and processed
+func (s *StatefulSetController) HasSynced() bool {
+	return s.controller.HasSynced()
+}
+
+func (s *StatefulSetController) Added(obj interface{}) {
+	HandleAddUpdateStatefulSet(obj, s)
+}
+
+func (s *StatefulSetController) Updated(obj interface{}, oldObj interface{}) {
+	HandleAddUpdateStatefulSet(obj, s)
+}
+
+func HandleAddUpdateStatefulSet(obj interface{}, s *StatefulSetController) {
+	statefulSet := obj.(*k8sAppsV1.StatefulSet)
+	key := s.Cache.getKey(statefulSet)
+	if len(key) > 0 {
+		if !s.shouldIgnoreBasedOnLabels(statefulSet) {
+			s.Cache.UpdateStatefulSetToClusterCache(key, statefulSet)
+			s.StatefulSetHandler.Added(statefulSet)
+		} else {
+			s.Cache.DeleteFromStatefulSetClusterCache(key, statefulSet)
+			log.Debugf("ignoring statefulset %v based on labels", statefulSet.Name)
+		}
+	}
+}
+
+func (s *StatefulSetController) Deleted(obj interface{}) {
+	statefulSet, ok := obj.(*k8sAppsV1.StatefulSet)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			return
+		}
+		if statefulSet, ok = tombstone.Obj.(*k8sAppsV1.StatefulSet); !ok {
+			return
+		}
+	}
+	key := s.Cache.getKey(statefulSet)
+	s.StatefulSetHandler.Deleted(statefulSet)
+	if len(key) > 0 {
+		s.Cache.DeleteFromStatefulSetClusterCache(key, statefulSet)
+	}
+}
+
+//Same semantics as for deployments, see DeploymentController.shouldIgnoreBasedOnLabels
+func (s *StatefulSetController) shouldIgnoreBasedOnLabels(statefulSet *k8sAppsV1.StatefulSet) bool {
+	if statefulSet.Spec.Template.Labels[s.labelSet.AdmiralIgnoreLabel] == "true" {
+		return true
+	}
+
+	if statefulSet.Spec.Template.Annotations[s.labelSet.DeploymentAnnotation] != "true" { //Not sidecar injected
+		return true
+	}
+
+	if statefulSet.Annotations[common.AdmiralIgnoreAnnotation] == "true" {
+		return true
+	}
+
+	ns, err := s.K8sClient.CoreV1().Namespaces().Get(statefulSet.Namespace, meta_v1.GetOptions{})
+	if err != nil {
+		log.Warnf("Failed to get namespace object for statefulset with namespace %v, err: %v", statefulSet.Namespace, err)
+		return false
+	}
+
+	if ns.Annotations[common.AdmiralIgnoreAnnotation] == "true" {
+		return true
+	}
+	return false
+}
+
+func (s *StatefulSetController) GetStatefulSetBySelectorInNamespace(serviceSelector map[string]string, namespace string) []k8sAppsV1.StatefulSet {
+
+	matchedStatefulSets, err := s.K8sClient.AppsV1().StatefulSets(namespace).List(meta_v1.ListOptions{})
+
+	if err != nil {
+		log.Errorf("Failed to list statefulsets in cluster, error: %v", err)
+		return nil
+	}
+
+	filteredStatefulSets := make([]k8sAppsV1.StatefulSet, 0)
+
+	for _, statefulSet := range matchedStatefulSets.Items {
+		if common.IsServiceMatch(serviceSelector, statefulSet.Spec.Selector) {
+			filteredStatefulSets = append(filteredStatefulSets, statefulSet)
+		}
+	}
+
+	return filteredStatefulSets
+}
@@ -0,0 +1,174 @@
+package admiral
+
+import (
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/common"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/test"
+	k8sAppsV1 "k8s.io/api/apps/v1"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStatefulSetController_Added(t *testing.T) {
+	mssh := test.MockStatefulSetHandler{}
+	labelset := common.LabelSet{
+		DeploymentAnnotation: "sidecar.istio.io/inject",
+		AdmiralIgnoreLabel:   "admiral-ignore",
+	}
+	ssController := StatefulSetController{
+		StatefulSetHandler: &mssh,
+		Cache:              &statefulSetCache{cache: map[string]*StatefulSetClusterEntry{}, mutex: &sync.Mutex{}},
+		labelSet:           &labelset,
+	}
+	statefulSet := k8sAppsV1.StatefulSet{}
+	statefulSet.Spec.Template.Labels = map[string]string{"identity": "id"}
+	statefulSet.Spec.Template.Annotations = map[string]string{"sidecar.istio.io/inject": "true"}
+	statefulSetNotInjected := k8sAppsV1.StatefulSet{}
+	statefulSetNotInjected.Spec.Template.Labels = map[string]string{"identity": "id"}
+	statefulSetWithIgnoreLabels := k8sAppsV1.StatefulSet{}
+	statefulSetWithIgnoreLabels.Spec.Template.Labels = map[string]string{"identity": "id", "admiral-ignore": "true"}
+	statefulSetWithIgnoreLabels.Spec.Template.Annotations = map[string]string{"sidecar.istio.io/inject": "true"}
+	statefulSetWithNsIgnoreAnnotations := k8sAppsV1.StatefulSet{}
+	statefulSetWithNsIgnoreAnnotations.Namespace = "test-ns"
+	statefulSetWithNsIgnoreAnnotations.Spec.Template.Labels = map[string]string{"identity": "id"}
+	statefulSetWithNsIgnoreAnnotations.Spec.Template.Annotations = map[string]string{"sidecar.istio.io/inject": "true"}
+
+	testCases := []struct {
+		name                string
+		statefulSet         *k8sAppsV1.StatefulSet
+		expectedStatefulSet *k8sAppsV1.StatefulSet
+		expectedHandlerObj  *k8sAppsV1.StatefulSet
+	}{
+		{
+			name:                "Expects statefulset to be added to the cache when it is sidecar injected",
+			statefulSet:         &statefulSet,
+			expectedStatefulSet: &statefulSet,
+			expectedHandlerObj:  &statefulSet,
+		},
+		{
+			name:        "Expects statefulset to not be added to the cache when it is not sidecar injected",
+			statefulSet: &statefulSetNotInjected,
+		},
+		{
+			name:        "Expects ignored statefulset identified by label to not be added to the cache",
+			statefulSet: &statefulSetWithIgnoreLabels,
+		},
+		{
+			name:        "Expects ignored statefulset identified by namespace annotation to not be added to the cache",
+			statefulSet: &statefulSetWithNsIgnoreAnnotations,
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			ssController.K8sClient = fake.NewSimpleClientset()
+			ns := coreV1.Namespace{}
+			ns.Name = "test-ns"
+			ns.Annotations = map[string]string{"admiral.io/ignore": "true"}
+			ssController.K8sClient.CoreV1().Namespaces().Create(&ns)
+			ssController.Cache.cache = map[string]*StatefulSetClusterEntry{}
+			mssh.Obj = nil
+
+			ssController.Added(c.statefulSet)
+
+			var cached *k8sAppsV1.StatefulSet
+			if entry := ssController.Cache.Get("id"); entry != nil {
+				cached = entry.StatefulSets[common.Default]
+			}
+			if cached != c.expectedStatefulSet {
+				t.Errorf("Unexpected statefulset in cache, got %v expected %v", cached, c.expectedStatefulSet)
+			}
+			if mssh.Obj != c.expectedHandlerObj {
+				t.Errorf("Unexpected statefulset passed to the handler, got %v expected %v", mssh.Obj, c.expectedHandlerObj)
+			}
+		})
+	}
+}
+
+func TestStatefulSetController_Deleted(t *testing.T) {
+	mssh := test.MockStatefulSetHandler{}
+	ssController := StatefulSetController{
+		StatefulSetHandler: &mssh,
+		Cache:              &statefulSetCache{cache: map[string]*StatefulSetClusterEntry{}, mutex: &sync.Mutex{}},
+	}
+	statefulSet := k8sAppsV1.StatefulSet{}
+	statefulSet.Spec.Template.Labels = map[string]string{"identity": "id"}
+
+	testCases := []struct {
+		name string
+		obj  interface{}
+	}{
+		{
+			name: "Expects statefulset to be deleted from the cache",
+			obj:  &statefulSet,
+		},
+		{
+			name: "Expects statefulset to be deleted from the cache when the final state is unknown",
+			obj:  cache.DeletedFinalStateUnknown{Key: "ns/ss", Obj: &statefulSet},
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			ssController.Cache.UpdateStatefulSetToClusterCache("id", &statefulSet)
+			mssh.Obj = &statefulSet
+
+			ssController.Deleted(c.obj)
+
+			if len(ssController.Cache.Get("id").StatefulSets) != 0 {
+				t.Errorf("Statefulset should have been removed from the cache")
+			}
+			if mssh.Obj != nil {
+				t.Errorf("Handler should have been called for the deleted statefulset")
+			}
+		})
+	}
+}
+
+func TestNewStatefulSetController(t *testing.T) {
+	config, err := clientcmd.BuildConfigFromFlags("", "../../test/resources/admins@fake-cluster.k8s.local")
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+	stop := make(chan struct{})
+	handler := test.MockStatefulSetHandler{}
+
+	ssCon, err := NewStatefulSetController("", stop, &handler, config, time.Duration(1000))
+
+	if ssCon == nil {
+		t.Errorf("StatefulSet controller should not be nil")
+	}
+}
+
+func TestStatefulSetController_GetStatefulSetBySelectorInNamespace(t *testing.T) {
+	statefulSet := k8sAppsV1.StatefulSet{}
+	statefulSet.Name = "ss1"
+	statefulSet.Namespace = "namespace"
+	statefulSet.Spec.Selector = &v1.LabelSelector{MatchLabels: map[string]string{"app": "test1"}}
+	statefulSet2 := k8sAppsV1.StatefulSet{}
+	statefulSet2.Name = "ss2"
+	statefulSet2.Namespace = "namespace"
+	statefulSet2.Spec.Selector = &v1.LabelSelector{MatchLabels: map[string]string{"app": "test2"}}
+	statefulSet3 := k8sAppsV1.StatefulSet{}
+	statefulSet3.Name = "ss3"
+	statefulSet3.Namespace = "other"
+	statefulSet3.Spec.Selector = &v1.LabelSelector{MatchLabels: map[string]string{"app": "test1"}}
+
+	ssController := StatefulSetController{K8sClient: fake.NewSimpleClientset()}
+	ssController.K8sClient.AppsV1().StatefulSets("namespace").Create(&statefulSet)
+	ssController.K8sClient.AppsV1().StatefulSets("namespace").Create(&statefulSet2)
+	ssController.K8sClient.AppsV1().StatefulSets("other").Create(&statefulSet3)
+
+	result := ssController.GetStatefulSetBySelectorInNamespace(map[string]string{"app": "test1"}, "namespace")
+	if len(result) != 1 || result[0].Name != "ss1" {
+		t.Errorf("Expected only statefulset ss1 to match, got %v", result)
+	}
+
+	result = ssController.GetStatefulSetBySelectorInNamespace(map[string]string{"app": "test3"}, "namespace")
+	if len(result) != 0 {
+		t.Errorf("Expected no statefulsets to match, got %v", result)
+	}
+}
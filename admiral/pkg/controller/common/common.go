@@ -19,7 +19,14 @@ const (
 	Grpc                          = "grpc"
 	GrpcWeb                       = "grpc-web"
 	Http2                         = "http2"
+	Https                         = "https"
+	Tcp                           = "tcp"
+	Tls                           = "tls"
+	Mongo                         = "mongo"
+	Mysql                         = "mysql"
+	Redis                         = "redis"
 	DefaultMtlsPort               = 15443
+	DummyGatewayAddress           = "dummy.admiral.global"
 	DefaultServiceEntryPort       = 80
 	Sep                           = "."
 	Dash                          = "-"
@@ -29,17 +36,56 @@ const (
 	MulticlusterIngressGateway    = "istio-multicluster-ingressgateway"
 	LocalAddressPrefix            = "240.0"
 	NodeRegionLabel               = "failure-domain.beta.kubernetes.io/region"
+	NodeZoneLabel                 = "failure-domain.beta.kubernetes.io/zone"
+	NodeTopologyRegionLabel       = "topology.kubernetes.io/region"
+	NodeTopologyZoneLabel         = "topology.kubernetes.io/zone"
+	NodeSubzoneLabel              = "topology.istio.io/subzone"
+	NetworkLabel                  = "topology.istio.io/network"
 	SpiffePrefix                  = "spiffe://"
 	SidecarEnabledPorts           = "traffic.sidecar.istio.io/includeInboundPorts"
 	Default                       = "default"
 	AdmiralIgnoreAnnotation       = "admiral.io/ignore"
 	AdmiralCnameCaseSensitive     = "admiral.io/cname-case-sensitive"
+	StatefulSetPodHostsAnnotation = "admiral.io/statefulset-pod-hosts"
+	FlatNetworkAnnotation         = "admiral.io/flat-network"
+	FlatNetworkModeAnnotation     = "admiral.io/flat-network-endpoints"
+	FlatNetworkPodEndpointLabel   = "admiral.io/flat-network-pod"
+	SidecarEgressHostsAnnotation  = "admiral.io/sidecar-egress-hosts"
+	AppProtocolsAnnotation        = "admiral.io/app-protocols"
 	BlueGreenRolloutPreviewPrefix = "preview"
+	CanaryRolloutPrefix           = "canary"
+	PreviewPrefixAnnotation       = "admiral.io/preview-hostname-prefix"
 	RolloutPodHashLabel           = "rollouts-pod-template-hash"
+	CreatedByAnnotation           = "app.kubernetes.io/created-by"
+	Admiral                       = "admiral"
 	RolloutActiveServiceSuffix	  = "active-service"
 	RolloutStableServiceSuffix	  = "stable-service"
 )
 
+//addresses the service entries of the clusters on the same flat network point at
+const (
+	FlatNetworkEndpointsService = "service"
+	FlatNetworkEndpointsPod     = "pod"
+)
+
+//modes of the workload sidecar updates
+const (
+	WorkloadSidecarUpdateEnabled = "enabled"
+	WorkloadSidecarUpdateManaged = "managed"
+)
+
+//workloads the sidecars written in the managed mode apply to
+const (
+	WorkloadSidecarScopeNamespace = "namespace"
+	WorkloadSidecarScopeWorkload  = "workload"
+)
+
+//backends for the allocation of service entry addresses
+const (
+	SeAddressAllocatorConfigMap = "configmap"
+	SeAddressAllocatorCrd       = "crd"
+)
+
 type Event int
 
 const (
@@ -132,11 +178,27 @@ func GetSAN(domain string, deployment *k8sAppsV1.Deployment, identifier string)
 	}
 }
 
+//Returns the region of the node, the topology label takes precedence over the deprecated failure-domain label
 func GetNodeLocality(node *k8sV1.Node) string {
-	region := node.Labels[NodeRegionLabel]
+	region := node.Labels[NodeTopologyRegionLabel]
+	if len(region) == 0 {
+		region = node.Labels[NodeRegionLabel]
+	}
 	return region
 }
 
+func GetNodeZone(node *k8sV1.Node) string {
+	zone := node.Labels[NodeTopologyZoneLabel]
+	if len(zone) == 0 {
+		zone = node.Labels[NodeZoneLabel]
+	}
+	return zone
+}
+
+func GetNodeSubzone(node *k8sV1.Node) string {
+	return node.Labels[NodeSubzoneLabel]
+}
+
 func GetValueForKeyFromDeployment(key string, deployment *k8sAppsV1.Deployment) string {
 	value := deployment.Spec.Template.Labels[key]
 	if len(value) == 0 {
@@ -153,6 +153,12 @@ func TestNodeLocality(t *testing.T) {
 			node:     k8sCoreV1.Node{Spec: k8sCoreV1.NodeSpec{}, ObjectMeta: v1.ObjectMeta{Labels: map[string]string{NodeRegionLabel: nodeLocalityLabel}}},
 			expected: nodeLocalityLabel,
 		},
+		{
+			name: "should prefer the topology region label over the deprecated one",
+			node: k8sCoreV1.Node{Spec: k8sCoreV1.NodeSpec{}, ObjectMeta: v1.ObjectMeta{Labels: map[string]string{NodeRegionLabel: "us-east-2",
+				NodeTopologyRegionLabel: nodeLocalityLabel}}},
+			expected: nodeLocalityLabel,
+		},
 		{
 			name:     "should return empty value when node annotation isn't present",
 			node:     k8sCoreV1.Node{Spec: k8sCoreV1.NodeSpec{}, ObjectMeta: v1.ObjectMeta{Annotations: map[string]string{}}},
@@ -170,6 +176,42 @@ func TestNodeLocality(t *testing.T) {
 	}
 }
 
+func TestNodeZoneAndSubzone(t *testing.T) {
+
+	testCases := []struct {
+		name            string
+		node            k8sCoreV1.Node
+		expectedZone    string
+		expectedSubzone string
+	}{
+		{
+			name:         "should return the zone from the deprecated label",
+			node:         k8sCoreV1.Node{ObjectMeta: v1.ObjectMeta{Labels: map[string]string{NodeZoneLabel: "us-west-2a"}}},
+			expectedZone: "us-west-2a",
+		},
+		{
+			name: "should prefer the topology zone label and return the subzone",
+			node: k8sCoreV1.Node{ObjectMeta: v1.ObjectMeta{Labels: map[string]string{NodeZoneLabel: "us-west-2a",
+				NodeTopologyZoneLabel: "us-west-2b", NodeSubzoneLabel: "rack1"}}},
+			expectedZone:    "us-west-2b",
+			expectedSubzone: "rack1",
+		},
+		{
+			name: "should return empty values when labels aren't present",
+			node: k8sCoreV1.Node{ObjectMeta: v1.ObjectMeta{Labels: map[string]string{}}},
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			zone, subzone := GetNodeZone(&c.node), GetNodeSubzone(&c.node)
+			if zone != c.expectedZone || subzone != c.expectedSubzone {
+				t.Errorf("Wanted zone: %s subzone: %s, got: %s %s", c.expectedZone, c.expectedSubzone, zone, subzone)
+			}
+		})
+	}
+}
+
 func TestGetDeploymentGlobalIdentifier(t *testing.T) {
 
 	identifier := "identity"
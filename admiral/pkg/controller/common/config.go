@@ -2,6 +2,7 @@ package common
 
 import (
 	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/labels"
 	"sync"
 	"time"
 )
@@ -32,6 +33,10 @@ func GetArgoRolloutsEnabled() bool {
 	return admiralParams.ArgoRolloutsEnabled
 }
 
+func GetFlaggerEnabled() bool {
+	return admiralParams.FlaggerEnabled
+}
+
 func GetKubeconfigPath() string {
 	return admiralParams.KubeconfigPath
 }
@@ -72,6 +77,18 @@ func GetHostnameSuffix() string {
 	return admiralParams.HostnameSuffix
 }
 
+//GetPreviewHostnamePrefix returns the prefix of the hosts of the preview services of the blue green rollouts
+func GetPreviewHostnamePrefix() string {
+	if len(admiralParams.PreviewHostnamePrefix) == 0 {
+		return BlueGreenRolloutPreviewPrefix
+	}
+	return admiralParams.PreviewHostnamePrefix
+}
+
+func GetCanaryHostnamePrefix() string {
+	return admiralParams.CanaryHostnamePrefix
+}
+
 func GetWorkloadIdentifier() string {
 	return admiralParams.LabelSet.WorkloadIdentityKey
 }
@@ -88,10 +105,118 @@ func GetWorkloadSidecarName() string {
 	return admiralParams.WorkloadSidecarName
 }
 
+//GetWorkloadSidecarScope returns whether the sidecars written in the managed mode apply to a namespace or to a workload
+func GetWorkloadSidecarScope() string {
+	if admiralParams.WorkloadSidecarScope == WorkloadSidecarScopeWorkload {
+		return WorkloadSidecarScopeWorkload
+	}
+	return WorkloadSidecarScopeNamespace
+}
+
+func GetWorkloadSidecarEgressHosts() []string {
+	return admiralParams.WorkloadSidecarEgressHosts
+}
+
 func GetEnvKey() string {
 	return admiralParams.LabelSet.EnvKey
 }
 
+func GetClusterLocality(clusterId string) string {
+	return admiralParams.ClusterLocality[clusterId]
+}
+
+func GetSeAddressReclaimInterval() time.Duration {
+	return admiralParams.SeAddressReclaimInterval
+}
+
+func GetSeAddressReclaimGracePeriod() time.Duration {
+	return admiralParams.SeAddressReclaimGracePeriod
+}
+
+func GetSeAddressAllocator() string {
+	return admiralParams.SeAddressAllocator
+}
+
+func GetOrphanCollectionInterval() time.Duration {
+	return admiralParams.OrphanCollectionInterval
+}
+
+func GetOrphanCollectionDryRun() bool {
+	return admiralParams.OrphanCollectionDryRun
+}
+
+func GetReconcileInterval() time.Duration {
+	return admiralParams.ReconcileInterval
+}
+
+func GetReconcileQPS() float32 {
+	return admiralParams.ReconcileQPS
+}
+
+func GetDryRun() bool {
+	return admiralParams.DryRun
+}
+
+func GetIdentityQueueWorkers() int {
+	return admiralParams.IdentityQueueWorkers
+}
+
+func GetIdentityQueueQPS() float32 {
+	return admiralParams.IdentityQueueQPS
+}
+
+func GetClusterWriteParallelism() int {
+	return admiralParams.ClusterWriteParallelism
+}
+
+func GetClusterWriteTimeout() time.Duration {
+	return admiralParams.ClusterWriteTimeout
+}
+
+func GetReadinessCheckInterval() time.Duration {
+	return admiralParams.ReadinessCheckInterval
+}
+
+func GetClusterSyncTimeout() time.Duration {
+	return admiralParams.ClusterSyncTimeout
+}
+
+func GetSeEndpointRemovalThreshold() int {
+	return admiralParams.SeEndpointRemovalThreshold
+}
+
+func GetSeUpdateConfirmationDelay() time.Duration {
+	return admiralParams.SeUpdateConfirmationDelay
+}
+
+//GetGatewaySelector returns the selector of the east west gateway services, defaulting to the `app` label set to the gateway app
+func GetGatewaySelector() labels.Selector {
+	if admiralParams.GatewaySelector == "" {
+		var gatewayApp string
+		if admiralParams.LabelSet != nil {
+			gatewayApp = admiralParams.LabelSet.GatewayApp
+		}
+		return labels.SelectorFromSet(labels.Set{"app": gatewayApp})
+	}
+	selector, err := labels.Parse(admiralParams.GatewaySelector)
+	if err != nil {
+		log.Errorf("invalid gateway selector %v: %v", admiralParams.GatewaySelector, err)
+		return labels.Nothing()
+	}
+	return selector
+}
+
+func GetGatewayNamespaces() []string {
+	if len(admiralParams.GatewayNamespaces) == 0 {
+		return []string{NamespaceIstioSystem}
+	}
+	return admiralParams.GatewayNamespaces
+}
+
+func GetGatewayPortName() string {
+	return admiralParams.GatewayPortName
+}
+
 func GetMetricsEnabled() bool {
 	return admiralParams.MetricsEnabled
 }
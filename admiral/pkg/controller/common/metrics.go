@@ -6,18 +6,46 @@ import (
 )
 
 const (
-	ClustersMonitoredMetricName    = "clusters_monitored"
-	EventsProcessedTotalMetricName = "events_processed_total"
+	ClustersMonitoredMetricName     = "clusters_monitored"
+	EventsProcessedTotalMetricName  = "events_processed_total"
+	GtpConflictsTotalMetricName     = "global_traffic_policy_conflicts_total"
+	SeAddressPoolMetricName         = "service_entry_address_pool"
+	SeAddressesReclaimedMetricName  = "service_entry_addresses_reclaimed_total"
+	OrphansFoundMetricName          = "orphans_found"
+	ReconcileCorrectionsMetricName  = "reconcile_corrections_total"
+	IdentityQueueDepthMetricName    = "identity_queue_depth"
+	IdentityQueueLatencyMetricName  = "identity_queue_latency_seconds"
+	IdentitySyncDurationMetricName  = "identity_sync_duration_seconds"
+	ClusterWriteDurationMetricName  = "cluster_write_duration_seconds"
+	ClusterWriteErrorsMetricName    = "cluster_write_errors_total"
+	SeUpdatesBlockedMetricName      = "service_entry_updates_blocked"
+	SeUpdatesBlockedTotalMetricName = "service_entry_updates_blocked_total"
 
 	AddEventLabelValue    = "add"
 	UpdateEventLabelValue = "update"
 	DeleteEventLabelValue = "delete"
+
+	SeAddressUsedLabelValue   = "used"
+	SeAddressUnusedLabelValue = "unused"
+	SeAddressFreeLabelValue   = "free"
 )
 
 var (
-	metricsOnce          sync.Once
-	RemoteClustersMetric Gauge
-	EventsProcessed      Counter
+	metricsOnce                     sync.Once
+	RemoteClustersMetric            Gauge
+	EventsProcessed                 Counter
+	GtpConflicts                    Counter
+	SeAddressPool                   Gauge
+	SeAddressesReclaimed            Counter
+	OrphansFound                    Gauge
+	ReconcileCorrections            Counter
+	IdentityQueueDepth              Gauge
+	IdentityQueueLatency            Histogram
+	IdentitySyncDuration            Histogram
+	ClusterWriteDuration            Histogram
+	ClusterWriteErrors              Counter
+	ServiceEntryUpdatesBlocked      Gauge
+	ServiceEntryUpdatesBlockedTotal Counter
 )
 
 type Gauge interface {
@@ -30,6 +58,11 @@ type Counter interface {
 	Inc()
 }
 
+type Histogram interface {
+	With(labelValues ...string) Histogram
+	Observe(value float64)
+}
+
 /*
 InitializeMetrics depends on AdmiralParams for metrics enablement.
 */
@@ -37,6 +70,18 @@ func InitializeMetrics() {
 	metricsOnce.Do(func() {
 		RemoteClustersMetric = NewGaugeFrom(ClustersMonitoredMetricName, "Gauge for the clusters monitored by Admiral", []string{})
 		EventsProcessed = NewCounterFrom(EventsProcessedTotalMetricName, "Counter for the events processed by Admiral", []string{"cluster", "object_type", "event_type"})
+		GtpConflicts = NewCounterFrom(GtpConflictsTotalMetricName, "Counter for the global traffic policies shadowed by a conflicting policy", []string{"cluster", "identity", "env"})
+		SeAddressPool = NewGaugeFrom(SeAddressPoolMetricName, "Gauge for the service entry addresses by state (used, unused, free)", []string{"state"})
+		SeAddressesReclaimed = NewCounterFrom(SeAddressesReclaimedMetricName, "Counter for the service entry addresses released by the reclaimer", []string{})
+		OrphansFound = NewGaugeFrom(OrphansFoundMetricName, "Gauge for the objects owned by Admiral that it wouldn't generate anymore, found by the last orphan collection", []string{"cluster", "object_type"})
+		ReconcileCorrections = NewCounterFrom(ReconcileCorrectionsMetricName, "Counter for the objects created or updated by the reconciler because they drifted from the generated config", []string{"cluster", "object_type"})
+		IdentityQueueDepth = NewGaugeFrom(IdentityQueueDepthMetricName, "Gauge for the identity and env keys waiting to be synced", []string{})
+		IdentityQueueLatency = NewHistogramFrom(IdentityQueueLatencyMetricName, "Histogram for the time an identity and env key waits in the queue before being synced", []string{})
+		IdentitySyncDuration = NewHistogramFrom(IdentitySyncDurationMetricName, "Histogram for the time taken to sync the config of an identity and env", []string{})
+		ClusterWriteDuration = NewHistogramFrom(ClusterWriteDurationMetricName, "Histogram for the time taken to write the service entries and destination rules of a sync to a cluster", []string{"cluster"})
+		ClusterWriteErrors = NewCounterFrom(ClusterWriteErrorsMetricName, "Counter for the syncs that failed to write to a cluster, including the ones skipped while the cluster is backed off", []string{"cluster"})
+		ServiceEntryUpdatesBlocked = NewGaugeFrom(SeUpdatesBlockedMetricName, "Gauge for the service entry updates waiting to be confirmed because they remove too many endpoints", []string{"cluster"})
+		ServiceEntryUpdatesBlockedTotal = NewCounterFrom(SeUpdatesBlockedTotalMetricName, "Counter for the service entry updates blocked because they remove too many endpoints", []string{"cluster"})
 	})
 }
 
@@ -60,8 +105,19 @@ func NewCounterFrom(name string, help string, labelNames []string) Counter {
 	return &PromCounter{c, labelNames}
 }
 
+func NewHistogramFrom(name string, help string, labelNames []string) Histogram {
+	if !GetMetricsEnabled() {
+		return &NoopHistogram{}
+	}
+	opts := prometheus.HistogramOpts{Name: name, Help: help}
+	h := prometheus.NewHistogramVec(opts, labelNames)
+	prometheus.MustRegister(h)
+	return &PromHistogram{h, labelNames}
+}
+
 type NoopGauge struct{}
 type NoopCounter struct{}
+type NoopHistogram struct{}
 
 type PromGauge struct {
 	g   *prometheus.GaugeVec
@@ -73,10 +129,14 @@ type PromCounter struct {
 	lvs []string
 }
 
-func (g *PromGauge) With(labelValues ...string) Gauge {
-	g.lvs = append([]string{}, labelValues...)
+type PromHistogram struct {
+	h   *prometheus.HistogramVec
+	lvs []string
+}
 
-	return g
+//With returns a child with the label values, the metric is shared by concurrent callers so it isn't modified
+func (g *PromGauge) With(labelValues ...string) Gauge {
+	return &PromGauge{g.g, append([]string{}, labelValues...)}
 }
 
 func (g *PromGauge) Set(value float64) {
@@ -84,17 +144,26 @@ func (g *PromGauge) Set(value float64) {
 }
 
 func (c *PromCounter) With(labelValues ...string) Counter {
-	c.lvs = append([]string{}, labelValues...)
-
-	return c
+	return &PromCounter{c.c, append([]string{}, labelValues...)}
 }
 
 func (c *PromCounter) Inc() {
 	c.c.WithLabelValues(c.lvs...).Inc()
 }
 
+func (h *PromHistogram) With(labelValues ...string) Histogram {
+	return &PromHistogram{h.h, append([]string{}, labelValues...)}
+}
+
+func (h *PromHistogram) Observe(value float64) {
+	h.h.WithLabelValues(h.lvs...).Observe(value)
+}
+
 func (g *NoopGauge) Set(float64)          {}
 func (g *NoopGauge) With(...string) Gauge { return g }
 
 func (g *NoopCounter) Inc()                   {}
 func (g *NoopCounter) With(...string) Counter { return g }
+
+func (h *NoopHistogram) Observe(float64)          {}
+func (h *NoopHistogram) With(...string) Histogram { return h }
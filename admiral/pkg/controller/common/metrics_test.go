@@ -130,3 +130,95 @@ func TestNewCounterFrom(t *testing.T) {
 		})
 	}
 }
+
+func TestNewHistogramFrom(t *testing.T) {
+	type args struct {
+		prom        bool
+		name        string
+		help        string
+		values      []float64
+		labelNames  []string
+		labelValues []string
+	}
+	tc := []struct {
+		name       string
+		args       args
+		wantMetric bool
+		wantCount  int64
+	}{
+		{
+			name:       "Should return a Noop histogram",
+			args:       args{false, "myhistogram", "", []float64{0.1}, []string{}, []string{}},
+			wantMetric: false,
+		},
+		{
+			name:       "Should return a Prometheus histogram",
+			args:       args{true, "myhistogram", "", []float64{0.1, 2}, []string{"l1", "l2"}, []string{"v1", "v2"}},
+			wantMetric: true,
+			wantCount:  2,
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			SetEnablePrometheus(tt.args.prom)
+
+			// exercise metric
+			actual := NewHistogramFrom(tt.args.name, tt.args.help, tt.args.labelNames)
+			for _, value := range tt.args.values {
+				actual.With(tt.args.labelValues...).Observe(value)
+			}
+
+			// query metrics endpoint
+			s := httptest.NewServer(promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{}))
+			defer s.Close()
+
+			// parse response
+			resp, _ := http.Get(s.URL)
+			buf, _ := ioutil.ReadAll(resp.Body)
+			actualString := string(buf)
+
+			// verify
+			if tt.wantMetric {
+				pattern := tt.args.name + `_count{l1="v1",l2="v2"} ([0-9]+)`
+				re := regexp.MustCompile(pattern)
+				s2 := re.FindStringSubmatch(actualString)[1]
+				f, _ := strconv.ParseInt(s2, 0, 64)
+				assert.Equal(t, tt.wantCount, f)
+			}
+			assert.Equal(t, 200, resp.StatusCode)
+		})
+	}
+}
+
+func TestPromCounterWithConcurrently(t *testing.T) {
+	SetEnablePrometheus(true)
+	counter := NewCounterFrom("myconcurrentcounter", "", []string{"cluster"})
+
+	// exercise metric from concurrent callers with different labels
+	done := make(chan struct{})
+	for _, cluster := range []string{"c1", "c2"} {
+		go func(cluster string) {
+			for i := 0; i < 100; i++ {
+				counter.With(cluster).Inc()
+			}
+			done <- struct{}{}
+		}(cluster)
+	}
+	<-done
+	<-done
+
+	// query metrics endpoint
+	s := httptest.NewServer(promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{}))
+	defer s.Close()
+	resp, _ := http.Get(s.URL)
+	buf, _ := ioutil.ReadAll(resp.Body)
+
+	// verify
+	for _, cluster := range []string{"c1", "c2"} {
+		matches := regexp.MustCompile(`myconcurrentcounter{cluster="` + cluster + `"} ([0-9]+)`).FindStringSubmatch(string(buf))
+		assert.Equal(t, 2, len(matches))
+		f, _ := strconv.ParseInt(matches[1], 0, 64)
+		assert.Equal(t, int64(100), f)
+	}
+}
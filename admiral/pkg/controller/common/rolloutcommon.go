@@ -28,6 +28,36 @@ func GetCnameForRollout(rollout *argo.Rollout, identifier string, nameSuffix str
 	return strings.ToLower(cname)
 }
 
+// GetPreviewPrefixForRollout returns the prefix of the cname of the preview service of a blue green rollout, the admiral.io/preview-hostname-prefix
+// annotation on the pod spec overrides the global prefix
+func GetPreviewPrefixForRollout(rollout *argo.Rollout) string {
+	prefix := rollout.Spec.Template.Annotations[PreviewPrefixAnnotation]
+	if len(prefix) == 0 {
+		prefix = GetPreviewHostnamePrefix()
+	}
+	return strings.ToLower(prefix)
+}
+
+// GetPreviewCnameForRollout returns the cname of the preview service of a blue green rollout in the format <prefix>.<cname>, Ex: preview.stage.Admiral.services.registry.global
+func GetPreviewCnameForRollout(rollout *argo.Rollout, identifier string, nameSuffix string) string {
+	cname := GetCnameForRollout(rollout, identifier, nameSuffix)
+	if len(cname) == 0 {
+		return ""
+	}
+	return GetPreviewPrefixForRollout(rollout) + Sep + cname
+}
+
+// GetCanaryCnameForRollout returns the cname of the canary service of a canary rollout in the format <prefix>.<cname>, Ex: canary.stage.Admiral.services.registry.global
+// Returns an empty string if the canary hosts are disabled
+func GetCanaryCnameForRollout(rollout *argo.Rollout, identifier string, nameSuffix string) string {
+	prefix := GetCanaryHostnamePrefix()
+	cname := GetCnameForRollout(rollout, identifier, nameSuffix)
+	if len(prefix) == 0 || len(cname) == 0 {
+		return ""
+	}
+	return strings.ToLower(prefix) + Sep + cname
+}
+
 // GetSAN returns SAN for a service entry in the format spiffe://<domain>/<identifier>, Ex: spiffe://subdomain.domain.com/Admiral.platform.mesh.server
 func GetSANForRollout(domain string, rollout *argo.Rollout, identifier string) string {
 	identifierVal := GetValueForKeyFromRollout(identifier, rollout)
@@ -487,3 +487,47 @@ func TestGetCnameForRollout(t *testing.T) {
 		})
 	}
 }
+
+func TestGetPreviewCnameForRollout(t *testing.T) {
+
+	nameSuffix := "global"
+	identifier := "identity"
+	identifierVal := "COMPANY.platform.server"
+
+	testCases := []struct {
+		name     string
+		rollout  argo.Rollout
+		expected string
+	}{
+		{
+			name:     "should return the cname with the default preview prefix",
+			rollout:  argo.Rollout{Spec: argo.RolloutSpec{Template: corev1.PodTemplateSpec{ObjectMeta: v1.ObjectMeta{Labels: map[string]string{identifier: identifierVal, "env": "stage"}}}}},
+			expected: strings.ToLower("preview.stage." + identifierVal + ".global"),
+		},
+		{
+			name:     "should return the cname with the preview prefix of the rollout",
+			rollout:  argo.Rollout{Spec: argo.RolloutSpec{Template: corev1.PodTemplateSpec{ObjectMeta: v1.ObjectMeta{Annotations: map[string]string{PreviewPrefixAnnotation: "Blue"}, Labels: map[string]string{identifier: identifierVal, "env": "stage"}}}}},
+			expected: strings.ToLower("blue.stage." + identifierVal + ".global"),
+		},
+		{
+			name:     "should return empty string",
+			rollout:  argo.Rollout{Spec: argo.RolloutSpec{Template: corev1.PodTemplateSpec{ObjectMeta: v1.ObjectMeta{Labels: map[string]string{"env": "stage"}}}}},
+			expected: "",
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			cname := GetPreviewCnameForRollout(&c.rollout, identifier, nameSuffix)
+			if !(cname == c.expected) {
+				t.Errorf("Wanted Cname: %s, got: %s", c.expected, cname)
+			}
+		})
+	}
+
+	//the canary hosts are disabled without a prefix
+	rollout := argo.Rollout{Spec: argo.RolloutSpec{Template: corev1.PodTemplateSpec{ObjectMeta: v1.ObjectMeta{Labels: map[string]string{identifier: identifierVal, "env": "stage"}}}}}
+	if cname := GetCanaryCnameForRollout(&rollout, identifier, nameSuffix); cname != "" {
+		t.Errorf("Wanted no canary Cname, got: %s", cname)
+	}
+}
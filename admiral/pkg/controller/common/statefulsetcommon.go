@@ -0,0 +1,75 @@
+package common
+
+import (
+	log "github.com/sirupsen/logrus"
+	k8sAppsV1 "k8s.io/api/apps/v1"
+	"strings"
+)
+
+// GetCnameForStatefulSet returns cname in the format <env>.<service identity>.global, Ex: stage.Admiral.services.registry.global
+func GetCnameForStatefulSet(statefulSet *k8sAppsV1.StatefulSet, identifier string, nameSuffix string) string {
+	var environment = GetEnvForStatefulSet(statefulSet)
+	alias := GetValueForKeyFromStatefulSet(identifier, statefulSet)
+	if len(alias) == 0 {
+		log.Errorf("Unable to get cname for statefulset with name %v in namespace %v as it doesn't have the %v annotation", statefulSet.Name, statefulSet.Namespace, identifier)
+		return ""
+	}
+	cname := GetCnameVal([]string{environment, alias, nameSuffix})
+	if statefulSet.Spec.Template.Annotations[AdmiralCnameCaseSensitive] == "true" {
+		log.Infof("admiral.io/cname-case-sensitive annotation enabled on statefulset with name %v", statefulSet.Name)
+		return cname
+	}
+	return strings.ToLower(cname)
+}
+
+// GetSANForStatefulSet returns SAN for a service entry in the format spiffe://<domain>/<identifier>, Ex: spiffe://subdomain.domain.com/Admiral.platform.mesh.server
+func GetSANForStatefulSet(domain string, statefulSet *k8sAppsV1.StatefulSet, identifier string) string {
+	identifierVal := GetValueForKeyFromStatefulSet(identifier, statefulSet)
+	if len(identifierVal) == 0 {
+		log.Errorf("Unable to get SAN for statefulset with name %v in namespace %v as it doesn't have the %v annotation or label", statefulSet.Name, statefulSet.Namespace, identifier)
+		return ""
+	}
+	if len(domain) > 0 {
+		return SpiffePrefix + domain + Slash + identifierVal
+	} else {
+		return SpiffePrefix + identifierVal
+	}
+}
+
+func GetValueForKeyFromStatefulSet(key string, statefulSet *k8sAppsV1.StatefulSet) string {
+	value := statefulSet.Spec.Template.Labels[key]
+	if len(value) == 0 {
+		log.Warnf("%v label missing on statefulset %v in namespace %v. Falling back to annotation.", key, statefulSet.Name, statefulSet.Namespace)
+		value = statefulSet.Spec.Template.Annotations[key]
+	}
+	return value
+}
+
+func GetStatefulSetGlobalIdentifier(statefulSet *k8sAppsV1.StatefulSet) string {
+	identity := statefulSet.Spec.Template.Labels[GetWorkloadIdentifier()]
+	if len(identity) == 0 {
+		identity = statefulSet.Spec.Template.Annotations[GetWorkloadIdentifier()]
+	}
+	return identity
+}
+
+func GetEnvForStatefulSet(statefulSet *k8sAppsV1.StatefulSet) string {
+	var environment = statefulSet.Spec.Template.Annotations[GetEnvKey()]
+	if len(environment) == 0 {
+		environment = statefulSet.Spec.Template.Labels[GetEnvKey()]
+	}
+	if len(environment) == 0 {
+		environment = statefulSet.Spec.Template.Labels[Env]
+	}
+	if len(environment) == 0 {
+		splitNamespace := strings.Split(statefulSet.Namespace, Dash)
+		if len(splitNamespace) > 1 {
+			environment = splitNamespace[len(splitNamespace)-1]
+		}
+		log.Warnf("Using deprecated approach to deduce env from namespace for statefulset, name=%v in namespace=%v", statefulSet.Name, statefulSet.Namespace)
+	}
+	if len(environment) == 0 {
+		environment = Default
+	}
+	return environment
+}
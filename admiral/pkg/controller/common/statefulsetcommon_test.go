@@ -0,0 +1,92 @@
+package common
+
+import (
+	k8sAppsV1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+func TestGetEnvForStatefulSet(t *testing.T) {
+
+	testCases := []struct {
+		name        string
+		statefulSet k8sAppsV1.StatefulSet
+		expected    string
+	}{
+		{
+			name:        "should return default env",
+			statefulSet: k8sAppsV1.StatefulSet{Spec: k8sAppsV1.StatefulSetSpec{Template: corev1.PodTemplateSpec{ObjectMeta: v1.ObjectMeta{Labels: map[string]string{}}}}},
+			expected:    Default,
+		},
+		{
+			name:        "should return valid env from label",
+			statefulSet: k8sAppsV1.StatefulSet{Spec: k8sAppsV1.StatefulSetSpec{Template: corev1.PodTemplateSpec{ObjectMeta: v1.ObjectMeta{Labels: map[string]string{"env": "stage2"}}}}},
+			expected:    "stage2",
+		},
+		{
+			name:        "should return env from namespace suffix",
+			statefulSet: k8sAppsV1.StatefulSet{Spec: k8sAppsV1.StatefulSetSpec{Template: corev1.PodTemplateSpec{ObjectMeta: v1.ObjectMeta{Labels: map[string]string{}}}}, ObjectMeta: v1.ObjectMeta{Namespace: "uswest2-prd"}},
+			expected:    "prd",
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			env := GetEnvForStatefulSet(&c.statefulSet)
+			if env != c.expected {
+				t.Errorf("Wanted env: %s, got: %s", c.expected, env)
+			}
+		})
+	}
+}
+
+func TestGetCnameAndSANForStatefulSet(t *testing.T) {
+
+	identifier := "identity"
+	identifierVal := "COMPANY.platform.server"
+
+	statefulSet := k8sAppsV1.StatefulSet{Spec: k8sAppsV1.StatefulSetSpec{Template: corev1.PodTemplateSpec{
+		ObjectMeta: v1.ObjectMeta{Labels: map[string]string{identifier: identifierVal, "env": "stage"}}}}}
+	statefulSetWithAnnotation := k8sAppsV1.StatefulSet{Spec: k8sAppsV1.StatefulSetSpec{Template: corev1.PodTemplateSpec{
+		ObjectMeta: v1.ObjectMeta{Labels: map[string]string{"env": "stage"}, Annotations: map[string]string{identifier: identifierVal}}}}}
+	statefulSetWithoutIdentity := k8sAppsV1.StatefulSet{Spec: k8sAppsV1.StatefulSetSpec{Template: corev1.PodTemplateSpec{
+		ObjectMeta: v1.ObjectMeta{Labels: map[string]string{"env": "stage"}}}}}
+
+	testCases := []struct {
+		name          string
+		statefulSet   k8sAppsV1.StatefulSet
+		expectedCname string
+		expectedSAN   string
+	}{
+		{
+			name:          "should return cname and SAN from label",
+			statefulSet:   statefulSet,
+			expectedCname: "stage.company.platform.server.global",
+			expectedSAN:   "spiffe://prefix/" + identifierVal,
+		},
+		{
+			name:          "should return cname and SAN from annotation",
+			statefulSet:   statefulSetWithAnnotation,
+			expectedCname: "stage.company.platform.server.global",
+			expectedSAN:   "spiffe://prefix/" + identifierVal,
+		},
+		{
+			name:        "should return empty values without the identity",
+			statefulSet: statefulSetWithoutIdentity,
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			cname := GetCnameForStatefulSet(&c.statefulSet, identifier, "global")
+			if cname != c.expectedCname {
+				t.Errorf("Wanted cname: %s, got: %s", c.expectedCname, cname)
+			}
+			san := GetSANForStatefulSet("prefix", &c.statefulSet, identifier)
+			if san != c.expectedSAN {
+				t.Errorf("Wanted SAN: %s, got: %s", c.expectedSAN, san)
+			}
+		})
+	}
+}
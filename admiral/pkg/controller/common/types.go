@@ -2,6 +2,7 @@ package common
 
 import (
 	"fmt"
+	"reflect"
 	"sync"
 	"time"
 )
@@ -20,6 +21,7 @@ type SidecarEgress struct {
 	Namespace string
 	FQDN      string
 	CNAMEs    map[string]string
+	Identity  string //identity of the dependency, only set by PutDependency
 }
 
 //maintains a map from workload identity -> map[namespace]SidecarEgress
@@ -29,22 +31,46 @@ type SidecarEgressMap struct {
 }
 
 type AdmiralParams struct {
-	ArgoRolloutsEnabled        bool
-	KubeconfigPath             string
-	CacheRefreshDuration       time.Duration
-	ClusterRegistriesNamespace string
-	DependenciesNamespace      string
-	SyncNamespace              string
-	EnableSAN                  bool
-	SANPrefix                  string
-	SecretResolver             string
-	LabelSet                   *LabelSet
-	LogLevel                   int
-	HostnameSuffix             string
-	PreviewHostnamePrefix      string
-	MetricsEnabled             bool
-	WorkloadSidecarUpdate      string
-	WorkloadSidecarName        string
+	ArgoRolloutsEnabled         bool
+	FlaggerEnabled              bool //routes through the services Flagger generates for the targets of its canaries
+	KubeconfigPath              string
+	CacheRefreshDuration        time.Duration
+	ClusterRegistriesNamespace  string
+	DependenciesNamespace       string
+	SyncNamespace               string
+	EnableSAN                   bool
+	SANPrefix                   string
+	SecretResolver              string
+	LabelSet                    *LabelSet
+	LogLevel                    int
+	HostnameSuffix              string
+	PreviewHostnamePrefix       string //prefix of the hosts of the preview services of the blue green rollouts
+	CanaryHostnamePrefix        string //prefix of the hosts of the canary services of the canary rollouts, empty disables them
+	MetricsEnabled              bool
+	WorkloadSidecarUpdate       string
+	WorkloadSidecarName         string
+	WorkloadSidecarScope        string            //whether the sidecars written in the managed mode apply to a namespace or to a workload
+	WorkloadSidecarEgressHosts  []string          //egress hosts of the sidecars written in the managed mode on top of the dependencies
+	ClusterLocality             map[string]string //overrides the locality derived from the nodes of a cluster, cluster id -> region/zone/subzone
+	SeAddressReclaimInterval    time.Duration     //how often the addresses of deleted service entries are looked for, 0 disables the reclaimer
+	SeAddressReclaimGracePeriod time.Duration     //how long an address has to be unused before it is released
+	SeAddressAllocator          string            //where the service entry addresses are recorded, either configmap or crd
+	OrphanCollectionInterval    time.Duration     //how often the objects owned by Admiral are checked against the caches, 0 disables the collector
+	OrphanCollectionDryRun      bool              //only report the orphans instead of deleting them
+	ReconcileInterval           time.Duration     //how often the generated istio config is reconciled with the clusters, 0 disables the reconciler
+	ReconcileQPS                float32           //reconciliations per second allowed in each cluster
+	DryRun                      bool              //record the changes to the clusters instead of writing them
+	IdentityQueueWorkers        int               //workers syncing the identities queued by the events, 0 syncs them in the event handlers
+	IdentityQueueQPS            float32           //identity syncs per second allowed across the workers, 0 disables the rate limit
+	ClusterWriteParallelism     int               //clusters written to at the same time by a sync
+	ClusterWriteTimeout         time.Duration     //how long a sync waits for the writes to a cluster before backing it off, 0 waits until they are done
+	ReadinessCheckInterval      time.Duration     //how often the informers are checked for the sync of every identity once they have all synced, 0 disables it
+	ClusterSyncTimeout          time.Duration     //how long the readiness of Admiral waits for the controllers of a cluster to sync before leaving it out, 0 waits until they have
+	SeEndpointRemovalThreshold  int               //percentage of the endpoints of a service entry an update can remove without being confirmed
+	SeUpdateConfirmationDelay   time.Duration     //how long after being blocked an update has to be observed again to be applied, 0 disables the guard
+	GatewaySelector             string            //label selector of the east west gateway services, the services with the `app` label set to LabelSet.GatewayApp when empty
+	GatewayNamespaces           []string          //namespaces of the east west gateway services, istio-system when empty
+	GatewayPortName             string            //name of the port of the east west gateway services for the cross cluster traffic, the 15443 port is used when no port has the name
 }
 
 func (b AdmiralParams) String() string {
@@ -107,6 +133,12 @@ func (s *Map) Delete(key string) {
 	delete(s.cache, key)
 }
 
+func (s *Map) Len() int {
+	defer s.mutex.Unlock()
+	s.mutex.Lock()
+	return len(s.cache)
+}
+
 func (s *Map) Copy() map[string]string {
 	if s != nil {
 		defer s.mutex.Unlock()
@@ -153,6 +185,36 @@ func (s *MapOfMaps) Delete(key string) {
 	delete(s.cache, key)
 }
 
+// PutMap replaces the map stored under pkey with values in one step, readers never see it partially updated. The map is removed when
+// values is empty
+func (s *MapOfMaps) PutMap(pkey string, values map[string]string) {
+	mapVal := NewMap()
+	for key, value := range values {
+		mapVal.Put(key, value)
+	}
+	defer s.mutex.Unlock()
+	s.mutex.Lock()
+	if len(values) == 0 {
+		delete(s.cache, pkey)
+		return
+	}
+	s.cache[pkey] = mapVal
+}
+
+// DeleteMap removes key from the map stored under pkey, the map itself is removed once it is empty
+func (s *MapOfMaps) DeleteMap(pkey string, key string) {
+	defer s.mutex.Unlock()
+	s.mutex.Lock()
+	var mapVal = s.cache[pkey]
+	if mapVal == nil {
+		return
+	}
+	mapVal.Delete(key)
+	if mapVal.Len() == 0 {
+		delete(s.cache, pkey)
+	}
+}
+
 func (s *MapOfMaps) Map() map[string]*Map {
 	return s.cache
 }
@@ -176,6 +238,46 @@ func (s *SidecarEgressMap) Put(identity string, namespace string, fqdn string, c
 	s.cache[identity] = mapVal
 }
 
+//PutDependency records the egress of a dependency of the identity in an env, returns true if it changed
+func (s *SidecarEgressMap) PutDependency(identity string, dependency string, env string, namespace string, fqdn string, cnames map[string]string) bool {
+	defer s.mutex.Unlock()
+	s.mutex.Lock()
+	key := ConstructGtpKey(env, dependency)
+	egress := SidecarEgress{Namespace: namespace, FQDN: fqdn, CNAMEs: cnames, Identity: dependency}
+	if old, ok := s.cache[identity][key]; ok && reflect.DeepEqual(old, egress) {
+		return false
+	}
+	//the map is copied as the one returned by Get can be iterated while it's updated
+	mapVal := make(map[string]SidecarEgress, len(s.cache[identity])+1)
+	for k, v := range s.cache[identity] {
+		mapVal[k] = v
+	}
+	mapVal[key] = egress
+	s.cache[identity] = mapVal
+	return true
+}
+
+//DeleteDependency removes the egress of a dependency of the identity in an env, in every env if empty. Returns true if there was any
+func (s *SidecarEgressMap) DeleteDependency(identity string, dependency string, env string) bool {
+	defer s.mutex.Unlock()
+	s.mutex.Lock()
+	mapVal := make(map[string]SidecarEgress, len(s.cache[identity]))
+	for key, egress := range s.cache[identity] {
+		if egress.Identity != dependency || (len(env) > 0 && key != ConstructGtpKey(env, dependency)) {
+			mapVal[key] = egress
+		}
+	}
+	if len(mapVal) == len(s.cache[identity]) {
+		return false
+	}
+	if len(mapVal) == 0 {
+		delete(s.cache, identity)
+	} else {
+		s.cache[identity] = mapVal
+	}
+	return true
+}
+
 func (s *SidecarEgressMap) Get(key string) map[string]SidecarEgress {
 	defer s.mutex.Unlock()
 	s.mutex.Lock()
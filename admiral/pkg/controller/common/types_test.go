@@ -46,6 +46,19 @@ func TestMapOfMaps(t *testing.T) {
 	if map3 != nil {
 		t.Fail()
 	}
+
+	mapOfMaps.Put("pkey5", "dev.a.global1", "127.0.10.1")
+	mapOfMaps.Put("pkey5", "dev.a.global2", "127.0.10.2")
+	mapOfMaps.DeleteMap("pkey5", "dev.a.global1")
+	map4 := mapOfMaps.Get("pkey5")
+	if map4 == nil || map4.Len() != 1 || map4.Get("dev.a.global1") != "" {
+		t.Fail()
+	}
+
+	mapOfMaps.DeleteMap("pkey5", "dev.a.global2")
+	if mapOfMaps.Get("pkey5") != nil {
+		t.Fail()
+	}
 }
 
 func TestEgressMap(t *testing.T) {
@@ -82,6 +95,39 @@ func TestEgressMap(t *testing.T) {
 	}
 }
 
+func TestEgressMapDependencies(t *testing.T) {
+	egressMap := NewSidecarEgressMap()
+	paymentsCnames := map[string]string{"prod.payments.global": "1"}
+	if !egressMap.PutDependency("orders", "payments", "prod", "payments-prod", "payments.payments-prod.svc.cluster.local", paymentsCnames) {
+		t.Errorf("Expected a new dependency to be a change")
+	}
+	if egressMap.PutDependency("orders", "payments", "prod", "payments-prod", "payments.payments-prod.svc.cluster.local", paymentsCnames) {
+		t.Errorf("Expected the same dependency not to be a change")
+	}
+	//dependencies in the same namespace don't replace each other
+	egressMap.PutDependency("orders", "payments", "staging", "payments-prod", "payments-staging.payments-prod.svc.cluster.local", nil)
+	egressMap.PutDependency("orders", "users", "prod", "payments-prod", "users.payments-prod.svc.cluster.local", nil)
+	ordersEgress := egressMap.Get("orders")
+	if len(ordersEgress) != 3 {
+		t.Fatalf("Expected an egress per dependency and env, got %v", ordersEgress)
+	}
+
+	if !egressMap.DeleteDependency("orders", "payments", "staging") || len(egressMap.Get("orders")) != 2 {
+		t.Errorf("Expected the dependency to be removed in the env only, got %v", egressMap.Get("orders"))
+	}
+	if len(ordersEgress) != 3 {
+		t.Errorf("Expected the map returned before the removal not to change")
+	}
+	if egressMap.DeleteDependency("orders", "carts", "") {
+		t.Errorf("Expected no change for an unknown dependency")
+	}
+	egressMap.DeleteDependency("orders", "payments", "")
+	egressMap.DeleteDependency("orders", "users", "")
+	if egressMap.Get("orders") != nil {
+		t.Errorf("Expected the identity to be removed with its last dependency, got %v", egressMap.Get("orders"))
+	}
+}
+
 func TestAdmiralParams(t *testing.T) {
 	admiralParams := AdmiralParams{SANPrefix: "custom.san.prefix"}
 	admiralParamsStr := admiralParams.String()
@@ -142,6 +188,20 @@ func TestMapOfMapsRange(t *testing.T) {
 
 }
 
+func TestMapOfMapsPutMap(t *testing.T) {
+
+	mapOfMaps := NewMapOfMaps()
+	mapOfMaps.Put("pkey1", "us-west-2", "3")
+	mapOfMaps.Put("pkey1", "us-east-2", "1")
+
+	mapOfMaps.PutMap("pkey1", map[string]string{"us-west-2": "2", "eu-west-1": "1"})
+	assert.Equal(t, map[string]string{"us-west-2": "2", "eu-west-1": "1"}, mapOfMaps.Get("pkey1").Copy())
+
+	mapOfMaps.PutMap("pkey1", map[string]string{})
+	assert.Nil(t, mapOfMaps.Get("pkey1"))
+
+}
+
 func TestMapConcurrency(t *testing.T) {
 
 	m := NewMap()
@@ -29,6 +29,7 @@ type DestinationRuleController struct {
 	IstioClient            versioned.Interface
 	DestinationRuleHandler DestinationRuleHandler
 	informer               cache.SharedIndexInformer
+	controller             *admiral.Controller
 }
 
 func NewDestinationRuleController(clusterID string, stopCh <-chan struct{}, handler DestinationRuleHandler, config *rest.Config, resyncPeriod time.Duration) (*DestinationRuleController, error) {
@@ -48,11 +49,16 @@ func NewDestinationRuleController(clusterID string, stopCh <-chan struct{}, hand
 	drController.informer = informers.NewDestinationRuleInformer(ic, k8sV1.NamespaceAll, resyncPeriod, cache.Indexers{})
 
 	mcd := admiral.NewMonitoredDelegator(&drController, clusterID, "destinationrule")
-	admiral.NewController("destinationrule-ctrl-"+config.Host, stopCh, mcd, drController.informer)
+	drController.controller = admiral.NewController("destinationrule-ctrl-"+config.Host, stopCh, mcd, drController.informer)
 
 	return &drController, nil
 }
 
+//HasSynced returns true once all the existing destination rules have been listed by the informer and processed
+func (sec *DestinationRuleController) HasSynced() bool {
+	return sec.controller.HasSynced()
+}
+
 func (sec *DestinationRuleController) Added(ojb interface{}) {
 	dr := ojb.(*networking.DestinationRule)
 	sec.DestinationRuleHandler.Added(dr)
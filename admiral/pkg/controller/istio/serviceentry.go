@@ -29,6 +29,7 @@ type ServiceEntryController struct {
 	IstioClient         versioned.Interface
 	ServiceEntryHandler ServiceEntryHandler
 	informer            cache.SharedIndexInformer
+	controller          *admiral.Controller
 }
 
 func NewServiceEntryController(clusterID string, stopCh <-chan struct{}, handler ServiceEntryHandler, config *rest.Config, resyncPeriod time.Duration) (*ServiceEntryController, error) {
@@ -48,11 +49,16 @@ func NewServiceEntryController(clusterID string, stopCh <-chan struct{}, handler
 	seController.informer = informers.NewServiceEntryInformer(ic, k8sV1.NamespaceAll, resyncPeriod, cache.Indexers{})
 
 	mcd := admiral.NewMonitoredDelegator(&seController, clusterID, "serviceentry")
-	admiral.NewController("serviceentry-ctrl-"+config.Host, stopCh, mcd, seController.informer)
+	seController.controller = admiral.NewController("serviceentry-ctrl-"+config.Host, stopCh, mcd, seController.informer)
 
 	return &seController, nil
 }
 
+//HasSynced returns true once all the existing service entries have been listed by the informer and processed
+func (sec *ServiceEntryController) HasSynced() bool {
+	return sec.controller.HasSynced()
+}
+
 func (sec *ServiceEntryController) Added(ojb interface{}) {
 	se := ojb.(*networking.ServiceEntry)
 	sec.ServiceEntryHandler.Added(se)
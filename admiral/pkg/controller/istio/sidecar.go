@@ -2,6 +2,8 @@ package istio
 
 import (
 	"fmt"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/admiral"
@@ -28,13 +30,54 @@ type SidecarEntry struct {
 type SidecarController struct {
 	IstioClient    versioned.Interface
 	SidecarHandler SidecarHandler
+	Cache          *sidecarCache
 	informer       cache.SharedIndexInformer
+	controller     *admiral.Controller
+}
+
+type sidecarCache struct {
+	//map of sidecars key=name then namespace
+	cache map[string]map[string]*networking.Sidecar
+	mutex *sync.Mutex
+}
+
+//GetByName returns the sidecars with the name in every namespace, sorted by namespace
+func (s *sidecarCache) GetByName(name string) []*networking.Sidecar {
+	defer s.mutex.Unlock()
+	s.mutex.Lock()
+	sidecars := make([]*networking.Sidecar, 0, len(s.cache[name]))
+	for _, sidecar := range s.cache[name] {
+		sidecars = append(sidecars, sidecar)
+	}
+	sort.Slice(sidecars, func(i, j int) bool {
+		return sidecars[i].Namespace < sidecars[j].Namespace
+	})
+	return sidecars
+}
+
+func (s *sidecarCache) Put(sidecar *networking.Sidecar) {
+	defer s.mutex.Unlock()
+	s.mutex.Lock()
+	if s.cache[sidecar.Name] == nil {
+		s.cache[sidecar.Name] = make(map[string]*networking.Sidecar)
+	}
+	s.cache[sidecar.Name][sidecar.Namespace] = sidecar
+}
+
+func (s *sidecarCache) Delete(sidecar *networking.Sidecar) {
+	defer s.mutex.Unlock()
+	s.mutex.Lock()
+	delete(s.cache[sidecar.Name], sidecar.Namespace)
+	if len(s.cache[sidecar.Name]) == 0 {
+		delete(s.cache, sidecar.Name)
+	}
 }
 
 func NewSidecarController(clusterID string, stopCh <-chan struct{}, handler SidecarHandler, config *rest.Config, resyncPeriod time.Duration) (*SidecarController, error) {
 
 	sidecarController := SidecarController{}
 	sidecarController.SidecarHandler = handler
+	sidecarController.Cache = &sidecarCache{cache: make(map[string]map[string]*networking.Sidecar), mutex: &sync.Mutex{}}
 
 	var err error
 
@@ -48,23 +91,31 @@ func NewSidecarController(clusterID string, stopCh <-chan struct{}, handler Side
 	sidecarController.informer = informers.NewSidecarInformer(ic, k8sV1.NamespaceAll, resyncPeriod, cache.Indexers{})
 
 	mcd := admiral.NewMonitoredDelegator(&sidecarController, clusterID, "sidecar")
-	admiral.NewController("sidecar-ctrl-"+config.Host, stopCh, mcd, sidecarController.informer)
+	sidecarController.controller = admiral.NewController("sidecar-ctrl-"+config.Host, stopCh, mcd, sidecarController.informer)
 
 	return &sidecarController, nil
 }
 
+//HasSynced returns true once all the existing sidecars have been listed by the informer and processed
+func (sec *SidecarController) HasSynced() bool {
+	return sec.controller.HasSynced()
+}
+
 func (sec *SidecarController) Added(ojb interface{}) {
 	sidecar := ojb.(*networking.Sidecar)
+	sec.Cache.Put(sidecar)
 	sec.SidecarHandler.Added(sidecar)
 }
 
 func (sec *SidecarController) Updated(ojb interface{}, oldObj interface{}) {
 	sidecar := ojb.(*networking.Sidecar)
+	sec.Cache.Put(sidecar)
 	sec.SidecarHandler.Updated(sidecar)
 }
 
 func (sec *SidecarController) Deleted(ojb interface{}) {
 	sidecar := ojb.(*networking.Sidecar)
+	sec.Cache.Delete(sidecar)
 	sec.SidecarHandler.Deleted(sidecar)
 
 }
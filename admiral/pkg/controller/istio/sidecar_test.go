@@ -36,6 +36,11 @@ func TestNewSidecarController(t *testing.T) {
 	if !cmp.Equal(sc.Spec, handler.Obj.Spec) {
 		t.Errorf("Handler should have the added obj")
 	}
+	sc2 := &v1alpha3.Sidecar{Spec: v1alpha32.Sidecar{}, ObjectMeta: v1.ObjectMeta{Name: "sc1", Namespace: "namespace0"}}
+	sidecarController.Added(sc2)
+	if sidecars := sidecarController.Cache.GetByName("sc1"); len(sidecars) != 2 || sidecars[0] != sc2 || sidecars[1] != sc {
+		t.Errorf("Cache should have the added sidecars sorted by namespace, got %v", sidecars)
+	}
 
 	updatedSc := &v1alpha3.Sidecar{Spec: v1alpha32.Sidecar{WorkloadSelector: &v1alpha32.WorkloadSelector{Labels: map[string]string{"this": "that"}}}, ObjectMeta: v1.ObjectMeta{Name: "sc1", Namespace: "namespace1"}}
 	sidecarController.Updated(updatedSc, sc)
@@ -44,9 +49,17 @@ func TestNewSidecarController(t *testing.T) {
 		t.Errorf("Handler should have the updated obj")
 	}
 
+	if sidecars := sidecarController.Cache.GetByName("sc1"); len(sidecars) != 2 || sidecars[1] != updatedSc {
+		t.Errorf("Cache should have the updated sidecar, got %v", sidecars)
+	}
+
 	sidecarController.Deleted(sc)
 
 	if handler.Obj != nil {
 		t.Errorf("Handler should have no obj")
 	}
+	sidecarController.Deleted(sc2)
+	if sidecars := sidecarController.Cache.GetByName("sc1"); len(sidecars) != 0 {
+		t.Errorf("Cache should have no sidecars left, got %v", sidecars)
+	}
 }
@@ -24,6 +24,7 @@ type VirtualServiceController struct {
 	IstioClient           versioned.Interface
 	VirtualServiceHandler VirtualServiceHandler
 	informer              cache.SharedIndexInformer
+	controller            *admiral.Controller
 }
 
 func NewVirtualServiceController(clusterID string, stopCh <-chan struct{}, handler VirtualServiceHandler, config *rest.Config, resyncPeriod time.Duration) (*VirtualServiceController, error) {
@@ -43,11 +44,16 @@ func NewVirtualServiceController(clusterID string, stopCh <-chan struct{}, handl
 	drController.informer = informers.NewVirtualServiceInformer(ic, k8sV1.NamespaceAll, resyncPeriod, cache.Indexers{})
 
 	mcd := admiral.NewMonitoredDelegator(&drController, clusterID, "virtualservice")
-	admiral.NewController("virtualservice-ctrl-"+config.Host, stopCh, mcd, drController.informer)
+	drController.controller = admiral.NewController("virtualservice-ctrl-"+config.Host, stopCh, mcd, drController.informer)
 
 	return &drController, nil
 }
 
+//HasSynced returns true once all the existing virtual services have been listed by the informer and processed
+func (sec *VirtualServiceController) HasSynced() bool {
+	return sec.controller.HasSynced()
+}
+
 func (sec *VirtualServiceController) Added(ojb interface{}) {
 	dr := ojb.(*networking.VirtualService)
 	sec.VirtualServiceHandler.Added(dr)
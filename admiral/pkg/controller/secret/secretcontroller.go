@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/common"
 	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/secret/resolver"
+	"github.com/istio-ecosystem/admiral/admiral/pkg/controller/util"
 	log "github.com/sirupsen/logrus"
 	"k8s.io/client-go/rest"
 	"time"
@@ -45,11 +46,11 @@ const (
 // DO NOT USE - TEST ONLY.
 var LoadKubeConfig = clientcmd.Load
 
-// addSecretCallback prototype for the add secret callback function.
-type addSecretCallback func(config *rest.Config, dataKey string, resyncPeriod time.Duration) error
+// addSecretCallback prototype for the add secret callback function, called with the annotations of the secret.
+type addSecretCallback func(config *rest.Config, dataKey string, resyncPeriod time.Duration, annotations map[string]string) error
 
-// updateSecretCallback prototype for the update secret callback function.
-type updateSecretCallback func(config *rest.Config, dataKey string, resyncPeriod time.Duration) error
+// updateSecretCallback prototype for the update secret callback function, called with the annotations of the secret.
+type updateSecretCallback func(config *rest.Config, dataKey string, resyncPeriod time.Duration, annotations map[string]string) error
 
 // removeSecretCallback prototype for the remove secret callback function.
 type removeSecretCallback func(dataKey string) error
@@ -65,6 +66,8 @@ type Controller struct {
 	updateCallback updateSecretCallback
 	removeCallback removeSecretCallback
 	secretResolver resolver.SecretResolver
+	//the cluster secrets listed at startup whose clusters haven't been added yet
+	initialSync *util.InitialSync
 }
 
 // RemoteCluster defines cluster structZZ
@@ -135,6 +138,7 @@ func NewController(
 		updateCallback: updateCallback,
 		removeCallback: removeCallback,
 		secretResolver: secretResolver,
+		initialSync:    &util.InitialSync{},
 	}
 
 	log.Info("Setting up event handlers")
@@ -181,9 +185,16 @@ func (c *Controller) Run(stopCh <-chan struct{}) {
 	}
 
 	log.Info("secret informer caches synced")
+	//the workers aren't started yet, so none of the listed clusters has been added
+	c.initialSync.Listed(c.informer.GetStore().ListKeys())
 	wait.Until(c.runWorker, 5*time.Second, stopCh)
 }
 
+// HasSynced returns true once the clusters of all the existing cluster secrets have been added, or failed to be
+func (c *Controller) HasSynced() bool {
+	return c.initialSync.HasSynced()
+}
+
 // StartSecretController creates the secret controller.
 func StartSecretController(
 	k8s kubernetes.Interface,
@@ -223,12 +234,16 @@ func (c *Controller) processNextItem() bool {
 	} else if c.queue.NumRequeues(secretName) < maxRetries {
 		log.Errorf("Error processing %s (will retry): %v", secretName, err)
 		c.queue.AddRateLimited(secretName)
+		//the secret is processed again, it isn't done yet
+		return true
 	} else {
 		log.Errorf("Error processing %s (giving up): %v", secretName, err)
 		c.queue.Forget(secretName)
 		utilruntime.HandleError(err)
 	}
 
+	c.initialSync.Processed(secretName.(string))
+
 	return true
 }
 
@@ -302,7 +317,7 @@ func (c *Controller) addMemberCluster(secretName string, s *corev1.Secret) {
 
 			c.Cs.RemoteClusters[clusterID] = remoteCluster
 
-			if err := c.addCallback(restConfig, clusterID, common.GetAdmiralParams().CacheRefreshDuration); err != nil {
+			if err := c.addCallback(restConfig, clusterID, common.GetAdmiralParams().CacheRefreshDuration, s.Annotations); err != nil {
 				log.Errorf("error during secret loading for clusterID: %s %v", clusterID, err)
 				continue
 			}
@@ -326,7 +341,7 @@ func (c *Controller) addMemberCluster(secretName string, s *corev1.Secret) {
 			}
 
 			c.Cs.RemoteClusters[clusterID] = remoteCluster
-			if err := c.updateCallback(restConfig, clusterID, common.GetAdmiralParams().CacheRefreshDuration); err != nil {
+			if err := c.updateCallback(restConfig, clusterID, common.GetAdmiralParams().CacheRefreshDuration, s.Annotations); err != nil {
 				log.Errorf("Error updating cluster_id from secret=%v: %s %v",
 					clusterID, secretName, err)
 			}
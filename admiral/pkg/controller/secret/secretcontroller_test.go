@@ -62,14 +62,14 @@ var (
 	deleted string
 )
 
-func addCallback(config *rest.Config, id string, resyncPeriod time.Duration) error {
+func addCallback(config *rest.Config, id string, resyncPeriod time.Duration, annotations map[string]string) error {
 	mu.Lock()
 	defer mu.Unlock()
 	added = id
 	return nil
 }
 
-func updateCallback(config *rest.Config, id string, resyncPeriod time.Duration) error {
+func updateCallback(config *rest.Config, id string, resyncPeriod time.Duration, annotations map[string]string) error {
 	mu.Lock()
 	defer mu.Unlock()
 	updated = id
@@ -89,7 +89,7 @@ func resetCallbackData() {
 	deleted = ""
 }
 
-func testCreateController(clientConfig *rest.Config, clusterID string, resyncPeriod time.Duration) error {
+func testCreateController(clientConfig *rest.Config, clusterID string, resyncPeriod time.Duration, annotations map[string]string) error {
 	testCreateControllerCalled = true
 	return nil
 }
@@ -295,3 +295,23 @@ func Test_SecretController(t *testing.T) {
 		})
 	}
 }
+
+func Test_SecretControllerHasSynced(t *testing.T) {
+	g := NewWithT(t)
+
+	LoadKubeConfig = mockLoadKubeConfig
+	resetCallbackData()
+
+	clientset := fake.NewSimpleClientset(makeSecret("s2", "c2", []byte("kubeconfig2-0")))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	controller, err := StartSecretController(clientset, addCallback, updateCallback, deleteCallback, secretNameSpace, ctx, "")
+	g.Expect(err).Should(BeNil())
+
+	//the controller is only synced once the clusters of the existing secrets have been added
+	g.Eventually(controller.HasSynced, 10*time.Second).Should(BeTrue())
+	mu.Lock()
+	defer mu.Unlock()
+	g.Expect(added).Should(Equal("c2"))
+}
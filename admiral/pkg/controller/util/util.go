@@ -3,6 +3,7 @@ package util
 import (
 	log "github.com/sirupsen/logrus"
 	"reflect"
+	"sync"
 	"time"
 )
 
@@ -49,3 +50,39 @@ func LogElapsedTime(op, identity, env, clusterId string) func() {
 func LogElapsedTimeSince(op, identity, env, clusterId string, start time.Time) {
 	log.Infof("op=%s identity=%s env=%s cluster=%s time=%v\n", op, identity, env, clusterId, time.Since(start).Milliseconds())
 }
+
+//InitialSync tracks the processing of the objects an informer listed at startup. The caches the handlers fill are only complete once the
+//queue of the controller has drained them, not when the informer has synced
+type InitialSync struct {
+	mutex   sync.Mutex
+	listed  bool
+	pending map[string]bool
+}
+
+//Listed records the keys of the objects listed by the informer, it must be called before they are processed
+func (s *InitialSync) Listed(keys []string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.pending = make(map[string]bool, len(keys))
+	for _, key := range keys {
+		s.pending[key] = true
+	}
+	s.listed = true
+}
+
+//Processed records that the events of the object with the key have been handled
+func (s *InitialSync) Processed(key string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.pending, key)
+}
+
+//HasSynced returns true once all the listed objects have been processed
+func (s *InitialSync) HasSynced() bool {
+	if s == nil {
+		return false
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.listed && len(s.pending) == 0
+}
@@ -128,3 +128,24 @@ func TestContains(t *testing.T) {
 		})
 	}
 }
+
+func TestInitialSync(t *testing.T) {
+	var nilSync *InitialSync
+	if nilSync.HasSynced() {
+		t.Errorf("Expected a missing tracker not to be synced")
+	}
+	initialSync := &InitialSync{}
+	if initialSync.HasSynced() {
+		t.Errorf("Expected the tracker not to be synced before the objects are listed")
+	}
+	initialSync.Listed([]string{"ns/a", "ns/b"})
+	initialSync.Processed("ns/a")
+	initialSync.Processed("ns/c")
+	if initialSync.HasSynced() {
+		t.Errorf("Expected the tracker not to be synced while a listed object is pending")
+	}
+	initialSync.Processed("ns/b")
+	if !initialSync.HasSynced() {
+		t.Errorf("Expected the tracker to be synced once the listed objects are processed")
+	}
+}
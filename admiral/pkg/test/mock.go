@@ -3,6 +3,7 @@ package test
 import (
 	argo "github.com/argoproj/argo-rollouts/pkg/apis/rollouts/v1alpha1"
 	"github.com/istio-ecosystem/admiral/admiral/pkg/apis/admiral/v1"
+	flagger "github.com/istio-ecosystem/admiral/admiral/pkg/apis/flagger/v1beta1"
 	v1alpha32 "istio.io/client-go/pkg/apis/networking/v1alpha3"
 	k8sAppsV1 "k8s.io/api/apps/v1"
 	k8sCoreV1 "k8s.io/api/core/v1"
@@ -36,19 +37,52 @@ func (m *MockDeploymentHandler) Deleted(obj *k8sAppsV1.Deployment) {
 
 }
 
+type MockStatefulSetHandler struct {
+	Obj *k8sAppsV1.StatefulSet
+}
+
+func (m *MockStatefulSetHandler) Added(obj *k8sAppsV1.StatefulSet) {
+	m.Obj = obj
+}
+
+func (m *MockStatefulSetHandler) Deleted(obj *k8sAppsV1.StatefulSet) {
+	m.Obj = nil
+}
+
 type MockRolloutHandler struct {
+	Obj     *argo.Rollout
+	Updates int
 }
 
 func (m *MockRolloutHandler) Added(obj *argo.Rollout) {
-
+	m.Obj = obj
 }
 
 func (m *MockRolloutHandler) Deleted(obj *argo.Rollout) {
-
+	m.Obj = nil
 }
 
 func (m *MockRolloutHandler) Updated(obj *argo.Rollout) {
+	m.Obj = obj
+	m.Updates++
+}
 
+type MockFlaggerHandler struct {
+	Obj     *flagger.Canary
+	Updates int
+}
+
+func (m *MockFlaggerHandler) Added(obj *flagger.Canary) {
+	m.Obj = obj
+}
+
+func (m *MockFlaggerHandler) Deleted(obj *flagger.Canary) {
+	m.Obj = nil
+}
+
+func (m *MockFlaggerHandler) Updated(obj *flagger.Canary) {
+	m.Obj = obj
+	m.Updates++
 }
 
 type MockServiceHandler struct {
@@ -78,7 +112,8 @@ func (m MockPodHandler) Deleted(obj *k8sCoreV1.Pod) {
 }
 
 type MockNodeHandler struct {
-	Obj *k8sCoreV1.Node
+	Obj        *k8sCoreV1.Node
+	Localities map[string]int
 }
 
 func (m *MockNodeHandler) Added(obj *k8sCoreV1.Node) {
@@ -89,7 +124,22 @@ func (m *MockNodeHandler) Deleted(obj *k8sCoreV1.Node) {
 	m.Obj = nil
 }
 
+func (m *MockNodeHandler) LocalitiesUpdated(localities map[string]int) {
+	m.Localities = localities
+}
+
+type MockEndpointsHandler struct {
+	Obj     *k8sCoreV1.Endpoints
+	Updates int
+}
+
+func (m *MockEndpointsHandler) Updated(obj *k8sCoreV1.Endpoints) {
+	m.Obj = obj
+	m.Updates++
+}
+
 type MockDependencyHandler struct {
+	Updates int
 }
 
 func (m *MockDependencyHandler) Added(obj *v1.Dependency) {
@@ -97,7 +147,7 @@ func (m *MockDependencyHandler) Added(obj *v1.Dependency) {
 }
 
 func (m *MockDependencyHandler) Updated(obj *v1.Dependency) {
-
+	m.Updates++
 }
 
 func (m *MockDependencyHandler) Deleted(obj *v1.Dependency) {